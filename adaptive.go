@@ -0,0 +1,182 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// adaptiveMinDelay and adaptiveMaxDelay bound the delay between two sends once
+// the concurrency has been reduced to its minimum. They are variables so tests
+// can use short delays.
+var (
+	adaptiveMinDelay = 100 * time.Millisecond
+	adaptiveMaxDelay = 30 * time.Second
+)
+
+// MinWorkers is a queue setting enabling adaptive concurrency: when the server
+// replies that there are too many connections or emails, with a 421 or 450
+// reply code, the number of emails sent concurrently is halved, down to n, and
+// the workers above the limit close their connection. Once at n, the sends are
+// also spaced by a growing delay. After each round of successful sends, the
+// delay is reduced and then the concurrency is increased by one, up to the
+// number of Workers. This avoids tuning the number of workers for each
+// provider.
+func MinWorkers(n int) QueueSetting {
+	return func(q *Queue) {
+		q.minWorkers = n
+	}
+}
+
+// isThrottled reports whether err is the reply of a server refusing more
+// connections or emails for now.
+func isThrottled(err error) bool {
+	var code int
+	var msg string
+	var perr *textproto.Error
+	var serr *SendError
+	if errors.As(err, &perr) {
+		code, msg = perr.Code, perr.Msg
+	} else if errors.As(err, &serr) && len(serr.Rejected) > 0 {
+		code, msg = serr.Rejected[0].Code, serr.Rejected[0].Message
+	}
+
+	switch code {
+	case 421:
+		// Idle timeouts are not throttling.
+		return !isExpired(err)
+	case 450, 451:
+		msg = strings.ToLower(msg)
+		for _, s := range []string{"too many", "rate limit", "throttl", "slow down"} {
+			if strings.Contains(msg, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// adaptiveLimit limits the number of concurrent sends with an additive
+// increase, multiplicative decrease algorithm driven by the throttling replies
+// of the server.
+type adaptiveLimit struct {
+	mu       sync.Mutex
+	min, max int
+	limit    int
+	active   int
+	// delay is the minimum delay between the start of two sends and next the
+	// earliest start of the next send.
+	delay time.Duration
+	next  time.Time
+	// round is the number of sends succeeded since the last change.
+	round int
+	// epoch is incremented on each decrease so the sends started before it
+	// do not decrease the limit again.
+	epoch int
+	// changed is closed and replaced when a send ends.
+	changed chan struct{}
+}
+
+func newAdaptiveLimit(min, max int) *adaptiveLimit {
+	if max < 1 {
+		max = 1
+	}
+	if min < 1 {
+		min = 1
+	} else if min > max {
+		min = max
+	}
+	return &adaptiveLimit{min: min, max: max, limit: max, changed: make(chan struct{})}
+}
+
+// acquire waits until a send can start and returns the current epoch, to be
+// passed to release. If the limit is reached, idle is called before waiting so
+// the worker can close its connection.
+func (a *adaptiveLimit) acquire(ctx context.Context, idle func()) (int, error) {
+	a.mu.Lock()
+	for a.active >= a.limit {
+		ch := a.changed
+		a.mu.Unlock()
+		idle()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		a.mu.Lock()
+	}
+	a.active++
+	epoch := a.epoch
+	var wait time.Duration
+	if a.delay > 0 {
+		t := time.Now()
+		if a.next.After(t) {
+			wait = a.next.Sub(t)
+			a.next = a.next.Add(a.delay)
+		} else {
+			a.next = t.Add(a.delay)
+		}
+	}
+	a.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			a.release(epoch, ctx.Err())
+			return 0, ctx.Err()
+		}
+	}
+	return epoch, nil
+}
+
+// release ends a send started in the given epoch and adjusts the limit
+// according to its error.
+func (a *adaptiveLimit) release(epoch int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active--
+	switch {
+	case err == nil:
+		a.round++
+		if a.round < a.limit {
+			break
+		}
+		a.round = 0
+		if a.delay > 0 {
+			a.delay /= 2
+			if a.delay < adaptiveMinDelay {
+				a.delay = 0
+			}
+		} else if a.limit < a.max {
+			a.limit++
+		}
+	case epoch == a.epoch && isThrottled(err):
+		a.epoch++
+		a.round = 0
+		if a.limit > a.min {
+			a.limit /= 2
+			if a.limit < a.min {
+				a.limit = a.min
+			}
+		} else if a.delay == 0 {
+			a.delay = adaptiveMinDelay
+		} else if a.delay *= 2; a.delay > adaptiveMaxDelay {
+			a.delay = adaptiveMaxDelay
+		}
+	}
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// concurrency returns the current limit.
+func (a *adaptiveLimit) concurrency() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit
+}
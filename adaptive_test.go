@@ -0,0 +1,144 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestIsThrottled(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&textproto.Error{Code: 421, Msg: "4.7.0 Too many connections"}, true},
+		{&textproto.Error{Code: 421, Msg: "4.4.2 Idle timeout"}, false},
+		{&textproto.Error{Code: 450, Msg: "4.7.1 Too many messages, slow down"}, true},
+		{&textproto.Error{Code: 451, Msg: "4.7.1 Rate limit exceeded"}, true},
+		{&textproto.Error{Code: 450, Msg: "4.2.0 Greylisted"}, false},
+		{&textproto.Error{Code: 550, Msg: "5.1.1 No such user"}, false},
+		{&SendError{Rejected: []*RecipientError{{Code: 450, Message: "4.7.1 Too many recipients per hour"}}}, true},
+		{errors.New("connection reset"), false},
+	}
+	for _, test := range tests {
+		if got := isThrottled(test.err); got != test.want {
+			t.Errorf("isThrottled(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	defer func(d time.Duration) { adaptiveMinDelay = d }(adaptiveMinDelay)
+	adaptiveMinDelay = time.Millisecond
+	throttled := &textproto.Error{Code: 421, Msg: "Too many connections"}
+
+	a := newAdaptiveLimit(1, 4)
+	send := func(err error) {
+		epoch, aerr := a.acquire(context.Background(), func() {})
+		if aerr != nil {
+			t.Fatal(aerr)
+		}
+		a.release(epoch, err)
+	}
+
+	send(throttled)
+	send(throttled)
+	if a.limit != 1 || a.delay != 0 {
+		t.Fatalf("Invalid limit, got %d and delay %v, want 1 and 0", a.limit, a.delay)
+	}
+	send(throttled)
+	send(throttled)
+	if a.limit != 1 || a.delay != 2*time.Millisecond {
+		t.Fatalf("Invalid limit, got %d and delay %v, want 1 and 2ms", a.limit, a.delay)
+	}
+
+	send(nil)
+	send(nil)
+	if a.limit != 1 || a.delay != 0 {
+		t.Errorf("The delay should be removed first, got limit %d and delay %v", a.limit, a.delay)
+	}
+	for i := 0; i < 1+2+3; i++ {
+		send(nil)
+	}
+	if a.limit != 4 {
+		t.Errorf("Invalid limit, got %d, want 4", a.limit)
+	}
+	send(nil)
+	if a.limit != 4 {
+		t.Errorf("The limit should not exceed the maximum, got %d", a.limit)
+	}
+}
+
+func TestAdaptiveLimitEpoch(t *testing.T) {
+	a := newAdaptiveLimit(1, 8)
+	var epochs []int
+	for i := 0; i < 3; i++ {
+		epoch, err := a.acquire(context.Background(), func() {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		epochs = append(epochs, epoch)
+	}
+	// The concurrent sends are throttled together: the limit is halved once.
+	for _, epoch := range epochs {
+		a.release(epoch, &textproto.Error{Code: 421, Msg: "Too many connections"})
+	}
+	if a.limit != 4 {
+		t.Errorf("Invalid limit, got %d, want 4", a.limit)
+	}
+}
+
+func TestAdaptiveLimitWait(t *testing.T) {
+	a := newAdaptiveLimit(1, 1)
+	epoch, err := a.acquire(context.Background(), func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idle := false
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := a.acquire(ctx, func() { idle = true })
+		done <- err
+	}()
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Invalid error, got %v, want %v", err, context.Canceled)
+	}
+	if !idle {
+		t.Error("The idle function should be called before waiting")
+	}
+	a.release(epoch, nil)
+}
+
+func TestQueueMinWorkers(t *testing.T) {
+	d := &fakeDialer{
+		fail: func(n int) error {
+			if n == 1 {
+				return &textproto.Error{Code: 421, Msg: "4.7.0 Too many connections"}
+			}
+			return nil
+		},
+	}
+	q := NewQueue(d, Workers(4), MinWorkers(1), Retry(&RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	}))
+	if got := q.Stats().Concurrency; got != 4 {
+		t.Errorf("Invalid concurrency, got %d, want 4", got)
+	}
+	q.Enqueue(testQueueMessage(testTo1))
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.sent) != 1 {
+		t.Errorf("Invalid emails sent, got %v", d.sent)
+	}
+	if got := q.Stats().Concurrency; got != 2 {
+		t.Errorf("Invalid concurrency, got %d, want 2", got)
+	}
+}
@@ -0,0 +1,130 @@
+package gomail
+
+import "strings"
+
+// PlusAddress returns the plus-addressed variant of addr with the given tag,
+// for example PlusAddress("bob@example.com", "news") returns
+// "bob+news@example.com".
+func PlusAddress(addr, tag string) string {
+	local, domain := splitAddress(addr)
+	if domain == "" {
+		return addr
+	}
+	return local + "+" + tag + "@" + domain
+}
+
+// ParsePlusAddress splits a plus-addressed address into the base address and
+// the tag. For example "bob+news@example.com" returns "bob@example.com" and
+// "news". If addr has no tag, it is returned unchanged with an empty tag.
+func ParsePlusAddress(addr string) (base, tag string) {
+	local, domain := splitAddress(addr)
+	if domain == "" {
+		return addr, ""
+	}
+	i := strings.IndexByte(local, '+')
+	if i == -1 {
+		return addr, ""
+	}
+	return local[:i] + "@" + domain, local[i+1:]
+}
+
+// VERPAddress returns the variable envelope return path (VERP) encoding rcpt
+// in the bounce address, for example "bounces+bob=example.org@example.com" for
+// the bounce address "bounces@example.com" and the recipient
+// "bob@example.org". Used as the envelope sender of an email sent to rcpt
+// only, the bounces of the email are sent to the bounce address and identify
+// the recipient even if they do not report it.
+func VERPAddress(bounce, rcpt string) string {
+	local, domain := splitAddress(bounce)
+	return local + "+" + strings.Replace(rcpt, "@", "=", 1) + "@" + domain
+}
+
+// ParseVERPAddress returns the recipient encoded in a VERP address generated
+// by VERPAddress with the given bounce address, for example the To field of a
+// bounce. It reports false if addr is not such an address.
+func ParseVERPAddress(bounce, addr string) (string, bool) {
+	base, tag := ParsePlusAddress(addr)
+	if !strings.EqualFold(base, bounce) {
+		return "", false
+	}
+	i := strings.LastIndexByte(tag, '=')
+	if i <= 0 || i == len(tag)-1 {
+		return "", false
+	}
+	return tag[:i] + "@" + tag[i+1:], true
+}
+
+type addressRule struct {
+	// domain is the canonical domain of the provider.
+	domain string
+	// separator is the character separating the tag in the local part, 0
+	// if the provider does not support subaddressing.
+	separator byte
+	// ignoreDots is true when the dots in the local part are ignored.
+	ignoreDots bool
+}
+
+var (
+	gmailRule   = addressRule{domain: "gmail.com", separator: '+', ignoreDots: true}
+	outlookRule = addressRule{separator: '+'}
+	yahooRule   = addressRule{separator: '-'}
+	icloudRule  = addressRule{separator: '+'}
+
+	addressRules = map[string]addressRule{
+		"gmail.com":      gmailRule,
+		"googlemail.com": gmailRule,
+		"outlook.com":    outlookRule,
+		"hotmail.com":    outlookRule,
+		"live.com":       outlookRule,
+		"yahoo.com":      yahooRule,
+		"icloud.com":     icloudRule,
+		"me.com":         icloudRule,
+		"mac.com":        icloudRule,
+		"fastmail.com":   {separator: '+'},
+		"protonmail.com": {separator: '+'},
+		"proton.me":      {separator: '+'},
+	}
+)
+
+// CanonicalAddress returns a canonical form of addr that can be used to detect
+// duplicate addresses, for example in suppression lists.
+//
+// The domain is always lowercased. For well-known providers, the local part is
+// lowercased and the provider rules are applied: subaddress tags are removed
+// and, for Gmail, dots are ignored and googlemail.com is replaced by
+// gmail.com. The local part of other domains is left unchanged since it may be
+// case-sensitive.
+func CanonicalAddress(addr string) string {
+	local, domain := splitAddress(strings.TrimSpace(addr))
+	if domain == "" {
+		return addr
+	}
+	domain = strings.ToLower(domain)
+
+	rule, ok := addressRules[domain]
+	if !ok {
+		return local + "@" + domain
+	}
+
+	local = strings.ToLower(local)
+	if rule.separator != 0 {
+		if i := strings.IndexByte(local, rule.separator); i > 0 {
+			local = local[:i]
+		}
+	}
+	if rule.ignoreDots {
+		local = strings.Replace(local, ".", "", -1)
+	}
+	if rule.domain != "" {
+		domain = rule.domain
+	}
+	return local + "@" + domain
+}
+
+func splitAddress(addr string) (local, domain string) {
+	i := strings.LastIndexByte(addr, '@')
+	if i == -1 {
+		return addr, ""
+	}
+	return addr[:i], addr[i+1:]
+}
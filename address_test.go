@@ -0,0 +1,69 @@
+package gomail
+
+import "testing"
+
+func TestPlusAddress(t *testing.T) {
+	if got := PlusAddress("bob@example.com", "news"); got != "bob+news@example.com" {
+		t.Errorf("Invalid address, got %q", got)
+	}
+
+	tests := []struct {
+		addr, base, tag string
+	}{
+		{"bob+news@example.com", "bob@example.com", "news"},
+		{"bob+news+2@example.com", "bob@example.com", "news+2"},
+		{"bob@example.com", "bob@example.com", ""},
+		{"invalid", "invalid", ""},
+	}
+	for _, test := range tests {
+		base, tag := ParsePlusAddress(test.addr)
+		if base != test.base || tag != test.tag {
+			t.Errorf("ParsePlusAddress(%q) = %q, %q, want %q, %q", test.addr, base, tag, test.base, test.tag)
+		}
+	}
+}
+
+func TestVERPAddress(t *testing.T) {
+	addr := VERPAddress("bounces@example.com", "bob+news@example.org")
+	if want := "bounces+bob+news=example.org@example.com"; addr != want {
+		t.Errorf("Invalid address, got %q, want %q", addr, want)
+	}
+
+	tests := []struct {
+		addr, rcpt string
+		ok         bool
+	}{
+		{addr, "bob+news@example.org", true},
+		{"Bounces+bob=example.org@EXAMPLE.com", "bob@example.org", true},
+		{"bounces@example.com", "", false},
+		{"bounces+bob@example.com", "", false},
+		{"bounces+bob=@example.com", "", false},
+		{"other+bob=example.org@example.com", "", false},
+	}
+	for _, test := range tests {
+		rcpt, ok := ParseVERPAddress("bounces@example.com", test.addr)
+		if rcpt != test.rcpt || ok != test.ok {
+			t.Errorf("ParseVERPAddress(%q) = %q, %v, want %q, %v", test.addr, rcpt, ok, test.rcpt, test.ok)
+		}
+	}
+}
+
+func TestCanonicalAddress(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{"Bob@Example.COM", "Bob@example.com"},
+		{"bob+news@example.com", "bob+news@example.com"},
+		{"B.o.b+news@GoogleMail.com", "bob@gmail.com"},
+		{"bob.smith@gmail.com", "bobsmith@gmail.com"},
+		{"Bob+tag@outlook.com", "bob@outlook.com"},
+		{"bob-tag@yahoo.com", "bob@yahoo.com"},
+		{"bob.smith@yahoo.com", "bob.smith@yahoo.com"},
+		{"invalid", "invalid"},
+	}
+	for _, test := range tests {
+		if got := CanonicalAddress(test.addr); got != test.want {
+			t.Errorf("CanonicalAddress(%q) = %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package gomail
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// doAPIRequest sends a request to the HTTP API of an email provider and
+// returns an *APIError if the response is not successful.
+func doAPIRequest(client *http.Client, provider string, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{
+			Provider:   provider,
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(body)),
+		}
+	}
+	return nil
+}
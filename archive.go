@@ -0,0 +1,305 @@
+package gomail
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An Archive keeps a compressed copy of the emails sent through it in a
+// directory, indexed by Message-ID and by recipient so they can be retrieved
+// later. It implements SendCloser and SendDialer like Maildir, so it can for
+// example be used as the Sender of a DKIMSender or with a Queue.
+//
+// The emails are compressed with gzip. Each email is stored in its own file in
+// the msg subdirectory and the index is an append-only file loaded when the
+// archive is opened. With the Indexed setting, the emails can also be searched
+// by subject, body, recipient and date.
+type Archive struct {
+	dir string
+
+	index ArchiveIndex
+
+	mu          sync.Mutex
+	all         []*ArchivedEmail
+	byID        map[string]*ArchivedEmail
+	byMessageID map[string][]*ArchivedEmail
+	byRecipient map[string][]*ArchivedEmail
+}
+
+// An ArchiveSetting can be used as an argument in NewArchive to configure an
+// archive.
+type ArchiveSetting func(a *Archive)
+
+// An ArchivedEmail describes an email kept in an Archive.
+type ArchivedEmail struct {
+	// ID identifies the email in the archive.
+	ID string `json:"id"`
+	// MessageID is the value of the Message-ID field without the angle
+	// brackets, if any.
+	MessageID string `json:"message_id,omitempty"`
+	// From and To are the envelope sender and recipients.
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	// Time is the time at which the email was archived.
+	Time time.Time `json:"time"`
+	// Size is the size of the email before compression.
+	Size int64 `json:"size"`
+}
+
+const archiveIndex = "index.jsonl"
+
+// NewArchive opens the archive stored in dir, creating it if needed.
+func NewArchive(dir string, settings ...ArchiveSetting) (*Archive, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "msg"), 0700); err != nil {
+		return nil, err
+	}
+	a := &Archive{
+		dir:         dir,
+		byID:        make(map[string]*ArchivedEmail),
+		byMessageID: make(map[string][]*ArchivedEmail),
+		byRecipient: make(map[string][]*ArchivedEmail),
+	}
+	for _, s := range settings {
+		s(a)
+	}
+	if err := a.loadIndex(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Archive) loadIndex() error {
+	f, err := os.Open(filepath.Join(a.dir, archiveIndex))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) == 0 {
+				return nil
+			}
+			// An incomplete last line is an entry whose write was
+			// interrupted, it is removed so the next entries can be
+			// appended.
+			return os.Truncate(f.Name(), offset)
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+		e := new(ArchivedEmail)
+		if err := json.Unmarshal(line, e); err != nil {
+			return fmt.Errorf("gomail: invalid archive index: %w", err)
+		}
+		a.add(e)
+	}
+}
+
+func (a *Archive) add(e *ArchivedEmail) {
+	a.all = append(a.all, e)
+	a.byID[e.ID] = e
+	if e.MessageID != "" {
+		a.byMessageID[e.MessageID] = append(a.byMessageID[e.MessageID], e)
+	}
+	seen := make(map[string]bool, len(e.To))
+	for _, addr := range e.To {
+		key := strings.ToLower(addr)
+		if !seen[key] {
+			seen[key] = true
+			a.byRecipient[key] = append(a.byRecipient[key], e)
+		}
+	}
+}
+
+// Dial implements SendDialer.
+func (a *Archive) Dial() (SendCloser, error) {
+	return a, nil
+}
+
+// Send implements Sender. It archives msg and indexes it.
+func (a *Archive) Send(from string, to []string, msg io.WriterTo) error {
+	id, err := newStoreID()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(a.dir, "msg", id+".tmp")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	h := new(headerCapture)
+	n, err := writeCompressed(f, h, msg)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, a.path(id))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("gomail: could not archive the email: %w", err)
+	}
+
+	e := &ArchivedEmail{
+		ID:        id,
+		MessageID: h.messageID(),
+		From:      from,
+		To:        append([]string(nil), to...),
+		Time:      now(),
+		Size:      n,
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := a.append(e, line); err != nil {
+		return fmt.Errorf("gomail: could not index the archived email: %w", err)
+	}
+	if a.index != nil {
+		return a.indexEmail(e)
+	}
+	return nil
+}
+
+func (a *Archive) append(e *ArchivedEmail, line []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(filepath.Join(a.dir, archiveIndex), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		a.add(e)
+	}
+	return err
+}
+
+// writeCompressed writes msg compressed to f and uncompressed to h. It returns
+// the uncompressed size.
+func writeCompressed(f *os.File, h io.Writer, msg io.WriterTo) (int64, error) {
+	zw := gzip.NewWriter(f)
+	cw := new(countingWriter)
+	if _, err := msg.WriteTo(io.MultiWriter(zw, h, cw)); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	return cw.n, f.Sync()
+}
+
+// Close implements SendCloser.
+func (a *Archive) Close() error {
+	return nil
+}
+
+// ByMessageID returns the archived emails with the given Message-ID, with or
+// without angle brackets, oldest first. An email is archived on each Send call,
+// so an email sent in several batches has several entries.
+func (a *Archive) ByMessageID(id string) []*ArchivedEmail {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*ArchivedEmail(nil), a.byMessageID[trimMessageID(id)]...)
+}
+
+// ByRecipient returns the archived emails sent to the given address, oldest
+// first. Addresses are compared case-insensitively.
+func (a *Archive) ByRecipient(address string) []*ArchivedEmail {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*ArchivedEmail(nil), a.byRecipient[strings.ToLower(address)]...)
+}
+
+// Open returns a reader streaming the decompressed email archived with the
+// given ID. The caller must close it.
+func (a *Archive) Open(id string) (io.ReadCloser, error) {
+	if strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("gomail: invalid archive ID %q", id)
+	}
+	f, err := os.Open(a.path(id))
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("gomail: invalid archived email %q: %w", id, err)
+	}
+	return &archiveReader{zr, f}, nil
+}
+
+type archiveReader struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (r *archiveReader) Close() error {
+	err := r.Reader.Close()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (a *Archive) path(id string) string {
+	return filepath.Join(a.dir, "msg", id+".eml.gz")
+}
+
+func trimMessageID(id string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(id), "<"), ">")
+}
+
+// maxCapturedHeader is the maximum size of the header kept by headerCapture.
+const maxCapturedHeader = 64 << 10
+
+// headerCapture keeps the header of the message written to it.
+type headerCapture struct {
+	buf  bytes.Buffer
+	done bool
+}
+
+func (w *headerCapture) Write(p []byte) (int, error) {
+	if w.done {
+		return len(p), nil
+	}
+	w.buf.Write(p)
+	b := w.buf.Bytes()
+	if i := bytes.Index(b, []byte("\r\n\r\n")); i >= 0 {
+		w.buf.Truncate(i + 4)
+		w.done = true
+	} else if w.buf.Len() > maxCapturedHeader {
+		w.buf.Truncate(maxCapturedHeader)
+		w.done = true
+	}
+	return len(p), nil
+}
+
+func (w *headerCapture) messageID() string {
+	// The header read before an error is still returned.
+	h, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(w.buf.Bytes()))).ReadMIMEHeader()
+	return trimMessageID(h.Get("Message-ID"))
+}
@@ -0,0 +1,96 @@
+package gomail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := NewArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := getTestMessage()
+	m.SetHeader("Message-ID", "<42@example.com>")
+	m.SetBody("text/plain", strings.Repeat("Lorem ipsum dolor sit amet. ", 1000))
+	if err := Send(a, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Send("from@example.com", []string{"TO@example.org"}, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	list := a.ByMessageID("42@example.com")
+	if len(list) != 1 {
+		t.Fatalf("Invalid emails for the Message-ID, got %v", list)
+	}
+	e := list[0]
+	if e.From != testFrom || len(e.To) != 2 || e.To[1] != testTo2 || e.MessageID != "42@example.com" {
+		t.Errorf("Invalid archived email, got %+v", e)
+	}
+
+	fi, err := os.Stat(a.path(e.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() >= e.Size/4 {
+		t.Errorf("The email should be compressed, got %d bytes for %d", fi.Size(), e.Size)
+	}
+
+	r, err := a.Open(e.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(b)) != e.Size || !strings.Contains(string(b), "Message-ID: <42@example.com>\r\n") {
+		t.Errorf("Invalid archived content, got %d bytes", len(b))
+	}
+
+	if got := a.ByRecipient("to@example.org"); len(got) != 1 || got[0].MessageID == "" {
+		t.Errorf("Invalid emails for the recipient, got %v", got)
+	}
+
+	// The index is loaded when the archive is opened again and an
+	// interrupted entry is ignored.
+	f, err := os.OpenFile(filepath.Join(dir, archiveIndex), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"trunc`)
+	f.Close()
+
+	a, err = NewArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Send(testFrom, []string{testTo2}, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	a, err = NewArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.ByMessageID("<42@example.com>"); len(got) != 1 || got[0].ID != e.ID {
+		t.Errorf("Invalid emails after reopening, got %v", got)
+	}
+	if got := a.ByRecipient(testTo2); len(got) != 2 {
+		t.Errorf("Invalid emails after reopening, got %v", got)
+	}
+
+	if _, err := a.Open("../index.jsonl"); err == nil {
+		t.Error("Open should reject invalid IDs")
+	}
+}
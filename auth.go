@@ -24,7 +24,7 @@ func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
 			}
 		}
 		if !advertised {
-			return "", nil, errors.New("gomail: unencrypted connection")
+			return "", nil, ErrTLSRequired
 		}
 	}
 	if server.Name != a.host {
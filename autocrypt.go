@@ -0,0 +1,115 @@
+package gomail
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// An Autocrypt is the content of an Autocrypt header field, which clients
+// adopting the Autocrypt specification use to exchange the OpenPGP keys needed
+// to encrypt their emails. See https://autocrypt.org/level1.html.
+type Autocrypt struct {
+	// Addr is the address the key belongs to. It must be the address of the
+	// From field of the email.
+	Addr string
+	// PreferEncrypt defines whether the owner of the key prefers to receive
+	// encrypted emails, the "mutual" preference of the specification.
+	PreferEncrypt bool
+	// KeyData is the OpenPGP public key in binary format.
+	KeyData []byte
+}
+
+// String returns the value of the Autocrypt header field. The key data is
+// split by spaces so the field can be folded.
+func (a *Autocrypt) String() string {
+	var sb strings.Builder
+	sb.WriteString("addr=")
+	sb.WriteString(a.Addr)
+	if a.PreferEncrypt {
+		sb.WriteString("; prefer-encrypt=mutual")
+	}
+	sb.WriteString("; keydata=")
+	key := base64.StdEncoding.EncodeToString(a.KeyData)
+	for len(key) > 64 {
+		sb.WriteString(key[:64])
+		sb.WriteByte(' ')
+		key = key[64:]
+	}
+	sb.WriteString(key)
+	return sb.String()
+}
+
+// SetAutocrypt sets the Autocrypt header field of the message.
+func (m *Message) SetAutocrypt(a *Autocrypt) {
+	m.header["Autocrypt"] = []string{a.String()}
+}
+
+// ParseAutocrypt parses the value of an Autocrypt header field. It returns an
+// error if the addr or keydata attribute is missing or if an unknown attribute
+// is critical, that is, not starting with an underscore.
+func ParseAutocrypt(value string) (*Autocrypt, error) {
+	a := new(Autocrypt)
+	seen := make(map[string]bool)
+	for _, attr := range strings.Split(value, ";") {
+		attr = strings.TrimSpace(attr)
+		if attr == "" {
+			continue
+		}
+		i := strings.IndexByte(attr, '=')
+		if i == -1 {
+			return nil, fmt.Errorf("gomail: invalid Autocrypt attribute %q", attr)
+		}
+		name, v := strings.TrimSpace(attr[:i]), strings.TrimSpace(attr[i+1:])
+		if seen[name] {
+			return nil, fmt.Errorf("gomail: duplicate Autocrypt attribute %q", name)
+		}
+		seen[name] = true
+
+		switch {
+		case name == "addr":
+			a.Addr = v
+		case name == "prefer-encrypt":
+			a.PreferEncrypt = v == "mutual"
+		case name == "keydata":
+			key, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(v), ""))
+			if err != nil {
+				return nil, fmt.Errorf("gomail: invalid Autocrypt key data: %w", err)
+			}
+			a.KeyData = key
+		case strings.HasPrefix(name, "_"):
+		default:
+			return nil, fmt.Errorf("gomail: unknown critical Autocrypt attribute %q", name)
+		}
+	}
+	if a.Addr == "" || len(a.KeyData) == 0 {
+		return nil, errors.New("gomail: the Autocrypt addr and keydata attributes are required")
+	}
+	return a, nil
+}
+
+// FindAutocrypt returns the Autocrypt header field of a received email, if
+// any. As required by the specification, the fields that cannot be parsed or
+// whose address is not the address of the From field are ignored, and none is
+// returned if several fields remain.
+func FindAutocrypt(h mail.Header) (*Autocrypt, bool) {
+	from, err := h.AddressList("From")
+	if err != nil || len(from) != 1 {
+		return nil, false
+	}
+
+	var found *Autocrypt
+	for _, v := range h["Autocrypt"] {
+		a, err := ParseAutocrypt(v)
+		if err != nil || !strings.EqualFold(a.Addr, from[0].Address) {
+			continue
+		}
+		if found != nil {
+			return nil, false
+		}
+		found = a
+	}
+	return found, found != nil
+}
@@ -0,0 +1,90 @@
+package gomail
+
+import (
+	"bytes"
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAutocrypt(t *testing.T) {
+	a := &Autocrypt{
+		Addr:          testFrom,
+		PreferEncrypt: true,
+		KeyData:       bytes.Repeat([]byte{0x99, 0x01, 0x0d, 0x04}, 100),
+	}
+
+	m := getTestMessage()
+	m.SetAutocrypt(a)
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > 78 {
+			t.Errorf("The line %q is too long", line)
+		}
+	}
+
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := FindAutocrypt(msg.Header)
+	if !ok {
+		t.Fatal("The Autocrypt field should be found")
+	}
+	if !reflect.DeepEqual(got, a) {
+		t.Errorf("Invalid Autocrypt, got %+v, want %+v", got, a)
+	}
+}
+
+func TestParseAutocrypt(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *Autocrypt
+	}{
+		{"addr=bob@example.com; keydata=AQID", &Autocrypt{Addr: "bob@example.com", KeyData: []byte{1, 2, 3}}},
+		{"addr=bob@example.com; prefer-encrypt=mutual; _extra=1; keydata=AQ ID", &Autocrypt{Addr: "bob@example.com", PreferEncrypt: true, KeyData: []byte{1, 2, 3}}},
+		{"addr=bob@example.com; prefer-encrypt=nopreference; keydata=AQID", &Autocrypt{Addr: "bob@example.com", KeyData: []byte{1, 2, 3}}},
+		{"addr=bob@example.com; extra=1; keydata=AQID", nil},
+		{"addr=bob@example.com", nil},
+		{"keydata=AQID", nil},
+		{"addr=bob@example.com; keydata=!!", nil},
+		{"addr=bob@example.com; addr=eve@example.com; keydata=AQID", nil},
+	}
+	for _, test := range tests {
+		got, err := ParseAutocrypt(test.value)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("ParseAutocrypt(%q) should fail", test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAutocrypt(%q): %v", test.value, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseAutocrypt(%q) = %+v, want %+v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestFindAutocrypt(t *testing.T) {
+	valid := "addr=bob@example.com; keydata=AQID"
+	tests := []struct {
+		header mail.Header
+		ok     bool
+	}{
+		{mail.Header{"From": {"Bob <BOB@example.com>"}, "Autocrypt": {valid}}, true},
+		{mail.Header{"From": {"eve@example.com"}, "Autocrypt": {valid}}, false},
+		{mail.Header{"From": {"bob@example.com"}, "Autocrypt": {valid, valid}}, false},
+		{mail.Header{"From": {"bob@example.com"}, "Autocrypt": {"addr=bob@example.com", valid}}, true},
+		{mail.Header{"Autocrypt": {valid}}, false},
+	}
+	for i, test := range tests {
+		if _, ok := FindAutocrypt(test.header); ok != test.ok {
+			t.Errorf("#%d: FindAutocrypt() = %v, want %v", i, ok, test.ok)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrQueueFull is returned by Queue.Enqueue when the queue is full and the
+// NonBlocking setting is used.
+var ErrQueueFull = errors.New("gomail: queue is full")
+
+// MaxPending is a queue setting to limit the number of emails enqueued and not
+// sent yet, including the scheduled ones. When the limit is reached, Enqueue
+// blocks until an email has been sent, or returns ErrQueueFull if the
+// NonBlocking setting is used. There is no limit by default.
+func MaxPending(n int) QueueSetting {
+	return func(q *Queue) {
+		q.maxPending = n
+	}
+}
+
+// MaxPendingBytes is a queue setting to limit the total size in bytes of the
+// emails enqueued and not sent yet, so the memory or disk space used by the
+// queue stays bounded during an SMTP outage. It behaves like MaxPending. An
+// email larger than the limit is only accepted when the queue is empty.
+//
+// The size of an email enqueued with EnqueueEnvelope is only known if the queue
+// has a Store, it is zero otherwise.
+func MaxPendingBytes(n int64) QueueSetting {
+	return func(q *Queue) {
+		q.maxPendingBytes = n
+	}
+}
+
+// NonBlocking is a queue setting making Enqueue return ErrQueueFull instead of
+// blocking when the queue is full.
+func NonBlocking() QueueSetting {
+	return func(q *Queue) {
+		q.nonBlocking = true
+	}
+}
+
+// QueueStats are statistics about a queue.
+type QueueStats struct {
+	// Pending is the number of emails enqueued and not sent yet, including
+	// the scheduled ones.
+	Pending int
+	// PendingBytes is the total size of the pending emails.
+	PendingBytes int64
+	// Scheduled is the number of emails waiting for their send time.
+	Scheduled int
+	// Wait is the total time spent by Enqueue waiting for the queue to have
+	// room for an email.
+	Wait time.Duration
+	// Rejected is the number of emails rejected with ErrQueueFull.
+	Rejected int
+	// Concurrency is the number of emails that can be sent concurrently. It
+	// is lower than the number of workers while the MinWorkers setting
+	// reduces it.
+	Concurrency int
+}
+
+// Stats returns statistics about the queue. They can be used to monitor its
+// depth.
+func (q *Queue) Stats() QueueStats {
+	q.limitMu.Lock()
+	stats := q.stats
+	q.limitMu.Unlock()
+
+	q.schedMu.Lock()
+	stats.Scheduled = len(q.sched)
+	q.schedMu.Unlock()
+
+	stats.Concurrency = q.workers
+	if q.adaptive != nil {
+		stats.Concurrency = q.adaptive.concurrency()
+	}
+	return stats
+}
+
+// acquire reserves room in the queue for an email of the given size. It
+// blocks while the queue is full unless block is false.
+func (q *Queue) acquire(size int64, block bool) error {
+	q.limitMu.Lock()
+	defer q.limitMu.Unlock()
+
+	var start time.Time
+	for q.full(size) {
+		if q.limitClosed {
+			return ErrQueueClosed
+		}
+		if !block {
+			q.stats.Rejected++
+			return ErrQueueFull
+		}
+		if start.IsZero() {
+			start = time.Now()
+		}
+		q.limitCond.Wait()
+	}
+	if !start.IsZero() {
+		q.stats.Wait += time.Since(start)
+	}
+
+	q.stats.Pending++
+	q.stats.PendingBytes += size
+	return nil
+}
+
+// release frees the room taken by an email once it has left the queue.
+func (q *Queue) release(item *queueItem) {
+	q.limitMu.Lock()
+	q.stats.Pending--
+	q.stats.PendingBytes -= item.size
+	q.limitMu.Unlock()
+	q.limitCond.Broadcast()
+}
+
+func (q *Queue) full(size int64) bool {
+	if q.stats.Pending == 0 {
+		return false
+	}
+	if q.maxPending > 0 && q.stats.Pending >= q.maxPending {
+		return true
+	}
+	return q.maxPendingBytes > 0 && q.stats.PendingBytes+size > q.maxPendingBytes
+}
+
+// countingWriterTo records the number of bytes written by an io.WriterTo.
+type countingWriterTo struct {
+	io.WriterTo
+	n int64
+}
+
+func (w *countingWriterTo) WriteTo(dst io.Writer) (int64, error) {
+	n, err := w.WriterTo.WriteTo(dst)
+	w.n = n
+	return n, err
+}
@@ -0,0 +1,122 @@
+package gomail
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// blockingDialer opens connections sending emails once release is closed.
+type blockingDialer struct {
+	release chan struct{}
+}
+
+func (d *blockingDialer) Dial() (SendCloser, error) { return d, nil }
+func (d *blockingDialer) Close() error              { return nil }
+
+func (d *blockingDialer) Send(from string, to []string, msg io.WriterTo) error {
+	<-d.release
+	return nil
+}
+
+func TestQueueNonBlocking(t *testing.T) {
+	d := &blockingDialer{release: make(chan struct{})}
+	q := NewQueue(d, MaxPending(2), NonBlocking())
+	for i := 0; i < 2; i++ {
+		if err := q.Enqueue(testQueueMessage(testTo1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Enqueue(testQueueMessage(testTo1)); err != ErrQueueFull {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrQueueFull)
+	}
+	if stats := q.Stats(); stats.Pending != 2 || stats.Rejected != 1 {
+		t.Errorf("Invalid stats, got %+v", stats)
+	}
+
+	close(d.release)
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := q.Stats(); stats.Pending != 0 {
+		t.Errorf("Invalid number of pending emails, got %d, want 0", stats.Pending)
+	}
+}
+
+func TestQueueMaxPendingBlocks(t *testing.T) {
+	d := &blockingDialer{release: make(chan struct{})}
+	q := NewQueue(d, MaxPending(1))
+	if err := q.Enqueue(testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- q.Enqueue(testQueueMessage(testTo2))
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Enqueue should block when the queue is full, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(d.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats := q.Stats(); stats.Wait < 50*time.Millisecond {
+		t.Errorf("Invalid wait time, got %v", stats.Wait)
+	}
+}
+
+func TestQueueMaxPendingBytes(t *testing.T) {
+	size, err := messageSize(testQueueMessage(testTo1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &blockingDialer{release: make(chan struct{})}
+	q := NewQueue(d, MaxPendingBytes(size+10), NonBlocking())
+	if err := q.Enqueue(testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue(testQueueMessage(testTo1)); err != ErrQueueFull {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrQueueFull)
+	}
+	if stats := q.Stats(); stats.PendingBytes != size {
+		t.Errorf("Invalid pending size, got %d, want %d", stats.PendingBytes, size)
+	}
+
+	close(d.release)
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueueFullShutdown(t *testing.T) {
+	d := &blockingDialer{release: make(chan struct{})}
+	defer close(d.release)
+	q := NewQueue(d, MaxPending(1))
+	if err := q.Enqueue(testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- q.Enqueue(testQueueMessage(testTo2))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Invalid error, got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-done; err != ErrQueueClosed {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrQueueClosed)
+	}
+}
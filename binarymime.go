@@ -0,0 +1,107 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+)
+
+// binaryEncoding is the "binary" transfer encoding of the BINARYMIME extension
+// defined in RFC 3030. It is only used on the wire, see
+// Dialer.SkipBodyEncoding.
+const binaryEncoding Encoding = "binary"
+
+// maxLineLen8bit is the maximum length of a line of 8bit data, without its
+// CRLF, as defined in RFC 2045.
+const maxLineLen8bit = 998
+
+// transportEncoding returns the encoding the bodies of msg can be sent with:
+// binaryEncoding if the server supports the BINARYMIME and CHUNKING
+// extensions, Unencoded if it supports 8BITMIME, or "" if they must be
+// encoded.
+func (c *smtpSender) transportEncoding(msg io.WriterTo) Encoding {
+	if _, ok := msg.(*Message); !ok || !c.d.SkipBodyEncoding {
+		return ""
+	}
+	ext := c.extensions()
+	_, binary := ext["BINARYMIME"]
+	_, chunking := ext["CHUNKING"]
+	if binary && chunking {
+		return binaryEncoding
+	}
+	if _, ok := ext["8BITMIME"]; ok {
+		return Unencoded
+	}
+	return ""
+}
+
+// writeUnencoded is like WriteTo but skips the encoding of the bodies allowed
+// by the transport encoding enc: with Unencoded, the text bodies that are
+// valid 8bit data are sent as is, and with binaryEncoding all the bodies,
+// including the attached and embedded files, are.
+func (m *Message) writeUnencoded(w io.Writer, enc Encoding) (int64, error) {
+	mw := m.newWriter(w)
+	mw.transport = enc
+	mw.writeMessage(m)
+	return mw.n, mw.err
+}
+
+// partEncoding returns the encoding and the content of a text body written by
+// w.
+func (w *messageWriter) partEncoding(p *part) (Encoding, func(io.Writer) error) {
+	if w.transport == "" {
+		return p.encoding, p.copier
+	}
+	var buf bytes.Buffer
+	if err := p.copier(&buf); err != nil {
+		return p.encoding, p.copier
+	}
+	b := toCRLF(buf.Bytes())
+	copier := func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	}
+	if is8bit(b) {
+		return Unencoded, copier
+	}
+	if w.transport == binaryEncoding {
+		return binaryEncoding, copier
+	}
+	return p.encoding, p.copier
+}
+
+// is8bit reports whether b, with CRLF line breaks, is valid 8bit data: it has
+// no NUL byte, no bare CR or LF and no line longer than 998 bytes.
+func is8bit(b []byte) bool {
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		line := b
+		if i == -1 {
+			b = nil
+		} else {
+			if i == 0 || b[i-1] != '\r' {
+				return false
+			}
+			line, b = b[:i-1], b[i+1:]
+		}
+		if len(line) > maxLineLen8bit || bytes.IndexByte(line, 0) != -1 || bytes.IndexByte(line, '\r') != -1 {
+			return false
+		}
+	}
+	return true
+}
+
+// toCRLF converts the bare LF line breaks of b to CRLF.
+func toCRLF(b []byte) []byte {
+	n := bytes.Count(b, []byte("\n")) - bytes.Count(b, []byte("\r\n"))
+	if n == 0 {
+		return b
+	}
+	out := make([]byte, 0, len(b)+n)
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			out = append(out, '\r')
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,150 @@
+package gomail
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIs8bit(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"", true},
+		{"Café\r\nà bientôt", true},
+		{"line\r\n", true},
+		{"bare\nLF", false},
+		{"bare\rCR", false},
+		{"NUL\x00", false},
+		{strings.Repeat("a", 998) + "\r\n", true},
+		{strings.Repeat("a", 999), false},
+	}
+	for _, test := range tests {
+		if got := is8bit([]byte(test.s)); got != test.want {
+			t.Errorf("is8bit(%.20q) = %v, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestToCRLF(t *testing.T) {
+	got := string(toCRLF([]byte("a\nb\r\nc\n")))
+	if want := "a\r\nb\r\nc\r\n"; got != want {
+		t.Errorf("Invalid conversion, got %q, want %q", got, want)
+	}
+}
+
+func TestWriteUnencoded(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", "from@example.com")
+	m.SetHeader("To", "to@example.com")
+	m.SetBody("text/plain", "Café\nà bientôt")
+	m.Attach(mockCopyFile("/tmp/test.pdf"))
+
+	var buf bytes.Buffer
+	if _, err := m.writeUnencoded(&buf, Unencoded); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, s := range []string{
+		"Content-Transfer-Encoding: 8bit\r\n",
+		"\r\n\r\nCafé\r\nà bientôt\r\n",
+		"Content-Transfer-Encoding: base64\r\n",
+	} {
+		if !strings.Contains(got, s) {
+			t.Errorf("The email should contain %q, got:\n%s", s, got)
+		}
+	}
+
+	buf.Reset()
+	if _, err := m.writeUnencoded(&buf, binaryEncoding); err != nil {
+		t.Fatal(err)
+	}
+	got = buf.String()
+	for _, s := range []string{
+		"Content-Transfer-Encoding: 8bit\r\n",
+		"Content-Transfer-Encoding: binary\r\n",
+		"\r\n\r\nContent of test.pdf\r\n",
+	} {
+		if !strings.Contains(got, s) {
+			t.Errorf("The email should contain %q, got:\n%s", s, got)
+		}
+	}
+
+	// The message itself is unchanged.
+	buf.Reset()
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); strings.Contains(got, "binary") || strings.Contains(got, "8bit") {
+		t.Errorf("WriteTo should encode the bodies, got:\n%s", got)
+	}
+}
+
+func TestDialerSkipBodyEncoding(t *testing.T) {
+	unencoded := strings.Replace(testMsg, "quoted-printable", "8bit", 1)
+	tests := []struct {
+		ext  map[string]string
+		want []string
+		body string
+	}{
+		{
+			ext:  map[string]string{"BINARYMIME": "", "CHUNKING": ""},
+			want: []string{"Mail " + testFrom + " BODY=BINARYMIME", "Bdat binary"},
+			body: unencoded,
+		},
+		{
+			ext:  map[string]string{"8BITMIME": ""},
+			want: []string{"Mail " + testFrom, "Data"},
+			body: unencoded,
+		},
+		{
+			ext:  map[string]string{"BINARYMIME": ""},
+			want: []string{"Mail " + testFrom, "Data"},
+			body: testMsg,
+		},
+	}
+	for _, test := range tests {
+		d := &Dialer{Host: testHost, Port: testPort, CapabilitiesTTL: -1, SkipBodyEncoding: true}
+		c := &mockClient{
+			t: t,
+			want: []string{
+				"Extension STARTTLS",
+				"StartTLS",
+				test.want[0],
+				"Rcpt " + testTo1,
+				"Rcpt " + testTo2,
+				test.want[1],
+				"Write message",
+				"Close writer",
+				"Quit",
+				"Close",
+			},
+			ext:    test.ext,
+			bodies: []string{test.body},
+		}
+		if err := sendMailWithClient(t, d, c); err != nil {
+			t.Errorf("%v: %v", test.ext, err)
+		}
+	}
+}
+
+func TestSMTPConnBinaryMIME(t *testing.T) {
+	c, cmds := newFakeConn(t, []string{"8BITMIME", "BINARYMIME", "CHUNKING"})
+	if err := c.Mail(testFrom, "BODY=BINARYMIME"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Quit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"EHLO localhost",
+		"MAIL FROM:<" + testFrom + "> BODY=BINARYMIME",
+		"QUIT",
+	}
+	if !reflect.DeepEqual(*cmds, want) {
+		t.Errorf("Invalid commands, got %q, want %q", *cmds, want)
+	}
+}
@@ -0,0 +1,351 @@
+package gomail
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotBounce is returned by ParseBounce when the email is neither a delivery
+// status notification nor an abuse report.
+var ErrNotBounce = errors.New("gomail: email is not a bounce")
+
+// BounceType is the kind of a bounce.
+type BounceType int
+
+const (
+	// BounceHard is a permanent delivery failure.
+	BounceHard BounceType = iota
+	// BounceSoft is a temporary delivery failure or a delay.
+	BounceSoft
+	// BounceComplaint is an abuse report sent through a feedback loop.
+	BounceComplaint
+)
+
+func (t BounceType) String() string {
+	switch t {
+	case BounceHard:
+		return "hard"
+	case BounceSoft:
+		return "soft"
+	default:
+		return "complaint"
+	}
+}
+
+// A Bounce is a delivery status notification (RFC 3464) or an abuse report in
+// the Abuse Reporting Format (RFC 5965).
+type Bounce struct {
+	// Type is the kind of the bounce. A notification containing both permanent
+	// and temporary failures is a hard bounce.
+	Type BounceType
+	// Recipients are the recipients the bounce is about.
+	Recipients []*BounceRecipient
+	// MessageID is the Message-ID of the original email, if it is included in
+	// the bounce.
+	MessageID string
+	// FeedbackType is the type of an abuse report, for example "abuse".
+	FeedbackType string
+	// To is the address the bounce was sent to, usually the envelope sender
+	// of the original email. With VERP, ParseVERPAddress returns the
+	// recipient it encodes.
+	To string
+}
+
+// A BounceRecipient is a recipient of a bounce.
+type BounceRecipient struct {
+	// Address is the address of the recipient.
+	Address string
+	// Action is the action of a delivery status notification, "failed" or
+	// "delayed".
+	Action string
+	// Status is the enhanced status code, for example "5.1.1".
+	Status string
+	// Diagnostic is the reply of the remote server, if any.
+	Diagnostic string
+}
+
+// ParseBounce parses a delivery status notification or an abuse report. It
+// returns ErrNotBounce if the email is neither.
+func ParseBounce(r io.Reader) (*Bounce, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" {
+		return nil, ErrNotBounce
+	}
+
+	b := new(Bounce)
+	if to, err := mail.ParseAddress(msg.Header.Get("To")); err == nil {
+		b.To = to.Address
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	found := false
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			err = b.parseDeliveryStatus(p)
+			found = true
+		case "message/feedback-report":
+			err = b.parseFeedbackReport(p)
+			found = true
+		case "message/rfc822", "message/global", "text/rfc822-headers", "message/global-headers":
+			err = b.parseOriginal(p)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !found || len(b.Recipients) == 0 {
+		return nil, ErrNotBounce
+	}
+	return b, nil
+}
+
+// readFieldGroups reads the groups of header fields separated by blank lines
+// of a delivery status or feedback report part.
+func readFieldGroups(r io.Reader) ([]textproto.MIMEHeader, error) {
+	tr := textproto.NewReader(bufio.NewReader(r))
+	var groups []textproto.MIMEHeader
+	for {
+		h, err := tr.ReadMIMEHeader()
+		if len(h) > 0 {
+			groups = append(groups, h)
+		}
+		if err == io.EOF {
+			return groups, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// typedValue returns the value of a field like "rfc822; bob@example.com".
+func typedValue(v string) string {
+	if i := strings.IndexByte(v, ';'); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// permanent reports whether the delivery to the recipient failed permanently.
+func (r *BounceRecipient) permanent() bool {
+	return r.Action == "failed" && !strings.HasPrefix(r.Status, "4")
+}
+
+func (b *Bounce) parseDeliveryStatus(r io.Reader) error {
+	groups, err := readFieldGroups(r)
+	if err != nil {
+		return err
+	}
+	if len(groups) < 2 {
+		return nil
+	}
+
+	b.Type = BounceSoft
+	for _, h := range groups[1:] {
+		action := strings.ToLower(strings.TrimSpace(h.Get("Action")))
+		if action != "failed" && action != "delayed" {
+			continue
+		}
+		addr := typedValue(h.Get("Final-Recipient"))
+		if orig := h.Get("Original-Recipient"); orig != "" {
+			addr = typedValue(orig)
+		}
+		rcpt := &BounceRecipient{
+			Address:    addr,
+			Action:     action,
+			Status:     strings.TrimSpace(h.Get("Status")),
+			Diagnostic: typedValue(h.Get("Diagnostic-Code")),
+		}
+		if rcpt.permanent() {
+			b.Type = BounceHard
+		}
+		b.Recipients = append(b.Recipients, rcpt)
+	}
+	return nil
+}
+
+func (b *Bounce) parseFeedbackReport(r io.Reader) error {
+	groups, err := readFieldGroups(r)
+	if err != nil || len(groups) == 0 {
+		return err
+	}
+
+	h := groups[0]
+	b.Type = BounceComplaint
+	b.FeedbackType = strings.TrimSpace(h.Get("Feedback-Type"))
+	for _, addr := range h["Original-Rcpt-To"] {
+		b.Recipients = append(b.Recipients, &BounceRecipient{Address: strings.TrimSpace(addr)})
+	}
+	return nil
+}
+
+func (b *Bounce) parseOriginal(r io.Reader) error {
+	h, err := textproto.NewReader(bufio.NewReader(r)).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	b.MessageID = strings.Trim(strings.TrimSpace(h.Get("Message-Id")), "<>")
+
+	// Abuse reports without Original-Rcpt-To are about the original recipient.
+	if b.Type == BounceComplaint && len(b.Recipients) == 0 {
+		if to, err := mail.ParseAddress(h.Get("To")); err == nil {
+			b.Recipients = append(b.Recipients, &BounceRecipient{Address: to.Address})
+		}
+	}
+	return nil
+}
+
+// A MailboxFetcher reads the emails of a mailbox.
+type MailboxFetcher interface {
+	// Fetch calls f with each email of the mailbox and deletes the emails for
+	// which f returns nil. The emails for which f returns an error are left
+	// in the mailbox.
+	Fetch(f func(r io.Reader) error) error
+}
+
+// A SuppressionList keeps track of the addresses that must no longer receive
+// emails.
+type SuppressionList interface {
+	// Suppress adds an address to the list because of the given bounce.
+	Suppress(address string, b *Bounce) error
+}
+
+// A BouncePoller drains a bounce mailbox: it parses the delivery status
+// notifications and abuse reports, adds the addresses of the hard bounces and
+// complaints to a suppression list and deletes the processed emails. The
+// emails that are not bounces are left in the mailbox.
+type BouncePoller struct {
+	// Fetcher reads the bounce mailbox.
+	Fetcher MailboxFetcher
+	// Suppression, if set, receives the addresses of the hard bounces and
+	// complaints.
+	Suppression SuppressionList
+	// OnBounce, if set, is called with every bounce so the delivery state of
+	// the emails can be updated.
+	OnBounce func(b *Bounce)
+	// ErrorFunc is called with the errors occurring when the mailbox is
+	// polled in the background.
+	ErrorFunc func(err error)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Poll drains the mailbox once and returns the number of bounces processed.
+func (p *BouncePoller) Poll() (int, error) {
+	n := 0
+	err := p.Fetcher.Fetch(func(r io.Reader) error {
+		b, err := ParseBounce(r)
+		if err != nil {
+			return err
+		}
+		if err := p.handle(b); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("gomail: could not poll bounces: %w", err)
+	}
+	return n, nil
+}
+
+func (p *BouncePoller) handle(b *Bounce) error {
+	if p.Suppression != nil && b.Type != BounceSoft {
+		for _, r := range b.Recipients {
+			if b.Type == BounceHard && !r.permanent() {
+				continue
+			}
+			if err := p.Suppression.Suppress(r.Address, b); err != nil {
+				return err
+			}
+		}
+	}
+	if p.OnBounce != nil {
+		p.OnBounce(b)
+	}
+	return nil
+}
+
+// Start starts polling the mailbox in the background at the given interval.
+// The fields of the BouncePoller must not be modified after Start is called.
+func (p *BouncePoller) Start(interval time.Duration) {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := p.Poll(); err != nil && p.ErrorFunc != nil {
+					p.ErrorFunc(err)
+				}
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background polling started by Start.
+func (p *BouncePoller) Close() error {
+	if p.stop != nil {
+		close(p.stop)
+		<-p.done
+		p.stop = nil
+	}
+	return nil
+}
+
+// MemorySuppressionList is a SuppressionList kept in memory. It is safe for
+// concurrent use.
+type MemorySuppressionList struct {
+	mu    sync.Mutex
+	addrs map[string]*Bounce
+}
+
+// Suppress implements SuppressionList.
+func (l *MemorySuppressionList) Suppress(address string, b *Bounce) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.addrs == nil {
+		l.addrs = make(map[string]*Bounce)
+	}
+	l.addrs[strings.ToLower(address)] = b
+	return nil
+}
+
+// Suppressed returns the bounce that caused an address to be suppressed, or nil
+// if the address is not suppressed.
+func (l *MemorySuppressionList) Suppressed(address string) *Bounce {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.addrs[strings.ToLower(address)]
+}
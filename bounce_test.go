@@ -0,0 +1,194 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDSN = "From: MAILER-DAEMON@example.org\r\n" +
+	"To: bounces@example.com\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status;\r\n" +
+	" boundary=\"BOUNDARY\"\r\n" +
+	"\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Your message could not be delivered.\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.example.org\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; bob@example.org\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 User unknown\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; cora@example.org\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.2.2\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; dan@example.org\r\n" +
+	"Action: delivered\r\n" +
+	"Status: 2.0.0\r\n" +
+	"\r\n" +
+	"--BOUNDARY\r\n" +
+	"Content-Type: text/rfc822-headers\r\n" +
+	"\r\n" +
+	"From: from@example.com\r\n" +
+	"Message-ID: <1234@example.com>\r\n" +
+	"\r\n" +
+	"--BOUNDARY--\r\n"
+
+const testARF = "From: fbl@example.net\r\n" +
+	"To: abuse@example.com\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=feedback-report; boundary=\"B\"\r\n" +
+	"\r\n" +
+	"--B\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"This is an email abuse report.\r\n" +
+	"--B\r\n" +
+	"Content-Type: message/feedback-report\r\n" +
+	"\r\n" +
+	"Feedback-Type: abuse\r\n" +
+	"User-Agent: SomeGenerator/1.0\r\n" +
+	"Version: 1\r\n" +
+	"\r\n" +
+	"--B\r\n" +
+	"Content-Type: message/rfc822\r\n" +
+	"\r\n" +
+	"From: from@example.com\r\n" +
+	"To: Erin <erin@example.net>\r\n" +
+	"Message-ID: <5678@example.com>\r\n" +
+	"\r\n" +
+	"Hello\r\n" +
+	"--B--\r\n"
+
+func TestParseBounceDSN(t *testing.T) {
+	b, err := ParseBounce(strings.NewReader(testDSN))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Bounce{
+		Type: BounceHard,
+		Recipients: []*BounceRecipient{
+			{Address: "bob@example.org", Action: "failed", Status: "5.1.1", Diagnostic: "550 5.1.1 User unknown"},
+			{Address: "cora@example.org", Action: "delayed", Status: "4.2.2"},
+		},
+		MessageID: "1234@example.com",
+		To:        "bounces@example.com",
+	}
+	if !reflect.DeepEqual(b, want) {
+		t.Errorf("Invalid bounce, got %+v, want %+v", b, want)
+	}
+}
+
+func TestParseBounceSoft(t *testing.T) {
+	dsn := strings.Replace(testDSN, "Action: failed\r\nStatus: 5.1.1", "Action: failed\r\nStatus: 4.4.7", 1)
+	b, err := ParseBounce(strings.NewReader(dsn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Type != BounceSoft {
+		t.Errorf("Invalid type, got %v, want %v", b.Type, BounceSoft)
+	}
+}
+
+func TestParseBounceARF(t *testing.T) {
+	b, err := ParseBounce(strings.NewReader(testARF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Bounce{
+		Type:         BounceComplaint,
+		Recipients:   []*BounceRecipient{{Address: "erin@example.net"}},
+		MessageID:    "5678@example.com",
+		FeedbackType: "abuse",
+		To:           "abuse@example.com",
+	}
+	if !reflect.DeepEqual(b, want) {
+		t.Errorf("Invalid bounce, got %+v, want %+v", b, want)
+	}
+}
+
+func TestParseBounceNotBounce(t *testing.T) {
+	msg := "From: bob@example.org\r\nSubject: Out of office\r\nContent-Type: text/plain\r\n\r\nI am away.\r\n"
+	if _, err := ParseBounce(strings.NewReader(msg)); err != ErrNotBounce {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrNotBounce)
+	}
+}
+
+// fakeFetcher is a MailboxFetcher over a list of emails.
+type fakeFetcher struct {
+	emails []string
+	err    error
+}
+
+func (f *fakeFetcher) Fetch(fn func(r io.Reader) error) error {
+	var kept []string
+	for _, e := range f.emails {
+		if fn(strings.NewReader(e)) != nil {
+			kept = append(kept, e)
+		}
+	}
+	f.emails = kept
+	return f.err
+}
+
+func TestBouncePoller(t *testing.T) {
+	outOfOffice := "From: bob@example.org\r\n\r\nI am away.\r\n"
+	f := &fakeFetcher{emails: []string{testDSN, outOfOffice, testARF}}
+	list := new(MemorySuppressionList)
+	var bounces []*Bounce
+	p := &BouncePoller{
+		Fetcher:     f,
+		Suppression: list,
+		OnBounce:    func(b *Bounce) { bounces = append(bounces, b) },
+	}
+
+	n, err := p.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || len(bounces) != 2 {
+		t.Errorf("Invalid number of bounces, got %d, want 2", n)
+	}
+	if len(f.emails) != 1 || f.emails[0] != outOfOffice {
+		t.Errorf("Only the bounces should be deleted, got %q", f.emails)
+	}
+
+	for _, addr := range []string{"bob@example.org", "ERIN@example.net"} {
+		if list.Suppressed(addr) == nil {
+			t.Errorf("%s should be suppressed", addr)
+		}
+	}
+	for _, addr := range []string{"cora@example.org", "dan@example.org"} {
+		if list.Suppressed(addr) != nil {
+			t.Errorf("%s should not be suppressed", addr)
+		}
+	}
+
+	f.err = errors.New("connection lost")
+	if _, err := p.Poll(); !errors.Is(err, f.err) {
+		t.Errorf("Invalid error, got %v, want %v", err, f.err)
+	}
+}
+
+func TestBouncePollerSoft(t *testing.T) {
+	dsn := strings.Replace(testDSN, "Status: 5.1.1", "Status: 4.4.7", 1)
+	list := new(MemorySuppressionList)
+	p := &BouncePoller{Fetcher: &fakeFetcher{emails: []string{dsn}}, Suppression: list}
+	if _, err := p.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if list.Suppressed("bob@example.org") != nil {
+		t.Error("Soft bounces should not be suppressed")
+	}
+}
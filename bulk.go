@@ -0,0 +1,283 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// A BulkRecipient is a recipient of an email personalized by a BulkSender.
+type BulkRecipient struct {
+	// Address is the email address of the recipient.
+	Address string
+	// Name is the optional name of the recipient.
+	Name string
+	// Data is any data used by the templates, for example a map or a struct.
+	Data interface{}
+}
+
+// A BulkResult is the result of sending an email to a BulkRecipient.
+type BulkResult struct {
+	Recipient BulkRecipient
+	// Err is nil if the email was sent.
+	Err error
+}
+
+// A BulkSender sends a personalized copy of a template message to each
+// recipient of a list, also known as mail merge.
+//
+// The header values and the bodies of the template message are parsed as
+// text/template templates, or html/template templates for the text/html
+// bodies, and executed with the BulkRecipient as data, for example
+// "Hello {{.Name}}" or "Your plan: {{.Data.Plan}}". Each copy is sent to its
+// recipient only: the To field is set to the recipient and the Cc and Bcc
+// fields of the template are removed. The attached and embedded files are
+// shared by all the copies.
+type BulkSender struct {
+	// Dialer opens the connections used to send the emails. Each worker
+	// keeps its connection open until all the emails are sent.
+	Dialer SendDialer
+	// Workers is the number of emails sent concurrently, each over its own
+	// connection. If zero, a single connection is used.
+	Workers int
+	// MinWorkers, if positive, enables adaptive concurrency: the number of
+	// emails sent concurrently is reduced down to MinWorkers while the server
+	// replies that there are too many connections or emails, see the
+	// MinWorkers queue setting.
+	MinWorkers int
+	// Watermarker, if set, embeds in each copy an invisible token identifying
+	// its recipient.
+	Watermarker *Watermarker
+}
+
+// Send sends tmpl personalized for each recipient and returns the results in
+// the order of recipients. It only returns an error if the templates are
+// invalid, in which case no email is sent. If ctx is canceled, the remaining
+// recipients get ctx.Err() as result.
+func (s *BulkSender) Send(ctx context.Context, tmpl *Message, recipients []BulkRecipient) ([]BulkResult, error) {
+	t, err := parseBulkTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkResult, len(recipients))
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := range recipients {
+			select {
+			case ch <- i:
+			case <-ctx.Done():
+				for ; i < len(recipients); i++ {
+					results[i] = BulkResult{recipients[i], ctx.Err()}
+				}
+				return
+			}
+		}
+	}()
+
+	s.run(ctx, func(w *bulkWorker) {
+		for i := range ch {
+			results[i] = w.send(t, recipients[i])
+		}
+	})
+	return results, nil
+}
+
+// SendStream is like Send but reads the recipients from a channel until it is
+// closed or ctx is canceled, so the recipient list does not have to be loaded
+// in memory. report is called with the result of each recipient, one call at a
+// time.
+func (s *BulkSender) SendStream(ctx context.Context, tmpl *Message, recipients <-chan BulkRecipient, report func(BulkResult)) error {
+	t, err := parseBulkTemplate(tmpl)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	s.run(ctx, func(w *bulkWorker) {
+		for {
+			var r BulkRecipient
+			var ok bool
+			select {
+			case r, ok = <-recipients:
+			case <-ctx.Done():
+			}
+			if !ok {
+				return
+			}
+			res := w.send(t, r)
+			mu.Lock()
+			report(res)
+			mu.Unlock()
+		}
+	})
+	return ctx.Err()
+}
+
+// run runs f in each worker and waits for them.
+func (s *BulkSender) run(ctx context.Context, f func(w *bulkWorker)) {
+	n := s.Workers
+	if n <= 0 {
+		n = 1
+	}
+	var a *adaptiveLimit
+	if s.MinWorkers > 0 {
+		a = newAdaptiveLimit(s.MinWorkers, n)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			w := &bulkWorker{d: s.Dialer, wm: s.Watermarker, a: a, ctx: ctx}
+			defer w.close()
+			f(w)
+		}()
+	}
+	wg.Wait()
+}
+
+type bulkWorker struct {
+	d   SendDialer
+	wm  *Watermarker
+	a   *adaptiveLimit
+	ctx context.Context
+	s   SendCloser
+}
+
+func (w *bulkWorker) send(t *bulkTemplate, r BulkRecipient) BulkResult {
+	m, err := t.execute(r)
+	if err == nil && w.wm != nil {
+		m, err = w.wm.Watermark(m, r.Address)
+	}
+	if err == nil {
+		err = w.deliver(m)
+	}
+	return BulkResult{r, err}
+}
+
+func (w *bulkWorker) deliver(m *Message) (err error) {
+	if w.a != nil {
+		var epoch int
+		if epoch, err = w.a.acquire(w.ctx, w.close); err != nil {
+			return err
+		}
+		defer func() { w.a.release(epoch, err) }()
+	}
+	if w.s == nil {
+		if w.s, err = w.d.Dial(); err != nil {
+			return err
+		}
+	}
+	if err = send(w.s, m); err != nil {
+		// The connection might be broken.
+		w.close()
+	}
+	return err
+}
+
+func (w *bulkWorker) close() {
+	if w.s != nil {
+		w.s.Close()
+		w.s = nil
+	}
+}
+
+// A bulkTemplate is a message whose header values and bodies are templates.
+type bulkTemplate struct {
+	msg    *Message
+	header map[string][]*template.Template
+	parts  []bulkExecutor
+}
+
+type bulkExecutor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+func parseBulkTemplate(m *Message) (*bulkTemplate, error) {
+	t := &bulkTemplate{
+		msg:    m.Clone(),
+		header: make(map[string][]*template.Template),
+	}
+	for _, field := range []string{"To", "Cc", "Bcc", "Message-ID"} {
+		delete(t.msg.header, field)
+	}
+
+	for field, values := range t.msg.header {
+		for i, v := range values {
+			if !strings.Contains(v, "{{") {
+				continue
+			}
+			// The values are decoded so the templates are not split by the
+			// encoding.
+			if dec, err := previewDecoder.DecodeHeader(v); err == nil {
+				v = dec
+			}
+			tpl, err := template.New(field).Parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("gomail: invalid template in the %q field: %w", field, err)
+			}
+			if t.header[field] == nil {
+				t.header[field] = make([]*template.Template, len(values))
+			}
+			t.header[field][i] = tpl
+		}
+	}
+
+	for _, p := range t.msg.parts {
+		var buf bytes.Buffer
+		if err := p.copier(&buf); err != nil {
+			return nil, fmt.Errorf("gomail: could not render the %s body: %w", p.contentType, err)
+		}
+		var tpl bulkExecutor
+		var err error
+		if strings.HasPrefix(p.contentType, "text/html") {
+			tpl, err = htmltemplate.New(p.contentType).Parse(buf.String())
+		} else {
+			tpl, err = template.New(p.contentType).Parse(buf.String())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("gomail: invalid template in the %s body: %w", p.contentType, err)
+		}
+		t.parts = append(t.parts, tpl)
+	}
+	return t, nil
+}
+
+// execute returns the message personalized for r.
+func (t *bulkTemplate) execute(r BulkRecipient) (*Message, error) {
+	m := t.msg.Clone()
+	var buf bytes.Buffer
+	for field, tpls := range t.header {
+		values := m.header[field]
+		for i, tpl := range tpls {
+			if tpl == nil {
+				continue
+			}
+			buf.Reset()
+			if err := tpl.Execute(&buf, r); err != nil {
+				return nil, fmt.Errorf("gomail: could not execute the template of the %q field: %w", field, err)
+			}
+			values[i] = buf.String()
+		}
+		m.SetHeader(field, values...)
+	}
+
+	for i, tpl := range t.parts {
+		buf.Reset()
+		if err := tpl.Execute(&buf, r); err != nil {
+			return nil, fmt.Errorf("gomail: could not execute the template of the %s body: %w", m.parts[i].contentType, err)
+		}
+		m.parts[i].copier = newCopier(buf.String())
+	}
+
+	m.SetAddressHeader("To", r.Address, r.Name)
+	return m, nil
+}
@@ -0,0 +1,144 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// bulkRecorder records the rendered emails sent to each recipient.
+type bulkRecorder struct {
+	mu    sync.Mutex
+	dials int
+	sent  map[string]string
+	fail  string
+}
+
+func (r *bulkRecorder) Dial() (SendCloser, error) {
+	r.mu.Lock()
+	r.dials++
+	r.mu.Unlock()
+	return r, nil
+}
+
+func (r *bulkRecorder) Send(from string, to []string, msg io.WriterTo) error {
+	if len(to) != 1 {
+		return errors.New("the email should have a single recipient")
+	}
+	if to[0] == r.fail {
+		return errors.New("rejected")
+	}
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.sent[to[0]] = buf.String()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *bulkRecorder) Close() error {
+	return nil
+}
+
+func testBulkTemplate() *Message {
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", "list@example.com")
+	m.SetHeader("Bcc", "archive@example.com")
+	m.SetHeader("Subject", "Bonjour {{ .Name }}, café ?")
+	m.SetBody("text/plain", "Plan: {{.Data.Plan}}")
+	m.AddAlternative("text/html", "<p>Plan: {{.Data.Plan}}</p>")
+	return m
+}
+
+func TestBulkSender(t *testing.T) {
+	r := &bulkRecorder{sent: make(map[string]string), fail: "carol@example.com"}
+	s := &BulkSender{Dialer: r, Workers: 2}
+
+	recipients := []BulkRecipient{
+		{Address: "alice@example.com", Name: "Alice", Data: map[string]string{"Plan": "<Pro>"}},
+		{Address: "bob@example.com", Name: "Bob", Data: map[string]string{"Plan": "Free"}},
+		{Address: "carol@example.com", Name: "Carol", Data: map[string]string{"Plan": "Free"}},
+	}
+	results, err := s.Send(context.Background(), testBulkTemplate(), recipients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Err != nil || results[1].Err != nil || results[2].Err == nil {
+		t.Fatalf("Invalid results, got %+v", results)
+	}
+	if results[2].Recipient.Address != "carol@example.com" {
+		t.Errorf("The results should be in the order of the recipients, got %+v", results)
+	}
+	if len(r.sent) != 2 || r.dials > 3 {
+		t.Errorf("Invalid sent emails, got %d emails with %d connections", len(r.sent), r.dials)
+	}
+
+	msg := r.sent["alice@example.com"]
+	for _, want := range []string{
+		"To: \"Alice\" <alice@example.com>\r\n",
+		"Subject: =?UTF-8?q?Bonjour_Alice,_caf=C3=A9_=3F?=\r\n",
+		"Plan: <Pro>\r\n",
+		"<p>Plan: &lt;Pro&gt;</p>\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("The email should contain %q, got:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "list@example.com") || strings.Contains(msg, "Bob") {
+		t.Errorf("The email should only be personalized for its recipient, got:\n%s", msg)
+	}
+}
+
+func TestBulkSenderStream(t *testing.T) {
+	r := &bulkRecorder{sent: make(map[string]string)}
+	s := &BulkSender{Dialer: r, Workers: 3}
+
+	ch := make(chan BulkRecipient)
+	go func() {
+		for _, addr := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+			ch <- BulkRecipient{Address: addr, Data: map[string]string{"Plan": "Free"}}
+		}
+		close(ch)
+	}()
+
+	var results []BulkResult
+	err := s.SendStream(context.Background(), testBulkTemplate(), ch, func(res BulkResult) {
+		results = append(results, res)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 || len(r.sent) != 4 {
+		t.Errorf("Invalid results, got %+v", results)
+	}
+}
+
+func TestBulkSenderInvalidTemplate(t *testing.T) {
+	m := testBulkTemplate()
+	m.SetHeader("Subject", "Hello {{.Name")
+	r := &bulkRecorder{sent: make(map[string]string)}
+	_, err := (&BulkSender{Dialer: r}).Send(context.Background(), m, []BulkRecipient{{Address: testTo1}})
+	if err == nil || !strings.Contains(err.Error(), `"Subject"`) {
+		t.Errorf("Invalid error, got %v", err)
+	}
+	if r.dials != 0 {
+		t.Error("No connection should be opened")
+	}
+
+	// A missing field fails for the recipient only.
+	m = testBulkTemplate()
+	results, err := (&BulkSender{Dialer: r}).Send(context.Background(), m, []BulkRecipient{{Address: testTo1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err == nil {
+		t.Error("Executing a template without data should fail")
+	}
+}
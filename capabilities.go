@@ -0,0 +1,118 @@
+package gomail
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Capabilities are the ESMTP extensions advertised by an SMTP server in its
+// reply to the EHLO command.
+type Capabilities struct {
+	// Extensions maps the names of the extensions to their parameters, for
+	// example "SIZE" to "35882577". Only the extensions gomail knows about are
+	// recorded.
+	Extensions map[string]string
+	// Time is the time at which the capabilities were received.
+	Time time.Time
+}
+
+// Has reports whether the server supports the given extension.
+func (c *Capabilities) Has(ext string) bool {
+	_, ok := c.Extensions[strings.ToUpper(ext)]
+	return ok
+}
+
+// MaxSize returns the maximum size of an email advertised with the SIZE
+// extension, or 0 if the server does not advertise a limit.
+func (c *Capabilities) MaxSize() int64 {
+	n, _ := strconv.ParseInt(c.Extensions["SIZE"], 10, 64)
+	return n
+}
+
+// knownExtensions are the extensions recorded in the capabilities of a server.
+var knownExtensions = []string{
+	"8BITMIME", "AUTH", "BINARYMIME", "CHUNKING", "DSN", "ENHANCEDSTATUSCODES",
+	"PIPELINING", "REQUIRETLS", "SIZE", "SMTPUTF8", "STARTTLS",
+}
+
+func (c *smtpConn) extensions() map[string]string {
+	ext := make(map[string]string)
+	for _, name := range knownExtensions {
+		if ok, param := c.Extension(name); ok {
+			ext[name] = param
+		}
+	}
+	return ext
+}
+
+// capabilityCache holds the capabilities of the servers the Dialers connected
+// to, by address, so they are shared by all the connections to a server. It
+// holds at most maxCachedCapabilities servers.
+var capabilityCache = struct {
+	sync.Mutex
+	m map[string]*Capabilities
+}{m: make(map[string]*Capabilities)}
+
+// maxCachedCapabilities is the maximum number of servers in capabilityCache,
+// which can grow with the destinations of an MXSender.
+const maxCachedCapabilities = 1000
+
+// Capabilities returns the capabilities the SMTP server advertised the last
+// time a Dialer connected to it, or nil if no connection has been opened
+// during the last CapabilitiesTTL. They can be used to check what the server
+// supports without opening a connection.
+func (d *Dialer) Capabilities() *Capabilities {
+	ttl := d.capabilitiesTTL()
+	if ttl < 0 {
+		return nil
+	}
+
+	capabilityCache.Lock()
+	defer capabilityCache.Unlock()
+	c, ok := capabilityCache.m[d.address()]
+	if !ok || now().Sub(c.Time) > ttl {
+		return nil
+	}
+	return c
+}
+
+func (d *Dialer) storeCapabilities(ext map[string]string) {
+	if d.capabilitiesTTL() < 0 {
+		return
+	}
+
+	capabilityCache.Lock()
+	defer capabilityCache.Unlock()
+	t := now()
+	address := d.address()
+	if _, ok := capabilityCache.m[address]; !ok && len(capabilityCache.m) >= maxCachedCapabilities {
+		evictCapabilities(t.Add(-d.capabilitiesTTL()))
+	}
+	capabilityCache.m[address] = &Capabilities{Extensions: ext, Time: t}
+}
+
+// evictCapabilities removes from capabilityCache the capabilities received
+// before expired or, if there are none, the oldest ones. capabilityCache must
+// be locked.
+func evictCapabilities(expired time.Time) {
+	var oldest string
+	for address, c := range capabilityCache.m {
+		if c.Time.Before(expired) {
+			delete(capabilityCache.m, address)
+		} else if oldest == "" || c.Time.Before(capabilityCache.m[oldest].Time) {
+			oldest = address
+		}
+	}
+	if len(capabilityCache.m) >= maxCachedCapabilities {
+		delete(capabilityCache.m, oldest)
+	}
+}
+
+func (d *Dialer) capabilitiesTTL() time.Duration {
+	if d.CapabilitiesTTL == 0 {
+		return time.Hour
+	}
+	return d.CapabilitiesTTL
+}
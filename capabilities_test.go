@@ -0,0 +1,121 @@
+package gomail
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCapabilityCacheLimit(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	current := time.Date(2014, 6, 25, 17, 46, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	capabilityCache.Lock()
+	saved := capabilityCache.m
+	capabilityCache.m = make(map[string]*Capabilities)
+	for i := 0; i < maxCachedCapabilities; i++ {
+		capabilityCache.m["mx"+strconv.Itoa(i)+":25"] = &Capabilities{Time: current.Add(time.Duration(i) * time.Second)}
+	}
+	capabilityCache.Unlock()
+	defer func() {
+		capabilityCache.Lock()
+		capabilityCache.m = saved
+		capabilityCache.Unlock()
+	}()
+
+	// The oldest server is removed when no capabilities are expired.
+	current = current.Add(time.Hour)
+	(&Dialer{Host: "new1", Port: 25}).storeCapabilities(nil)
+	capabilityCache.Lock()
+	_, old := capabilityCache.m["mx0:25"]
+	n := len(capabilityCache.m)
+	capabilityCache.Unlock()
+	if old || n != maxCachedCapabilities {
+		t.Errorf("The oldest server should be removed, got %d servers", n)
+	}
+
+	// The expired capabilities are all removed.
+	current = current.Add(time.Duration(maxCachedCapabilities/2) * time.Second)
+	(&Dialer{Host: "new2", Port: 25}).storeCapabilities(nil)
+	capabilityCache.Lock()
+	n = len(capabilityCache.m)
+	capabilityCache.Unlock()
+	if n != maxCachedCapabilities/2+2 {
+		t.Errorf("The expired capabilities should be removed, got %d servers", n)
+	}
+}
+
+func TestDialerCapabilities(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	current := time.Date(2014, 6, 25, 17, 46, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	d := &Dialer{Host: testHost, Port: 2525}
+	capabilityCache.Lock()
+	delete(capabilityCache.m, addr(d.Host, d.Port))
+	capabilityCache.Unlock()
+	if d.Capabilities() != nil {
+		t.Fatal("Capabilities should be nil before the first connection")
+	}
+
+	c := &mockClient{
+		t:    t,
+		want: []string{"Extension STARTTLS", "StartTLS", "Quit", "Close"},
+		ext:  map[string]string{"SIZE": "35882577", "PIPELINING": ""},
+	}
+	netDialTimeout = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		return testConn, nil
+	}
+	smtpNewClient = func(conn net.Conn, host string) (smtpClient, error) {
+		return c, nil
+	}
+	s, err := d.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	caps := d.Capabilities()
+	if caps == nil {
+		t.Fatal("Capabilities should be cached")
+	}
+	if !caps.Has("pipelining") || caps.Has("CHUNKING") || caps.MaxSize() != 35882577 {
+		t.Errorf("Invalid capabilities, got %+v", caps)
+	}
+
+	// The capabilities are shared by the Dialers of the same server.
+	if (&Dialer{Host: testHost, Port: 2525}).Capabilities() != caps {
+		t.Error("Capabilities should be shared by the Dialers of a server")
+	}
+	if (&Dialer{Host: testHost, Port: 2526}).Capabilities() != nil {
+		t.Error("Capabilities should not be shared by different servers")
+	}
+
+	current = current.Add(2 * time.Hour)
+	if d.Capabilities() != nil {
+		t.Error("Capabilities should expire")
+	}
+	d.CapabilitiesTTL = 3 * time.Hour
+	if d.Capabilities() == nil {
+		t.Error("Capabilities should not expire before CapabilitiesTTL")
+	}
+	d.CapabilitiesTTL = -1
+	if d.Capabilities() != nil {
+		t.Error("Capabilities should be disabled by a negative TTL")
+	}
+}
+
+func TestSMTPConnExtensions(t *testing.T) {
+	c, _ := newFakeConn(t, []string{"PIPELINING", "SIZE 1000", "AUTH PLAIN LOGIN", "X-UNKNOWN"})
+	defer c.Close()
+
+	want := map[string]string{"PIPELINING": "", "SIZE": "1000", "AUTH": "PLAIN LOGIN"}
+	if got := c.extensions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid extensions, got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+)
+
+// bdatChunkSize is the size of the chunks sent with the BDAT command. It is a
+// variable so tests can use small chunks.
+var bdatChunkSize = 1 << 20
+
+// Bdat starts the transfer of the email content with the BDAT command of the
+// CHUNKING extension defined in RFC 3030. The content is sent in chunks of
+// known size, so it does not need dot-stuffing, and the server acknowledges
+// each chunk. The last chunk is sent when the writer is closed. If binary is
+// true, the content is binary MIME and its line breaks are not converted.
+func (c *smtpConn) Bdat(binary bool) (io.WriteCloser, error) {
+	return &bdatWriter{c: c, binary: binary}, nil
+}
+
+type bdatWriter struct {
+	c      *smtpConn
+	binary bool
+	buf    []byte
+	// cr is true if the last byte written is a CR.
+	cr  bool
+	err error
+}
+
+// Write buffers p and sends the full chunks. The bare LF line breaks are
+// converted to CRLF since, unlike DATA, BDAT sends the content as is.
+func (w *bdatWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := len(p)
+	if w.binary {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) >= bdatChunkSize {
+			if w.err = w.send(false); w.err != nil {
+				return 0, w.err
+			}
+		}
+		return n, nil
+	}
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			w.buf = append(w.buf, p...)
+			w.cr = p[len(p)-1] == '\r'
+			p = nil
+		} else {
+			w.buf = append(w.buf, p[:i]...)
+			if (i == 0 && !w.cr) || (i > 0 && p[i-1] != '\r') {
+				w.buf = append(w.buf, '\r')
+			}
+			w.buf = append(w.buf, '\n')
+			w.cr = false
+			p = p[i+1:]
+		}
+		if len(w.buf) >= bdatChunkSize {
+			if w.err = w.send(false); w.err != nil {
+				return 0, w.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close sends the last chunk.
+func (w *bdatWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.send(true)
+	return w.err
+}
+
+func (w *bdatWriter) send(last bool) error {
+	format := "BDAT %d"
+	if last {
+		format += " LAST"
+	}
+	text := w.c.Text
+	id, err := text.Cmd(format, len(w.buf))
+	if err != nil {
+		return err
+	}
+	if _, err := text.W.Write(w.buf); err != nil {
+		return err
+	}
+	if err := text.W.Flush(); err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	_, _, err = text.ReadResponse(250)
+	return err
+}
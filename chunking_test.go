@@ -0,0 +1,96 @@
+package gomail
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDialerChunking(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort, CapabilitiesTTL: -1}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Bdat",
+			"Write message",
+			"Close writer",
+			"Quit",
+			"Close",
+		},
+		ext: map[string]string{"CHUNKING": ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBdatWriter(t *testing.T) {
+	defer func(n int) { bdatChunkSize = n }(bdatChunkSize)
+	bdatChunkSize = 16
+
+	client, server := net.Pipe()
+	defer client.Close()
+	cmds := make(chan []string, 1)
+	data := make(chan string, 1)
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		fmt.Fprint(server, "220 localhost ready\r\n")
+		var list []string
+		var content strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimSuffix(line, "\r\n")
+			list = append(list, line)
+			var n int
+			fmt.Sscanf(line, "BDAT %d", &n)
+			if _, err := io.CopyN(&content, r, int64(n)); err != nil {
+				break
+			}
+			fmt.Fprint(server, "250 OK\r\n")
+			if strings.HasSuffix(line, " LAST") {
+				break
+			}
+		}
+		cmds <- list
+		data <- content.String()
+	}()
+
+	c, err := smtp.NewClient(client, testHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := (&smtpConn{c}).Bdat(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"Subject: Hi\n", "\r\nLine 1\r", "\nLine 2\nLast line"} {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"BDAT 22", "BDAT 18", "BDAT 0 LAST"}
+	if got := <-cmds; !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid commands, got %q, want %q", got, want)
+	}
+	if got, want := <-data, "Subject: Hi\r\n\r\nLine 1\r\nLine 2\r\nLast line"; got != want {
+		t.Errorf("Invalid content, got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,498 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// A MailerConfig is the configuration of a Mailer. It is usually loaded from a
+// JSON file with LoadMailerConfig, for example:
+//
+//	{
+//		"transport": {"type": "smtp", "host": "smtp.example.com", "port": 587,
+//			"username": "user", "password": "123456"},
+//		"queue": {"workers": 4, "spool_dir": "/var/spool/app"},
+//		"retry": {"max_attempts": 5, "initial_backoff": "2s"},
+//		"rate_limit": {"count": 100, "interval": "1m"},
+//		"dkim": {"keys": [{"domain": "example.com", "selector": "s1",
+//			"key_file": "/etc/app/dkim.pem"}]}
+//	}
+type MailerConfig struct {
+	// Transport defines how the emails are sent.
+	Transport TransportConfig `json:"transport"`
+	// Queue configures the queue of the Mailer.
+	Queue QueueConfig `json:"queue"`
+	// Retry, if set, defines how the emails are retried after a temporary
+	// failure.
+	Retry *RetryConfig `json:"retry,omitempty"`
+	// RateLimit, if set, limits the rate at which emails are sent.
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty"`
+	// DKIM, if set, signs the emails with DKIM.
+	DKIM *DKIMConfig `json:"dkim,omitempty"`
+}
+
+// A TransportConfig defines the transport used to send the emails. Only the
+// fields of its type are used.
+type TransportConfig struct {
+	// Type is the type of transport: "smtp", "sendmail", "sendgrid",
+	// "mailgun", "maildir", "mbox" or "pickup".
+	Type string `json:"type"`
+
+	// Host, Port, Username, Password, SSL and LocalName configure the "smtp"
+	// transport, see Dialer.
+	Host      string `json:"host,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	SSL       bool   `json:"ssl,omitempty"`
+	LocalName string `json:"local_name,omitempty"`
+	// TLSPolicy is the TLSPolicy of the "smtp" transport: "opportunistic",
+	// the default, "mandatory" or "none".
+	TLSPolicy string `json:"tls_policy,omitempty"`
+	// Proxy is the URL of the proxy of the "smtp" transport, see
+	// Dialer.Proxy.
+	Proxy string `json:"proxy,omitempty"`
+	// Network and Address are the network, "tcp" by default, and the address
+	// dialed by the "smtp" transport instead of Host and Port, for example
+	// "unix" and the path of a socket or "pipe" and the name of a Windows
+	// named pipe, see Dialer.Network. Host defaults to "localhost" if Address
+	// is set.
+	Network string `json:"network,omitempty"`
+	Address string `json:"address,omitempty"`
+	// LMTP defines whether the "smtp" transport speaks LMTP, see
+	// Dialer.LMTP.
+	LMTP bool `json:"lmtp,omitempty"`
+	// DryRun defines whether the "smtp" transport only checks the emails
+	// without sending them, see Dialer.DryRun.
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// APIKey is the API key of the "sendgrid" and "mailgun" transports.
+	APIKey string `json:"api_key,omitempty"`
+	// Domain is the sending domain of the "mailgun" transport.
+	Domain string `json:"domain,omitempty"`
+	// BaseURL is the URL of the API of the "mailgun" transport.
+	BaseURL string `json:"base_url,omitempty"`
+
+	// Path is the program of the "sendmail" transport, the directory of the
+	// "maildir" transport, the file of the "mbox" transport or the pickup
+	// directory of the "pickup" transport.
+	Path string `json:"path,omitempty"`
+	// Args are the arguments of the "sendmail" transport.
+	Args []string `json:"args,omitempty"`
+}
+
+// A QueueConfig configures the queue of a Mailer. The zero values use the
+// defaults of the corresponding queue settings.
+type QueueConfig struct {
+	Workers         int      `json:"workers,omitempty"`
+	MinWorkers      int      `json:"min_workers,omitempty"`
+	Buffer          int      `json:"buffer,omitempty"`
+	IdleTimeout     Duration `json:"idle_timeout,omitempty"`
+	MaxPending      int      `json:"max_pending,omitempty"`
+	MaxPendingBytes int64    `json:"max_pending_bytes,omitempty"`
+	NonBlocking     bool     `json:"non_blocking,omitempty"`
+	// SpoolDir, if set, is the directory of a DirStore persisting the
+	// enqueued emails, see Spool.
+	SpoolDir string `json:"spool_dir,omitempty"`
+}
+
+// A RetryConfig is the configuration of a RetryPolicy.
+type RetryConfig struct {
+	MaxAttempts    int      `json:"max_attempts"`
+	InitialBackoff Duration `json:"initial_backoff,omitempty"`
+	MaxBackoff     Duration `json:"max_backoff,omitempty"`
+	Multiplier     float64  `json:"multiplier,omitempty"`
+	Jitter         float64  `json:"jitter,omitempty"`
+}
+
+// A RateLimitConfig allows Count emails per Interval, see NewRateLimiter.
+type RateLimitConfig struct {
+	Count    int      `json:"count"`
+	Interval Duration `json:"interval"`
+}
+
+// A DKIMConfig is the configuration of a DKIMSigner.
+type DKIMConfig struct {
+	// Keys are the signing keys of the sending domains.
+	Keys []*DKIMKeyConfig `json:"keys"`
+	// HeaderCanonicalization and BodyCanonicalization are "simple" or
+	// "relaxed", the default.
+	HeaderCanonicalization string `json:"header_canonicalization,omitempty"`
+	BodyCanonicalization   string `json:"body_canonicalization,omitempty"`
+	// Expiration, if positive, is the duration after which the signatures
+	// expire.
+	Expiration Duration `json:"expiration,omitempty"`
+}
+
+// A DKIMKeyConfig is the signing key of a domain.
+type DKIMKeyConfig struct {
+	Domain   string `json:"domain"`
+	Selector string `json:"selector"`
+	// KeyFile is the path of the PEM encoded private key, in PKCS #8 or, for
+	// RSA keys, PKCS #1 form.
+	KeyFile string `json:"key_file"`
+}
+
+// A Duration is a time.Duration written in JSON as a string accepted by
+// time.ParseDuration, for example "1m30s".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("gomail: invalid duration %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("gomail: invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadMailerConfig loads and validates the JSON configuration file at path.
+// Unknown fields are rejected so that typos are not silently ignored.
+func LoadMailerConfig(path string) (*MailerConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(MailerConfig)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("gomail: invalid configuration %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the configuration.
+func (c *MailerConfig) Validate() error {
+	t := &c.Transport
+	switch t.Type {
+	case "smtp":
+		if t.Host == "" && t.Address == "" {
+			return errors.New("gomail: invalid configuration, the SMTP host is empty")
+		}
+		if (t.Port <= 0 && t.Address == "") || t.Port < 0 || t.Port > 65535 {
+			return fmt.Errorf("gomail: invalid configuration, invalid SMTP port %d", t.Port)
+		}
+		if _, ok := parseTLSPolicy(t.TLSPolicy); !ok {
+			return fmt.Errorf("gomail: invalid configuration, unknown TLS policy %q", t.TLSPolicy)
+		}
+		if t.Proxy != "" {
+			if _, err := parseProxyURL(t.Proxy); err != nil {
+				return fmt.Errorf("gomail: invalid configuration: %w", err)
+			}
+		}
+	case "sendgrid":
+		if t.APIKey == "" {
+			return errors.New("gomail: invalid configuration, the SendGrid API key is empty")
+		}
+	case "mailgun":
+		if t.APIKey == "" || t.Domain == "" {
+			return errors.New("gomail: invalid configuration, the Mailgun API key and domain are required")
+		}
+	case "maildir", "mbox", "pickup":
+		if t.Path == "" {
+			return fmt.Errorf("gomail: invalid configuration, the %s path is empty", t.Type)
+		}
+	case "sendmail":
+	default:
+		return fmt.Errorf("gomail: invalid configuration, unknown transport %q", t.Type)
+	}
+
+	q := &c.Queue
+	if q.Workers < 0 || q.MinWorkers < 0 || q.Buffer < 0 || q.IdleTimeout < 0 || q.MaxPending < 0 || q.MaxPendingBytes < 0 {
+		return errors.New("gomail: invalid configuration, negative queue setting")
+	}
+	if r := c.Retry; r != nil {
+		if r.MaxAttempts < 1 {
+			return errors.New("gomail: invalid configuration, the retry max attempts must be positive")
+		}
+		if r.Jitter < 0 || r.Jitter > 1 {
+			return fmt.Errorf("gomail: invalid configuration, invalid retry jitter %v", r.Jitter)
+		}
+	}
+	if r := c.RateLimit; r != nil && (r.Count <= 0 || r.Interval <= 0) {
+		return errors.New("gomail: invalid configuration, the rate limit count and interval must be positive")
+	}
+	if k := c.DKIM; k != nil {
+		for _, can := range []string{k.HeaderCanonicalization, k.BodyCanonicalization} {
+			if can != "" && can != string(DKIMSimple) && can != string(DKIMRelaxed) {
+				return fmt.Errorf("gomail: invalid configuration, unknown DKIM canonicalization %q", can)
+			}
+		}
+		if len(k.Keys) == 0 {
+			return errors.New("gomail: invalid configuration, no DKIM key")
+		}
+		for _, key := range k.Keys {
+			if key.Domain == "" || key.Selector == "" || key.KeyFile == "" {
+				return errors.New("gomail: invalid configuration, the DKIM keys require a domain, a selector and a key file")
+			}
+		}
+	}
+	return nil
+}
+
+// NewMailerFromConfig returns a new Mailer configured with c. The key files
+// are read and the spool directory is created if needed.
+func NewMailerFromConfig(c *MailerConfig) (*Mailer, error) {
+	d, settings, err := c.build()
+	if err != nil {
+		return nil, err
+	}
+	return NewMailer(d, settings...), nil
+}
+
+func (c *MailerConfig) build() (SendDialer, []QueueSetting, error) {
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	var d SendDialer
+	t := &c.Transport
+	switch t.Type {
+	case "smtp":
+		dialer := NewDialer(t.Host, t.Port, t.Username, t.Password)
+		dialer.SSL = dialer.SSL || t.SSL
+		dialer.LocalName = t.LocalName
+		dialer.TLSPolicy, _ = parseTLSPolicy(t.TLSPolicy)
+		if t.Proxy != "" {
+			dialer.Proxy, _ = parseProxyURL(t.Proxy)
+		}
+		dialer.Network, dialer.Address = t.Network, t.Address
+		dialer.LMTP = t.LMTP
+		dialer.DryRun = t.DryRun
+		if dialer.Host == "" {
+			dialer.Host = "localhost"
+		}
+		d = dialer
+	case "sendmail":
+		d = &SendmailSender{Path: t.Path, Args: t.Args}
+	case "sendgrid":
+		d = NewSendGridSender(t.APIKey)
+	case "mailgun":
+		d = &MailgunSender{Domain: t.Domain, APIKey: t.APIKey, BaseURL: t.BaseURL}
+	case "maildir":
+		md, err := NewMaildir(t.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		d = md
+	case "mbox":
+		d = NewMbox(t.Path)
+	case "pickup":
+		pd, err := NewPickupDir(t.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		d = pd
+	}
+
+	cd := &configDialer{d: d}
+	if r := c.RateLimit; r != nil {
+		cd.limiter = NewRateLimiter(r.Count, time.Duration(r.Interval))
+	}
+	if k := c.DKIM; k != nil {
+		keys := make(KeyMap)
+		for _, key := range k.Keys {
+			signer, err := loadPrivateKey(key.KeyFile)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys[strings.ToLower(key.Domain)] = &SigningKey{Selector: key.Selector, Signer: signer}
+		}
+		cd.signer = &DKIMSigner{
+			Keys:                   keys,
+			HeaderCanonicalization: DKIMCanonicalization(k.HeaderCanonicalization),
+			BodyCanonicalization:   DKIMCanonicalization(k.BodyCanonicalization),
+			Expiration:             time.Duration(k.Expiration),
+		}
+	}
+	if cd.limiter != nil || cd.signer != nil {
+		d = cd
+	}
+
+	q := &c.Queue
+	var settings []QueueSetting
+	if q.Workers > 0 {
+		settings = append(settings, Workers(q.Workers))
+	}
+	if q.MinWorkers > 0 {
+		settings = append(settings, MinWorkers(q.MinWorkers))
+	}
+	if q.Buffer > 0 {
+		settings = append(settings, Buffer(q.Buffer))
+	}
+	if q.IdleTimeout > 0 {
+		settings = append(settings, IdleTimeout(time.Duration(q.IdleTimeout)))
+	}
+	if q.MaxPending > 0 {
+		settings = append(settings, MaxPending(q.MaxPending))
+	}
+	if q.MaxPendingBytes > 0 {
+		settings = append(settings, MaxPendingBytes(q.MaxPendingBytes))
+	}
+	if q.NonBlocking {
+		settings = append(settings, NonBlocking())
+	}
+	if q.SpoolDir != "" {
+		s, err := NewDirStore(q.SpoolDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		settings = append(settings, Spool(s))
+	}
+	if r := c.Retry; r != nil {
+		settings = append(settings, Retry(&RetryPolicy{
+			MaxAttempts:    r.MaxAttempts,
+			InitialBackoff: time.Duration(r.InitialBackoff),
+			MaxBackoff:     time.Duration(r.MaxBackoff),
+			Multiplier:     r.Multiplier,
+			Jitter:         r.Jitter,
+		}))
+	}
+	return d, settings, nil
+}
+
+// loadPrivateKey reads a PEM encoded private key.
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("gomail: no PEM data in %s", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("gomail: invalid private key in %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("gomail: unsupported private key in %s", path)
+	}
+	return signer, nil
+}
+
+// configDialer applies the rate limit and DKIM signing of a configuration to
+// the emails sent by another transport.
+type configDialer struct {
+	d       SendDialer
+	limiter Limiter
+	signer  *DKIMSigner
+}
+
+func (d *configDialer) Dial() (SendCloser, error) {
+	s, err := d.d.Dial()
+	if err != nil {
+		return nil, err
+	}
+	return &configSender{s, d}, nil
+}
+
+type configSender struct {
+	SendCloser
+	d *configDialer
+}
+
+func (s *configSender) Send(from string, to []string, msg io.WriterTo) error {
+	return s.SendEnvelope(&Envelope{From: from, To: to}, msg)
+}
+
+func (s *configSender) SendEnvelope(e *Envelope, msg io.WriterTo) error {
+	if s.d.limiter != nil {
+		if err := s.d.limiter.Wait(); err != nil {
+			return err
+		}
+	}
+	if s.d.signer != nil {
+		return (&DKIMSender{Sender: s.SendCloser, Signer: s.d.signer}).SendEnvelope(e, msg)
+	}
+	return sendEnvelope(s.SendCloser, e, msg)
+}
+
+// Reload replaces the transport and the queue settings of the Mailer with those
+// of c. If the Mailer is started, its queue is shut down like with Stop, then
+// a new queue is started with the new configuration. Emails enqueued during
+// the reload wait for the new queue. If c is invalid, the Mailer is left
+// unchanged.
+func (m *Mailer) Reload(ctx context.Context, c *MailerConfig) error {
+	d, settings, err := c.build()
+	if err != nil {
+		return err
+	}
+
+	m.stopMu.Lock()
+	defer m.stopMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.d, m.settings = d, settings
+	if m.q == nil {
+		return nil
+	}
+	err = m.q.Shutdown(ctx)
+	m.q = NewQueue(m.d, m.settings...)
+	return err
+}
+
+// ReloadOnSIGHUP reloads the Mailer with the configuration file at path each
+// time the process receives SIGHUP, until ctx is done. The errors, including
+// invalid configurations, are passed to errorFunc if not nil and the previous
+// configuration is kept.
+func (m *Mailer) ReloadOnSIGHUP(ctx context.Context, path string, errorFunc func(err error)) {
+	ch := make(chan os.Signal, 1)
+	signalNotify(ch, syscall.SIGHUP)
+	go func() {
+		defer signalStop(ch)
+		for {
+			select {
+			case <-ch:
+				c, err := LoadMailerConfig(path)
+				if err == nil {
+					err = m.Reload(ctx, c)
+				}
+				if err != nil && errorFunc != nil {
+					errorFunc(err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stubbed out for tests.
+var (
+	signalNotify = signal.Notify
+	signalStop   = signal.Stop
+)
@@ -0,0 +1,268 @@
+package gomail
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func testConfigDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestKey(t *testing.T, path string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, path, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+}
+
+func TestLoadMailerConfig(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	keyFile := filepath.Join(dir, "dkim.pem")
+	writeTestKey(t, keyFile)
+	path := filepath.Join(dir, "mailer.json")
+	writeTestFile(t, path, `{
+		"transport": {"type": "smtp", "host": "smtp.example.com", "port": 465,
+			"username": "user", "password": "pass", "local_name": "mx.example.com"},
+		"queue": {"workers": 4, "idle_timeout": "10s", "max_pending": 50,
+			"spool_dir": "`+filepath.Join(dir, "spool")+`"},
+		"retry": {"max_attempts": 3, "initial_backoff": "2s", "jitter": 0.1},
+		"rate_limit": {"count": 10, "interval": "1m"},
+		"dkim": {"keys": [{"domain": "Example.com", "selector": "s1", "key_file": "`+keyFile+`"}]}
+	}`)
+
+	c, err := LoadMailerConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Queue.IdleTimeout != Duration(10*time.Second) {
+		t.Errorf("Invalid idle timeout, got %v", time.Duration(c.Queue.IdleTimeout))
+	}
+
+	d, settings, err := c.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd, ok := d.(*configDialer)
+	if !ok {
+		t.Fatalf("Invalid dialer type %T", d)
+	}
+	dialer, ok := cd.d.(*Dialer)
+	if !ok {
+		t.Fatalf("Invalid transport type %T", cd.d)
+	}
+	if dialer.Host != "smtp.example.com" || !dialer.SSL || dialer.LocalName != "mx.example.com" {
+		t.Errorf("Invalid dialer %+v", dialer)
+	}
+	if cd.limiter == nil {
+		t.Error("The rate limit is not applied")
+	}
+	if k, err := cd.signer.Keys.SigningKey("example.com"); err != nil || k.Selector != "s1" {
+		t.Errorf("Invalid DKIM key, got %v, %v", k, err)
+	}
+
+	q := new(Queue)
+	for _, s := range settings {
+		s(q)
+	}
+	if q.workers != 4 || q.idleTimeout != 10*time.Second || q.maxPending != 50 {
+		t.Errorf("Invalid queue settings %+v", q)
+	}
+	if q.store == nil {
+		t.Error("The spool directory is not used")
+	}
+	if q.retry == nil || q.retry.MaxAttempts != 3 || q.retry.InitialBackoff != 2*time.Second {
+		t.Errorf("Invalid retry policy %+v", q.retry)
+	}
+}
+
+func TestLoadMailerConfigInvalid(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	tests := []string{
+		`{"transport": {"type": "smtp", "host": "example.com", "port": 25}, "typo": 1}`,
+		`{"transport": {"type": "smtp", "port": 25}}`,
+		`{"transport": {"type": "smtp", "host": "example.com", "port": 0}}`,
+		`{"transport": {"type": "carrier-pigeon"}}`,
+		`{"transport": {"type": "mailgun", "api_key": "key"}}`,
+		`{"transport": {"type": "sendmail"}, "queue": {"idle_timeout": "soon"}}`,
+		`{"transport": {"type": "sendmail"}, "retry": {"max_attempts": 0}}`,
+		`{"transport": {"type": "sendmail"}, "retry": {"max_attempts": 2, "jitter": 2}}`,
+		`{"transport": {"type": "sendmail"}, "rate_limit": {"count": 10}}`,
+		`{"transport": {"type": "sendmail"}, "dkim": {"keys": []}}`,
+		`{"transport": {"type": "sendmail"}, "dkim": {"body_canonicalization": "loose",
+			"keys": [{"domain": "example.com", "selector": "s1", "key_file": "key.pem"}]}}`,
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, "mailer.json")
+		writeTestFile(t, path, test)
+		if _, err := LoadMailerConfig(path); err == nil {
+			t.Errorf("LoadMailerConfig should fail with %s", test)
+		}
+	}
+}
+
+func TestConfigDialer(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+	keyFile := filepath.Join(dir, "dkim.pem")
+	writeTestKey(t, keyFile)
+
+	r := new(envelopeRecorder)
+	signer, err := loadPrivateKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &configDialer{
+		d:       r,
+		limiter: NewRateLimiter(10, time.Second),
+		signer: &DKIMSigner{Keys: KeyMap{
+			"example.com": {Selector: "s1", Signer: signer},
+		}},
+	}
+	s, err := d.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Send(s, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.bodies) != 1 || !strings.HasPrefix(r.bodies[0], "DKIM-Signature: ") {
+		t.Errorf("The email should be signed, got %q", r.bodies)
+	}
+
+	m := getTestMessage()
+	RequireTLS()(m)
+	if err := Send(s, m); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.envelopes) != 2 || !r.envelopes[1].Options.RequireTLS {
+		t.Errorf("The RequireTLS setting should be kept, got %+v", r.envelopes)
+	}
+}
+
+func TestConfigUnixSocket(t *testing.T) {
+	c := &MailerConfig{Transport: TransportConfig{Type: "smtp", Network: "unix", Address: "/var/run/smtpd.sock"}}
+	sd, _, err := c.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := sd.(*Dialer)
+	if d.Host != "localhost" || d.Network != "unix" || d.Address != "/var/run/smtpd.sock" {
+		t.Errorf("Invalid dialer, got %+v", d)
+	}
+}
+
+func TestMailerReload(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	d := &fakeDialer{}
+	m := NewMailer(d)
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := &MailerConfig{Transport: TransportConfig{Type: "maildir"}}
+	if err := m.Reload(context.Background(), invalid); err == nil {
+		t.Error("Reload should fail with an invalid configuration")
+	}
+	if err := m.Enqueue(testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &MailerConfig{Transport: TransportConfig{Type: "maildir", Path: dir}}
+	if err := m.Reload(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.sent) != 1 {
+		t.Errorf("The emails enqueued before the reload should be sent, got %d", len(d.sent))
+	}
+	if err := m.Enqueue(testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := readDirNames(filepath.Join(dir, "new")); err != nil {
+		t.Fatal(err)
+	} else if len(names) != 1 {
+		t.Errorf("Invalid number of delivered emails, got %d, want 1", len(names))
+	}
+}
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	dir, cleanup := testConfigDir(t)
+	defer cleanup()
+
+	var sig chan<- os.Signal
+	signalNotify = func(c chan<- os.Signal, s ...os.Signal) { sig = c }
+	signalStop = func(c chan<- os.Signal) {}
+	defer func() {
+		signalNotify = signal.Notify
+		signalStop = signal.Stop
+	}()
+
+	path := filepath.Join(dir, "mailer.json")
+	writeTestFile(t, path, `{"transport": {"type": "sendmail"}, "retry": {"max_attempts": 0}}`)
+	m := NewMailer(&fakeDialer{})
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	m.ReloadOnSIGHUP(ctx, path, func(err error) { errs <- err })
+
+	// The invalid configuration is reported.
+	sig <- syscall.SIGHUP
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("The invalid configuration should be reported")
+	}
+
+	q := m.Queue()
+	writeTestFile(t, path, `{"transport": {"type": "mbox", "path": "`+filepath.Join(dir, "mbox")+`"}}`)
+	sig <- syscall.SIGHUP
+	for i := 0; m.Queue() == q; i++ {
+		if i == 100 {
+			t.Fatal("The mailer should be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := m.d.(*Mbox); !ok {
+		t.Errorf("Invalid transport %T", m.d)
+	}
+}
@@ -0,0 +1,227 @@
+package gomail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The conformance tests render emails and parse them again with the standard
+// library only, without the parser of the package, to check that other MIME
+// implementations read what was written.
+//
+// If the GOMAIL_VALIDATOR environment variable is set, it is run as a shell
+// command with each rendered email on its standard input, for example an
+// external MIME validator, and the test fails if it exits with an error.
+
+// conformancePart is a leaf part of an email as read by the standard library.
+type conformancePart struct {
+	ContentType string
+	Filename    string
+	Body        string
+}
+
+func TestConformance(t *testing.T) {
+	longLine := strings.Repeat("Lorem ipsum dolor sit amet, ", 20)
+	tests := []struct {
+		name    string
+		msg     func() *Message
+		subject string
+		parts   []conformancePart
+	}{
+		{
+			name: "plain",
+			msg: func() *Message {
+				m := NewMessage()
+				m.SetBody("text/plain", "Hello\r\nWorld")
+				return m
+			},
+			subject: "Conformance",
+			parts:   []conformancePart{{"text/plain", "", "Hello\r\nWorld"}},
+		},
+		{
+			name: "non-ASCII and long lines",
+			msg: func() *Message {
+				m := NewMessage()
+				m.SetHeader("Subject", "Café ¡Olé! "+strings.Repeat("é", 40))
+				m.SetBody("text/plain", "Café "+longLine)
+				return m
+			},
+			subject: "Café ¡Olé! " + strings.Repeat("é", 40),
+			parts:   []conformancePart{{"text/plain", "", "Café " + longLine}},
+		},
+		{
+			name: "base64",
+			msg: func() *Message {
+				m := NewMessage(SetEncoding(Base64))
+				m.SetHeader("Subject", "Ünïcödé")
+				m.SetBody("text/plain", "Ünïcödé "+longLine)
+				return m
+			},
+			subject: "Ünïcödé",
+			parts:   []conformancePart{{"text/plain", "", "Ünïcödé " + longLine}},
+		},
+		{
+			name: "alternative with attachment and embedded image",
+			msg: func() *Message {
+				m := NewMessage()
+				m.SetBody("text/plain", "Text")
+				m.AddAlternative("text/html", `<p>HTML <img src="cid:logo.png"></p>`)
+				m.Attach("report.pdf", SetCopyFunc(func(w io.Writer) error {
+					_, err := w.Write([]byte("%PDF\x00\xff"))
+					return err
+				}))
+				m.Embed("logo.png", SetCopyFunc(func(w io.Writer) error {
+					_, err := w.Write([]byte("\x89PNG"))
+					return err
+				}))
+				return m
+			},
+			subject: "Conformance",
+			parts: []conformancePart{
+				{"text/plain", "", "Text"},
+				{"text/html", "", `<p>HTML <img src="cid:logo.png"></p>`},
+				{"image/png", "logo.png", "\x89PNG"},
+				{"application/pdf", "report.pdf", "%PDF\x00\xff"},
+			},
+		},
+		{
+			name: "non-ASCII file name",
+			msg: func() *Message {
+				m := NewMessage()
+				m.SetBody("text/plain", "See attachment")
+				m.Attach("/tmp/résumé.txt", SetCopyFunc(func(w io.Writer) error {
+					_, err := w.Write([]byte("CV"))
+					return err
+				}))
+				return m
+			},
+			subject: "Conformance",
+			parts: []conformancePart{
+				{"text/plain", "", "See attachment"},
+				{"text/plain", "résumé.txt", "CV"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		m := test.msg()
+		m.SetAddressHeader("From", testFrom, "Señor Sender")
+		m.SetHeader("To", testTo1)
+		if len(m.GetHeader("Subject")) == 0 {
+			m.SetHeader("Subject", "Conformance")
+		}
+		var buf bytes.Buffer
+		if _, err := m.WriteTo(&buf); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		runValidator(t, test.name, buf.Bytes())
+
+		msg, err := mail.ReadMessage(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Errorf("%s: net/mail could not read the email: %v", test.name, err)
+			continue
+		}
+		dec := new(mime.WordDecoder)
+		if subject, err := dec.DecodeHeader(msg.Header.Get("Subject")); err != nil || subject != test.subject {
+			t.Errorf("%s: invalid subject, got %q (%v), want %q", test.name, subject, err, test.subject)
+		}
+		if from, err := msg.Header.AddressList("From"); err != nil || len(from) != 1 || from[0].Name != "Señor Sender" {
+			t.Errorf("%s: invalid From field, got %v (%v)", test.name, from, err)
+		}
+		if _, err := msg.Header.Date(); err != nil {
+			t.Errorf("%s: invalid Date field: %v", test.name, err)
+		}
+
+		var parts []conformancePart
+		if err := readConformancePart(&parts, msg.Header, msg.Body); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(parts, test.parts) {
+			t.Errorf("%s: invalid parts, got %q, want %q", test.name, parts, test.parts)
+		}
+	}
+}
+
+// readConformancePart reads a part and appends its leaves to parts, depth
+// first.
+func readConformancePart(parts *[]conformancePart, h map[string][]string, body io.Reader) error {
+	get := func(k string) string {
+		if v := h[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			// mime/multipart decodes the quoted-printable parts itself
+			// and removes their Content-Transfer-Encoding field.
+			if err := readConformancePart(parts, p.Header, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	var filename string
+	if disp := get("Content-Disposition"); disp != "" {
+		_, dparams, err := mime.ParseMediaType(disp)
+		if err != nil {
+			return err
+		}
+		filename = dparams["filename"]
+		if filename, err = new(mime.WordDecoder).DecodeHeader(filename); err != nil {
+			return err
+		}
+	}
+	*parts = append(*parts, conformancePart{mediaType, filename, string(b)})
+	return nil
+}
+
+// runValidator runs the command of the GOMAIL_VALIDATOR environment variable with
+// the email on its standard input.
+func runValidator(t *testing.T, name string, email []byte) {
+	cmd := os.Getenv("GOMAIL_VALIDATOR")
+	if cmd == "" {
+		return
+	}
+	c := exec.Command("sh", "-c", cmd)
+	c.Stdin = bytes.NewReader(email)
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Errorf("%s: the validator failed: %v\n%s", name, err, out)
+	}
+}
@@ -0,0 +1,154 @@
+package gomail
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+)
+
+var dataImageRegexp = regexp.MustCompile(`(?i)\bsrc\s*=\s*("|')data:(image/[\w.+-]+);base64,([^"']*)("|')`)
+
+// ExtractDataImages replaces the data URI images of the text/html bodies, like
+// <img src="data:image/png;base64,...">, with embedded files referenced by a
+// cid: URI. The emails are smaller and many clients, Outlook and Gmail among
+// them, do not display data URI images. The identical images are embedded
+// once.
+func (m *Message) ExtractDataImages() error {
+	names := make(map[string]bool)
+	for _, f := range m.embedded {
+		names[f.Name] = true
+	}
+
+	for _, p := range m.parts {
+		if !strings.HasPrefix(p.contentType, "text/html") {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := p.copier(&buf); err != nil {
+			return err
+		}
+
+		var err error
+		body := dataImageRegexp.ReplaceAllStringFunc(buf.String(), func(s string) string {
+			sub := dataImageRegexp.FindStringSubmatch(s)
+			data, derr := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(sub[3]), ""))
+			if derr != nil {
+				if err == nil {
+					err = fmt.Errorf("gomail: invalid data URI image: %w", derr)
+				}
+				return s
+			}
+
+			sum := sha256.Sum256(data)
+			name := "image-" + hex.EncodeToString(sum[:6]) + imageExtension(sub[2])
+			if !names[name] {
+				names[name] = true
+				m.Embed(name, setContent(data))
+			}
+			return "src=" + sub[1] + "cid:" + name + sub[4]
+		})
+		if err != nil {
+			return err
+		}
+		p.copier = newCopier(body)
+	}
+	return nil
+}
+
+func imageExtension(mediaType string) string {
+	switch strings.ToLower(mediaType) {
+	case "image/png":
+		return ".png"
+	case "image/jpeg", "image/jpg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/svg+xml":
+		return ".svg"
+	}
+	return ""
+}
+
+var cidRegexp = regexp.MustCompile(`cid:([^"'\s)>]+)`)
+
+// InlineImages is the opposite of ExtractDataImages: it replaces the cid:
+// references of the text/html bodies to the embedded images of at most maxSize
+// bytes with data URIs, for the clients preferring them, and removes these
+// images from the embedded files. The other embedded files are kept.
+func (m *Message) InlineImages(maxSize int) error {
+	uris := make(map[string]string)
+	var kept []*file
+	for _, f := range m.embedded {
+		mediaType := f.mediaType()
+		if v, ok := f.Header["Content-Type"]; ok && len(v) > 0 {
+			mediaType = v[0]
+		}
+		if mediaType, _, _ = mime.ParseMediaType(mediaType); !strings.HasPrefix(mediaType, "image/") {
+			kept = append(kept, f)
+			continue
+		}
+
+		var buf bytes.Buffer
+		err := f.CopyFunc(&limitedWriter{w: &buf, n: maxSize})
+		if errors.Is(err, errTooLarge) {
+			kept = append(kept, f)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		id := f.Name
+		if v, ok := f.Header["Content-ID"]; ok && len(v) > 0 {
+			id = strings.TrimSuffix(strings.TrimPrefix(v[0], "<"), ">")
+		}
+		uris[id] = "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+	if len(uris) == 0 {
+		return nil
+	}
+
+	for _, p := range m.parts {
+		if !strings.HasPrefix(p.contentType, "text/html") {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := p.copier(&buf); err != nil {
+			return err
+		}
+		body := cidRegexp.ReplaceAllStringFunc(buf.String(), func(s string) string {
+			if uri, ok := uris[s[len("cid:"):]]; ok {
+				return uri
+			}
+			return s
+		})
+		p.copier = newCopier(body)
+	}
+	m.embedded = kept
+	return nil
+}
+
+var errTooLarge = errors.New("gomail: the image is too large")
+
+// A limitedWriter fails with errTooLarge after n bytes.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, errTooLarge
+	}
+	w.n -= len(p)
+	return w.w.Write(p)
+}
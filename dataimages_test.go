@@ -0,0 +1,80 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func htmlBody(t *testing.T, m *Message) string {
+	for _, p := range m.parts {
+		if strings.HasPrefix(p.contentType, "text/html") {
+			var buf bytes.Buffer
+			if err := p.copier(&buf); err != nil {
+				t.Fatal(err)
+			}
+			return buf.String()
+		}
+	}
+	t.Fatal("No HTML body")
+	return ""
+}
+
+func TestExtractDataImages(t *testing.T) {
+	m := NewMessage()
+	m.SetBody("text/plain", `src="data:image/png;base64,UE5H"`)
+	m.AddAlternative("text/html", `<img src="data:image/png;base64,UE5H"><img src='data:image/png;base64,UE5H'>`+
+		`<img SRC="data:image/gif;base64,R0lG`+"\r\n"+`ODk=">`)
+	if err := m.ExtractDataImages(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := m.EmbeddedFiles()
+	if len(files) != 2 {
+		t.Fatalf("Invalid number of embedded files, got %d, want 2", len(files))
+	}
+	png, gif := files[0].Name, files[1].Name
+	if !strings.HasSuffix(png, ".png") || !strings.HasSuffix(gif, ".gif") {
+		t.Errorf("Invalid names, got %q and %q", png, gif)
+	}
+	want := `<img src="cid:` + png + `"><img src='cid:` + png + `'><img src="cid:` + gif + `">`
+	if got := htmlBody(t, m); got != want {
+		t.Errorf("Invalid HTML body, got %q, want %q", got, want)
+	}
+	var buf bytes.Buffer
+	m.embedded[1].CopyFunc(&buf)
+	if buf.String() != "GIF89" {
+		t.Errorf("Invalid image, got %q", buf.String())
+	}
+
+	m.AddAlternative("text/html", `<img src="data:image/png;base64,!!!">`)
+	if err := m.ExtractDataImages(); err == nil {
+		t.Error("ExtractDataImages should fail with invalid base64")
+	}
+}
+
+func TestInlineImages(t *testing.T) {
+	copier := func(s string) FileSetting {
+		return SetCopyFunc(func(w io.Writer) error {
+			_, err := io.WriteString(w, s)
+			return err
+		})
+	}
+	m := NewMessage()
+	m.SetBody("text/html", `<img src="cid:small.png"><img src="cid:large.jpg"><a href="cid:doc.pdf">`)
+	m.Embed("small.png", copier("PNG"))
+	m.Embed("large.jpg", copier(strings.Repeat("J", 100)))
+	m.Embed("doc.pdf", copier("PDF"))
+	if err := m.InlineImages(10); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<img src="data:image/png;base64,UE5H"><img src="cid:large.jpg"><a href="cid:doc.pdf">`
+	if got := htmlBody(t, m); got != want {
+		t.Errorf("Invalid HTML body, got %q, want %q", got, want)
+	}
+	if files := m.EmbeddedFiles(); len(files) != 2 || files[0].Name != "large.jpg" || files[1].Name != "doc.pdf" {
+		t.Errorf("Invalid embedded files, got %+v", files)
+	}
+}
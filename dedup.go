@@ -0,0 +1,155 @@
+package gomail
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A DedupSender is a Sender that suppresses the identical emails sent to the
+// same recipient within a time window, for example to protect the recipients
+// of alerts from a buggy loop sending the same alert again and again. The
+// suppressed emails are not sent and no error is returned.
+//
+// Two emails are identical if their header fields, except the Date,
+// Message-ID and recipient fields, their bodies and their files are the same.
+// The emails not built with Message are compared byte for byte.
+type DedupSender struct {
+	// Sender is the Sender used to send the emails.
+	Sender Sender
+	// Window is how long an email sent to a recipient suppresses its
+	// duplicates.
+	Window time.Duration
+	// OnSuppress, if set, is called with the envelope of an email and the
+	// recipients it was not sent to because they already received it.
+	OnSuppress func(e *Envelope, suppressed []string)
+
+	mu        sync.Mutex
+	sent      map[string]time.Time
+	lastSweep time.Time
+}
+
+// Send implements Sender.
+func (s *DedupSender) Send(from string, to []string, msg io.WriterTo) error {
+	return s.SendEnvelope(&Envelope{From: from, To: to}, msg)
+}
+
+// SendEnvelope implements EnvelopeSender.
+func (s *DedupSender) SendEnvelope(e *Envelope, msg io.WriterTo) error {
+	sum, err := contentHash(msg)
+	if err != nil {
+		return err
+	}
+
+	var to, suppressed, keys []string
+	s.mu.Lock()
+	t := now()
+	if s.sent == nil {
+		s.sent = make(map[string]time.Time)
+	}
+	s.sweep(t)
+	for _, addr := range e.To {
+		key := strings.ToLower(addr) + " " + sum
+		if at, ok := s.sent[key]; ok && t.Sub(at) < s.Window {
+			suppressed = append(suppressed, addr)
+			continue
+		}
+		// The recipient is reserved so a concurrent duplicate is suppressed.
+		s.sent[key] = t
+		to = append(to, addr)
+		keys = append(keys, key)
+	}
+	s.mu.Unlock()
+
+	if len(suppressed) > 0 && s.OnSuppress != nil {
+		s.OnSuppress(e, suppressed)
+	}
+	if len(to) == 0 {
+		return nil
+	}
+
+	env := *e
+	env.To = to
+	if err := sendEnvelope(s.Sender, &env, msg); err != nil {
+		// The email can be sent again if it failed.
+		s.mu.Lock()
+		for _, key := range keys {
+			if s.sent[key] == t {
+				delete(s.sent, key)
+			}
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// sweep removes the expired entries, at most once per window.
+func (s *DedupSender) sweep(t time.Time) {
+	if t.Sub(s.lastSweep) < s.Window {
+		return
+	}
+	s.lastSweep = t
+	for key, at := range s.sent {
+		if t.Sub(at) >= s.Window {
+			delete(s.sent, key)
+		}
+	}
+}
+
+// contentHash returns the hash of the content of msg, see DedupSender.
+func contentHash(msg io.WriterTo) (string, error) {
+	h := sha256.New()
+	m, ok := msg.(*Message)
+	if !ok {
+		if _, err := msg.WriteTo(h); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	hashHeader(h, m.header, "Date", "Message-Id", "To", "Cc", "Bcc")
+	for _, p := range m.parts {
+		fmt.Fprintf(h, "\x00part %s\x00", p.contentType)
+		if err := p.copier(h); err != nil {
+			return "", err
+		}
+	}
+	for _, list := range [][]*file{m.attachments, m.embedded} {
+		for _, f := range list {
+			fmt.Fprintf(h, "\x00file %s\x00", f.Name)
+			hashHeader(h, f.Header)
+			if err := f.CopyFunc(h); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashHeader writes the fields of header to h in a stable order, except the
+// ignored fields.
+func hashHeader(h hash.Hash, header map[string][]string, ignored ...string) {
+	fields := make([]string, 0, len(header))
+	for k := range header {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+fields:
+	for _, k := range fields {
+		for _, i := range ignored {
+			if strings.EqualFold(k, i) {
+				continue fields
+			}
+		}
+		for _, v := range header[k] {
+			fmt.Fprintf(h, "%s: %s\x00", k, v)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedupSender(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	current := time.Date(2014, 6, 25, 17, 46, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	r := new(envelopeRecorder)
+	var suppressed []string
+	s := &DedupSender{
+		Sender:     r,
+		Window:     time.Hour,
+		OnSuppress: func(e *Envelope, to []string) { suppressed = append(suppressed, to...) },
+	}
+
+	if err := Send(s, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	// The Date, Message-ID and recipient fields are ignored.
+	m := getTestMessage()
+	m.SetHeader("To", testTo2, "to3@example.com")
+	m.SetDateHeader("Date", current.Add(time.Minute))
+	m.SetHeader("Message-ID", "<other@example.com>")
+	if err := Send(s, m); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.envelopes) != 2 || !reflect.DeepEqual(r.envelopes[1].To, []string{"to3@example.com"}) {
+		t.Errorf("Only the new recipient should receive the email, got %+v", r.envelopes)
+	}
+	if !reflect.DeepEqual(suppressed, []string{testTo2}) {
+		t.Errorf("Invalid suppressed recipients, got %v", suppressed)
+	}
+
+	// A different email is sent.
+	m = getTestMessage()
+	m.SetBody("text/plain", "Other message")
+	if err := Send(s, m); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.envelopes) != 3 {
+		t.Errorf("A different email should be sent, got %d emails", len(r.envelopes))
+	}
+
+	// The duplicates are sent again after the window.
+	current = current.Add(time.Hour)
+	if err := Send(s, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.envelopes) != 4 || len(s.sent) != 2 {
+		t.Errorf("The email should be sent again, got %d emails and %d entries", len(r.envelopes), len(s.sent))
+	}
+}
+
+func TestDedupSenderFailure(t *testing.T) {
+	fail := true
+	s := &DedupSender{
+		Sender: mockSender(func(string, []string, io.WriterTo) error {
+			if fail {
+				return errors.New("failure")
+			}
+			return nil
+		}),
+		Window: time.Hour,
+	}
+	if err := Send(s, getTestMessage()); err == nil {
+		t.Fatal("Send should fail")
+	}
+	// The failed email is not a duplicate.
+	fail = false
+	var suppressed bool
+	s.OnSuppress = func(*Envelope, []string) { suppressed = true }
+	if err := Send(s, getTestMessage()); err != nil || suppressed {
+		t.Errorf("The email should be sent again, got %v", err)
+	}
+}
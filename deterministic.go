@@ -0,0 +1,90 @@
+package gomail
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// defaultHeaderOrder is the order of the header fields written by
+// Deterministic, before the other fields sorted by name.
+var defaultHeaderOrder = []string{"From", "Sender", "Reply-To", "To", "Cc", "Subject"}
+
+// SetHeaderOrder is a message setting to write the header fields of the email
+// in a stable order instead of a random one: the given fields first, in this
+// order, matched whatever their case, and then the others sorted by name. The
+// Mime-Version, Date and Message-ID fields added automatically are always
+// written first and the Content-Type and Content-Transfer-Encoding fields of
+// the body last.
+func SetHeaderOrder(fields ...string) MessageSetting {
+	return func(m *Message) {
+		m.sortHeaders = true
+		m.headerOrder = fields
+	}
+}
+
+// Deterministic is a message setting to write the email the same way each
+// time, for golden-file tests: the header fields are written in a stable
+// order, From, Sender, Reply-To, To, Cc and Subject first, and the generated
+// Message-ID and multipart boundaries are numbered from 1 on each write,
+// replacing the IDGenerator if any. The Date field is still the current time:
+// set it with SetDateHeader or use DisableDate.
+func Deterministic() MessageSetting {
+	return func(m *Message) {
+		SetHeaderOrder(defaultHeaderOrder...)(m)
+		m.deterministic = true
+	}
+}
+
+// newWriter returns a messageWriter writing m to w.
+func (m *Message) newWriter(w io.Writer) *messageWriter {
+	mw := &messageWriter{
+		w:           w,
+		idGenerator: m.idGenerator,
+		sortHeaders: m.sortHeaders,
+		headerOrder: m.headerOrder,
+		fieldNames:  m.fieldNames,
+		rawFields:   m.rawFields,
+	}
+	if m.deterministic {
+		mw.idGenerator = new(sequentialIDs)
+	}
+	return mw
+}
+
+// sequentialIDs generates numbered identifiers, as long as ULIDs.
+type sequentialIDs int
+
+func (n *sequentialIDs) NewID() (string, error) {
+	*n++
+	return fmt.Sprintf("%026d", int(*n)), nil
+}
+
+// headerKeys returns the names of the fields of h in the order they are
+// written.
+func (w *messageWriter) headerKeys(h map[string][]string) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	if !w.sortHeaders {
+		return keys
+	}
+
+	rank := func(k string) int {
+		for i, f := range w.headerOrder {
+			if strings.EqualFold(f, k) {
+				return i
+			}
+		}
+		return len(w.headerOrder)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if ri, rj := rank(keys[i]), rank(keys[j]); ri != rj {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
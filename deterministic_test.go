@@ -0,0 +1,76 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDeterministic(t *testing.T) {
+	m := NewMessage(Deterministic(), DisableDate())
+	m.SetHeader("X-Mailer", "gomail")
+	m.SetHeader("Subject", "Hello")
+	m.SetHeader("Cc", "cc@example.com")
+	m.SetHeader("To", testTo1)
+	m.SetHeader("From", testFrom)
+	m.SetHeader("Bcc", "bcc@example.com")
+	m.SetHeader("Content-Language", "en")
+	m.SetBody("text/plain", "Hello")
+	m.AddAlternative("text/html", "<p>Hello</p>")
+	m.Attach("test.txt", SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write([]byte("Content"))
+		return err
+	}))
+
+	var a, b bytes.Buffer
+	if _, err := m.WriteTo(&a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Clone().WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Errorf("The output should be deterministic:\n%s\n%s", a.Bytes(), b.Bytes())
+	}
+
+	want := "Mime-Version: 1.0\r\n" +
+		"Message-ID: <00000000000000000000000001@example.com>\r\n" +
+		"From: " + testFrom + "\r\n" +
+		"To: " + testTo1 + "\r\n" +
+		"Cc: cc@example.com\r\n" +
+		"Subject: Hello\r\n" +
+		"Content-Language: en\r\n" +
+		"X-Mailer: gomail\r\n" +
+		"Content-Type: multipart/mixed;\r\n boundary=00000000000000000000000002\r\n"
+	if !strings.HasPrefix(a.String(), want) {
+		t.Errorf("Invalid header, got:\n%s\nwant:\n%s", a.String(), want)
+	}
+	if !strings.Contains(a.String(), "boundary=00000000000000000000000003") {
+		t.Errorf("The boundaries should be numbered, got:\n%s", a.String())
+	}
+}
+
+func TestSetHeaderOrder(t *testing.T) {
+	m := NewMessage(SetHeaderOrder("subject", "From"))
+	m.SetHeader("To", testTo1)
+	m.SetHeader("From", testFrom)
+	m.SetHeader("Subject", "Hello")
+	m.SetHeader("Cc", "cc@example.com")
+	m.SetBody("text/plain", "Hello")
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if i := strings.IndexByte(line, ':'); i > 0 && !strings.HasPrefix(line, " ") {
+			fields = append(fields, line[:i])
+		}
+	}
+	got := strings.Join(fields, ",")
+	if want := "Mime-Version,Date,Message-ID,Subject,From,Cc,To,Content-Transfer-Encoding,Content-Type"; got != want {
+		t.Errorf("Invalid order, got %s, want %s", got, want)
+	}
+}
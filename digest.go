@@ -0,0 +1,156 @@
+package gomail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Digester accumulates the emails sent to each recipient and sends them
+// periodically as a single digest email. The digest is a multipart/digest
+// email whose parts are the accumulated emails.
+type Digester struct {
+	// Sender is the Sender used to send the digests.
+	Sender Sender
+	// From is the address the digests are sent from.
+	From string
+	// Subject returns the subject of a digest sent to the given recipient and
+	// containing n emails. If nil, a generic subject is used.
+	Subject func(to string, n int) string
+	// ErrorFunc is called with the errors occurring when the digests are
+	// sent in the background. The emails of a digest that could not be sent
+	// are kept for the next flush.
+	ErrorFunc func(err error)
+
+	mu      sync.Mutex
+	pending map[string][][]byte
+	order   []string
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Add adds an email to the next digest sent to the given recipient. The email
+// is rendered immediately so it can be reset or modified after Add returns.
+func (d *Digester) Add(to string, m *Message) error {
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.add(to, buf.Bytes())
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *Digester) add(to string, msg ...[]byte) {
+	if d.pending == nil {
+		d.pending = make(map[string][][]byte)
+	}
+	if _, ok := d.pending[to]; !ok {
+		d.order = append(d.order, to)
+	}
+	d.pending[to] = append(d.pending[to], msg...)
+}
+
+// Flush sends the digests of all the recipients having pending emails.
+func (d *Digester) Flush() error {
+	d.mu.Lock()
+	pending, order := d.pending, d.order
+	d.pending, d.order = nil, nil
+	d.mu.Unlock()
+
+	var firstErr error
+	for _, to := range order {
+		if err := d.send(to, pending[to]); err != nil {
+			d.mu.Lock()
+			d.add(to, pending[to]...)
+			d.mu.Unlock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("gomail: could not send digest to %q: %w", to, err)
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// Start starts flushing the digests in the background at the given interval.
+// The fields of the Digester must not be modified after Start is called.
+func (d *Digester) Start(interval time.Duration) {
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := d.Flush(); err != nil && d.ErrorFunc != nil {
+					d.ErrorFunc(err)
+				}
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background flushing started by Start and sends the pending
+// digests.
+func (d *Digester) Close() error {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+		d.stop = nil
+	}
+	return d.Flush()
+}
+
+func (d *Digester) send(to string, msgs [][]byte) error {
+	m := NewMessage()
+	m.SetHeader("From", d.From)
+	m.SetHeader("To", to)
+	if d.Subject != nil {
+		m.SetHeader("Subject", d.Subject(to, len(msgs)))
+	} else {
+		m.SetHeader("Subject", "Digest of "+strconv.Itoa(len(msgs))+" messages")
+	}
+
+	from, err := m.getFrom()
+	if err != nil {
+		return err
+	}
+	rcpts, err := m.getRecipients()
+	if err != nil {
+		return err
+	}
+
+	return d.Sender.Send(from, rcpts, &digestMessage{Message: m, parts: msgs})
+}
+
+// digestMessage is a multipart/digest email containing the given emails.
+type digestMessage struct {
+	*Message
+	parts [][]byte
+}
+
+func (m *digestMessage) WriteTo(w io.Writer) (int64, error) {
+	mw := &messageWriter{w: w}
+	mw.writeMessageHeader(m.Message)
+	mw.openMultipart("digest", nil)
+	for _, p := range m.parts {
+		// Parts of a multipart/digest are message/rfc822 by default.
+		mw.createPart(nil)
+		if mw.err != nil {
+			break
+		}
+		_, mw.err = mw.partWriter.Write(p)
+	}
+	mw.closeMultipart()
+	return mw.n, mw.err
+}
@@ -0,0 +1,86 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigester(t *testing.T) {
+	var sent []sentMessage
+	d := &Digester{Sender: recordSender(&sent), From: testFrom}
+
+	for _, body := range []string{"First", "Second"} {
+		m := getTestMessage()
+		m.SetBody("text/plain", body)
+		if err := d.Add(testTo1, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Add(testTo2, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("Invalid number of digests, got %d, want 2", len(sent))
+	}
+	if sent[0].from != testFrom || len(sent[0].to) != 1 || sent[0].to[0] != testTo1 {
+		t.Errorf("Invalid envelope, got %q %q", sent[0].from, sent[0].to)
+	}
+
+	msg := sent[0].msg
+	for _, s := range []string{
+		"Subject: Digest of 2 messages\r\n",
+		"Content-Type: multipart/digest;\r\n boundary=",
+		"\r\n\r\nFirst\r\n--",
+		"\r\n\r\nSecond\r\n--",
+	} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("Missing %q in digest:\n%s", s, msg)
+		}
+	}
+	if strings.Count(msg, "Content-Type: text/plain") != 2 {
+		t.Errorf("Invalid digest parts:\n%s", msg)
+	}
+
+	sent = nil
+	if err := d.Flush(); err != nil || len(sent) != 0 {
+		t.Errorf("Flush should not send anything, got %d emails, error %v", len(sent), err)
+	}
+}
+
+func TestDigesterRetry(t *testing.T) {
+	fail := true
+	var sent []sentMessage
+	rec := recordSender(&sent)
+	d := &Digester{
+		Sender: SendFunc(func(from string, to []string, msg io.WriterTo) error {
+			if fail {
+				return errors.New("unavailable")
+			}
+			return rec(from, to, msg)
+		}),
+		From: testFrom,
+	}
+
+	if err := d.Add(testTo1, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err == nil {
+		t.Fatal("Flush should fail")
+	}
+
+	fail = false
+	d.Start(time.Hour)
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0].msg, "Subject: Digest of 1 messages") {
+		t.Errorf("The digest should be sent on Close, got %v", sent)
+	}
+}
@@ -0,0 +1,451 @@
+package gomail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DKIMCanonicalization represents a DKIM canonicalization algorithm as defined
+// in RFC 6376, section 3.4.
+type DKIMCanonicalization string
+
+const (
+	// DKIMSimple is the "simple" canonicalization algorithm. It tolerates
+	// almost no modification of the message.
+	DKIMSimple DKIMCanonicalization = "simple"
+	// DKIMRelaxed is the "relaxed" canonicalization algorithm. It tolerates
+	// common modifications such as whitespace replacement and header
+	// refolding.
+	DKIMRelaxed DKIMCanonicalization = "relaxed"
+)
+
+// DKIMDefaultHeaders are the header fields signed by default by a DKIMSigner.
+var DKIMDefaultHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"In-Reply-To", "References", "Mime-Version", "Content-Type",
+	"Content-Transfer-Encoding", "List-Id", "List-Unsubscribe",
+	"List-Unsubscribe-Post",
+}
+
+// A DKIMSigner signs emails with DKIM as defined in RFC 6376.
+type DKIMSigner struct {
+	// Domain is the signing domain (d= tag). If empty, the domain of the From
+	// address is used.
+	Domain string
+	// Selector is the selector of the public key published in the DNS at
+	// <Selector>._domainkey.<Domain> (s= tag). If empty, the selector of the
+	// key returned by Keys is used.
+	Selector string
+	// Identity is the optional agent or user identifier (i= tag), for example
+	// "@example.com".
+	Identity string
+	// Key is the private key used to sign emails. RSA (rsa-sha256) and Ed25519
+	// (ed25519-sha256) keys are supported.
+	Key crypto.Signer
+	// Keys provides the key of the signing domain when Key is nil.
+	Keys KeyProvider
+	// HeaderCanonicalization and BodyCanonicalization are the canonicalization
+	// algorithms used for the header and the body. They default to
+	// DKIMRelaxed.
+	HeaderCanonicalization DKIMCanonicalization
+	BodyCanonicalization   DKIMCanonicalization
+	// Headers is the list of header fields signed when present in the email.
+	// It defaults to DKIMDefaultHeaders. The From header is always signed.
+	Headers []string
+	// Oversign is the list of header fields signed one more time than they
+	// appear in the email, so that no instance of these fields can be added
+	// without breaking the signature.
+	Oversign []string
+	// BodyLength defines whether the length of the signed body is included in
+	// the signature (l= tag). It allows intermediaries to append content, such
+	// as mailing list footers, without breaking the signature but also allows
+	// anyone to do so.
+	BodyLength bool
+	// MaxBodyLength, if positive, limits the number of bytes of the
+	// canonicalized body covered by the signature. It implies BodyLength.
+	MaxBodyLength int64
+	// Expiration, if positive, is the duration after which the signature
+	// expires (x= tag).
+	Expiration time.Duration
+}
+
+// Sign returns the DKIM-Signature header field, including the trailing CRLF,
+// of the given email.
+func (s *DKIMSigner) Sign(msg []byte) (string, error) {
+	fields, body := splitMessage(msg)
+
+	domain, selector, key, err := s.signingKey(fields)
+	if err != nil {
+		return "", err
+	}
+
+	var algo string
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		algo = "rsa-sha256"
+	case ed25519.PublicKey:
+		algo = "ed25519-sha256"
+	default:
+		return "", errors.New("gomail: unsupported DKIM key type")
+	}
+
+	hc, bc := s.HeaderCanonicalization, s.BodyCanonicalization
+	if hc == "" {
+		hc = DKIMRelaxed
+	}
+	if bc == "" {
+		bc = DKIMRelaxed
+	}
+
+	body = canonicalBody(body, bc)
+	signBodyLength := s.BodyLength
+	if s.MaxBodyLength > 0 {
+		signBodyLength = true
+		if int64(len(body)) > s.MaxBodyLength {
+			body = body[:s.MaxBodyLength]
+		}
+	}
+	bh := sha256.Sum256(body)
+
+	names, signed := s.selectHeaders(fields)
+	hasFrom := false
+	for _, field := range signed {
+		if strings.EqualFold(fieldName(field), "From") {
+			hasFrom = true
+		}
+	}
+	if !hasFrom {
+		return "", errors.New(`gomail: cannot sign with DKIM, "From" field is absent`)
+	}
+
+	t := now().Unix()
+	tags := []string{
+		"v=1",
+		"a=" + algo,
+		"c=" + string(hc) + "/" + string(bc),
+		"d=" + domain,
+	}
+	if s.Identity != "" {
+		tags = append(tags, "i="+s.Identity)
+	}
+	tags = append(tags, "s="+selector, "t="+strconv.FormatInt(t, 10))
+	if s.Expiration > 0 {
+		tags = append(tags, "x="+strconv.FormatInt(t+int64(s.Expiration/time.Second), 10))
+	}
+	if signBodyLength {
+		tags = append(tags, "l="+strconv.Itoa(len(body)))
+	}
+
+	f := &headerFolder{}
+	f.buf.WriteString("DKIM-Signature:")
+	f.lineLen = f.buf.Len()
+	for _, tag := range tags {
+		f.add(" ", tag+";")
+	}
+	for i, name := range names {
+		sep, tok := "", name+":"
+		if i == 0 {
+			sep, tok = " ", "h="+tok
+		}
+		if i == len(names)-1 {
+			tok = tok[:len(tok)-1] + ";"
+		}
+		f.add(sep, tok)
+	}
+	f.add(" ", "bh="+base64.StdEncoding.EncodeToString(bh[:])+";")
+	f.add(" ", "b=")
+
+	h := sha256.New()
+	for _, field := range signed {
+		io.WriteString(h, canonicalHeader(field, hc))
+	}
+	sigField := canonicalHeader(f.buf.String()+"\r\n", hc)
+	io.WriteString(h, strings.TrimSuffix(sigField, "\r\n"))
+
+	var sig []byte
+	if algo == "rsa-sha256" {
+		sig, err = key.Sign(rand.Reader, h.Sum(nil), crypto.SHA256)
+	} else {
+		sig, err = key.Sign(rand.Reader, h.Sum(nil), crypto.Hash(0))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	b := base64.StdEncoding.EncodeToString(sig)
+	for len(b) > 0 {
+		n := maxLineLen - f.lineLen
+		if n < 1 {
+			f.buf.WriteString("\r\n ")
+			f.lineLen = 1
+			continue
+		}
+		if n > len(b) {
+			n = len(b)
+		}
+		f.buf.WriteString(b[:n])
+		f.lineLen += n
+		b = b[n:]
+	}
+	f.buf.WriteString("\r\n")
+
+	return f.buf.String(), nil
+}
+
+// signingKey returns the domain, selector and key used to sign an email with
+// the given header fields.
+func (s *DKIMSigner) signingKey(fields []string) (string, string, crypto.Signer, error) {
+	domain, selector, key := s.Domain, s.Selector, s.Key
+	if domain == "" {
+		for _, field := range fields {
+			if strings.EqualFold(fieldName(field), "From") {
+				value := strings.Replace(field[strings.IndexByte(field, ':')+1:], "\r\n", "", -1)
+				addr, err := parseAddress(strings.TrimSpace(value))
+				if err != nil {
+					return "", "", nil, err
+				}
+				_, domain = splitAddress(addr)
+				break
+			}
+		}
+		if domain == "" {
+			return "", "", nil, errors.New("gomail: DKIM domain is required")
+		}
+	}
+
+	if key == nil {
+		if s.Keys == nil {
+			return "", "", nil, errors.New("gomail: DKIM key is required")
+		}
+		k, err := s.Keys.SigningKey(domain)
+		if err != nil {
+			return "", "", nil, err
+		}
+		key = k.Signer
+		if selector == "" {
+			selector = k.Selector
+		}
+	}
+
+	if selector == "" {
+		return "", "", nil, errors.New("gomail: DKIM selector is required")
+	}
+	return domain, selector, key, nil
+}
+
+// selectHeaders returns the names listed in the h= tag and the header fields
+// they refer to, in signing order.
+func (s *DKIMSigner) selectHeaders(fields []string) (names []string, signed []string) {
+	list := s.Headers
+	if list == nil {
+		list = DKIMDefaultHeaders
+	}
+	hasFrom := false
+	for _, name := range list {
+		if strings.EqualFold(name, "From") {
+			hasFrom = true
+		}
+	}
+	if !hasFrom {
+		list = append([]string{"From"}, list...)
+	}
+
+	used := make([]bool, len(fields))
+	pick := func(name string) bool {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fieldName(fields[i]), name) {
+				used[i] = true
+				signed = append(signed, fields[i])
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range list {
+		for pick(name) {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	for _, name := range s.Oversign {
+		names = append(names, strings.ToLower(name))
+	}
+
+	return names, signed
+}
+
+// A DKIMSender is a Sender that signs the emails with DKIM before sending them
+// with another Sender.
+type DKIMSender struct {
+	// Sender is the Sender used to send the signed emails.
+	Sender Sender
+	// Signer is the DKIMSigner used to sign the emails.
+	Signer *DKIMSigner
+}
+
+// Send implements Sender.
+func (s *DKIMSender) Send(from string, to []string, msg io.WriterTo) error {
+	return s.SendEnvelope(&Envelope{From: from, To: to}, msg)
+}
+
+// SendEnvelope implements EnvelopeSender. The DSN and RequireTLS settings of a
+// Message are kept in the options of the envelope given to Sender.
+func (s *DKIMSender) SendEnvelope(e *Envelope, msg io.WriterTo) error {
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	sig, err := s.Signer.Sign(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	e = &Envelope{From: e.From, To: e.To, Options: resolvedOptions(e, msg)}
+	return sendEnvelope(s.Sender, e, &signedMessage{sig, buf.Bytes()})
+}
+
+type signedMessage struct {
+	header string
+	msg    []byte
+}
+
+func (m *signedMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, m.header)
+	if err != nil {
+		return int64(n), err
+	}
+	n2, err := w.Write(m.msg)
+	return int64(n + n2), err
+}
+
+// splitMessage splits an email into its raw header fields, including their
+// trailing CRLF, and its body.
+func splitMessage(msg []byte) ([]string, []byte) {
+	var fields []string
+	for len(msg) > 0 {
+		if bytes.HasPrefix(msg, []byte("\r\n")) {
+			return fields, msg[2:]
+		}
+
+		end := 0
+		for {
+			i := bytes.Index(msg[end:], []byte("\r\n"))
+			if i == -1 {
+				end = len(msg)
+				break
+			}
+			end += i + 2
+			if end >= len(msg) || (msg[end] != ' ' && msg[end] != '\t') {
+				break
+			}
+		}
+
+		if msg[0] == ' ' || msg[0] == '\t' {
+			// Continuation line without a field, append it to the
+			// previous one.
+			if len(fields) > 0 {
+				fields[len(fields)-1] += string(msg[:end])
+			}
+		} else {
+			fields = append(fields, string(msg[:end]))
+		}
+		msg = msg[end:]
+	}
+
+	return fields, nil
+}
+
+func fieldName(field string) string {
+	i := strings.IndexByte(field, ':')
+	if i == -1 {
+		return strings.TrimSpace(field)
+	}
+	return strings.TrimRight(field[:i], " \t")
+}
+
+func canonicalHeader(field string, c DKIMCanonicalization) string {
+	if c == DKIMSimple {
+		return field
+	}
+
+	i := strings.IndexByte(field, ':')
+	if i == -1 {
+		return field
+	}
+	name := strings.ToLower(strings.TrimRight(field[:i], " \t"))
+	value := strings.Replace(field[i+1:], "\r\n", "", -1)
+	value = strings.TrimLeft(value, " \t")
+	return name + ":" + string(compressWSP([]byte(value))) + "\r\n"
+}
+
+func canonicalBody(body []byte, c DKIMCanonicalization) []byte {
+	if c == DKIMRelaxed {
+		lines := bytes.Split(body, []byte("\r\n"))
+		var buf bytes.Buffer
+		for i, line := range lines {
+			buf.Write(compressWSP(line))
+			if i < len(lines)-1 {
+				buf.WriteString("\r\n")
+			}
+		}
+		body = buf.Bytes()
+	}
+
+	for bytes.HasSuffix(body, []byte("\r\n")) {
+		body = body[:len(body)-2]
+	}
+	if len(body) == 0 && c == DKIMRelaxed {
+		return nil
+	}
+	return append(body, '\r', '\n')
+}
+
+// compressWSP replaces the sequences of whitespace characters by a single
+// space and removes trailing whitespaces.
+func compressWSP(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	space := false
+	for _, c := range b {
+		if c == ' ' || c == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			out = append(out, ' ')
+		}
+		space = false
+		out = append(out, c)
+	}
+	return out
+}
+
+// headerFolder writes a header field folded at 76 characters per line.
+type headerFolder struct {
+	buf     bytes.Buffer
+	lineLen int
+}
+
+// add writes tok preceded by sep, or by a line break if tok does not fit on the
+// current line.
+func (f *headerFolder) add(sep, tok string) {
+	if f.lineLen+len(sep)+len(tok) > maxLineLen {
+		f.buf.WriteString("\r\n ")
+		f.lineLen = 1
+	} else {
+		f.buf.WriteString(sep)
+		f.lineLen += len(sep)
+	}
+	f.buf.WriteString(tok)
+	f.lineLen += len(tok)
+}
@@ -0,0 +1,251 @@
+package gomail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Example from RFC 6376, section 3.4.5.
+const testDKIMCanonMsg = "A: X\r\n" +
+	"B : Y\t\r\n" +
+	"\tZ  \r\n" +
+	"\r\n" +
+	" C \r\n" +
+	"D \t E\r\n" +
+	"\r\n" +
+	"\r\n"
+
+func TestDKIMCanonicalization(t *testing.T) {
+	fields, body := splitMessage([]byte(testDKIMCanonMsg))
+
+	var got string
+	for _, f := range fields {
+		got += canonicalHeader(f, DKIMRelaxed)
+	}
+	if want := "a:X\r\nb:Y Z\r\n"; got != want {
+		t.Errorf("Invalid relaxed header, got %q, want %q", got, want)
+	}
+
+	got = ""
+	for _, f := range fields {
+		got += canonicalHeader(f, DKIMSimple)
+	}
+	if want := "A: X\r\nB : Y\t\r\n\tZ  \r\n"; got != want {
+		t.Errorf("Invalid simple header, got %q, want %q", got, want)
+	}
+
+	if got, want := string(canonicalBody(body, DKIMRelaxed)), " C\r\nD E\r\n"; got != want {
+		t.Errorf("Invalid relaxed body, got %q, want %q", got, want)
+	}
+	if got, want := string(canonicalBody(body, DKIMSimple)), " C \r\nD \t E\r\n"; got != want {
+		t.Errorf("Invalid simple body, got %q, want %q", got, want)
+	}
+	if got := canonicalBody(nil, DKIMSimple); string(got) != "\r\n" {
+		t.Errorf("Invalid simple empty body, got %q", got)
+	}
+	if got := canonicalBody([]byte("\r\n\r\n"), DKIMRelaxed); len(got) != 0 {
+		t.Errorf("Invalid relaxed empty body, got %q", got)
+	}
+}
+
+func TestDKIMBodyHash(t *testing.T) {
+	// Example from RFC 8463, appendix A.
+	body := "Hi.\r\n\r\nWe lost the game.  Are you hungry yet?\r\n\r\nJoe.\r\n"
+	bh := sha256.Sum256(canonicalBody([]byte(body), DKIMRelaxed))
+	if got, want := base64.StdEncoding.EncodeToString(bh[:]), "2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8="; got != want {
+		t.Errorf("Invalid body hash, got %q, want %q", got, want)
+	}
+}
+
+func TestDKIMSignRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &DKIMSigner{Domain: "example.com", Selector: "s1", Key: key}
+	sig, msg := testDKIMSign(t, s)
+
+	for _, tag := range []string{"a=rsa-sha256;", "c=relaxed/relaxed;", "d=example.com;", "s=s1;", "t=1403718360;"} {
+		if !strings.Contains(sig, tag) {
+			t.Errorf("Missing tag %q in %q", tag, sig)
+		}
+	}
+	if strings.Contains(sig, "l=") || strings.Contains(sig, "x=") {
+		t.Errorf("Unexpected l= or x= tag in %q", sig)
+	}
+	verifyDKIM(t, sig, msg, key.Public())
+}
+
+func TestDKIMSignEd25519(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &DKIMSigner{
+		Domain:                 "example.com",
+		Selector:               "s2",
+		Key:                    key,
+		HeaderCanonicalization: DKIMSimple,
+		BodyCanonicalization:   DKIMSimple,
+		Headers:                []string{"To", "Subject"},
+		Oversign:               []string{"From", "Subject", "Reply-To"},
+		MaxBodyLength:          6,
+		Expiration:             time.Hour,
+	}
+	sig, msg := testDKIMSign(t, s)
+
+	for _, tag := range []string{
+		"a=ed25519-sha256;",
+		"c=simple/simple;",
+		"h=from:to:subject:from:subject:reply-to;",
+		"l=6;",
+		"x=1403721960;",
+	} {
+		if !strings.Contains(sig, tag) {
+			t.Errorf("Missing tag %q in %q", tag, sig)
+		}
+	}
+	verifyDKIM(t, sig, msg, key.Public())
+}
+
+func TestDKIMSender(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []sentMessage
+	s := &DKIMSender{
+		Sender: recordSender(&sent),
+		Signer: &DKIMSigner{Domain: "example.com", Selector: "s1", Key: key},
+	}
+	if err := Send(s, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || !strings.HasPrefix(sent[0].msg, "DKIM-Signature: v=1;") {
+		t.Fatalf("Invalid email sent: %v", sent)
+	}
+
+	if _, err := s.Signer.Sign([]byte("To: " + testTo1 + "\r\n\r\nTest")); err == nil {
+		t.Error("Sign should fail without From field")
+	}
+}
+
+func TestDKIMSenderRequireTLS(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := getTestMessage()
+	RequireTLS()(m)
+	s := &DKIMSender{
+		Sender: &smtpSender{smtpClient: &mockClient{t: t}, d: &Dialer{}},
+		Signer: &DKIMSigner{Domain: "example.com", Selector: "s1", Key: key},
+	}
+	if err := Send(s, m); !errors.Is(err, ErrTLSRequired) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrTLSRequired)
+	}
+
+	r := new(envelopeRecorder)
+	s.Sender = r
+	if err := s.Send(testFrom, []string{testTo1}, m); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.envelopes) != 1 || !r.envelopes[0].Options.RequireTLS {
+		t.Errorf("The RequireTLS setting should be kept, got %+v", r.envelopes)
+	}
+}
+
+func testDKIMSign(t *testing.T, s *DKIMSigner) (string, []byte) {
+	m := getTestMessage()
+	m.SetHeader("Subject", "A rather long subject so the header field is folded by the message writer")
+	m.SetBody("text/plain", "Hello,\r\n\r\nThis email is signed.  \r\n\r\n")
+	buf := new(bytes.Buffer)
+	if _, err := m.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := s.Sign(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sig, "DKIM-Signature:") || !strings.HasSuffix(sig, "\r\n") {
+		t.Fatalf("Invalid signature field %q", sig)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(sig, "\r\n"), "\r\n") {
+		if len(line) > 78 {
+			t.Errorf("Signature line too long: %q", line)
+		}
+	}
+
+	return sig, buf.Bytes()
+}
+
+// verifyDKIM verifies the signature of msg as a DKIM verifier would do.
+func verifyDKIM(t *testing.T, sig string, msg []byte, pub crypto.PublicKey) {
+	tags := make(map[string]string)
+	unfolded := strings.Replace(strings.TrimSuffix(sig, "\r\n"), "\r\n", "", -1)
+	for _, tag := range strings.Split(unfolded[len("DKIM-Signature:"):], ";") {
+		kv := strings.SplitN(strings.TrimSpace(tag), "=", 2)
+		tags[kv[0]] = strings.Replace(strings.Replace(kv[1], " ", "", -1), "\t", "", -1)
+	}
+	c := strings.Split(tags["c"], "/")
+	hc, bc := DKIMCanonicalization(c[0]), DKIMCanonicalization(c[1])
+
+	fields, body := splitMessage(msg)
+	body = canonicalBody(body, bc)
+	if l, ok := tags["l"]; ok {
+		n := 0
+		for _, d := range l {
+			n = n*10 + int(d-'0')
+		}
+		body = body[:n]
+	}
+	bh := sha256.Sum256(body)
+	if got := base64.StdEncoding.EncodeToString(bh[:]); got != tags["bh"] {
+		t.Fatalf("Invalid body hash, got %q, want %q", got, tags["bh"])
+	}
+
+	h := sha256.New()
+	used := make([]bool, len(fields))
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fieldName(fields[i]), name) {
+				used[i] = true
+				io.WriteString(h, canonicalHeader(fields[i], hc))
+				break
+			}
+		}
+	}
+	i := strings.Index(sig, "b=")
+	for sig[i-1] != ' ' && sig[i-1] != ';' {
+		i = i + 2 + strings.Index(sig[i+2:], "b=")
+	}
+	empty := canonicalHeader(sig[:i+2]+"\r\n", hc)
+	io.WriteString(h, strings.TrimSuffix(empty, "\r\n"))
+
+	b, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, h.Sum(nil), b)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, h.Sum(nil), b) {
+			err = rsa.ErrVerification
+		}
+	}
+	if err != nil {
+		t.Errorf("Invalid signature: %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package gomail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// A DLPAction is what a DLPSender does with an email containing sensitive
+// data.
+type DLPAction int
+
+const (
+	// DLPBlock does not send the email and returns a *DLPError.
+	DLPBlock DLPAction = iota
+	// DLPRedact replaces the sensitive data of the text bodies with
+	// Redaction. Since files cannot be modified safely, sensitive data found
+	// in an attached or embedded file blocks the email.
+	DLPRedact
+	// DLPAnnotate sends the email unchanged with the name of the policy in
+	// the X-DLP-Policy header field, for example to be reviewed by the
+	// outbound server.
+	DLPAnnotate
+)
+
+// Redaction replaces the sensitive data redacted by a DLPRedact policy.
+const Redaction = "[REDACTED]"
+
+// A DLPDetector finds sensitive data in a text.
+type DLPDetector interface {
+	// FindAll returns the start and end indexes of the sensitive data found
+	// in b, like regexp.Regexp.FindAllIndex.
+	FindAll(b []byte) [][]int
+}
+
+// The DLPDetectorFunc type is an adapter to allow the use of ordinary
+// functions as detectors.
+type DLPDetectorFunc func(b []byte) [][]int
+
+// FindAll calls f(b).
+func (f DLPDetectorFunc) FindAll(b []byte) [][]int {
+	return f(b)
+}
+
+// RegexpDetector returns a DLPDetector finding the matches of re.
+func RegexpDetector(re *regexp.Regexp) DLPDetector {
+	return DLPDetectorFunc(func(b []byte) [][]int {
+		return re.FindAllIndex(b, -1)
+	})
+}
+
+var creditCardRegexp = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+
+// CreditCardDetector returns a DLPDetector finding payment card numbers: 13 to
+// 19 digits, optionally grouped with spaces or dashes, with a valid Luhn
+// checksum.
+func CreditCardDetector() DLPDetector {
+	return DLPDetectorFunc(func(b []byte) [][]int {
+		var found [][]int
+		for _, loc := range creditCardRegexp.FindAllIndex(b, -1) {
+			if luhn(b[loc[0]:loc[1]]) {
+				found = append(found, loc)
+			}
+		}
+		return found
+	})
+}
+
+// luhn reports whether the digits of b have a valid Luhn checksum.
+func luhn(b []byte) bool {
+	sum, n := 0, 0
+	for i := len(b) - 1; i >= 0; i-- {
+		c := b[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+var ssnRegexp = regexp.MustCompile(`\b(\d{3})-(\d{2})-(\d{4})\b`)
+
+// SSNDetector returns a DLPDetector finding US social security numbers written
+// as AAA-GG-SSSS, excluding the numbers that are never assigned.
+func SSNDetector() DLPDetector {
+	return DLPDetectorFunc(func(b []byte) [][]int {
+		var found [][]int
+		for _, m := range ssnRegexp.FindAllSubmatchIndex(b, -1) {
+			area, group, serial := string(b[m[2]:m[3]]), string(b[m[4]:m[5]]), string(b[m[6]:m[7]])
+			if area == "000" || area == "666" || area[0] == '9' || group == "00" || serial == "0000" {
+				continue
+			}
+			found = append(found, m[:2])
+		}
+		return found
+	})
+}
+
+// A DLPPolicy is a data loss prevention rule applied by a DLPSender.
+type DLPPolicy struct {
+	// Name identifies the policy in errors and annotations.
+	Name string
+	// Detector finds the sensitive data.
+	Detector DLPDetector
+	// Action is what is done with the emails containing sensitive data.
+	Action DLPAction
+}
+
+// A DLPError is returned by a DLPSender when an email is blocked.
+type DLPError struct {
+	// Policy is the name of the policy blocking the email.
+	Policy string
+	// Part is where the sensitive data was found, for example "text/plain
+	// body" or "file report.pdf".
+	Part string
+}
+
+func (e *DLPError) Error() string {
+	return fmt.Sprintf("gomail: email blocked by the DLP policy %q, sensitive data found in the %s", e.Policy, e.Part)
+}
+
+// A DLPSender is a Sender that scans the emails for sensitive data, like
+// payment card numbers, before sending them with another Sender.
+//
+// The text bodies and the attached and embedded files of the emails built
+// with Message are scanned; the files are read as streams and never kept in
+// memory. Other emails are rendered and their decoded text bodies are scanned,
+// they cannot be redacted so a DLPRedact policy blocks them.
+type DLPSender struct {
+	// Sender is the Sender used to send the emails.
+	Sender Sender
+	// Policies are the policies applied to the emails.
+	Policies []*DLPPolicy
+}
+
+// Send implements Sender.
+func (s *DLPSender) Send(from string, to []string, msg io.WriterTo) error {
+	return s.SendEnvelope(&Envelope{From: from, To: to}, msg)
+}
+
+// SendEnvelope implements EnvelopeSender.
+func (s *DLPSender) SendEnvelope(e *Envelope, msg io.WriterTo) error {
+	m, ok := msg.(*Message)
+	if !ok {
+		raw, annotated, err := s.scanRendered(msg)
+		if err != nil {
+			return err
+		}
+		var h bytes.Buffer
+		if len(annotated) > 0 {
+			mw := &messageWriter{w: &h}
+			mw.writeHeader("X-DLP-Policy", strings.Join(annotated, ", "))
+		}
+		return sendEnvelope(s.Sender, e, &signedMessage{h.String(), raw})
+	}
+
+	m, err := s.scanMessage(m)
+	if err != nil {
+		return err
+	}
+	return sendEnvelope(s.Sender, e, m)
+}
+
+// scanMessage returns the message to send: m itself or a redacted or
+// annotated copy.
+func (s *DLPSender) scanMessage(m *Message) (*Message, error) {
+	bodies := make([][]byte, len(m.parts))
+	for i, p := range m.parts {
+		var buf bytes.Buffer
+		if err := p.copier(&buf); err != nil {
+			return nil, err
+		}
+		bodies[i] = buf.Bytes()
+	}
+
+	var redact []*DLPPolicy
+	var annotated []string
+	for _, pol := range s.Policies {
+		part := ""
+		for i, b := range bodies {
+			if strings.HasPrefix(m.parts[i].contentType, "text/") && len(pol.Detector.FindAll(b)) > 0 {
+				part = m.parts[i].contentType + " body"
+				break
+			}
+		}
+		filePart, err := scanFiles(pol.Detector, m)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case filePart != "" && pol.Action != DLPAnnotate:
+			return nil, &DLPError{Policy: pol.Name, Part: filePart}
+		case part == "" && filePart == "":
+		case pol.Action == DLPBlock:
+			return nil, &DLPError{Policy: pol.Name, Part: part}
+		case pol.Action == DLPRedact:
+			redact = append(redact, pol)
+		default:
+			annotated = append(annotated, pol.Name)
+		}
+	}
+
+	if len(redact) == 0 && len(annotated) == 0 {
+		return m, nil
+	}
+	c := m.Clone()
+	for i, p := range c.parts {
+		if !strings.HasPrefix(p.contentType, "text/") {
+			continue
+		}
+		b := bodies[i]
+		for _, pol := range redact {
+			b = redactAll(b, pol.Detector)
+		}
+		p.copier = newCopier(string(b))
+	}
+	if len(annotated) > 0 {
+		c.SetHeader("X-DLP-Policy", strings.Join(annotated, ", "))
+	}
+	return c, nil
+}
+
+// scanFiles returns the name of the first file of m in which d finds
+// sensitive data.
+func scanFiles(d DLPDetector, m *Message) (string, error) {
+	for _, list := range [][]*file{m.attachments, m.embedded} {
+		for _, f := range list {
+			w := &dlpWriter{d: d}
+			if err := f.CopyFunc(w); err != nil {
+				return "", fmt.Errorf("gomail: could not scan the file %q: %w", f.Name, err)
+			}
+			if w.found || w.flush() {
+				return "file " + f.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func redactAll(b []byte, d DLPDetector) []byte {
+	locs := d.FindAll(b)
+	if len(locs) == 0 {
+		return b
+	}
+	var buf bytes.Buffer
+	last := 0
+	for _, loc := range locs {
+		if loc[0] < last {
+			continue
+		}
+		buf.Write(b[last:loc[0]])
+		buf.WriteString(Redaction)
+		last = loc[1]
+	}
+	buf.Write(b[last:])
+	return buf.Bytes()
+}
+
+const (
+	dlpChunkSize = 32 << 10
+	dlpOverlap   = 256
+)
+
+// dlpWriter scans a stream by chunks. The end of each chunk is kept and
+// scanned again with the next one so sensitive data split between two chunks
+// is found.
+type dlpWriter struct {
+	d     DLPDetector
+	buf   []byte
+	found bool
+}
+
+func (w *dlpWriter) Write(p []byte) (int, error) {
+	if w.found {
+		return len(p), nil
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= dlpChunkSize && !w.flush() {
+		w.buf = append(w.buf[:0], w.buf[len(w.buf)-dlpOverlap:]...)
+	}
+	return len(p), nil
+}
+
+// flush scans the buffered data and reports whether sensitive data was
+// found.
+func (w *dlpWriter) flush() bool {
+	w.found = w.found || len(w.d.FindAll(w.buf)) > 0
+	return w.found
+}
+
+// scanRendered renders msg, scans its decoded text bodies and returns the
+// rendered email and the names of the DLPAnnotate policies matching it.
+func (s *DLPSender) scanRendered(msg io.WriterTo) ([]byte, []string, error) {
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return nil, nil, err
+	}
+	p := parsePreview(buf.Bytes())
+	if p.Err != nil {
+		return nil, nil, fmt.Errorf("gomail: could not scan the email: %w", p.Err)
+	}
+
+	var annotated []string
+	for _, pol := range s.Policies {
+		part := ""
+		if len(pol.Detector.FindAll([]byte(p.Text))) > 0 {
+			part = "text/plain body"
+		} else if len(pol.Detector.FindAll([]byte(p.HTML))) > 0 {
+			part = "text/html body"
+		}
+		switch {
+		case part == "":
+		case pol.Action == DLPAnnotate:
+			annotated = append(annotated, pol.Name)
+		default:
+			return nil, nil, &DLPError{Policy: pol.Name, Part: part}
+		}
+	}
+	return buf.Bytes(), annotated, nil
+}
@@ -0,0 +1,103 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDetectors(t *testing.T) {
+	tests := []struct {
+		d    DLPDetector
+		text string
+		want []string
+	}{
+		{CreditCardDetector(), "Card: 4111 1111 1111 1111, exp 12/30", []string{"4111 1111 1111 1111"}},
+		{CreditCardDetector(), "Card: 4111-1111-1111-1112", nil},
+		{CreditCardDetector(), "Order 1234567890", nil},
+		{SSNDetector(), "SSN 078-05-1120 or 666-12-3456 or 123-00-4567", []string{"078-05-1120"}},
+		{RegexpDetector(regexp.MustCompile(`(?i)confidential`)), "CONFIDENTIAL report", []string{"CONFIDENTIAL"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, loc := range test.d.FindAll([]byte(test.text)) {
+			got = append(got, test.text[loc[0]:loc[1]])
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("FindAll(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func dlpRecorder(got *string) Sender {
+	return SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		var buf bytes.Buffer
+		_, err := msg.WriteTo(&buf)
+		*got = buf.String()
+		return err
+	})
+}
+
+func TestDLPSender(t *testing.T) {
+	var got string
+	s := &DLPSender{
+		Sender: dlpRecorder(&got),
+		Policies: []*DLPPolicy{
+			{Name: "cards", Detector: CreditCardDetector(), Action: DLPRedact},
+			{Name: "ssn", Detector: SSNDetector(), Action: DLPBlock},
+			{Name: "secret", Detector: RegexpDetector(regexp.MustCompile(`secret`)), Action: DLPAnnotate},
+		},
+	}
+
+	m := getTestMessage()
+	m.SetBody("text/plain", "Your card 4111 1111 1111 1111 is a secret.")
+	if err := Send(s, m); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "Your card [REDACTED] is a secret.") || !strings.Contains(got, "X-DLP-Policy: secret\r\n") {
+		t.Errorf("The email should be redacted and annotated, got:\n%s", got)
+	}
+	if len(m.GetHeader("X-DLP-Policy")) != 0 {
+		t.Error("The message should not be modified")
+	}
+
+	got = ""
+	m = getTestMessage()
+	m.SetBody("text/plain", "SSN: 078-05-1120")
+	err := Send(s, m)
+	var derr *DLPError
+	if !errors.As(err, &derr) || derr.Policy != "ssn" || derr.Part != "text/plain body" {
+		t.Errorf("Invalid error, got %v", err)
+	}
+	if got != "" {
+		t.Error("A blocked email should not be sent")
+	}
+
+	// Files are scanned by chunks.
+	m = getTestMessage()
+	m.Attach("cards.csv", SetCopyFunc(func(w io.Writer) error {
+		io.WriteString(w, strings.Repeat("a", dlpChunkSize-10))
+		_, err := io.WriteString(w, " 4111 1111 1111 1111 ")
+		return err
+	}))
+	err = Send(s, m)
+	if !errors.As(err, &derr) || derr.Policy != "cards" || derr.Part != "file cards.csv" {
+		t.Errorf("Invalid error, got %v", err)
+	}
+
+	// Rendered emails are scanned but cannot be redacted.
+	raw := "From: " + testFrom + "\r\nTo: " + testTo1 + "\r\n\r\nIt is a secret.\r\n"
+	if err := SendRaw(s, testFrom, []string{testTo1}, strings.NewReader(raw)); err != nil {
+		t.Fatal(err)
+	}
+	if got != "X-DLP-Policy: secret\r\n"+raw {
+		t.Errorf("Invalid email, got %q", got)
+	}
+	raw = strings.Replace(raw, "secret", "4111111111111111", 1)
+	if err := SendRaw(s, testFrom, []string{testTo1}, strings.NewReader(raw)); !errors.As(err, &derr) {
+		t.Errorf("Invalid error, got %v", err)
+	}
+}
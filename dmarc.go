@@ -0,0 +1,224 @@
+package gomail
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// A DMARCReport is a DMARC aggregate report as defined in RFC 7489,
+// Appendix C.
+type DMARCReport struct {
+	XMLName  xml.Name             `xml:"feedback"`
+	Version  string               `xml:"version,omitempty"`
+	Metadata DMARCReportMetadata  `xml:"report_metadata"`
+	Policy   DMARCPolicyPublished `xml:"policy_published"`
+	Records  []DMARCRecord        `xml:"record"`
+}
+
+// DMARCReportMetadata contains the information about the organization that
+// generated a DMARC aggregate report.
+type DMARCReportMetadata struct {
+	OrgName          string         `xml:"org_name"`
+	Email            string         `xml:"email"`
+	ExtraContactInfo string         `xml:"extra_contact_info,omitempty"`
+	ReportID         string         `xml:"report_id"`
+	DateRange        DMARCDateRange `xml:"date_range"`
+	Errors           []string       `xml:"error,omitempty"`
+}
+
+// DMARCDateRange is the time range covered by a DMARC aggregate report. Begin
+// and End are Unix timestamps.
+type DMARCDateRange struct {
+	Begin int64 `xml:"begin"`
+	End   int64 `xml:"end"`
+}
+
+// DMARCPolicyPublished is the DMARC policy found in the DNS for the reported
+// domain.
+type DMARCPolicyPublished struct {
+	Domain string `xml:"domain"`
+	ADKIM  string `xml:"adkim,omitempty"`
+	ASPF   string `xml:"aspf,omitempty"`
+	P      string `xml:"p"`
+	SP     string `xml:"sp,omitempty"`
+	Pct    int    `xml:"pct"`
+	FO     string `xml:"fo,omitempty"`
+}
+
+// A DMARCRecord groups the messages that share the same source IP address,
+// identifiers and authentication results.
+type DMARCRecord struct {
+	Row         DMARCRow         `xml:"row"`
+	Identifiers DMARCIdentifiers `xml:"identifiers"`
+	AuthResults DMARCAuthResults `xml:"auth_results"`
+}
+
+// A DMARCRow contains the number of messages sent from an IP address and the
+// DMARC policy applied to them.
+type DMARCRow struct {
+	SourceIP        string               `xml:"source_ip"`
+	Count           int                  `xml:"count"`
+	PolicyEvaluated DMARCPolicyEvaluated `xml:"policy_evaluated"`
+}
+
+// DMARCPolicyEvaluated is the result of the DMARC policy evaluation.
+type DMARCPolicyEvaluated struct {
+	Disposition string              `xml:"disposition"`
+	DKIM        string              `xml:"dkim"`
+	SPF         string              `xml:"spf"`
+	Reasons     []DMARCPolicyReason `xml:"reason,omitempty"`
+}
+
+// A DMARCPolicyReason explains why the applied policy differs from the
+// published one.
+type DMARCPolicyReason struct {
+	Type    string `xml:"type"`
+	Comment string `xml:"comment,omitempty"`
+}
+
+// DMARCIdentifiers contains the identifiers of the reported messages.
+type DMARCIdentifiers struct {
+	EnvelopeTo   string `xml:"envelope_to,omitempty"`
+	EnvelopeFrom string `xml:"envelope_from,omitempty"`
+	HeaderFrom   string `xml:"header_from"`
+}
+
+// DMARCAuthResults contains the DKIM and SPF results of the reported messages.
+type DMARCAuthResults struct {
+	DKIM []DMARCDKIMResult `xml:"dkim,omitempty"`
+	SPF  []DMARCSPFResult  `xml:"spf"`
+}
+
+// DMARCDKIMResult is the result of the verification of a DKIM signature.
+type DMARCDKIMResult struct {
+	Domain      string `xml:"domain"`
+	Selector    string `xml:"selector,omitempty"`
+	Result      string `xml:"result"`
+	HumanResult string `xml:"human_result,omitempty"`
+}
+
+// DMARCSPFResult is the result of an SPF check.
+type DMARCSPFResult struct {
+	Domain string `xml:"domain"`
+	Scope  string `xml:"scope,omitempty"`
+	Result string `xml:"result"`
+}
+
+// ParseDMARCReport parses a DMARC aggregate report. The report can either be
+// plain XML or compressed with gzip or zip as it is usually the case when it is
+// received as an attachment.
+func ParseDMARCReport(r io.Reader) (*DMARCReport, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var xr io.Reader
+	switch {
+	case bytes.HasPrefix(b, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("gomail: invalid gzip DMARC report: %w", err)
+		}
+		defer gr.Close()
+		xr = gr
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, fmt.Errorf("gomail: invalid zip DMARC report: %w", err)
+		}
+		if len(zr.File) == 0 {
+			return nil, errors.New("gomail: empty zip DMARC report")
+		}
+		f, err := zr.File[0].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		xr = f
+	default:
+		xr = bytes.NewReader(b)
+	}
+
+	report := new(DMARCReport)
+	if err := xml.NewDecoder(xr).Decode(report); err != nil {
+		return nil, fmt.Errorf("gomail: invalid DMARC report: %w", err)
+	}
+
+	return report, nil
+}
+
+// WriteTo implements io.WriterTo. It writes the report as XML into w.
+func (r *DMARCReport) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	if _, err := io.WriteString(cw, xml.Header); err != nil {
+		return cw.n, err
+	}
+
+	enc := xml.NewEncoder(cw)
+	enc.Indent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return cw.n, err
+	}
+	_, err := io.WriteString(cw, "\n")
+	return cw.n, err
+}
+
+// Filename returns the name of the report file without extension as defined in
+// RFC 7489, section 7.2.1.1.
+func (r *DMARCReport) Filename() string {
+	receiver := r.Metadata.Email
+	if i := strings.LastIndexByte(receiver, '@'); i != -1 {
+		receiver = receiver[i+1:]
+	}
+
+	return receiver + "!" + r.Policy.Domain +
+		"!" + strconv.FormatInt(r.Metadata.DateRange.Begin, 10) +
+		"!" + strconv.FormatInt(r.Metadata.DateRange.End, 10)
+}
+
+// Subject returns the subject recommended for the email sending the report by
+// RFC 7489, section 7.2.1.1.
+func (r *DMARCReport) Subject() string {
+	return "Report Domain: " + r.Policy.Domain +
+		" Submitter: " + r.Metadata.OrgName +
+		" Report-ID: " + r.Metadata.ReportID
+}
+
+// AttachDMARCReport attaches the given DMARC aggregate report to the message
+// as a gzip-compressed XML file.
+func (m *Message) AttachDMARCReport(r *DMARCReport) {
+	name := r.Filename() + ".xml.gz"
+	m.Attach(name,
+		SetHeader(map[string][]string{
+			"Content-Type": {`application/gzip; name="` + name + `"`},
+		}),
+		SetCopyFunc(func(w io.Writer) error {
+			gw := gzip.NewWriter(w)
+			if _, err := r.WriteTo(gw); err != nil {
+				gw.Close()
+				return err
+			}
+			return gw.Close()
+		}),
+	)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
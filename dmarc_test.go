@@ -0,0 +1,127 @@
+package gomail
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func getTestDMARCReport() *DMARCReport {
+	return &DMARCReport{
+		Version: "1.0",
+		Metadata: DMARCReportMetadata{
+			OrgName:   "Example Receiver",
+			Email:     "dmarc@receiver.example.org",
+			ReportID:  "1234",
+			DateRange: DMARCDateRange{Begin: 1403654400, End: 1403740799},
+		},
+		Policy: DMARCPolicyPublished{
+			Domain: "example.com",
+			P:      "reject",
+			Pct:    100,
+		},
+		Records: []DMARCRecord{{
+			Row: DMARCRow{
+				SourceIP: "192.0.2.1",
+				Count:    2,
+				PolicyEvaluated: DMARCPolicyEvaluated{
+					Disposition: "none",
+					DKIM:        "pass",
+					SPF:         "fail",
+				},
+			},
+			Identifiers: DMARCIdentifiers{HeaderFrom: "example.com"},
+			AuthResults: DMARCAuthResults{
+				DKIM: []DMARCDKIMResult{{Domain: "example.com", Selector: "s1", Result: "pass"}},
+				SPF:  []DMARCSPFResult{{Domain: "example.com", Result: "fail"}},
+			},
+		}},
+	}
+}
+
+func TestDMARCReport(t *testing.T) {
+	r := getTestDMARCReport()
+	buf := new(bytes.Buffer)
+	n, err := r.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("Invalid count, got %d, want %d", n, buf.Len())
+	}
+	if !strings.Contains(buf.String(), "<source_ip>192.0.2.1</source_ip>") {
+		t.Errorf("Invalid report:\n%s", buf.String())
+	}
+
+	testParseDMARCReport(t, buf.Bytes(), r)
+}
+
+func TestDMARCReportGzip(t *testing.T) {
+	r := getTestDMARCReport()
+	buf := new(bytes.Buffer)
+	gw := gzip.NewWriter(buf)
+	if _, err := r.WriteTo(gw); err != nil {
+		t.Fatal(err)
+	}
+	gw.Close()
+
+	testParseDMARCReport(t, buf.Bytes(), r)
+}
+
+func TestDMARCReportZip(t *testing.T) {
+	r := getTestDMARCReport()
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	f, err := zw.Create(r.Filename() + ".xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+
+	testParseDMARCReport(t, buf.Bytes(), r)
+}
+
+func TestDMARCReportAttachment(t *testing.T) {
+	r := getTestDMARCReport()
+	if want := "receiver.example.org!example.com!1403654400!1403740799"; r.Filename() != want {
+		t.Errorf("Invalid filename, got %q, want %q", r.Filename(), want)
+	}
+
+	m := NewMessage()
+	m.SetHeader("Subject", r.Subject())
+	m.AttachDMARCReport(r)
+
+	buf := new(bytes.Buffer)
+	if _, err := m.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.SplitN(buf.String(), "\r\n\r\n", 2)
+	parts[0] = strings.Replace(parts[0], "\r\n ", " ", -1)
+	if !strings.Contains(parts[0], `Content-Type: application/gzip; name="`+r.Filename()+`.xml.gz"`) {
+		t.Errorf("Invalid headers:\n%s", parts[0])
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.Replace(parts[1], "\r\n", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testParseDMARCReport(t, b, r)
+}
+
+func testParseDMARCReport(t *testing.T, b []byte, want *DMARCReport) {
+	got, err := ParseDMARCReport(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.XMLName = want.XMLName
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid report, got %#v, want %#v", got, want)
+	}
+}
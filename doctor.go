@@ -0,0 +1,363 @@
+package gomail
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckStatus is the result of a check run by Doctor.
+type CheckStatus int
+
+const (
+	// CheckOK means the check passed.
+	CheckOK CheckStatus = iota
+	// CheckWarning means the setup works but could be improved.
+	CheckWarning
+	// CheckFailed means the setup is broken or missing.
+	CheckFailed
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "ok"
+	case CheckWarning:
+		return "warning"
+	default:
+		return "failed"
+	}
+}
+
+// A Check is the result of one of the checks run by Doctor.
+type Check struct {
+	// Name identifies the check, for example "SPF" or "DKIM s1".
+	Name string
+	// Status is the result of the check.
+	Status CheckStatus
+	// Detail is a human readable description of the result.
+	Detail string
+}
+
+// A DoctorReport is the result of Doctor.
+type DoctorReport struct {
+	Domain string
+	Checks []*Check
+}
+
+// OK reports whether no check failed.
+func (r *DoctorReport) OK() bool {
+	for _, c := range r.Checks {
+		if c.Status == CheckFailed {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *DoctorReport) String() string {
+	var lines []string
+	for _, c := range r.Checks {
+		lines = append(lines, fmt.Sprintf("%-7s %s: %s", c.Status, c.Name, c.Detail))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *DoctorReport) add(name string, status CheckStatus, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, &Check{
+		Name:   name,
+		Status: status,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+// Doctor verifies the sending setup of a domain: the syntax of its SPF record,
+// the publication of the DKIM keys of the given selectors, the presence of a
+// DMARC policy and the reachability of its mail exchangers. If d is not nil, it
+// also checks that a connection to the SMTP server can be opened and
+// authenticated.
+//
+// Doctor is meant to be run when a domain is set up, for example during
+// onboarding, and does not replace a full deliverability test.
+func Doctor(domain string, d *Dialer, selectors ...string) *DoctorReport {
+	r := &DoctorReport{Domain: domain}
+	r.checkSPF(domain)
+	for _, s := range selectors {
+		r.checkDKIM(domain, s)
+	}
+	r.checkDMARC(domain)
+	r.checkMX(domain)
+	if d != nil {
+		r.checkSMTP(d)
+	}
+	return r
+}
+
+// txtRecords returns the TXT records of name starting with the given version
+// tag, case-insensitively.
+func txtRecords(name, version string) ([]string, error) {
+	txts, err := lookupTXT(name)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []string
+	for _, txt := range txts {
+		if len(txt) < len(version) || !strings.EqualFold(txt[:len(version)], version) {
+			continue
+		}
+		if version == "" || len(txt) == len(version) || txt[len(version)] == ' ' || txt[len(version)] == ';' {
+			records = append(records, txt)
+		}
+	}
+	return records, nil
+}
+
+// spfLookups are the SPF terms causing a DNS lookup, limited to 10 by RFC 7208.
+var spfLookups = map[string]bool{
+	"include": true, "a": true, "mx": true, "ptr": true, "exists": true, "redirect": true,
+}
+
+func (r *DoctorReport) checkSPF(domain string) {
+	records, err := txtRecords(domain, "v=spf1")
+	if err != nil {
+		r.add("SPF", CheckFailed, "could not look up the SPF record: %v", err)
+		return
+	}
+	switch {
+	case len(records) == 0:
+		r.add("SPF", CheckFailed, "no SPF record published at %s", domain)
+		return
+	case len(records) > 1:
+		r.add("SPF", CheckFailed, "%d SPF records published at %s, only one is allowed", len(records), domain)
+		return
+	}
+
+	terms := strings.Fields(records[0])[1:]
+	var warnings []string
+	lookups := 0
+	for _, term := range terms {
+		name, err := parseSPFTerm(term)
+		if err != nil {
+			r.add("SPF", CheckFailed, "invalid record %q: %v", records[0], err)
+			return
+		}
+		if spfLookups[name] {
+			lookups++
+		}
+		switch {
+		case name == "ptr":
+			warnings = append(warnings, "the ptr mechanism is deprecated")
+		case term == "all" || term == "+all":
+			warnings = append(warnings, "+all allows any server to send emails for the domain")
+		}
+	}
+	if lookups > 10 {
+		r.add("SPF", CheckFailed, "%d terms need a DNS lookup, the limit is 10", lookups)
+		return
+	}
+
+	if len(warnings) > 0 {
+		r.add("SPF", CheckWarning, "%s", strings.Join(warnings, "; "))
+		return
+	}
+	r.add("SPF", CheckOK, "%s", records[0])
+}
+
+// parseSPFTerm checks the syntax of an SPF term and returns its name.
+func parseSPFTerm(term string) (string, error) {
+	if i := strings.IndexByte(term, '='); i > 0 && !strings.ContainsAny(term[:i], ":/") {
+		name := strings.ToLower(term[:i])
+		if (name == "redirect" || name == "exp") && term[i+1:] == "" {
+			return "", fmt.Errorf("%s modifier without domain", name)
+		}
+		return name, nil
+	}
+
+	if strings.ContainsAny(term[:1], "+-~?") {
+		term = term[1:]
+	}
+	name, value := term, ""
+	if i := strings.IndexAny(term, ":/"); i >= 0 {
+		name, value = term[:i], term[i:]
+	}
+	name = strings.ToLower(name)
+
+	switch name {
+	case "all":
+		if value != "" {
+			return "", fmt.Errorf("unexpected value in %q", term)
+		}
+	case "include", "exists":
+		if len(value) < 2 || value[0] != ':' {
+			return "", fmt.Errorf("%s mechanism without domain", name)
+		}
+	case "a", "mx", "ptr":
+	case "ip4", "ip6":
+		if len(value) < 2 || value[0] != ':' {
+			return "", fmt.Errorf("%s mechanism without address", name)
+		}
+		ip := value[1:]
+		if i := strings.IndexByte(ip, '/'); i >= 0 {
+			bits, err := strconv.Atoi(ip[i+1:])
+			if err != nil || bits < 0 || (name == "ip4" && bits > 32) || bits > 128 {
+				return "", fmt.Errorf("invalid prefix length in %q", term)
+			}
+			ip = ip[:i]
+		}
+		parsed := net.ParseIP(ip)
+		if parsed == nil || (name == "ip4") != (parsed.To4() != nil && !strings.Contains(ip, ":")) {
+			return "", fmt.Errorf("invalid address in %q", term)
+		}
+	default:
+		return "", fmt.Errorf("unknown mechanism %q", term)
+	}
+	return name, nil
+}
+
+func (r *DoctorReport) checkDKIM(domain, selector string) {
+	name := "DKIM " + selector
+	host := selector + "._domainkey." + domain
+	records, err := txtRecords(host, "")
+	if err != nil {
+		r.add(name, CheckFailed, "could not look up the DKIM key: %v", err)
+		return
+	}
+
+	var keys []map[string]string
+	for _, txt := range records {
+		tags := parseTagList(txt)
+		if v, ok := tags["v"]; ok && v != "DKIM1" {
+			continue
+		}
+		if _, ok := tags["p"]; ok {
+			keys = append(keys, tags)
+		}
+	}
+	switch {
+	case len(keys) == 0:
+		r.add(name, CheckFailed, "no DKIM key published at %s", host)
+		return
+	case len(keys) > 1:
+		r.add(name, CheckFailed, "%d DKIM keys published at %s, only one is allowed", len(keys), host)
+		return
+	}
+
+	tags := keys[0]
+	alg := tags["k"]
+	if alg == "" {
+		alg = "rsa"
+	}
+	if alg != "rsa" && alg != "ed25519" {
+		r.add(name, CheckFailed, "unsupported key type %q", alg)
+		return
+	}
+	p := strings.Join(strings.Fields(tags["p"]), "")
+	if p == "" {
+		r.add(name, CheckFailed, "the key published at %s has been revoked", host)
+		return
+	}
+	if _, err := base64.StdEncoding.DecodeString(p); err != nil {
+		r.add(name, CheckFailed, "invalid public key at %s: %v", host, err)
+		return
+	}
+	if strings.Contains(tags["t"], "y") {
+		r.add(name, CheckWarning, "the key is in testing mode (t=y)")
+		return
+	}
+	r.add(name, CheckOK, "%s key published at %s", alg, host)
+}
+
+// parseTagList parses a DKIM or DMARC tag list as defined in RFC 6376,
+// section 3.2.
+func parseTagList(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, spec := range strings.Split(s, ";") {
+		i := strings.IndexByte(spec, '=')
+		if i < 0 {
+			continue
+		}
+		tags[strings.TrimSpace(spec[:i])] = strings.TrimSpace(spec[i+1:])
+	}
+	return tags
+}
+
+func (r *DoctorReport) checkDMARC(domain string) {
+	host := "_dmarc." + domain
+	records, err := txtRecords(host, "v=DMARC1")
+	if err != nil {
+		r.add("DMARC", CheckFailed, "could not look up the DMARC policy: %v", err)
+		return
+	}
+	switch {
+	case len(records) == 0:
+		r.add("DMARC", CheckFailed, "no DMARC policy published at %s", host)
+		return
+	case len(records) > 1:
+		r.add("DMARC", CheckFailed, "%d DMARC policies published at %s, only one is allowed", len(records), host)
+		return
+	}
+
+	tags := parseTagList(records[0])
+	switch p := strings.ToLower(tags["p"]); p {
+	case "quarantine", "reject":
+		r.add("DMARC", CheckOK, "policy %s", p)
+	case "none":
+		r.add("DMARC", CheckWarning, "policy none only monitors the emails failing authentication")
+	default:
+		r.add("DMARC", CheckFailed, "invalid policy %q", tags["p"])
+	}
+}
+
+func (r *DoctorReport) checkMX(domain string) {
+	mxs, err := lookupMX(domain)
+	if err != nil {
+		r.add("MX", CheckFailed, "could not look up the mail exchangers: %v", err)
+		return
+	}
+	if len(mxs) == 0 {
+		r.add("MX", CheckFailed, "no MX record published at %s", domain)
+		return
+	}
+
+	var errs []string
+	for _, mx := range mxs {
+		host := strings.TrimSuffix(mx.Host, ".")
+		conn, err := netDialTimeout("tcp", net.JoinHostPort(host, "25"), 10*time.Second)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		conn.Close()
+		r.add("MX", CheckOK, "%s is reachable", host)
+		return
+	}
+	r.add("MX", CheckFailed, "no mail exchanger is reachable: %s", strings.Join(errs, "; "))
+}
+
+func (r *DoctorReport) checkSMTP(d *Dialer) {
+	s, err := d.Dial()
+	if err != nil {
+		r.add("SMTP", CheckFailed, "could not connect to %s: %v", d.address(), err)
+		return
+	}
+	s.Close()
+
+	if d.Username != "" || d.Auth != nil {
+		r.add("SMTP", CheckOK, "authenticated to %s", d.address())
+	} else {
+		r.add("SMTP", CheckOK, "connected to %s", d.address())
+	}
+}
+
+// Stubbed out for tests.
+var (
+	lookupTXT = net.LookupTXT
+	lookupMX  = net.LookupMX
+)
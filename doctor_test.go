@@ -0,0 +1,160 @@
+package gomail
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func stubDNS(t *testing.T, txt map[string][]string, mx []*net.MX) func() {
+	oldTXT, oldMX, oldDial := lookupTXT, lookupMX, netDialTimeout
+	lookupTXT = func(name string) ([]string, error) {
+		records, ok := txt[name]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return records, nil
+	}
+	lookupMX = func(name string) ([]*net.MX, error) {
+		return mx, nil
+	}
+	netDialTimeout = func(network, address string, d time.Duration) (net.Conn, error) {
+		if address == "mx2.example.com:25" {
+			return testConn, nil
+		}
+		if address != "mx1.example.com:25" {
+			t.Errorf("Invalid address %q", address)
+		}
+		return nil, errors.New("connection refused")
+	}
+	return func() {
+		lookupTXT, lookupMX, netDialTimeout = oldTXT, oldMX, oldDial
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	defer stubDNS(t, map[string][]string{
+		"example.com": {
+			"google-site-verification=abc",
+			"v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 include:_spf.example.net -all",
+		},
+		"s1._domainkey.example.com": {"v=DKIM1; k=ed25519; p=ehfbo/2xz5r7lbX8r62CQmzrooSMwlomWm/jnoVT6Yg="},
+		"s2._domainkey.example.com": {"v=DKIM1; p="},
+		"_dmarc.example.com":        {"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+	}, []*net.MX{{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}})()
+
+	d := NewDialer(testHost, testPort, "user", "pwd")
+	c := &mockClient{
+		t:    t,
+		want: []string{"Extension STARTTLS", "StartTLS", "Extension AUTH", "Auth", "Quit", "Close"},
+	}
+	oldDial, oldClient := netDialTimeout, smtpNewClient
+	defer func() { smtpNewClient = oldClient }()
+	netDialTimeout = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		if address == addr(testHost, testPort) {
+			return testConn, nil
+		}
+		return oldDial(network, address, timeout)
+	}
+	smtpNewClient = func(conn net.Conn, host string) (smtpClient, error) {
+		return c, nil
+	}
+
+	r := Doctor("example.com", d, "s1", "s2", "s3")
+	want := []struct {
+		name   string
+		status CheckStatus
+	}{
+		{"SPF", CheckOK},
+		{"DKIM s1", CheckOK},
+		{"DKIM s2", CheckFailed},
+		{"DKIM s3", CheckFailed},
+		{"DMARC", CheckOK},
+		{"MX", CheckOK},
+		{"SMTP", CheckOK},
+	}
+	if len(r.Checks) != len(want) {
+		t.Fatalf("Invalid checks, got:\n%s", r)
+	}
+	for i, w := range want {
+		if c := r.Checks[i]; c.Name != w.name || c.Status != w.status {
+			t.Errorf("Invalid check #%d, got %s %s: %s, want %s %s", i, c.Status, c.Name, c.Detail, w.status, w.name)
+		}
+	}
+	if !strings.Contains(r.Checks[5].Detail, "mx2.example.com") {
+		t.Errorf("Invalid MX detail, got %q", r.Checks[5].Detail)
+	}
+	if !strings.Contains(r.Checks[2].Detail, "revoked") {
+		t.Errorf("Invalid DKIM detail, got %q", r.Checks[2].Detail)
+	}
+	if r.OK() {
+		t.Error("OK should be false when a check failed")
+	}
+}
+
+func TestDoctorSPF(t *testing.T) {
+	tests := []struct {
+		records []string
+		status  CheckStatus
+		detail  string
+	}{
+		{[]string{"v=spf1 mx ~all"}, CheckOK, ""},
+		{[]string{"V=SPF1 a:mail.example.com/24 redirect=_spf.example.com"}, CheckOK, ""},
+		{nil, CheckFailed, "no SPF record"},
+		{[]string{"v=spf10 -all"}, CheckFailed, "no SPF record"},
+		{[]string{"v=spf1 -all", "v=spf1 mx -all"}, CheckFailed, "2 SPF records"},
+		{[]string{"v=spf1 ip4:192.0.2.300 -all"}, CheckFailed, "invalid address"},
+		{[]string{"v=spf1 ip4:2001:db8::1 -all"}, CheckFailed, "invalid address"},
+		{[]string{"v=spf1 ip6:2001:db8::/129 -all"}, CheckFailed, "invalid prefix length"},
+		{[]string{"v=spf1 include -all"}, CheckFailed, "include mechanism without domain"},
+		{[]string{"v=spf1 mx allow"}, CheckFailed, "unknown mechanism"},
+		{[]string{"v=spf1 ptr -all"}, CheckWarning, "ptr"},
+		{[]string{"v=spf1 mx +all"}, CheckWarning, "+all"},
+		{[]string{"v=spf1 " + strings.Repeat("include:example.net ", 11) + "-all"}, CheckFailed, "11 terms"},
+	}
+
+	for _, test := range tests {
+		txt := map[string][]string{}
+		if test.records != nil {
+			txt["example.com"] = test.records
+		}
+		restore := stubDNS(t, txt, nil)
+		r := &DoctorReport{}
+		r.checkSPF("example.com")
+		restore()
+
+		c := r.Checks[0]
+		if c.Status != test.status || !strings.Contains(c.Detail, test.detail) {
+			t.Errorf("Invalid check for %q, got %s: %s, want %s: %s", test.records, c.Status, c.Detail, test.status, test.detail)
+		}
+	}
+}
+
+func TestDoctorDMARC(t *testing.T) {
+	tests := []struct {
+		records []string
+		status  CheckStatus
+	}{
+		{[]string{"v=DMARC1; p=quarantine"}, CheckOK},
+		{[]string{"v=DMARC1; p=none; rua=mailto:dmarc@example.com"}, CheckWarning},
+		{[]string{"v=DMARC1; rua=mailto:dmarc@example.com"}, CheckFailed},
+		{nil, CheckFailed},
+	}
+
+	for _, test := range tests {
+		txt := map[string][]string{}
+		if test.records != nil {
+			txt["_dmarc.example.com"] = test.records
+		}
+		restore := stubDNS(t, txt, nil)
+		r := &DoctorReport{}
+		r.checkDMARC("example.com")
+		restore()
+
+		if c := r.Checks[0]; c.Status != test.status {
+			t.Errorf("Invalid check for %q, got %s: %s, want %s", test.records, c.Status, c.Detail, test.status)
+		}
+	}
+}
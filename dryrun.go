@@ -0,0 +1,32 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+)
+
+// dryRunSender writes the emails to the DryRunOutput of a Dialer instead of
+// sending them.
+type dryRunSender struct {
+	d *Dialer
+}
+
+func (s *dryRunSender) Send(from string, to []string, msg io.WriterTo) error {
+	var buf bytes.Buffer
+	if err := writeEnvelopeFields(&buf, from, to); err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+	buf.WriteString("\r\n")
+	if _, err := s.d.DryRunOutput.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.d.logf("gomail: dry run, email to %d recipients not sent", len(to))
+	return nil
+}
+
+func (s *dryRunSender) Close() error {
+	return nil
+}
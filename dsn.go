@@ -0,0 +1,138 @@
+package gomail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DSN represents the delivery status notifications requested from the SMTP
+// server as defined in RFC 3461. The parameters are only sent if the server
+// advertises the DSN extension.
+type DSN struct {
+	// Notify defines the conditions under which a notification is sent to the
+	// envelope sender. If zero, the server uses its default (usually
+	// NotifyFailure|NotifyDelay).
+	Notify DSNNotify
+	// Return defines whether the notification should contain the full message
+	// or only its headers. If empty, the server uses its default.
+	Return DSNReturn
+	// EnvelopeID is an identifier returned in the notifications so they can be
+	// matched with the original message.
+	EnvelopeID string
+}
+
+// DSNNotify represents the NOTIFY parameter of the RCPT command.
+type DSNNotify uint8
+
+const (
+	// NotifySuccess requests a notification when the email is delivered.
+	NotifySuccess DSNNotify = 1 << iota
+	// NotifyFailure requests a notification when the email cannot be
+	// delivered.
+	NotifyFailure
+	// NotifyDelay requests a notification when the delivery is delayed.
+	NotifyDelay
+	// NotifyNever requests that no notification is ever sent. It cannot be
+	// combined with other values.
+	NotifyNever
+)
+
+func (n DSNNotify) String() string {
+	if n&NotifyNever != 0 {
+		return "NEVER"
+	}
+
+	var list []string
+	if n&NotifySuccess != 0 {
+		list = append(list, "SUCCESS")
+	}
+	if n&NotifyFailure != 0 {
+		list = append(list, "FAILURE")
+	}
+	if n&NotifyDelay != 0 {
+		list = append(list, "DELAY")
+	}
+	return strings.Join(list, ",")
+}
+
+// DSNReturn represents the RET parameter of the MAIL command.
+type DSNReturn string
+
+const (
+	// ReturnHeaders requests that only the headers of the message are returned
+	// in the notification.
+	ReturnHeaders DSNReturn = "HDRS"
+	// ReturnFull requests that the full message is returned in the
+	// notification.
+	ReturnFull DSNReturn = "FULL"
+)
+
+// SetDSN is a message setting to request delivery status notifications for the
+// email. It overrides the DSN field of the Dialer.
+func SetDSN(dsn *DSN) MessageSetting {
+	return func(m *Message) {
+		m.dsn = dsn
+	}
+}
+
+func (d *DSN) mailParams() []string {
+	if d == nil {
+		return nil
+	}
+
+	var params []string
+	if d.Return != "" {
+		params = append(params, "RET="+string(d.Return))
+	}
+	if d.EnvelopeID != "" {
+		params = append(params, "ENVID="+xtext(d.EnvelopeID))
+	}
+	return params
+}
+
+func (d *DSN) rcptParams(addr string) []string {
+	if d == nil {
+		return nil
+	}
+
+	var params []string
+	if d.Notify != 0 {
+		params = append(params, "NOTIFY="+d.Notify.String())
+	}
+	return append(params, "ORCPT=rfc822;"+xtext(addr))
+}
+
+// xtext encodes s as defined in RFC 3461, section 4.
+func xtext(s string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || c == '+' || c == '=' {
+			fmt.Fprintf(&b, "+%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// dsn returns the DSN requested for msg if the server supports it.
+func (c *smtpSender) dsn(e *Envelope, msg io.WriterTo) *DSN {
+	dsn := c.d.DSN
+	if m, ok := msg.(*Message); ok && m.dsn != nil {
+		dsn = m.dsn
+	}
+	if e.Options.DSN != nil {
+		dsn = e.Options.DSN
+	}
+	if dsn == nil {
+		return nil
+	}
+
+	if ok, _ := c.Extension("DSN"); !ok {
+		return nil
+	}
+	return dsn
+}
@@ -0,0 +1,36 @@
+package gomail
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDSNParams(t *testing.T) {
+	tests := []struct {
+		dsn  *DSN
+		mail []string
+		rcpt []string
+	}{
+		{nil, nil, nil},
+		{&DSN{}, nil, []string{"ORCPT=rfc822;" + testTo1}},
+		{
+			&DSN{Notify: NotifyDelay | NotifyFailure, Return: ReturnFull, EnvelopeID: "a b=c"},
+			[]string{"RET=FULL", "ENVID=a+20b+3Dc"},
+			[]string{"NOTIFY=FAILURE,DELAY", "ORCPT=rfc822;" + testTo1},
+		},
+		{
+			&DSN{Notify: NotifyNever | NotifySuccess},
+			nil,
+			[]string{"NOTIFY=NEVER", "ORCPT=rfc822;" + testTo1},
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.dsn.mailParams(); !reflect.DeepEqual(got, test.mail) {
+			t.Errorf("Invalid MAIL parameters, got %q, want %q", got, test.mail)
+		}
+		if got := test.dsn.rcptParams(testTo1); !reflect.DeepEqual(got, test.rcpt) {
+			t.Errorf("Invalid RCPT parameters, got %q, want %q", got, test.rcpt)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrSMTPUTF8Unsupported is returned when an email is sent to or from an
+// address with a non-ASCII local part and the SMTP server does not support the
+// SMTPUTF8 extension defined in RFC 6531.
+var ErrSMTPUTF8Unsupported = errors.New("gomail: the server does not support SMTPUTF8")
+
+// smtpAddresses returns the envelope addresses used with the server. Addresses
+// that are not ASCII are kept as is if the server supports SMTPUTF8, otherwise
+// their domain is converted to punycode.
+func (c *smtpSender) smtpAddresses(e *Envelope) (from string, to []string, err error) {
+	if isASCII(e.From) && allASCII(e.To) {
+		return e.From, e.To, nil
+	}
+	if ok, _ := c.Extension("SMTPUTF8"); ok {
+		return e.From, e.To, nil
+	}
+
+	if from, err = asciiAddress(e.From); err != nil {
+		return "", nil, err
+	}
+	to = make([]string, len(e.To))
+	for i, addr := range e.To {
+		if to[i], err = asciiAddress(addr); err != nil {
+			return "", nil, err
+		}
+	}
+	return from, to, nil
+}
+
+// asciiAddress converts the domain of addr to punycode. It returns an error if
+// the local part of addr is not ASCII since it cannot be converted.
+func asciiAddress(addr string) (string, error) {
+	if isASCII(addr) {
+		return addr, nil
+	}
+	local, domain := splitAddress(addr)
+	if !isASCII(local) {
+		return "", fmt.Errorf("gomail: could not use address %q: %w", addr, ErrSMTPUTF8Unsupported)
+	}
+	domain, err := asciiDomain(domain)
+	if err != nil {
+		return "", fmt.Errorf("gomail: invalid domain in address %q: %w", addr, err)
+	}
+	return local + "@" + domain, nil
+}
+
+// asciiDomain converts an internationalized domain name to its ASCII form as
+// defined in RFC 5891. Labels are only lowercased, not fully normalized, so the
+// domain should already be in Unicode Normalization Form C.
+func asciiDomain(domain string) (string, error) {
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		enc, err := punycode(strings.ToLower(label))
+		if err != nil {
+			return "", err
+		}
+		labels[i] = "xn--" + enc
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func allASCII(list []string) bool {
+	for _, s := range list {
+		if !isASCII(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Punycode parameters defined in RFC 3492, section 5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encodes s as defined in RFC 3492.
+func punycode(s string) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", errors.New("invalid UTF-8")
+	}
+	runes := []rune(s)
+	var out []byte
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	b := len(out)
+	h := b
+	if b > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for h < len(runes) {
+		m := int(utf8.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out), nil
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > (punyBase-punyTMin)*punyTMax/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
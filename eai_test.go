@@ -0,0 +1,123 @@
+package gomail
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPunycode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"bücher", "bcher-kva"},
+		{"münchen", "mnchen-3ya"},
+		{"mañana", "maana-pta"},
+		{"пример", "e1afmkfd"},
+		{"日本語", "wgv71a119e"},
+	}
+
+	for _, test := range tests {
+		got, err := punycode(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("punycode(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestASCIIAddress(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"bob@example.com", "bob@example.com"},
+		{"bob@Bücher.example", "bob@xn--bcher-kva.example"},
+		{"bob@mail.пример.рф", "bob@mail.xn--e1afmkfd.xn--p1ai"},
+	}
+
+	for _, test := range tests {
+		got, err := asciiAddress(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("asciiAddress(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+
+	if _, err := asciiAddress("josé@example.com"); !errors.Is(err, ErrSMTPUTF8Unsupported) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrSMTPUTF8Unsupported)
+	}
+}
+
+func TestSMTPUTF8(t *testing.T) {
+	const rcpt = "josé@bücher.example"
+	tests := []struct {
+		unsupported bool
+		from, to    string
+		want        []string
+		err         error
+	}{
+		{false, testFrom, rcpt, []string{
+			"Extension SMTPUTF8",
+			"Mail " + testFrom,
+			"Rcpt " + rcpt,
+			"Data",
+			"Write message",
+			"Close writer",
+		}, nil},
+		{true, "bob@bücher.example", testTo1, []string{
+			"Extension SMTPUTF8",
+			"Mail bob@xn--bcher-kva.example",
+			"Rcpt " + testTo1,
+			"Data",
+			"Write message",
+			"Close writer",
+		}, nil},
+		{true, testFrom, rcpt, []string{
+			"Extension SMTPUTF8",
+		}, ErrSMTPUTF8Unsupported},
+	}
+
+	for _, test := range tests {
+		c := &mockClient{t: t, want: test.want}
+		if test.unsupported {
+			c.unsupported = map[string]bool{"SMTPUTF8": true}
+		}
+		s := &smtpSender{smtpClient: c, d: &Dialer{}}
+
+		err := s.SendEnvelope(&Envelope{From: test.from, To: []string{test.to}}, getTestMessage())
+		if !errors.Is(err, test.err) {
+			t.Errorf("Invalid error, got %v, want %v", err, test.err)
+		}
+		if c.i != len(test.want) {
+			t.Errorf("Missing commands, got %q", test.want[:c.i])
+		}
+	}
+}
+
+func TestSetHeaderInternationalizedAddress(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", "José <josé@bücher.example>")
+	m.SetHeader("To", "ü@bücher.example")
+	m.SetHeader("Subject", "josé@bücher.example")
+
+	if got, want := m.GetHeader("From")[0], "=?UTF-8?q?Jos=C3=A9?= <josé@bücher.example>"; got != want {
+		t.Errorf("Invalid From, got %q, want %q", got, want)
+	}
+	if got, want := m.GetHeader("To")[0], "ü@bücher.example"; got != want {
+		t.Errorf("Invalid To, got %q, want %q", got, want)
+	}
+	if got, want := m.GetHeader("Subject")[0], "=?UTF-8?q?jos=C3=A9@b=C3=BCcher.example?="; got != want {
+		t.Errorf("Invalid Subject, got %q, want %q", got, want)
+	}
+
+	e, err := m.Envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.From != "josé@bücher.example" || len(e.To) != 1 || e.To[0] != "ü@bücher.example" {
+		t.Errorf("Invalid envelope, got %+v", e)
+	}
+}
@@ -0,0 +1,77 @@
+package gomail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// EmbedWithCID embeds the file like Embed but with a unique generated
+// Content-ID and returns the cid: URL referencing it, so the HTML bodies and
+// templates do not depend on the file name:
+//
+//	logo := m.EmbedWithCID("/path/to/logo.png")
+//	m.SetBody("text/html", `<img src="`+logo+`" alt="Logo">`)
+//
+// The identifier is generated by the IDGenerator of the message, or numbered
+// with the Deterministic setting. A Content-ID set by the settings is
+// replaced.
+func (m *Message) EmbedWithCID(filename string, settings ...FileSetting) string {
+	id := m.newContentID()
+	settings = append(settings[:len(settings):len(settings)], SetHeader(map[string][]string{
+		"Content-ID": {"<" + id + ">"},
+	}))
+	m.Embed(filename, settings...)
+	return "cid:" + id
+}
+
+// EmbedReaderWithCID is like EmbedWithCID but the content of the file is read
+// from r the first time the email is written and kept in memory, so the email
+// can be written several times.
+func (m *Message) EmbedReaderWithCID(name string, r io.Reader, settings ...FileSetting) string {
+	copier := readerCopier(r)
+	return m.EmbedWithCID(name, append([]FileSetting{func(f *file) {
+		f.CopyFunc = copier
+		f.repeatable = true
+	}}, settings...)...)
+}
+
+// EmbedBytesWithCID is like EmbedWithCID but the content of the file is b.
+func (m *Message) EmbedBytesWithCID(name string, b []byte, settings ...FileSetting) string {
+	return m.EmbedReaderWithCID(name, bytes.NewReader(b), settings...)
+}
+
+// newContentID returns a new identifier for an embedded file, made of a
+// generated identifier and the domain of the Message-ID. If the IDGenerator
+// fails, the identifier is numbered instead.
+func (m *Message) newContentID() string {
+	var id string
+	var err error
+	if !m.deterministic {
+		id, err = newID(m.idGenerator)
+	}
+	if m.deterministic || err != nil {
+		id = fmt.Sprintf("%026d", len(m.embedded)+1)
+	}
+	return id + "@" + m.messageIDDomainOrDefault()
+}
+
+// readerCopier returns a copy function writing the content read from r, read
+// once.
+func readerCopier(r io.Reader) func(io.Writer) error {
+	var once sync.Once
+	var content []byte
+	var readErr error
+	return func(w io.Writer) error {
+		once.Do(func() {
+			content, readErr = ioutil.ReadAll(r)
+		})
+		if readErr != nil {
+			return readErr
+		}
+		_, err := w.Write(content)
+		return err
+	}
+}
@@ -0,0 +1,50 @@
+package gomail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEmbedWithCID(t *testing.T) {
+	m := NewMessage(SetIDGenerator(IDGeneratorFunc(func() (string, error) {
+		return "ID1", nil
+	})), SetMessageIDDomain("example.com"))
+	if got, want := m.EmbedBytesWithCID("logo.png", []byte("PNG")), "cid:ID1@example.com"; got != want {
+		t.Errorf("Invalid URL, got %q, want %q", got, want)
+	}
+
+	m = NewMessage(Deterministic(), SetMessageIDDomain("example.com"))
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	logo := m.EmbedBytesWithCID("logo.png", []byte("PNG"), SetHeader(map[string][]string{
+		"Content-ID": {"<logo>"},
+	}))
+	photo := m.EmbedReaderWithCID("images/photo.jpg", strings.NewReader("JPEG"))
+	m.SetBody("text/html", `<img src="`+logo+`"><img src="`+photo+`">`)
+
+	if logo != "cid:00000000000000000000000001@example.com" || photo != "cid:00000000000000000000000002@example.com" {
+		t.Errorf("Invalid URLs, got %q and %q", logo, photo)
+	}
+
+	// The email can be written several times.
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		if _, err := m.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"Content-ID: <00000000000000000000000001@example.com>\r\n",
+			"Content-ID: <00000000000000000000000002@example.com>\r\n",
+			`filename="photo.jpg"`,
+			"\r\n\r\nSlBFRw==\r\n",
+		} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("Missing %q in:\n%s", want, buf.String())
+			}
+		}
+	}
+	if err := m.Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,213 @@
+package gomail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// SaveToFile writes the message in an EML file at path. EML files can be opened
+// by most email clients, for example to review an email before sending it.
+//
+// The Bcc field is not written, like when the message is sent.
+func (m *Message) SaveToFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = m.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// A ParsedMessage is an email parsed from its MIME form, for example loaded
+// from an EML file. It implements io.WriterTo so it can be sent with
+// SendEnvelope, using the envelope returned by its Envelope method.
+type ParsedMessage struct {
+	// Header is the header of the email.
+	Header mail.Header
+
+	header []byte
+	body   []byte
+}
+
+// LoadEML loads the email saved in the EML file at path.
+func LoadEML(path string) (*ParsedMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMessage(f)
+}
+
+// ParseMessage parses the email read from r.
+func ParseMessage(r io.Reader) (*ParsedMessage, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("gomail: invalid email: %w", err)
+	}
+
+	p := &ParsedMessage{Header: msg.Header, header: b}
+	for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+		if i := bytes.Index(b, []byte(sep)); i >= 0 {
+			p.header, p.body = b[:i+len(sep)/2], b[i+len(sep)/2:]
+			break
+		}
+	}
+	return p, nil
+}
+
+// Envelope returns the envelope of the email: the address of the Sender or
+// From field and the addresses of the To, Cc and Bcc fields.
+func (p *ParsedMessage) Envelope() (*Envelope, error) {
+	e := new(Envelope)
+	for _, field := range []string{"Sender", "From"} {
+		if p.Header.Get(field) == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(p.Header.Get(field))
+		if err != nil {
+			return nil, fmt.Errorf("gomail: invalid %q field: %w", field, err)
+		}
+		e.From = addr.Address
+		break
+	}
+	if e.From == "" {
+		return nil, errors.New(`gomail: invalid message, "From" field is absent`)
+	}
+
+	for _, field := range []string{"To", "Cc", "Bcc"} {
+		list, err := p.Header.AddressList(field)
+		if err == mail.ErrHeaderNotPresent {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("gomail: invalid %q field: %w", field, err)
+		}
+		for _, a := range list {
+			e.To = addAddress(e.To, a.Address)
+		}
+	}
+	return e, nil
+}
+
+// WriteTo implements io.WriterTo. It writes the email as it was parsed except
+// for the Bcc field, which is removed.
+func (p *ParsedMessage) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	skip := false
+	for h := p.header; len(h) > 0; {
+		line := h
+		if i := bytes.IndexByte(h, '\n'); i >= 0 {
+			line = h[:i+1]
+		}
+		h = h[len(line):]
+
+		continued := line[0] == ' ' || line[0] == '\t'
+		if !continued {
+			skip = len(line) > 4 && bytes.EqualFold(line[:4], []byte("bcc:"))
+		}
+		if skip {
+			continue
+		}
+		nn, err := w.Write(line)
+		n += int64(nn)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	nn, err := w.Write(p.body)
+	return n + int64(nn), err
+}
+
+// Attachments calls yield for each file attached or embedded in the email, in
+// order, with its decoded content, until yield returns false. If the email is
+// malformed, yield is called with the error and the iteration stops. With Go
+// 1.23 or newer, Attachments can be used in a for range loop:
+//
+//	for f, err := range p.Attachments {
+//		...
+//	}
+func (p *ParsedMessage) Attachments(yield func(FilePart, error) bool) {
+	h := textproto.MIMEHeader(p.Header)
+	if _, err := walkAttachments(h, bytes.NewReader(p.body), yield); err != nil {
+		yield(FilePart{}, err)
+	}
+}
+
+// walkAttachments calls yield for the files of the MIME part read from body.
+// It returns false if yield returned false.
+func walkAttachments(h textproto.MIMEHeader, body io.Reader, yield func(FilePart, error) bool) (bool, error) {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return true, nil
+			}
+			if err != nil {
+				return false, fmt.Errorf("gomail: invalid email: %w", err)
+			}
+			if ok, err := walkAttachments(part.Header, part, yield); !ok || err != nil {
+				return ok, err
+			}
+		}
+	}
+
+	name := params["name"]
+	disp, dparams, err := mime.ParseMediaType(h.Get("Content-Disposition"))
+	if err == nil && dparams["filename"] != "" {
+		name = dparams["filename"]
+	}
+	if name == "" && disp != "attachment" {
+		return true, nil
+	}
+
+	// multipart.Reader already decodes quoted-printable parts and removes
+	// their Content-Transfer-Encoding header.
+	switch strings.ToLower(h.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return false, fmt.Errorf("gomail: could not decode the file %q: %w", name, err)
+	}
+
+	header := make(map[string][]string, len(h))
+	for k, v := range h {
+		header[k] = copyValues(v)
+	}
+	return yield(FilePart{
+		Name:   decodePreviewHeader(name),
+		Header: header,
+		copier: func(w io.Writer) error {
+			_, err := w.Write(b)
+			return err
+		},
+	}, nil), nil
+}
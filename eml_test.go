@@ -0,0 +1,169 @@
+package gomail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndLoadEML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := getTestMessage()
+	m.SetHeader("Bcc", "bcc@example.com")
+	path := filepath.Join(dir, "draft.eml")
+	if err := m.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareBodies(t, string(b), testMsg)
+
+	p, err := LoadEML(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Header.Get("From"); got != testFrom {
+		t.Errorf("Invalid From field, got %q, want %q", got, testFrom)
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(b) {
+		t.Errorf("Invalid email, got %q, want %q", buf.String(), b)
+	}
+
+	if _, err := LoadEML(filepath.Join(dir, "missing.eml")); err == nil {
+		t.Error("LoadEML should fail with a missing file")
+	}
+}
+
+func TestParsedMessageEnvelope(t *testing.T) {
+	p, err := ParseMessage(strings.NewReader("From: Alice <alice@example.com>\r\n" +
+		"Sender: bob@example.com\r\n" +
+		"To: carol@example.com, \"Dan\" <dan@example.com>\r\n" +
+		"Cc: carol@example.com\r\n" +
+		"Bcc: eve@example.com\r\n" +
+		"\r\n" +
+		"Hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := p.Envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Envelope{
+		From: "bob@example.com",
+		To:   []string{"carol@example.com", "dan@example.com", "eve@example.com"},
+	}
+	if !reflect.DeepEqual(e, want) {
+		t.Errorf("Invalid envelope, got %#v, want %#v", e, want)
+	}
+
+	p, err = ParseMessage(strings.NewReader("To: carol@example.com\r\n\r\nHello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Envelope(); err == nil {
+		t.Error("Envelope should fail without a From field")
+	}
+}
+
+func TestParsedMessageWriteTo(t *testing.T) {
+	p, err := ParseMessage(strings.NewReader("From: alice@example.com\n" +
+		"BCC: bob@example.com,\n" +
+		"\tcarol@example.com\n" +
+		"Subject: Hi\n" +
+		"\n" +
+		"Bcc: not a header\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "From: alice@example.com\nSubject: Hi\n\nBcc: not a header\n"
+	if buf.String() != want {
+		t.Errorf("Invalid email, got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestParsedMessageAttachments(t *testing.T) {
+	m := getTestMessage()
+	m.AddAlternative("text/html", "<p>Hello</p>")
+	m.Attach(mockCopyFile("/tmp/test.pdf"))
+	m.Embed(mockCopyFile("image.jpg"))
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParseMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	p.Attachments(func(f FilePart, err error) bool {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var content bytes.Buffer
+		if _, err := f.WriteTo(&content); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, f.Name+": "+content.String())
+		return true
+	})
+	want := []string{"image.jpg: Content of image.jpg", "test.pdf: Content of test.pdf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid attachments, got %q, want %q", got, want)
+	}
+
+	got = nil
+	p.Attachments(func(f FilePart, err error) bool {
+		got = append(got, f.Name)
+		return false
+	})
+	if len(got) != 1 {
+		t.Errorf("Attachments should stop when yield returns false, got %v", got)
+	}
+}
+
+func TestParsedMessageAttachmentsInvalid(t *testing.T) {
+	p, err := ParseMessage(strings.NewReader("From: " + testFrom + "\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/pdf; name=\"test.pdf\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"not base64!\r\n" +
+		"--b--\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	p.Attachments(func(f FilePart, err error) bool {
+		errs = append(errs, err)
+		return true
+	})
+	if len(errs) != 1 || errs[0] == nil {
+		t.Errorf("Attachments should yield the decoding error, got %v", errs)
+	}
+}
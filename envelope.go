@@ -0,0 +1,108 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+)
+
+// An Envelope is the SMTP envelope of an email: the addresses given to the
+// MAIL FROM and RCPT TO commands and the parameters of these commands. It is
+// independent of the content of the email so emails not built with Message,
+// for example relayed emails, can be sent and enqueued.
+type Envelope struct {
+	// From is the address bounces are sent to.
+	From string
+	// To are the addresses of the recipients.
+	To []string
+	// Options are the parameters of the envelope.
+	Options EnvelopeOptions
+}
+
+// EnvelopeOptions are the optional parameters of an Envelope.
+type EnvelopeOptions struct {
+	// DSN defines the delivery status notifications requested for the email.
+	// If not nil, it overrides the DSN of the Message and of the Dialer.
+	DSN *DSN
+	// RequireTLS defines whether the email is only sent over an encrypted
+	// connection, see RequireTLS.
+	RequireTLS bool
+}
+
+// An EnvelopeSender is a Sender able to use the options of an Envelope. The
+// senders returned by Dialer.Dial implement it.
+type EnvelopeSender interface {
+	SendEnvelope(e *Envelope, msg io.WriterTo) error
+}
+
+// Envelope returns the envelope of the message: the address set with
+// SetEnvelopeFrom or else the address of the Sender or From field, and the
+// addresses of the To, Cc and Bcc fields. With the StrictAddresses setting, it
+// returns a *ValidationError if the addresses are invalid.
+func (m *Message) Envelope() (*Envelope, error) {
+	if m.strict {
+		if errs := m.headerErrors(); len(errs) > 0 {
+			return nil, &ValidationError{Errors: errs}
+		}
+	}
+	from := m.envFrom
+	if from == "" {
+		var err error
+		if from, err = m.getFrom(); err != nil {
+			return nil, err
+		}
+	}
+	to, err := m.getRecipients()
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{From: from, To: to, Options: EnvelopeOptions{DSN: m.dsn, RequireTLS: m.requireTLS}}, nil
+}
+
+// SetEnvelopeFrom sets the address given to the MAIL FROM command, also known
+// as the return path, to which bounces are sent. By default, the address of
+// the Sender or From field is used. It can be used for VERP bounce addresses or
+// to send an email on behalf of another address. An empty address restores the
+// default.
+func (m *Message) SetEnvelopeFrom(address string) {
+	m.envFrom = address
+}
+
+// SendEnvelope sends msg to the recipients of e using the given Sender. If s
+// is not an EnvelopeSender, the options of e are ignored.
+func SendEnvelope(s Sender, e *Envelope, msg io.WriterTo) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	return sendEnvelope(s, e, msg)
+}
+
+func sendEnvelope(s Sender, e *Envelope, msg io.WriterTo) error {
+	if es, ok := s.(EnvelopeSender); ok {
+		return es.SendEnvelope(e, msg)
+	}
+	return s.Send(e.From, e.To, msg)
+}
+
+// resolvedOptions returns the options of e completed with the DSN and
+// RequireTLS settings of msg if it is a Message, so they are not lost when a
+// Sender replaces the Message before sending it.
+func resolvedOptions(e *Envelope, msg io.WriterTo) EnvelopeOptions {
+	opts := e.Options
+	if m, ok := msg.(*Message); ok {
+		if opts.DSN == nil {
+			opts.DSN = m.dsn
+		}
+		opts.RequireTLS = opts.RequireTLS || m.requireTLS
+	}
+	return opts
+}
+
+func (e *Envelope) validate() error {
+	if e.From == "" {
+		return errors.New("gomail: invalid envelope, the sender is empty")
+	}
+	if len(e.To) == 0 {
+		return errors.New("gomail: invalid envelope, no recipient")
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMessageEnvelope(t *testing.T) {
+	m := getTestMessage()
+	dsn := &DSN{Notify: NotifyFailure}
+	m.SetHeader("Bcc", testTo1)
+	SetDSN(dsn)(m)
+
+	e, err := m.Envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Envelope{
+		From:    testFrom,
+		To:      []string{testTo1, testTo2},
+		Options: EnvelopeOptions{DSN: dsn},
+	}
+	if !reflect.DeepEqual(e, want) {
+		t.Errorf("Invalid envelope, got %#v, want %#v", e, want)
+	}
+
+	if _, err := NewMessage().Envelope(); err == nil {
+		t.Error("Envelope should fail without a From field")
+	}
+}
+
+func TestSetEnvelopeFrom(t *testing.T) {
+	m := getTestMessage()
+	m.SetEnvelopeFrom("bounces+to1=example.com@example.com")
+
+	var from string
+	var buf bytes.Buffer
+	s := SendFunc(func(f string, _ []string, msg io.WriterTo) error {
+		from = f
+		_, err := msg.WriteTo(&buf)
+		return err
+	})
+	if err := Send(s, m); err != nil {
+		t.Fatal(err)
+	}
+	if from != "bounces+to1=example.com@example.com" {
+		t.Errorf("Invalid envelope sender, got %q", from)
+	}
+	if !strings.Contains(buf.String(), "From: "+testFrom+"\r\n") {
+		t.Errorf("The From field should not change, got:\n%s", buf.String())
+	}
+
+	if c := m.Clone(); c.envFrom != m.envFrom {
+		t.Error("Clone should keep the envelope sender")
+	}
+	m.SetEnvelopeFrom("")
+	if e, err := m.Envelope(); err != nil || e.From != testFrom {
+		t.Errorf("Invalid envelope sender, got %v, %v", e, err)
+	}
+}
+
+func TestSendEnvelope(t *testing.T) {
+	var from string
+	var to []string
+	var buf bytes.Buffer
+	s := SendFunc(func(f string, t []string, msg io.WriterTo) error {
+		from, to = f, t
+		_, err := msg.WriteTo(&buf)
+		return err
+	})
+
+	e := &Envelope{From: testFrom, To: []string{testTo1}}
+	if err := SendEnvelope(s, e, &rawMessage{r: strings.NewReader(testMsg)}); err != nil {
+		t.Fatal(err)
+	}
+	if from != testFrom || !reflect.DeepEqual(to, e.To) {
+		t.Errorf("Invalid envelope, got %q %q", from, to)
+	}
+	if buf.String() != testMsg {
+		t.Errorf("Invalid message, got %q, want %q", buf.String(), testMsg)
+	}
+
+	for _, e := range []*Envelope{{To: []string{testTo1}}, {From: testFrom}} {
+		if err := SendEnvelope(s, e, getTestMessage()); err == nil {
+			t.Errorf("SendEnvelope should fail with envelope %#v", e)
+		}
+	}
+}
+
+func TestSendEnvelopeDSN(t *testing.T) {
+	c := &mockClient{t: t, want: []string{
+		"Extension DSN",
+		"Mail " + testFrom,
+		"Rcpt " + testTo1 + " NOTIFY=SUCCESS ORCPT=rfc822;" + testTo1,
+		"Data",
+		"Write message",
+		"Close writer",
+	}}
+	s := &smtpSender{smtpClient: c, d: &Dialer{DSN: &DSN{Notify: NotifyFailure}}}
+
+	e := &Envelope{
+		From:    testFrom,
+		To:      []string{testTo1},
+		Options: EnvelopeOptions{DSN: &DSN{Notify: NotifySuccess}},
+	}
+	if err := SendEnvelope(s, e, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if c.i != len(c.want) {
+		t.Errorf("Only %d of %d commands sent", c.i, len(c.want))
+	}
+}
+
+// envelopeRecorder records the envelopes of the emails sent.
+type envelopeRecorder struct {
+	envelopes []*Envelope
+	bodies    []string
+}
+
+func (r *envelopeRecorder) Dial() (SendCloser, error) { return r, nil }
+func (r *envelopeRecorder) Close() error              { return nil }
+
+func (r *envelopeRecorder) Send(from string, to []string, msg io.WriterTo) error {
+	return r.SendEnvelope(&Envelope{From: from, To: to}, msg)
+}
+
+func (r *envelopeRecorder) SendEnvelope(e *Envelope, msg io.WriterTo) error {
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+	r.envelopes = append(r.envelopes, e)
+	r.bodies = append(r.bodies, buf.String())
+	return nil
+}
+
+func TestQueueEnqueueEnvelope(t *testing.T) {
+	s, cleanup := testDirStore(t)
+	defer cleanup()
+
+	down := &fakeDialer{fail: func(int) error {
+		return &textproto.Error{Code: 421, Msg: "Service not available"}
+	}}
+	q := NewQueue(down, Spool(s))
+	e := &Envelope{
+		From:    testFrom,
+		To:      []string{testTo1, testTo2},
+		Options: EnvelopeOptions{DSN: &DSN{Notify: NotifyNever}},
+	}
+	if err := q.EnqueueEnvelope(e, &rawMessage{r: strings.NewReader(testMsg)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.EnqueueEnvelope(&Envelope{From: testFrom}, getTestMessage()); err == nil {
+		t.Error("EnqueueEnvelope should fail without recipients")
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The envelope and its options are recovered from the store.
+	r := new(envelopeRecorder)
+	q = NewQueue(r, Spool(s))
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.envelopes) != 1 {
+		t.Fatalf("Invalid number of emails sent, got %d, want 1", len(r.envelopes))
+	}
+	if !reflect.DeepEqual(r.envelopes[0], e) {
+		t.Errorf("Invalid envelope, got %#v, want %#v", r.envelopes[0], e)
+	}
+	if r.bodies[0] != testMsg {
+		t.Errorf("Invalid message, got %q, want %q", r.bodies[0], testMsg)
+	}
+}
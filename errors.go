@@ -0,0 +1,174 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Errors that can be tested with errors.Is. The errors returned by the package
+// wrap them, so the underlying cause, like a *textproto.Error, is still
+// available with errors.As.
+var (
+	// ErrAuthFailed is returned when the SMTP server or the API of an email
+	// provider rejects the credentials.
+	ErrAuthFailed = errors.New("gomail: authentication failed")
+	// ErrTLSRequired is returned when the credentials cannot be sent over an
+	// unencrypted connection, when the SMTP server requires TLS or when it
+	// does not support STARTTLS with the MandatoryTLS policy.
+	ErrTLSRequired = errors.New("gomail: TLS is required")
+	// ErrRecipientRejected is matched by a RecipientError and by a SendError
+	// having rejected recipients.
+	ErrRecipientRejected = errors.New("gomail: recipient rejected")
+	// ErrMessageTooLarge is returned when an email exceeds the maximum size
+	// accepted by the SMTP server or the email provider.
+	ErrMessageTooLarge = errors.New("gomail: message too large")
+	// ErrMTASTSFailed is returned when a connection does not satisfy the
+	// MTA-STS policy of a Dialer in enforce mode.
+	ErrMTASTSFailed = errors.New("gomail: MTA-STS policy not satisfied")
+	// ErrInvalidHeader is matched by the validation errors of the header
+	// fields and file names containing CR, LF or NUL characters, with the
+	// StrictAddresses setting. Without it, these characters are removed or
+	// encoded.
+	ErrInvalidHeader = errors.New("gomail: invalid header field")
+)
+
+// A wrappedError is an error matching a sentinel error with errors.Is.
+type wrappedError struct {
+	sentinel error
+	err      error
+}
+
+func (e *wrappedError) Error() string {
+	return e.sentinel.Error() + ": " + strings.TrimPrefix(e.err.Error(), "gomail: ")
+}
+
+func (e *wrappedError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+// smtpError wraps err, returned by an SMTP command, with the sentinel error
+// corresponding to its reply code.
+func smtpError(err error) error {
+	var perr *textproto.Error
+	if !errors.As(err, &perr) {
+		return err
+	}
+	switch {
+	case perr.Code == 530 || perr.Code == 538:
+		return &wrappedError{ErrTLSRequired, err}
+	case perr.Code == 534 || perr.Code == 535:
+		return &wrappedError{ErrAuthFailed, err}
+	case perr.Code == 552 || strings.HasPrefix(perr.Msg, "5.3.4"):
+		// 5.3.4 is the enhanced status code for a message too big, defined
+		// in RFC 3463.
+		return &wrappedError{ErrMessageTooLarge, err}
+	}
+	return err
+}
+
+// A SendError is returned when the SMTP server rejects some of the recipients
+// of an email.
+type SendError struct {
+	// Accepted are the recipients accepted by the server.
+	Accepted []string
+	// Rejected are the recipients rejected by the server.
+	Rejected []*RecipientError
+	// Sent defines whether the email was sent to the accepted recipients. It
+	// can only be true if Dialer.AllowPartialSend, Dialer.SplitRecipients or
+	// Dialer.LMTP is set.
+	Sent bool
+}
+
+func (e *SendError) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString("gomail: ")
+	buf.WriteString(strconv.Itoa(len(e.Rejected)))
+	buf.WriteString(" of ")
+	buf.WriteString(strconv.Itoa(len(e.Rejected) + len(e.Accepted)))
+	buf.WriteString(" recipients rejected")
+	if !e.Sent {
+		buf.WriteString(", email not sent")
+	}
+	for i, r := range e.Rejected {
+		if i == 0 {
+			buf.WriteString(": ")
+		} else {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(r.Address)
+		buf.WriteString(" (")
+		buf.WriteString(strconv.Itoa(r.Code))
+		buf.WriteString(" ")
+		buf.WriteString(r.Message)
+		buf.WriteString(")")
+	}
+	return buf.String()
+}
+
+// Is reports whether target is ErrRecipientRejected and some recipients were
+// rejected.
+func (e *SendError) Is(target error) bool {
+	return target == ErrRecipientRejected && len(e.Rejected) > 0
+}
+
+// A RecipientError describes the rejection of a recipient by the SMTP server.
+type RecipientError struct {
+	// Address is the address of the rejected recipient.
+	Address string
+	// Code is the SMTP reply code, for example 550.
+	Code int
+	// Message is the reply text sent by the server.
+	Message string
+}
+
+func (e *RecipientError) Error() string {
+	return "gomail: recipient " + e.Address + " rejected: " +
+		strconv.Itoa(e.Code) + " " + e.Message
+}
+
+// Is reports whether target is ErrRecipientRejected.
+func (e *RecipientError) Is(target error) bool {
+	return target == ErrRecipientRejected
+}
+
+// An APIError is returned when the HTTP API of an email provider rejects an
+// email.
+type APIError struct {
+	// Provider is the name of the provider, for example "SendGrid".
+	Provider string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Message is the body of the response.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return "gomail: " + e.Provider + " API error: " +
+		strconv.Itoa(e.StatusCode) + " " + e.Message
+}
+
+// Temporary reports whether the request can be retried later: the provider is
+// rate limiting requests or failed internally.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == 429 || e.StatusCode >= 500
+}
+
+// Is reports whether target is ErrAuthFailed and the provider rejected the
+// credentials, or ErrMessageTooLarge and the provider rejected the size of the
+// email.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrAuthFailed:
+		return e.StatusCode == 401 || e.StatusCode == 403
+	case ErrMessageTooLarge:
+		return e.StatusCode == 413
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"net/smtp"
+	"net/textproto"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	tooBig := &textproto.Error{Code: 552, Msg: "5.3.4 Message size exceeds fixed limit"}
+	tests := []struct {
+		err    error
+		target error
+		want   bool
+	}{
+		{smtpError(tooBig), ErrMessageTooLarge, true},
+		{smtpError(&textproto.Error{Code: 554, Msg: "5.3.4 Message too big"}), ErrMessageTooLarge, true},
+		{smtpError(&textproto.Error{Code: 530, Msg: "5.7.0 Must issue a STARTTLS command first"}), ErrTLSRequired, true},
+		{smtpError(&textproto.Error{Code: 550, Msg: "5.7.1 Relaying denied"}), ErrAuthFailed, false},
+		{smtpError(io.EOF), io.EOF, true},
+
+		{authError(&textproto.Error{Code: 535, Msg: "5.7.8 Bad credentials"}, true), ErrAuthFailed, true},
+		{authError(&textproto.Error{Code: 538, Msg: "5.7.11 Encryption required"}, true), ErrTLSRequired, true},
+		{authError(errors.New("unencrypted connection"), false), ErrTLSRequired, true},
+		{authError(errors.New("unencrypted connection"), false), ErrAuthFailed, false},
+		{authError(ErrTLSRequired, false), ErrTLSRequired, true},
+		{authError(io.EOF, true), ErrAuthFailed, false},
+
+		{&RecipientError{Address: testTo1, Code: 550}, ErrRecipientRejected, true},
+		{&SendError{Rejected: []*RecipientError{{Address: testTo1}}}, ErrRecipientRejected, true},
+		{&SendError{}, ErrRecipientRejected, false},
+
+		{&APIError{StatusCode: 401}, ErrAuthFailed, true},
+		{&APIError{StatusCode: 413}, ErrMessageTooLarge, true},
+		{&APIError{StatusCode: 400}, ErrMessageTooLarge, false},
+	}
+
+	for _, test := range tests {
+		if got := errors.Is(test.err, test.target); got != test.want {
+			t.Errorf("errors.Is(%v, %v) = %v, want %v", test.err, test.target, got, test.want)
+		}
+	}
+
+	// The underlying error is still available.
+	var perr *textproto.Error
+	if err := smtpError(tooBig); !errors.As(err, &perr) || perr != tooBig {
+		t.Errorf("errors.As should return the SMTP error, got %v", perr)
+	}
+	if got, want := smtpError(tooBig).Error(), `gomail: message too large: 552 "5.3.4 Message size exceeds fixed limit"`; got != want {
+		t.Errorf("Invalid error message, got %q, want %q", got, want)
+	}
+}
+
+func TestLoginAuthTLSRequired(t *testing.T) {
+	a := &loginAuth{username: "user", password: "pwd", host: testHost}
+	_, _, err := a.Start(&smtp.ServerInfo{Name: testHost, Auth: []string{"PLAIN"}})
+	if !errors.Is(err, ErrTLSRequired) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrTLSRequired)
+	}
+}
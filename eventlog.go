@@ -0,0 +1,73 @@
+package gomail
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// An EventLog keeps the most recent connection events of a Dialer and the
+// SMTP commands with the replies of the server in memory, as logged with
+// Debug, so a transient failure can be diagnosed after the fact without
+// logging everything. The credentials and the content of the emails are never
+// recorded. It implements Logger and is safe for concurrent use.
+type EventLog struct {
+	mu     sync.Mutex
+	events []Event
+	// next is the index of the next event written in events once it is full.
+	next int
+}
+
+// An Event is an entry of an EventLog.
+type Event struct {
+	Time    time.Time
+	Message string
+}
+
+func (e Event) String() string {
+	return e.Time.Format("2006-01-02T15:04:05.000Z07:00") + " " + e.Message
+}
+
+// NewEventLog returns an EventLog keeping the last size events.
+func NewEventLog(size int) *EventLog {
+	if size < 1 {
+		size = 1
+	}
+	return &EventLog{events: make([]Event, 0, size)}
+}
+
+// Printf implements Logger.
+func (l *EventLog) Printf(format string, v ...interface{}) {
+	e := Event{Time: now(), Message: fmt.Sprintf(format, v...)}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) < cap(l.events) {
+		l.events = append(l.events, e)
+		return
+	}
+	l.events[l.next] = e
+	l.next = (l.next + 1) % len(l.events)
+}
+
+// Events returns the events kept, oldest first.
+func (l *EventLog) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make([]Event, 0, len(l.events))
+	events = append(events, l.events[l.next:]...)
+	return append(events, l.events[:l.next]...)
+}
+
+// WriteTo writes the events kept to w, one per line, oldest first.
+func (l *EventLog) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, e := range l.Events() {
+		m, err := fmt.Fprintln(w, e)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
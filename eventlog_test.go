@@ -0,0 +1,79 @@
+package gomail
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEventLog(t *testing.T) {
+	l := NewEventLog(2)
+	if len(l.Events()) != 0 {
+		t.Error("A new EventLog should be empty")
+	}
+	for i := 1; i <= 3; i++ {
+		l.Printf("event %d", i)
+	}
+
+	var got []string
+	for _, e := range l.Events() {
+		got = append(got, e.Message)
+	}
+	if want := []string{"event 2", "event 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid events, got %q, want %q", got, want)
+	}
+
+	var buf strings.Builder
+	if _, err := l.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2014-06-25T17:46:00.000Z event 2\n2014-06-25T17:46:00.000Z event 3\n"; buf.String() != want {
+		t.Errorf("Invalid dump, got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestDialerEvents(t *testing.T) {
+	logs := new(recordLogger)
+	d := NewDialer(testHost, testPort, "user", "pwd")
+	d.Logger = logs
+	d.Events = NewEventLog(100)
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Extension AUTH",
+			"Auth",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Reset",
+			"Quit",
+			"Close",
+		},
+		rejected: map[string]bool{testTo1: true, testTo2: true},
+	})
+	var serr *SendError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Invalid error, got %v, want a *SendError", err)
+	}
+
+	var events []string
+	for _, e := range d.Events.Events() {
+		events = append(events, e.Message)
+	}
+	want := "gomail: RCPT TO:<" + testTo1 + "> => 550 No such user (0s)"
+	if len(events) != 13 || events[7] != want {
+		t.Errorf("Invalid events, got %q", events)
+	}
+
+	// The Logger only logs the phases until the events are dumped.
+	if len(*logs) != 6 {
+		t.Fatalf("Invalid logs, got %q", *logs)
+	}
+	dump := (*logs)[5]
+	if !strings.HasPrefix(dump, err.Error()+"; recent events:\n") || !strings.Contains(dump, want) {
+		t.Errorf("Invalid dump, got %q", dump)
+	}
+}
@@ -1,6 +1,7 @@
 package gomail_test
 
 import (
+	"context"
 	"fmt"
 	"html/template"
 	"io"
@@ -71,6 +72,28 @@ func Example_daemon() {
 	close(ch)
 }
 
+// Send emails in the background with a pool of connections.
+func ExampleQueue() {
+	d := gomail.NewDialer("smtp.example.com", 587, "user", "123456")
+	q := gomail.NewQueue(d, gomail.Workers(8), gomail.Buffer(1000))
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", "alex@example.com")
+	m.SetHeader("To", "bob@example.com")
+	m.SetHeader("Subject", "Hello!")
+	m.SetBody("text/plain", "Hello Bob!")
+	if err := q.Enqueue(m); err != nil {
+		log.Print(err)
+	}
+
+	// Wait for the enqueued emails to be sent before exiting.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := q.Shutdown(ctx); err != nil {
+		log.Print(err)
+	}
+}
+
 // Efficiently send a customized newsletter to a list of recipients.
 func Example_newsletter() {
 	// The list of recipients.
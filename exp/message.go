@@ -0,0 +1,173 @@
+// Package exp is an experimental redesign of the gomail API. Its methods return
+// an error as soon as something is invalid, instead of when the email is sent,
+// and sending takes a context.
+//
+// It is built on top of package gomail and its types can be converted to and
+// from the gomail ones with Wrap and Unwrap, so a codebase can migrate one call
+// site at a time.
+//
+// This package is experimental: its API may change in incompatible ways until
+// it is declared stable.
+package exp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"os"
+	"strings"
+
+	"gopkg.in/gomail.v2"
+)
+
+// A Message represents an email.
+type Message struct {
+	m *gomail.Message
+}
+
+// NewMessage creates a new message.
+func NewMessage(settings ...gomail.MessageSetting) *Message {
+	return &Message{gomail.NewMessage(settings...)}
+}
+
+// Wrap returns a Message using m. Changes made to one are visible in the
+// other.
+func Wrap(m *gomail.Message) *Message {
+	return &Message{m}
+}
+
+// Unwrap returns the gomail message used by m.
+func (m *Message) Unwrap() *gomail.Message {
+	return m.m
+}
+
+// SetHeader sets a value to the given header field. It returns an error if the
+// field name is invalid or if a value contains a line break.
+func (m *Message) SetHeader(field string, value ...string) error {
+	if err := validField(field); err != nil {
+		return err
+	}
+	for _, v := range value {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("gomail: invalid value for the %q field, it contains a line break", field)
+		}
+	}
+	m.m.SetHeader(field, value...)
+	return nil
+}
+
+// SetAddresses sets the addresses of the given header field, for example "To".
+// Each address can include a name, as in "Alice <alice@example.com>".
+func (m *Message) SetAddresses(field string, address ...string) error {
+	if err := validField(field); err != nil {
+		return err
+	}
+	values := make([]string, len(address))
+	for i, a := range address {
+		addr, err := mail.ParseAddress(a)
+		if err != nil {
+			return fmt.Errorf("gomail: invalid address %q: %w", a, err)
+		}
+		values[i] = m.m.FormatAddress(addr.Address, addr.Name)
+	}
+	m.m.SetHeader(field, values...)
+	return nil
+}
+
+// SetBody sets the body of the message. It replaces any content previously set
+// by SetBody or AddAlternative.
+func (m *Message) SetBody(contentType, body string, settings ...gomail.PartSetting) error {
+	if err := validContentType(contentType); err != nil {
+		return err
+	}
+	m.m.SetBody(contentType, body, settings...)
+	return nil
+}
+
+// AddAlternative adds an alternative part to the message, see
+// gomail.Message.AddAlternative.
+func (m *Message) AddAlternative(contentType, body string, settings ...gomail.PartSetting) error {
+	if err := validContentType(contentType); err != nil {
+		return err
+	}
+	m.m.AddAlternative(contentType, body, settings...)
+	return nil
+}
+
+// Attach attaches a file to the email. It returns an error if the file is not
+// a readable regular file, even when a gomail.SetCopyFunc setting is given.
+func (m *Message) Attach(path string, settings ...gomail.FileSetting) error {
+	if err := checkFile(path); err != nil {
+		return err
+	}
+	m.m.Attach(path, settings...)
+	return nil
+}
+
+// Embed embeds an image in the email. It returns an error like Attach.
+func (m *Message) Embed(path string, settings ...gomail.FileSetting) error {
+	if err := checkFile(path); err != nil {
+		return err
+	}
+	m.m.Embed(path, settings...)
+	return nil
+}
+
+// Validate checks that the message has a sender and at least one recipient.
+func (m *Message) Validate() error {
+	e, err := m.m.Envelope()
+	if err != nil {
+		return err
+	}
+	if len(e.To) == 0 {
+		return errors.New("gomail: invalid message, no recipient")
+	}
+	return nil
+}
+
+// WriteTo implements io.WriterTo.
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	return m.m.WriteTo(w)
+}
+
+// validField checks a field name as defined in RFC 5322, section 2.2.
+func validField(field string) error {
+	if field == "" {
+		return errors.New("gomail: empty header field name")
+	}
+	for i := 0; i < len(field); i++ {
+		if c := field[i]; c < 33 || c > 126 || c == ':' {
+			return fmt.Errorf("gomail: invalid header field name %q", field)
+		}
+	}
+	return nil
+}
+
+func validContentType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("gomail: invalid content type %q: %w", contentType, err)
+	}
+	if i := strings.IndexByte(mediaType, '/'); i <= 0 || i == len(mediaType)-1 {
+		return fmt.Errorf("gomail: invalid content type %q, it must be type/subtype", contentType)
+	}
+	return nil
+}
+
+func checkFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("gomail: %s is not a regular file", path)
+	}
+	return nil
+}
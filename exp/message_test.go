@@ -0,0 +1,93 @@
+package exp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/gomail.v2"
+)
+
+func TestMessageErrors(t *testing.T) {
+	m := NewMessage()
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"empty field", m.SetHeader("", "value")},
+		{"field with colon", m.SetHeader("X-A:B", "value")},
+		{"field with space", m.SetHeader("X A", "value")},
+		{"value with line break", m.SetHeader("Subject", "Hi\r\nBcc: eve@example.com")},
+		{"invalid address", m.SetAddresses("To", "not an address")},
+		{"invalid content type", m.SetBody("text", "Hello")},
+		{"invalid alternative", m.AddAlternative("", "Hello")},
+		{"missing attachment", m.Attach("/does/not/exist.pdf")},
+		{"directory attachment", m.Embed(os.TempDir())},
+	}
+	for _, test := range tests {
+		if test.err == nil {
+			t.Errorf("%s: an error should be returned", test.name)
+		}
+	}
+
+	if err := m.Validate(); err == nil {
+		t.Error("Validate should fail without a sender")
+	}
+	if err := m.SetAddresses("From", "from@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Validate(); err == nil {
+		t.Error("Validate should fail without a recipient")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	f, err := ioutil.TempFile("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("content")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	m := NewMessage(gomail.DisableMessageID())
+	for _, err := range []error{
+		m.SetAddresses("From", "Alice <alice@example.com>"),
+		m.SetAddresses("To", "bob@example.com", "\"Carol\" <carol@example.com>"),
+		m.SetHeader("Subject", "Hello"),
+		m.SetBody("text/plain", "Hello!"),
+		m.AddAlternative("text/html", "<p>Hello!</p>"),
+		m.Attach(f.Name()),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.Unwrap().GetHeader("To"); len(got) != 2 || got[1] != `"Carol" <carol@example.com>` {
+		t.Errorf("Invalid To field, got %q", got)
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Subject: Hello\r\n") {
+		t.Errorf("Invalid email %q", buf.String())
+	}
+}
+
+func TestWrap(t *testing.T) {
+	gm := gomail.NewMessage()
+	m := Wrap(gm)
+	if err := m.SetHeader("Subject", "Hello"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Unwrap() != gm || gm.GetHeader("Subject")[0] != "Hello" {
+		t.Error("Wrap should use the given message")
+	}
+}
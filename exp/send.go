@@ -0,0 +1,85 @@
+package exp
+
+import (
+	"context"
+	"fmt"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Send sends the emails using the given Sender. The messages are validated
+// first and ctx is checked before each email, so no email is sent once ctx is
+// done. An email being sent is not interrupted.
+func Send(ctx context.Context, s gomail.Sender, msg ...*Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	return send(ctx, s, msg)
+}
+
+func send(ctx context.Context, s gomail.Sender, msg []*Message) error {
+	for i, m := range msg {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := gomail.Send(s, m.m); err != nil {
+			return fmt.Errorf("gomail: could not send email %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// A Dialer is a dialer to an SMTP server.
+type Dialer struct {
+	d *gomail.Dialer
+}
+
+// NewDialer returns a new SMTP Dialer, see gomail.NewDialer.
+func NewDialer(host string, port int, username, password string) *Dialer {
+	return &Dialer{gomail.NewDialer(host, port, username, password)}
+}
+
+// WrapDialer returns a Dialer using d.
+func WrapDialer(d *gomail.Dialer) *Dialer {
+	return &Dialer{d}
+}
+
+// Unwrap returns the gomail dialer used by d. It can be used to configure the
+// connection.
+func (d *Dialer) Unwrap() *gomail.Dialer {
+	return d.d
+}
+
+// DialAndSend opens a connection to the SMTP server, sends the given emails and
+// closes the connection. The messages are validated first. The connection is
+// opened with gomail.Dialer.DialContext, so the deadline of ctx bounds it, and
+// ctx is checked before each email, like with Send. Unlike
+// gomail.Dialer.DialAndSend, the emails are not retried with the RetryPolicy
+// of the dialer.
+func (d *Dialer) DialAndSend(ctx context.Context, msg ...*Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s, err := d.d.DialContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err := send(ctx, s, msg); err != nil {
+		s.Close()
+		return err
+	}
+	return s.Close()
+}
+
+func validate(msg []*Message) error {
+	for i, m := range msg {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("gomail: invalid email %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package exp
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"gopkg.in/gomail.v2"
+)
+
+func testMessage(t *testing.T) *Message {
+	m := NewMessage()
+	if err := m.SetAddresses("From", "from@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAddresses("To", "to@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetBody("text/plain", "Hello!"); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSend(t *testing.T) {
+	var sent int
+	s := gomail.SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		sent++
+		return nil
+	})
+
+	if err := Send(context.Background(), s, testMessage(t), testMessage(t)); err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 {
+		t.Errorf("Invalid number of emails sent, got %d, want 2", sent)
+	}
+
+	// No email is sent if one of them is invalid.
+	if err := Send(context.Background(), s, testMessage(t), NewMessage()); err == nil {
+		t.Error("Send should fail with an invalid message")
+	}
+	if sent != 2 {
+		t.Errorf("No email should be sent, got %d", sent-2)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Send(ctx, s, testMessage(t)); err != context.Canceled {
+		t.Errorf("Invalid error, got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDialAndSendCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The canceled context prevents the connection to the unresolvable host.
+	d := NewDialer("smtp.invalid", 587, "user", "pass")
+	if err := d.DialAndSend(ctx, testMessage(t)); err != context.Canceled {
+		t.Errorf("Invalid error, got %v, want %v", err, context.Canceled)
+	}
+	if err := d.DialAndSend(context.Background(), NewMessage()); err == nil {
+		t.Error("DialAndSend should fail with an invalid message")
+	}
+	if WrapDialer(d.Unwrap()).Unwrap().Host != "smtp.invalid" {
+		t.Error("WrapDialer should use the given dialer")
+	}
+}
+
+// cancelWriter cancels a context when it is first written to.
+type cancelWriter struct {
+	cancel context.CancelFunc
+	n      int
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	w.n += len(p)
+	return len(p), nil
+}
+
+func TestDialAndSendContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The dry run dialer writes the emails to w, which cancels ctx while the
+	// first email is sent.
+	w := &cancelWriter{cancel: cancel}
+	d := NewDialer("smtp.invalid", 587, "user", "pass")
+	d.Unwrap().DryRun = true
+	d.Unwrap().DryRunOutput = w
+	if err := d.DialAndSend(ctx, testMessage(t), testMessage(t)); err != context.Canceled {
+		t.Errorf("Invalid error, got %v, want %v", err, context.Canceled)
+	}
+	first := w.n
+	if first == 0 {
+		t.Fatal("The first email should be sent")
+	}
+
+	w = &cancelWriter{cancel: func() {}}
+	d.Unwrap().DryRunOutput = w
+	if err := d.DialAndSend(context.Background(), testMessage(t), testMessage(t)); err != nil {
+		t.Fatal(err)
+	}
+	if w.n <= first {
+		t.Errorf("Both emails should be sent, got %d bytes, want more than %d", w.n, first)
+	}
+}
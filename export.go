@@ -0,0 +1,144 @@
+package gomail
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExportOptions select the emails exported by Archive.Export.
+type ExportOptions struct {
+	// Since and Until restrict the export to the emails archived in
+	// [Since, Until). A zero value does not restrict the range.
+	Since, Until time.Time
+	// After is the ID of the last email of an interrupted export, see
+	// ExportManifest. The export then resumes with the next email.
+	After string
+}
+
+// An ExportManifest describes the emails of an export.
+type ExportManifest struct {
+	Since  time.Time       `json:"since"`
+	Until  time.Time       `json:"until"`
+	Emails []ExportedEmail `json:"emails"`
+}
+
+// An ExportedEmail is an email of an export.
+type ExportedEmail struct {
+	ArchivedEmail
+	// File is the name of the EML file in the tar archive.
+	File string `json:"file"`
+	// SHA256 is the hexadecimal SHA-256 checksum of the EML file.
+	SHA256 string `json:"sha256"`
+}
+
+// Export writes the archived emails selected by opts to w as a tar archive, for
+// example to answer a legal discovery request. Each email is an EML file named
+// after its ID, in the order they were archived, followed by a manifest.json
+// file describing them with their checksums and a SHA256SUMS file that can be
+// verified with the sha256sum tool.
+//
+// The emails are streamed from the archive and never kept in memory. If an
+// error occurs, the returned manifest lists the emails completely written, so
+// the export can be resumed in a new tar archive with its last ID as
+// ExportOptions.After.
+func (a *Archive) Export(w io.Writer, opts ExportOptions) (*ExportManifest, error) {
+	emails, err := a.exportList(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &ExportManifest{Since: opts.Since, Until: opts.Until, Emails: []ExportedEmail{}}
+	tw := tar.NewWriter(w)
+	var sums strings.Builder
+	for _, e := range emails {
+		x := ExportedEmail{ArchivedEmail: *e, File: e.ID + ".eml"}
+		if x.SHA256, err = a.exportEmail(tw, e, x.File); err != nil {
+			return manifest, fmt.Errorf("gomail: could not export the archived email %q: %w", e.ID, err)
+		}
+		manifest.Emails = append(manifest.Emails, x)
+		fmt.Fprintf(&sums, "%s  %s\n", x.SHA256, x.File)
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := writeTarFile(tw, "manifest.json", now(), b); err != nil {
+		return manifest, err
+	}
+	if err := writeTarFile(tw, "SHA256SUMS", now(), []byte(sums.String())); err != nil {
+		return manifest, err
+	}
+	return manifest, tw.Close()
+}
+
+// exportList returns the archived emails selected by opts.
+func (a *Archive) exportList(opts ExportOptions) ([]*ArchivedEmail, error) {
+	a.mu.Lock()
+	all := a.all
+	a.mu.Unlock()
+
+	if opts.After != "" {
+		found := false
+		for i, e := range all {
+			if e.ID == opts.After {
+				all, found = all[i+1:], true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("gomail: unknown archive ID %q", opts.After)
+		}
+	}
+
+	var list []*ArchivedEmail
+	for _, e := range all {
+		if (!opts.Since.IsZero() && e.Time.Before(opts.Since)) || (!opts.Until.IsZero() && !e.Time.Before(opts.Until)) {
+			continue
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+// exportEmail writes an archived email in the tar archive and returns its
+// checksum.
+func (a *Archive) exportEmail(tw *tar.Writer, e *ArchivedEmail, name string) (string, error) {
+	r, err := a.Open(e.ID)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    e.Size,
+		ModTime: e.Time,
+	}); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tw, h), r)
+	if err != nil {
+		return "", err
+	}
+	if n != e.Size {
+		return "", fmt.Errorf("gomail: the size of the email is %d bytes, the index says %d", n, e.Size)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, t time.Time, b []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(b)), ModTime: t}); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
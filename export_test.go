@@ -0,0 +1,110 @@
+package gomail
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveExport(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	current := time.Date(2014, 6, 25, 17, 46, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := NewArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		if err := a.Send(testFrom, []string{to}, getTestMessage()); err != nil {
+			t.Fatal(err)
+		}
+		current = current.Add(24 * time.Hour)
+	}
+
+	opts := ExportOptions{Since: time.Date(2014, 6, 26, 0, 0, 0, 0, time.UTC), Until: time.Date(2014, 6, 28, 17, 46, 0, 0, time.UTC)}
+	var buf bytes.Buffer
+	manifest, err := a.Export(&buf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Emails) != 2 || manifest.Emails[0].To[0] != "b@example.com" || manifest.Emails[1].To[0] != "c@example.com" {
+		t.Fatalf("Invalid exported emails, got %+v", manifest.Emails)
+	}
+
+	files := readTar(t, &buf)
+	var sums strings.Builder
+	for _, e := range manifest.Emails {
+		b, ok := files[e.File]
+		if !ok {
+			t.Fatalf("The file %q is missing", e.File)
+		}
+		sum := sha256.Sum256(b)
+		if hex.EncodeToString(sum[:]) != e.SHA256 {
+			t.Errorf("Invalid checksum of %q", e.File)
+		}
+		if !strings.Contains(string(b), "To: "+testTo1) {
+			t.Errorf("Invalid content of %q", e.File)
+		}
+		sums.WriteString(e.SHA256 + "  " + e.File + "\n")
+	}
+	if got := string(files["SHA256SUMS"]); got != sums.String() {
+		t.Errorf("Invalid SHA256SUMS, got %q, want %q", got, sums.String())
+	}
+	got := new(ExportManifest)
+	if err := json.Unmarshal(files["manifest.json"], got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, manifest) {
+		t.Errorf("Invalid manifest, got %+v, want %+v", got, manifest)
+	}
+
+	// An export can be resumed after its last email.
+	opts.After = manifest.Emails[0].ID
+	manifest, err = a.Export(ioutil.Discard, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Emails) != 1 || manifest.Emails[0].To[0] != "c@example.com" {
+		t.Errorf("Invalid resumed export, got %+v", manifest.Emails)
+	}
+
+	opts.After = "unknown"
+	if _, err := a.Export(ioutil.Discard, opts); err == nil {
+		t.Error("Export should fail with an unknown ID")
+	}
+}
+
+func readTar(t *testing.T, r io.Reader) map[string][]byte {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[h.Name] = b
+	}
+}
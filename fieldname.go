@@ -0,0 +1,40 @@
+package gomail
+
+import "net/textproto"
+
+// fieldKey returns the key of the field in the header of a Message: its
+// canonical name, so the fields are found whatever the case used to set them.
+func fieldKey(field string) string {
+	key := textproto.CanonicalMIMEHeaderKey(field)
+	if key == "Message-Id" {
+		return "Message-ID"
+	}
+	return key
+}
+
+// setFieldName records the name of the field as given by the caller, written
+// instead of its canonical name, and returns its key. The characters not
+// allowed in a field name are removed. The field is no longer raw,
+// SetRawHeader marks it again.
+func (m *Message) setFieldName(field string) string {
+	field = sanitizeFieldName(field)
+	key := fieldKey(field)
+	delete(m.rawFields, key)
+	if key == field {
+		delete(m.fieldNames, key)
+		return key
+	}
+	if m.fieldNames == nil {
+		m.fieldNames = make(map[string]string)
+	}
+	m.fieldNames[key] = field
+	return key
+}
+
+// typedFieldName returns the name of the field of the given key as it was set.
+func typedFieldName(names map[string]string, key string) string {
+	if name, ok := names[key]; ok {
+		return name
+	}
+	return key
+}
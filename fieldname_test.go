@@ -0,0 +1,56 @@
+package gomail
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFieldNameCase(t *testing.T) {
+	m := NewMessage(SetHeaderOrder("from", "TO", "x-api-key"))
+	m.SetHeader("FROM", testFrom)
+	m.SetHeader("to", testTo1)
+	m.SetHeader("X-API-Key", "secret")
+	m.SetHeader("MIME-Version", "1.0")
+	m.SetHeader("Subject", "Hello")
+	m.SetHeader("SUBJECT", "Hello again")
+	m.SetBody("text/plain", testBody)
+
+	if got := m.GetHeader("x-api-key"); !reflect.DeepEqual(got, []string{"secret"}) {
+		t.Errorf("Invalid X-API-Key field, got %q", got)
+	}
+	e, err := m.Envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.From != testFrom || !reflect.DeepEqual(e.To, []string{testTo1}) {
+		t.Errorf("Invalid envelope, got %+v", e)
+	}
+
+	var fields []string
+	m.Headers(func(field string, values []string) bool {
+		fields = append(fields, field)
+		return true
+	})
+	if want := []string{"FROM", "MIME-Version", "SUBJECT", "to", "X-API-Key"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Invalid fields, got %q, want %q", fields, want)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.Clone().WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := "FROM: " + testFrom + "\r\n" +
+		"to: " + testTo1 + "\r\n" +
+		"X-API-Key: secret\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"SUBJECT: Hello again\r\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("Invalid header, got:\n%s\nwant:\n%s", got, want)
+	}
+	if strings.Contains(got, "Mime-Version") || strings.Count(got, "Hello") != 1 {
+		t.Errorf("The fields should not be duplicated, got:\n%s", got)
+	}
+}
@@ -0,0 +1,87 @@
+package gomail
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// AttachMessage attaches the email other as a message/rfc822 part, to forward
+// it as an attachment or to build a report quoting it. other is rendered
+// immediately, so it can be reset or modified after AttachMessage returns, and
+// its content is attached as is, without being re-encoded. The attachment is
+// named after the subject of other.
+func (m *Message) AttachMessage(other *Message, settings ...FileSetting) error {
+	var buf bytes.Buffer
+	if _, err := other.WriteTo(&buf); err != nil {
+		return err
+	}
+	m.AttachRawMessage(buf.Bytes(), settings...)
+	return nil
+}
+
+// AttachRawMessage is like AttachMessage but attaches an already rendered
+// email, for example read from an EML file. Its bare LF line breaks are
+// converted to CRLF.
+func (m *Message) AttachRawMessage(raw []byte, settings ...FileSetting) {
+	raw = toCRLF(raw)
+	name := messageFileName(raw)
+	m.Attach(name, append([]FileSetting{
+		SetHeader(map[string][]string{
+			"Content-Type":              {"message/rfc822"},
+			"Content-Transfer-Encoding": {string(identityEncoding(raw))},
+		}),
+		setContent(raw),
+	}, settings...)...)
+}
+
+// messageFileName returns the name of the attachment containing the email
+// raw: its decoded subject with the extension .eml.
+func messageFileName(raw []byte) string {
+	name := "message"
+	if msg, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		subject := msg.Header.Get("Subject")
+		if s, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+			subject = s
+		}
+		subject = strings.Map(func(r rune) rune {
+			if r == '/' || r == '\\' || r < ' ' || !utf8.ValidRune(r) {
+				return '_'
+			}
+			return r
+		}, strings.TrimSpace(subject))
+		if subject != "" {
+			name = subject
+		}
+	}
+	return name + ".eml"
+}
+
+// sevenBit is the 7bit transfer encoding of the ASCII content with short
+// lines, the default one.
+const sevenBit Encoding = "7bit"
+
+// identityEncoding returns the transfer encoding of b written as is: 7bit,
+// 8bit or binary. The message parts cannot be encoded with base64 or
+// quoted-printable, as required by RFC 2046, section 5.2.1.
+func identityEncoding(b []byte) Encoding {
+	switch {
+	case !is8bit(b):
+		return binaryEncoding
+	case bytes.IndexFunc(b, func(r rune) bool { return r >= utf8.RuneSelf }) != -1:
+		return Unencoded
+	}
+	return sevenBit
+}
+
+// isIdentityEncoding reports whether a file written with the transfer encoding
+// enc is written as is.
+func isIdentityEncoding(enc string) bool {
+	switch Encoding(strings.ToLower(enc)) {
+	case sevenBit, Unencoded, binaryEncoding:
+		return true
+	}
+	return false
+}
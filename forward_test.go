@@ -0,0 +1,51 @@
+package gomail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAttachMessage(t *testing.T) {
+	other := NewMessage(Deterministic())
+	other.SetHeader("From", testFrom)
+	other.SetHeader("To", testTo1)
+	other.SetHeader("Subject", "Réunion / planning")
+	other.SetBody("text/plain", "¡Hola, señor!")
+	var raw bytes.Buffer
+	if _, err := other.WriteTo(&raw); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo2)
+	m.SetHeader("Subject", "Fwd: Réunion / planning")
+	m.SetBody("text/plain", "See the attached email.")
+	if err := m.AttachMessage(other); err != nil {
+		t.Fatal(err)
+	}
+	m.AttachRawMessage([]byte("Subject: Report\nFrom: " + testFrom + "\n\nCafé\n"))
+	m.AttachRawMessage([]byte("\n"+strings.Repeat("a", 1000)), Rename("long.eml"))
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"Content-Disposition: attachment; filename=\"Réunion _ planning.eml\"\r\n" +
+			"Content-Transfer-Encoding: 7bit\r\n" +
+			"Content-Type: message/rfc822\r\n\r\n" + raw.String(),
+		"Content-Disposition: attachment; filename=\"Report.eml\"\r\n" +
+			"Content-Transfer-Encoding: 8bit\r\n" +
+			"Content-Type: message/rfc822\r\n\r\n" +
+			"Subject: Report\r\nFrom: " + testFrom + "\r\n\r\nCafé\r\n",
+		"Content-Disposition: attachment; filename=\"long.eml\"\r\n" +
+			"Content-Transfer-Encoding: binary\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %q in:\n%s", want, got)
+		}
+	}
+}
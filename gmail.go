@@ -0,0 +1,117 @@
+package gomail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// A GmailSender sends emails with the users.messages.send method of the Gmail
+// API. It can be used when the SMTP submission ports are blocked. It implements
+// SendCloser and SendDialer so it can be used with a Queue.
+type GmailSender struct {
+	// UserID is the user sending the emails. It defaults to "me", the
+	// authenticated user.
+	UserID string
+	// Token returns the OAuth2 access token sent with each request. The token
+	// needs the https://www.googleapis.com/auth/gmail.send scope. If nil, the
+	// Client must authorize the requests itself, for example a client created
+	// with the golang.org/x/oauth2 package.
+	Token func() (string, error)
+	// Endpoint is the URL of the users resource of the API. It defaults to
+	// https://gmail.googleapis.com/gmail/v1/users/.
+	Endpoint string
+	// Client is the HTTP client used to call the API. It defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+// Dial implements SendDialer. No connection is opened since every email is
+// sent with its own HTTP request.
+func (s *GmailSender) Dial() (SendCloser, error) {
+	return s, nil
+}
+
+// Send implements Sender. Gmail sends the email to the recipients found in its
+// headers and from the authenticated user, so from is ignored. When msg is a
+// *Message, the recipients that are neither in the To nor in the Cc header
+// are added in a Bcc header, which Gmail removes before delivery.
+func (s *GmailSender) Send(from string, to []string, msg io.WriterTo) error {
+	var body bytes.Buffer
+	body.WriteString(`{"raw":"`)
+	enc := base64.NewEncoder(base64.URLEncoding, &body)
+	if m, ok := msg.(*Message); ok {
+		if bcc := hiddenRecipients(m, to); len(bcc) > 0 {
+			mw := &messageWriter{w: enc}
+			mw.writeHeader("Bcc", strings.Join(bcc, ", "))
+			if mw.err != nil {
+				return mw.err
+			}
+		}
+	}
+	if _, err := msg.WriteTo(enc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	body.WriteString(`"}`)
+
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://gmail.googleapis.com/gmail/v1/users/"
+	}
+	userID := s.UserID
+	if userID == "" {
+		userID = "me"
+	}
+	u := strings.TrimSuffix(endpoint, "/") + "/" + url.PathEscape(userID) + "/messages/send"
+	req, err := http.NewRequest("POST", u, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != nil {
+		token, err := s.Token()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return doAPIRequest(s.Client, "Gmail", req)
+}
+
+// Close implements SendCloser.
+func (s *GmailSender) Close() error {
+	return nil
+}
+
+// hiddenRecipients returns the recipients of to that are neither in the To nor
+// in the Cc header of m.
+func hiddenRecipients(m *Message, to []string) []string {
+	visible := make(map[string]bool)
+	for _, field := range []string{"To", "Cc"} {
+		for _, v := range m.header[field] {
+			list, err := mail.ParseAddressList(v)
+			if err != nil {
+				continue
+			}
+			for _, a := range list {
+				visible[strings.ToLower(a.Address)] = true
+			}
+		}
+	}
+
+	var hidden []string
+	for _, addr := range to {
+		if !visible[strings.ToLower(addr)] {
+			hidden = append(hidden, addr)
+		}
+	}
+	return hidden
+}
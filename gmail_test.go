@@ -0,0 +1,75 @@
+package gomail
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGmailSender(t *testing.T) {
+	var raw string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/bob@example.com/messages/send" {
+			t.Errorf("Invalid path, got %q", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+			t.Errorf("Invalid Authorization, got %q", auth)
+		}
+		var body struct{ Raw string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+			return
+		}
+		b, err := base64.URLEncoding.DecodeString(body.Raw)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		raw = string(b)
+		w.Write([]byte(`{"id":"1","threadId":"1","labelIds":["SENT"]}`))
+	}))
+	defer srv.Close()
+
+	s := &GmailSender{
+		UserID:   "bob@example.com",
+		Token:    func() (string, error) { return "token", nil },
+		Endpoint: srv.URL + "/users",
+	}
+	m := getTestMessage()
+	m.SetHeader("To", testTo1)
+	if err := s.Send(testFrom, []string{testTo1, testTo2}, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(raw, "Bcc: "+testTo2+"\r\n") {
+		t.Errorf("The hidden recipient should be in a Bcc header, got:\n%s", raw)
+	}
+	compareBodies(t, strings.TrimPrefix(raw, "Bcc: "+testTo2+"\r\n"), strings.Replace(testMsg, ", "+testTo2, "", 1))
+}
+
+func TestGmailSenderErrors(t *testing.T) {
+	errToken := errors.New("token expired")
+	s := &GmailSender{Token: func() (string, error) { return "", errToken }}
+	if err := s.Send(testFrom, []string{testTo1}, getTestMessage()); err != errToken {
+		t.Errorf("Invalid error, got %v, want %v", err, errToken)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/me/messages/send" {
+			t.Errorf("Invalid path, got %q", r.URL.Path)
+		}
+		http.Error(w, `{"error":{"code":503}}`, http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s = &GmailSender{Endpoint: srv.URL}
+	err := s.Send(testFrom, []string{testTo1}, getTestMessage())
+	var aerr *APIError
+	if !errors.As(err, &aerr) || aerr.Provider != "Gmail" || !IsTemporary(err) {
+		t.Errorf("Invalid error, got %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package gomailtest
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"gopkg.in/gomail.v2"
+)
+
+// A Recorder is a test double recording the emails sent instead of sending
+// them. It implements gomail.SendCloser and gomail.SendDialer so it can
+// replace a Dialer, a Sender or a Queue transport in unit tests:
+//
+//	r := new(gomailtest.Recorder)
+//	if err := gomail.Send(r, m); err != nil {
+//		t.Fatal(err)
+//	}
+//	if got := r.LastMessage().HTMLBody(); !strings.Contains(got, "Welcome") {
+//		t.Errorf("Invalid HTML body: %s", got)
+//	}
+type Recorder struct {
+	// Err, if not nil, is returned by Send instead of recording the email.
+	Err error
+
+	mu       sync.Mutex
+	messages []*Message
+}
+
+// Dial implements gomail.SendDialer.
+func (r *Recorder) Dial() (gomail.SendCloser, error) {
+	return r, nil
+}
+
+// Send implements gomail.Sender.
+func (r *Recorder) Send(from string, to []string, msg io.WriterTo) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, &Message{
+		From: from,
+		To:   append([]string(nil), to...),
+		Data: buf.Bytes(),
+	})
+	return nil
+}
+
+// Close implements gomail.SendCloser.
+func (r *Recorder) Close() error {
+	return nil
+}
+
+// Messages returns the emails recorded so far.
+func (r *Recorder) Messages() []*Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Message(nil), r.messages...)
+}
+
+// LastMessage returns the last email recorded or nil if there is none.
+func (r *Recorder) LastMessage() *Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.messages) == 0 {
+		return nil
+	}
+	return r.messages[len(r.messages)-1]
+}
+
+// Reset forgets the emails recorded.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.messages = nil
+	r.mu.Unlock()
+}
+
+// A Part is a leaf MIME part of an email, decoded.
+type Part struct {
+	Header textproto.MIMEHeader
+	// ContentType is the media type of the part, without its parameters.
+	ContentType string
+	// Disposition is the disposition of the part, "attachment" or "inline",
+	// or empty if it has none.
+	Disposition string
+	// Filename is the decoded file name of the attachments and the embedded
+	// files.
+	Filename string
+	// Body is the body without its transfer encoding, with LF line endings
+	// for the text parts.
+	Body []byte
+}
+
+func (m *Message) entity() *entity {
+	return parseEntity(bytes.Replace(m.Data, []byte("\r\n"), []byte("\n"), -1), "")
+}
+
+// Header returns the decoded values of the header field k of the email.
+func (m *Message) Header(k string) []string {
+	values := m.entity().header[textproto.CanonicalMIMEHeaderKey(k)]
+	dec := new(mime.WordDecoder)
+	decoded := make([]string, len(values))
+	for i, v := range values {
+		if d, err := dec.DecodeHeader(v); err == nil {
+			v = d
+		}
+		decoded[i] = v
+	}
+	return decoded
+}
+
+// Subject returns the decoded subject of the email.
+func (m *Message) Subject() string {
+	if v := m.Header("Subject"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Parts returns the leaf parts of the email, depth first.
+func (m *Message) Parts() []*Part {
+	var parts []*Part
+	var walk func(e *entity)
+	walk = func(e *entity) {
+		if e.parts != nil {
+			for _, p := range e.parts {
+				walk(p)
+			}
+			return
+		}
+		parts = append(parts, newPart(e))
+	}
+	walk(m.entity())
+	return parts
+}
+
+func newPart(e *entity) *Part {
+	p := &Part{Header: e.header, Body: e.decodedBody()}
+	p.ContentType, _, _ = mime.ParseMediaType(e.header.Get("Content-Type"))
+	if p.ContentType == "" {
+		p.ContentType = "text/plain"
+	}
+	if disp, params, err := mime.ParseMediaType(e.header.Get("Content-Disposition")); err == nil {
+		p.Disposition = disp
+		p.Filename = params["filename"]
+		if name, err := new(mime.WordDecoder).DecodeHeader(p.Filename); err == nil {
+			p.Filename = name
+		}
+	}
+	return p
+}
+
+// TextBody returns the first text/plain body of the email that is not an
+// attachment, or an empty string if there is none.
+func (m *Message) TextBody() string {
+	return m.body("text/plain")
+}
+
+// HTMLBody returns the first text/html body of the email that is not an
+// attachment, or an empty string if there is none.
+func (m *Message) HTMLBody() string {
+	return m.body("text/html")
+}
+
+func (m *Message) body(contentType string) string {
+	for _, p := range m.Parts() {
+		if p.ContentType == contentType && p.Disposition != "attachment" {
+			return string(p.Body)
+		}
+	}
+	return ""
+}
+
+// Attachments returns the attachments of the email.
+func (m *Message) Attachments() []*Part {
+	return m.filesWith("attachment")
+}
+
+// Embedded returns the embedded files of the email, like the images of its
+// HTML body.
+func (m *Message) Embedded() []*Part {
+	return m.filesWith("inline")
+}
+
+func (m *Message) filesWith(disposition string) []*Part {
+	var files []*Part
+	for _, p := range m.Parts() {
+		if strings.EqualFold(p.Disposition, disposition) && p.Filename != "" {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// AttachmentNames returns the file names of the attachments of the email.
+func (m *Message) AttachmentNames() []string {
+	var names []string
+	for _, p := range m.Attachments() {
+		names = append(names, p.Filename)
+	}
+	return names
+}
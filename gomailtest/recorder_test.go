@@ -0,0 +1,67 @@
+package gomailtest
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"gopkg.in/gomail.v2"
+)
+
+func TestRecorder(t *testing.T) {
+	r := new(Recorder)
+	if r.LastMessage() != nil {
+		t.Error("LastMessage should return nil without emails")
+	}
+
+	m := testMessage("<p>Hello!</p>", true)
+	m.SetHeader("Subject", "Café")
+	m.SetHeader("Bcc", "bcc@example.com")
+	m.Embed("logo.png", gomail.SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write([]byte("\x89PNG"))
+		return err
+	}))
+	m.Attach("résumé.txt", gomail.SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write([]byte("CV"))
+		return err
+	}))
+	if err := gomail.Send(r, m); err != nil {
+		t.Fatal(err)
+	}
+
+	got := r.LastMessage()
+	if want := []string{"to@example.com", "bcc@example.com"}; got.From != "from@example.com" || !reflect.DeepEqual(got.To, want) {
+		t.Errorf("Invalid envelope, got %q %q", got.From, got.To)
+	}
+	if got.Subject() != "Café" {
+		t.Errorf("Invalid subject, got %q", got.Subject())
+	}
+	if got.TextBody() != "Hello!" {
+		t.Errorf("Invalid text body, got %q", got.TextBody())
+	}
+	if got.HTMLBody() != "<p>Hello!</p>" {
+		t.Errorf("Invalid HTML body, got %q", got.HTMLBody())
+	}
+	if want := []string{"terms.pdf", "résumé.txt"}; !reflect.DeepEqual(got.AttachmentNames(), want) {
+		t.Errorf("Invalid attachments, got %q, want %q", got.AttachmentNames(), want)
+	}
+	if a := got.Attachments(); len(a) != 2 || string(a[0].Body) != "%PDF-1.4" || a[0].ContentType != "application/pdf" {
+		t.Errorf("Invalid attachment, got %+v", a)
+	}
+	if e := got.Embedded(); len(e) != 1 || e[0].Filename != "logo.png" || string(e[0].Body) != "\x89PNG" {
+		t.Errorf("Invalid embedded files, got %+v", e)
+	}
+
+	r.Reset()
+	if len(r.Messages()) != 0 {
+		t.Error("Reset should forget the emails")
+	}
+	r.Err = errors.New("test error")
+	if err := gomail.Send(r, m); !errors.Is(err, r.Err) {
+		t.Errorf("Invalid error, got %v", err)
+	}
+	if len(r.Messages()) != 0 {
+		t.Error("A failed email should not be recorded")
+	}
+}
@@ -0,0 +1,588 @@
+package gomailtest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// A Server is an in-process SMTP server recording the emails it receives, to
+// test code sending emails without a real server. It listens on a random port
+// of the loopback interface.
+//
+// The fields must be set before calling Start:
+//
+//	s := &gomailtest.Server{
+//		Users:  map[string]string{"user": "password"},
+//		Faults: []gomailtest.Fault{{Command: "RCPT", N: 3, Reply: "452 4.5.3 Too many recipients"}},
+//	}
+//	if err := s.Start(); err != nil {
+//		t.Fatal(err)
+//	}
+//	defer s.Close()
+//
+//	err := s.Dialer("user", "password").DialAndSend(m)
+type Server struct {
+	// Extensions are the extensions advertised in the reply to EHLO, in
+	// addition to STARTTLS and AUTH. The default is 8BITMIME and PIPELINING.
+	// If CHUNKING is advertised, the server accepts the BDAT command.
+	Extensions []string
+	// TLS defines whether STARTTLS is advertised, with a self-signed
+	// certificate for localhost trusted by ClientTLSConfig.
+	TLS bool
+	// Users, if not nil, are the usernames and passwords accepted by the
+	// PLAIN and LOGIN authentication mechanisms. Authentication is then
+	// required before sending an email.
+	Users map[string]string
+	// Faults are the scripted failures of the server.
+	Faults []Fault
+
+	// Addr and Port are the address and the port the server listens on, set
+	// by Start.
+	Addr string
+	Port int
+
+	l        net.Listener
+	tlsConf  *tls.Config
+	certPool *x509.CertPool
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	closed   bool
+	conns    map[net.Conn]bool
+	counts   map[string]int
+	messages []*Message
+}
+
+// A Fault is a scripted failure of a Server: the reply to a command is
+// replaced, delayed or the connection is closed. For example, Fault{Command:
+// "RCPT", N: 3, Reply: "452 4.5.3 Too many recipients"} rejects the third
+// recipient and Fault{Command: ".", Delay: time.Minute} makes the client time
+// out after sending the content of an email.
+type Fault struct {
+	// Command is the failing command, like "EHLO", "AUTH", "MAIL", "RCPT",
+	// "DATA" or "BDAT", or "." for the end of the content of an email.
+	Command string
+	// N is the occurrence of the command that fails, counted since the
+	// server started: 1 for the first one. 0 fails every occurrence.
+	N int
+	// Reply, if not empty, is the reply sent instead of the normal one, for
+	// example "451 4.3.0 Try again later". The command then has no effect.
+	Reply string
+	// Delay is waited before replying.
+	Delay time.Duration
+	// Close closes the connection instead of replying.
+	Close bool
+}
+
+// A Message is an email received by a Server or recorded by a Recorder. Its
+// methods parse the raw email to check its fields and parts.
+type Message struct {
+	// From and To are the envelope sender and recipients.
+	From string
+	To   []string
+	// Data is the raw email, with CRLF line endings.
+	Data []byte
+	// Username is the authenticated user, if any, of an email received by a
+	// Server.
+	Username string
+	// TLS defines whether an email received by a Server was received over
+	// TLS.
+	TLS bool
+}
+
+// Start starts the server.
+func (s *Server) Start() error {
+	if s.TLS {
+		if err := s.generateCertificate(); err != nil {
+			return err
+		}
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.l = l
+	s.Addr = l.Addr().String()
+	s.Port = l.Addr().(*net.TCPAddr).Port
+	s.done = make(chan struct{})
+	s.conns = make(map[net.Conn]bool)
+	s.counts = make(map[string]int)
+
+	s.wg.Add(1)
+	go s.serve()
+	return nil
+}
+
+// Close stops the server, closing its connections, and waits for them to be
+// done.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	err := s.l.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// Messages returns the emails received so far.
+func (s *Server) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message(nil), s.messages...)
+}
+
+// Dialer returns a Dialer connecting to the server with the given credentials.
+// STARTTLS is required if the server advertises it and not used otherwise.
+func (s *Server) Dialer(username, password string) *gomail.Dialer {
+	d := gomail.NewDialer("localhost", s.Port, username, password)
+	d.Address = s.Addr
+	d.TLSPolicy = gomail.NoTLS
+	if s.TLS {
+		d.TLSPolicy = gomail.MandatoryTLS
+		d.TLSConfig = s.ClientTLSConfig()
+	}
+	return d
+}
+
+// ClientTLSConfig returns a TLS configuration trusting the certificate of the
+// server, or nil if TLS is false.
+func (s *Server) ClientTLSConfig() *tls.Config {
+	if s.certPool == nil {
+		return nil
+	}
+	return &tls.Config{ServerName: "localhost", RootCAs: s.certPool}
+}
+
+func (s *Server) generateCertificate() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"gomailtest"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+
+	s.certPool = x509.NewCertPool()
+	s.certPool.AddCert(cert)
+	s.tlsConf = &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}}}
+	return nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			sess := &session{s: s, conn: conn, text: textproto.NewConn(conn)}
+			sess.serve()
+			conn.Close()
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// fault returns the fault of the current occurrence of cmd, if any.
+func (s *Server) fault(cmd string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[cmd]++
+	n := s.counts[cmd]
+	for i := range s.Faults {
+		f := &s.Faults[i]
+		if strings.EqualFold(f.Command, cmd) && (f.N == 0 || f.N == n) {
+			return f
+		}
+	}
+	return nil
+}
+
+func (s *Server) record(m *Message) {
+	s.mu.Lock()
+	s.messages = append(s.messages, m)
+	s.mu.Unlock()
+}
+
+// A session is a connection to a Server.
+type session struct {
+	s    *Server
+	conn net.Conn
+	text *textproto.Conn
+	tls  bool
+	user string
+
+	// The current transaction.
+	from *string
+	to   []string
+	data bytes.Buffer
+}
+
+var errQuit = errors.New("gomailtest: quit")
+
+func (c *session) serve() {
+	if c.reply("220 localhost ESMTP gomailtest") != nil {
+		return
+	}
+	for {
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+		cmd = strings.ToUpper(cmd)
+
+		// The faults of BDAT are applied once its chunk is read.
+		if cmd != "BDAT" {
+			if handled, err := c.applyFault(cmd); err != nil {
+				return
+			} else if handled {
+				continue
+			}
+		}
+		if c.command(cmd, arg) != nil {
+			return
+		}
+	}
+}
+
+// applyFault applies the fault of the current occurrence of cmd, if any. It
+// returns true if the fault replied instead of the command.
+func (c *session) applyFault(cmd string) (bool, error) {
+	f := c.s.fault(cmd)
+	if f == nil {
+		return false, nil
+	}
+	if f.Delay > 0 {
+		t := time.NewTimer(f.Delay)
+		select {
+		case <-t.C:
+		case <-c.s.done:
+			t.Stop()
+			return false, errQuit
+		}
+	}
+	if f.Close {
+		return false, errQuit
+	}
+	if f.Reply == "" {
+		return false, nil
+	}
+	return true, c.reply(f.Reply)
+}
+
+func (c *session) reply(lines ...string) error {
+	for i, line := range lines {
+		if i < len(lines)-1 && len(line) >= 3 {
+			line = line[:3] + "-" + strings.TrimPrefix(line[3:], " ")
+		}
+		if err := c.text.PrintfLine("%s", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *session) reset() {
+	c.from = nil
+	c.to = nil
+	c.data.Reset()
+}
+
+func (c *session) command(cmd, arg string) error {
+	switch cmd {
+	case "HELO":
+		c.reset()
+		return c.reply("250 localhost")
+	case "EHLO":
+		c.reset()
+		lines := []string{"250 localhost"}
+		ext := c.s.Extensions
+		if ext == nil {
+			ext = []string{"8BITMIME", "PIPELINING"}
+		}
+		for _, e := range ext {
+			lines = append(lines, "250 "+e)
+		}
+		if c.s.TLS && !c.tls {
+			lines = append(lines, "250 STARTTLS")
+		}
+		if c.s.Users != nil {
+			lines = append(lines, "250 AUTH PLAIN LOGIN")
+		}
+		return c.reply(lines...)
+	case "STARTTLS":
+		if !c.s.TLS || c.tls {
+			return c.reply("502 5.5.1 STARTTLS not available")
+		}
+		if err := c.reply("220 2.0.0 Ready to start TLS"); err != nil {
+			return err
+		}
+		conn := tls.Server(c.conn, c.s.tlsConf)
+		if err := conn.Handshake(); err != nil {
+			return err
+		}
+		c.conn, c.text, c.tls = conn, textproto.NewConn(conn), true
+		c.reset()
+		return nil
+	case "AUTH":
+		return c.auth(arg)
+	case "MAIL":
+		if c.s.Users != nil && c.user == "" {
+			return c.reply("530 5.7.0 Authentication required")
+		}
+		from, ok := parsePath(arg, "FROM:")
+		if !ok {
+			return c.reply("501 5.5.4 Syntax error in MAIL command")
+		}
+		c.reset()
+		c.from = &from
+		return c.reply("250 2.1.0 OK")
+	case "RCPT":
+		if c.from == nil {
+			return c.reply("503 5.5.1 MAIL first")
+		}
+		to, ok := parsePath(arg, "TO:")
+		if !ok {
+			return c.reply("501 5.5.4 Syntax error in RCPT command")
+		}
+		c.to = append(c.to, to)
+		return c.reply("250 2.1.5 OK")
+	case "DATA":
+		if c.from == nil || len(c.to) == 0 {
+			return c.reply("503 5.5.1 MAIL and RCPT first")
+		}
+		if err := c.reply("354 Start mail input; end with <CRLF>.<CRLF>"); err != nil {
+			return err
+		}
+		if err := c.readData(); err != nil {
+			return err
+		}
+		return c.endData()
+	case "BDAT":
+		return c.bdat(arg)
+	case "RSET":
+		c.reset()
+		return c.reply("250 2.0.0 OK")
+	case "NOOP":
+		return c.reply("250 2.0.0 OK")
+	case "QUIT":
+		c.reply("221 2.0.0 Bye")
+		return errQuit
+	}
+	return c.reply("502 5.5.2 Command not implemented")
+}
+
+// readData reads the content sent after DATA, removing the dot-stuffing but
+// keeping the CRLF line endings.
+func (c *session) readData() error {
+	for {
+		line, err := c.text.R.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if line == ".\r\n" {
+			return nil
+		}
+		c.data.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+// endData ends the transaction once its content is received.
+func (c *session) endData() error {
+	if handled, err := c.applyFault("."); err != nil || handled {
+		c.reset()
+		return err
+	}
+	c.s.record(&Message{
+		From:     *c.from,
+		To:       c.to,
+		Data:     append([]byte(nil), c.data.Bytes()...),
+		Username: c.user,
+		TLS:      c.tls,
+	})
+	c.reset()
+	return c.reply("250 2.0.0 OK")
+}
+
+func (c *session) bdat(arg string) error {
+	args := strings.Fields(arg)
+	if len(args) == 0 || len(args) > 2 {
+		return c.reply("501 5.5.4 Syntax error in BDAT command")
+	}
+	size, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || size < 0 {
+		return c.reply("501 5.5.4 Syntax error in BDAT command")
+	}
+	if _, err := io.CopyN(&c.data, c.text.R, size); err != nil {
+		return err
+	}
+	if c.from == nil || len(c.to) == 0 {
+		c.data.Reset()
+		return c.reply("503 5.5.1 MAIL and RCPT first")
+	}
+
+	if handled, err := c.applyFault("BDAT"); err != nil || handled {
+		c.reset()
+		return err
+	}
+	if len(args) == 2 && strings.EqualFold(args[1], "LAST") {
+		return c.endData()
+	}
+	return c.reply("250 2.0.0 OK")
+}
+
+func (c *session) auth(arg string) error {
+	if c.s.Users == nil {
+		return c.reply("502 5.5.1 AUTH not available")
+	}
+	if c.user != "" {
+		return c.reply("503 5.5.1 Already authenticated")
+	}
+	args := strings.Fields(arg)
+	if len(args) == 0 {
+		return c.reply("501 5.5.4 Syntax error in AUTH command")
+	}
+
+	var username, password string
+	switch strings.ToUpper(args[0]) {
+	case "PLAIN":
+		resp, ok, err := c.challenge(args[1:], "")
+		if err != nil || !ok {
+			return err
+		}
+		fields := strings.Split(resp, "\x00")
+		if len(fields) != 3 {
+			return c.reply("501 5.5.2 Invalid response")
+		}
+		username, password = fields[1], fields[2]
+	case "LOGIN":
+		var ok bool
+		var err error
+		if username, ok, err = c.challenge(args[1:], "Username:"); err != nil || !ok {
+			return err
+		}
+		if password, ok, err = c.challenge(nil, "Password:"); err != nil || !ok {
+			return err
+		}
+	default:
+		return c.reply("504 5.5.4 Unrecognized authentication mechanism")
+	}
+
+	if pass, ok := c.s.Users[username]; !ok || pass != password {
+		return c.reply("535 5.7.8 Authentication credentials invalid")
+	}
+	c.user = username
+	return c.reply("235 2.7.0 Authentication successful")
+}
+
+// challenge returns the decoded initial response, if any, or sends the
+// challenge and returns the decoded response of the client. It returns false
+// if the client canceled the authentication or sent an invalid response.
+func (c *session) challenge(initial []string, challenge string) (string, bool, error) {
+	resp := ""
+	if len(initial) > 0 {
+		resp = initial[0]
+	} else {
+		if err := c.reply("334 " + base64.StdEncoding.EncodeToString([]byte(challenge))); err != nil {
+			return "", false, err
+		}
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return "", false, err
+		}
+		if line == "*" {
+			return "", false, c.reply("501 5.7.0 Authentication canceled")
+		}
+		resp = line
+	}
+	b, err := base64.StdEncoding.DecodeString(resp)
+	if err != nil {
+		return "", false, c.reply("501 5.5.2 Invalid base64 response")
+	}
+	return string(b), true, nil
+}
+
+// parsePath parses the path of a MAIL or RCPT command, like "FROM:<addr>
+// BODY=8BITMIME", ignoring the parameters.
+func parsePath(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	arg = strings.TrimLeft(arg[len(prefix):], " ")
+	if !strings.HasPrefix(arg, "<") {
+		return "", false
+	}
+	end := strings.IndexByte(arg, '>')
+	if end < 0 {
+		return "", false
+	}
+	return arg[1:end], true
+}
@@ -0,0 +1,133 @@
+package gomailtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+func startServer(t *testing.T, s *Server) {
+	t.Helper()
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkMessage(t *testing.T, got *Message, want Message) {
+	t.Helper()
+	if got.From != want.From || !reflect.DeepEqual(got.To, want.To) || got.Username != want.Username || got.TLS != want.TLS {
+		t.Errorf("Invalid message, got %+v, want %+v", got, want)
+	}
+	if !bytes.Contains(got.Data, []byte("Subject: Welcome\r\n")) || !bytes.HasSuffix(got.Data, []byte("\r\n")) {
+		t.Errorf("Invalid content, got:\n%s", got.Data)
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := &Server{Users: map[string]string{"user": "password"}}
+	startServer(t, s)
+	defer s.Close()
+
+	if err := s.Dialer("user", "wrong").DialAndSend(testMessage("<p>Hello!</p>", true)); !errors.Is(err, gomail.ErrAuthFailed) {
+		t.Errorf("Invalid error, got %v", err)
+	}
+	if err := s.Dialer("user", "password").DialAndSend(testMessage("<p>Hello!</p>", true)); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := s.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("Invalid number of messages, got %d", len(msgs))
+	}
+	checkMessage(t, msgs[0], Message{From: "from@example.com", To: []string{"to@example.com"}, Username: "user"})
+}
+
+func TestServerTLS(t *testing.T) {
+	s := &Server{TLS: true, Users: map[string]string{"user": "password"}}
+	startServer(t, s)
+	defer s.Close()
+
+	if err := s.Dialer("user", "password").DialAndSend(testMessage("<p>Hello!</p>", false)); err != nil {
+		t.Fatal(err)
+	}
+	msgs := s.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("Invalid number of messages, got %d", len(msgs))
+	}
+	checkMessage(t, msgs[0], Message{From: "from@example.com", To: []string{"to@example.com"}, Username: "user", TLS: true})
+}
+
+func TestServerChunking(t *testing.T) {
+	s := &Server{Extensions: []string{"8BITMIME", "CHUNKING"}}
+	startServer(t, s)
+	defer s.Close()
+
+	m := testMessage("<p>Hello!</p>", true)
+	if err := s.Dialer("", "").DialAndSend(m); err != nil {
+		t.Fatal(err)
+	}
+	msgs := s.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("Invalid number of messages, got %d", len(msgs))
+	}
+	checkMessage(t, msgs[0], Message{From: "from@example.com", To: []string{"to@example.com"}})
+}
+
+func TestServerFaults(t *testing.T) {
+	s := &Server{Faults: []Fault{
+		{Command: "MAIL", N: 1, Reply: "451 4.3.0 Try again later"},
+		{Command: "RCPT", N: 2, Reply: "452 4.5.3 Too many recipients"},
+	}}
+	startServer(t, s)
+	defer s.Close()
+
+	d := s.Dialer("", "")
+	if err := d.DialAndSend(testMessage("<p>Hello!</p>", false)); !gomail.IsTemporary(err) {
+		t.Errorf("The first email should fail temporarily, got %v", err)
+	}
+	if err := d.DialAndSend(testMessage("<p>Hello!</p>", false)); err != nil {
+		t.Errorf("The second email should be sent, got %v", err)
+	}
+
+	// The second RCPT is the first recipient of the third email.
+	m := testMessage("<p>Hello!</p>", false)
+	m.SetHeader("To", "to1@example.com", "to2@example.com")
+	var serr *gomail.SendError
+	if err := d.DialAndSend(m); !errors.As(err, &serr) {
+		t.Fatalf("The third email should fail, got %v", err)
+	}
+	if len(serr.Rejected) != 1 || serr.Rejected[0].Address != "to1@example.com" || serr.Rejected[0].Code != 452 {
+		t.Errorf("Invalid rejected recipients, got %+v", serr.Rejected)
+	}
+	if msgs := s.Messages(); len(msgs) != 1 {
+		t.Errorf("Invalid number of messages, got %d", len(msgs))
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	s := &Server{Faults: []Fault{{Command: ".", Delay: time.Minute}}}
+	startServer(t, s)
+	defer s.Close()
+
+	d := s.Dialer("", "")
+	d.NetDialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := net.Dial(network, address)
+		if err == nil {
+			conn.SetDeadline(time.Now().Add(200 * time.Millisecond))
+		}
+		return conn, err
+	}
+	var nerr net.Error
+	if err := d.DialAndSend(testMessage("<p>Hello!</p>", false)); !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Errorf("The client should time out, got %v", err)
+	}
+	if msgs := s.Messages(); len(msgs) != 0 {
+		t.Errorf("No email should be received, got %d", len(msgs))
+	}
+}
@@ -0,0 +1,348 @@
+// Package gomailtest provides utilities to test code sending emails with
+// gomail.
+package gomailtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("gomailtest.update", false, "update the email snapshots")
+
+// A Case is a named email compared with its snapshot by Snapshots, for example
+// an email template executed with test data.
+type Case struct {
+	// Name is the name of the case. It is used as file name so it should only
+	// contain characters valid in a file name.
+	Name string
+	// Render renders the email.
+	Render func() (io.WriterTo, error)
+}
+
+// Snapshots runs a subtest for each case comparing the rendered email with the
+// snapshot stored in dir/<name>.eml, see Snapshot.
+func Snapshots(t *testing.T, dir string, cases []Case) {
+	t.Helper()
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			msg, err := c.Render()
+			if err != nil {
+				t.Fatalf("could not render the email: %v", err)
+			}
+			Snapshot(t, filepath.Join(dir, c.Name+".eml"), msg)
+		})
+	}
+}
+
+// Snapshot compares the normalized form of msg, see Normalize, with the
+// snapshot stored at path and reports the differences part by part.
+//
+// The snapshots are created or updated by running the tests with the
+// -gomailtest.update flag. A missing snapshot fails the test.
+func Snapshot(t testing.TB, path string, msg io.WriterTo) {
+	t.Helper()
+	if err := snapshot(path, msg, *update); err != nil {
+		t.Error(err)
+	}
+}
+
+func snapshot(path string, msg io.WriterTo, update bool) error {
+	got, err := Normalize(msg)
+	if err != nil {
+		return err
+	}
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, got, 0644)
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("snapshot %s does not exist, run the tests with -gomailtest.update to create it", path)
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(got, want) {
+		return nil
+	}
+
+	diff := diffEntities(parseEntity(got, ""), parseEntity(want, ""))
+	if len(diff) == 0 {
+		diff = []string{"the formatting differs"}
+	}
+	return fmt.Errorf("email does not match snapshot %s, run the tests with -gomailtest.update if the change is expected:\n\t%s",
+		path, strings.Join(diff, "\n\t"))
+}
+
+// Normalize returns msg in a deterministic form suitable for snapshots: lines
+// end with LF, the header fields are sorted, the multipart boundaries are
+// numbered and the Date and Message-ID fields are replaced with placeholders.
+func Normalize(msg io.WriterTo) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	b := bytes.Replace(buf.Bytes(), []byte("\r\n"), []byte("\n"), -1)
+
+	buf.Reset()
+	n := 0
+	parseEntity(b, "").write(&buf, &n)
+	return buf.Bytes(), nil
+}
+
+// An entity is a MIME entity: the whole email or one of its parts.
+type entity struct {
+	path   string
+	header textproto.MIMEHeader
+	body   []byte
+	parts  []*entity
+}
+
+func parseEntity(b []byte, path string) *entity {
+	r := bufio.NewReader(bytes.NewReader(b))
+	h, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil && h == nil {
+		h = make(textproto.MIMEHeader)
+	}
+	body, _ := ioutil.ReadAll(r)
+
+	e := &entity{path: path, header: h}
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		e.body = body
+		return e
+	}
+
+	for i, p := range splitParts(body, params["boundary"]) {
+		path := strconv.Itoa(i + 1)
+		if e.path != "" {
+			path = e.path + "." + path
+		}
+		e.parts = append(e.parts, parseEntity(p, path))
+	}
+	return e
+}
+
+// splitParts returns the parts of a multipart body, without its preamble and
+// epilogue.
+func splitParts(body []byte, boundary string) [][]byte {
+	delim, end := "--"+boundary, "--"+boundary+"--"
+	var parts [][]byte
+	var cur []string
+	in := false
+	for _, line := range strings.Split(string(body), "\n") {
+		if l := strings.TrimRight(line, " \t"); l == delim || l == end {
+			if in {
+				parts = append(parts, []byte(strings.Join(cur, "\n")))
+			}
+			if l == end {
+				return parts
+			}
+			cur, in = nil, true
+			continue
+		}
+		if in {
+			cur = append(cur, line)
+		}
+	}
+	return parts
+}
+
+var placeholders = map[string]string{
+	"Date":       "<date>",
+	"Message-Id": "<message-id>",
+}
+
+func (e *entity) write(w *bytes.Buffer, n *int) {
+	boundary := ""
+	if e.parts != nil {
+		*n++
+		boundary = "BOUNDARY-" + strconv.Itoa(*n)
+	}
+
+	keys := make([]string, 0, len(e.header))
+	for k := range e.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range e.header[k] {
+			if p, ok := placeholders[k]; ok {
+				v = p
+			} else if k == "Content-Type" && boundary != "" {
+				mediaType, params, _ := mime.ParseMediaType(v)
+				params["boundary"] = boundary
+				v = mime.FormatMediaType(mediaType, params)
+			}
+			fmt.Fprintf(w, "%s: %s\n", k, v)
+		}
+	}
+	w.WriteByte('\n')
+
+	if e.parts == nil {
+		w.Write(e.body)
+		return
+	}
+	for _, p := range e.parts {
+		w.WriteString("--" + boundary + "\n")
+		p.write(w, n)
+		w.WriteByte('\n')
+	}
+	w.WriteString("--" + boundary + "--\n")
+}
+
+func (e *entity) flatten(m map[string]*entity, order *[]string) {
+	m[e.path] = e
+	*order = append(*order, e.path)
+	for _, p := range e.parts {
+		p.flatten(m, order)
+	}
+}
+
+func (e *entity) name() string {
+	mediaType, _, _ := mime.ParseMediaType(e.header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+	if e.path == "" {
+		return "message (" + mediaType + ")"
+	}
+	return "part " + e.path + " (" + mediaType + ")"
+}
+
+// decodedBody returns the body of e without its transfer encoding.
+func (e *entity) decodedBody() []byte {
+	var r io.Reader = bytes.NewReader(e.body)
+	switch strings.ToLower(e.header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return e.body
+	}
+	return b
+}
+
+// diffEntities describes the differences between got and want, part by part.
+func diffEntities(got, want *entity) []string {
+	gotParts, wantParts := make(map[string]*entity), make(map[string]*entity)
+	var gotOrder, wantOrder []string
+	got.flatten(gotParts, &gotOrder)
+	want.flatten(wantParts, &wantOrder)
+
+	// The parts of a replaced entity are not compared.
+	var replaced []string
+	isReplaced := func(path string) bool {
+		for _, r := range replaced {
+			if r == "" || strings.HasPrefix(path, r+".") {
+				return true
+			}
+		}
+		return false
+	}
+
+	var diff []string
+	for _, path := range wantOrder {
+		if isReplaced(path) {
+			continue
+		}
+		w := wantParts[path]
+		g, ok := gotParts[path]
+		if !ok {
+			diff = append(diff, w.name()+" is missing")
+			continue
+		}
+		if g.name() != w.name() {
+			diff = append(diff, fmt.Sprintf("%s replaces %s", g.name(), w.name()))
+			replaced = append(replaced, path)
+			continue
+		}
+		diff = append(diff, diffHeaders(g, w)...)
+		if g.parts == nil && w.parts == nil {
+			if d := diffBodies(g, w); d != "" {
+				diff = append(diff, d)
+			}
+		}
+	}
+	for _, path := range gotOrder {
+		if _, ok := wantParts[path]; !ok && !isReplaced(path) {
+			diff = append(diff, "unexpected "+gotParts[path].name())
+		}
+	}
+	return diff
+}
+
+func diffHeaders(got, want *entity) []string {
+	keys := make(map[string]bool)
+	for k := range got.header {
+		keys[k] = true
+	}
+	for k := range want.header {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diff []string
+	for _, k := range sorted {
+		if _, ok := placeholders[k]; ok || k == "Content-Type" && got.parts != nil {
+			continue
+		}
+		g, w := strings.Join(got.header[k], ", "), strings.Join(want.header[k], ", ")
+		if g != w {
+			diff = append(diff, fmt.Sprintf("%s: header %s: got %q, want %q", got.name(), k, g, w))
+		}
+	}
+	return diff
+}
+
+func diffBodies(got, want *entity) string {
+	g, w := got.decodedBody(), want.decodedBody()
+	if bytes.Equal(g, w) {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(got.header.Get("Content-Type"))
+	if mediaType != "" && !strings.HasPrefix(mediaType, "text/") {
+		return fmt.Sprintf("%s: body differs, got %d bytes, want %d bytes", got.name(), len(g), len(w))
+	}
+
+	gl := strings.Split(string(g), "\n")
+	wl := strings.Split(string(w), "\n")
+	for i := 0; ; i++ {
+		var gs, ws string
+		if i < len(gl) {
+			gs = gl[i]
+		}
+		if i < len(wl) {
+			ws = wl[i]
+		}
+		if gs != ws || i >= len(gl) || i >= len(wl) {
+			return fmt.Sprintf("%s: body differs at line %d:\n\t\tgot:  %q\n\t\twant: %q", got.name(), i+1, gs, ws)
+		}
+	}
+}
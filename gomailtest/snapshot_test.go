@@ -0,0 +1,140 @@
+package gomailtest
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/gomail.v2"
+)
+
+func testMessage(html string, attach bool) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", "from@example.com")
+	m.SetHeader("To", "to@example.com")
+	m.SetHeader("Subject", "Welcome")
+	m.SetBody("text/plain", "Hello!")
+	m.AddAlternative("text/html", html)
+	if attach {
+		m.Attach("terms.pdf", gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := io.WriteString(w, "%PDF-1.4")
+			return err
+		}))
+	}
+	return m
+}
+
+func TestNormalize(t *testing.T) {
+	a, err := Normalize(testMessage("<p>Hello!</p>", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Normalize(testMessage("<p>Hello!</p>", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("Normalize is not deterministic:\n%s\n%s", a, b)
+	}
+
+	for _, want := range []string{
+		"Date: <date>\n",
+		"Message-Id: <message-id>\n",
+		"Content-Type: multipart/mixed; boundary=BOUNDARY-1\n",
+		"Content-Type: multipart/alternative; boundary=BOUNDARY-2\n",
+		"\n--BOUNDARY-1--\n",
+	} {
+		if !bytes.Contains(a, []byte(want)) {
+			t.Errorf("%q not found in:\n%s", want, a)
+		}
+	}
+	if bytes.Contains(a, []byte("\r")) {
+		t.Error("The normalized email should not contain CR")
+	}
+
+	// Normalizing a normalized email does not change it.
+	c, err := Normalize(bytes.NewBuffer(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, c) {
+		t.Errorf("Normalize is not idempotent:\n%s\n%s", a, c)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomailtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshots", "welcome.eml")
+
+	err = snapshot(path, testMessage("<p>Hello!</p>", false), false)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Invalid error for a missing snapshot, got %v", err)
+	}
+
+	if err := snapshot(path, testMessage("<p>Hello!</p>", false), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshot(path, testMessage("<p>Hello!</p>", false), false); err != nil {
+		t.Errorf("The snapshot should match, got %v", err)
+	}
+
+	m := testMessage("<p>Hello!</p>\n<p>Welcome aboard.</p>", true)
+	m.SetHeader("Subject", "Welcome!")
+	err = snapshot(path, m, false)
+	if err == nil {
+		t.Fatal("The snapshot should not match")
+	}
+	if want := "\tmessage (multipart/mixed) replaces message (multipart/alternative)"; !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("Invalid error, got %v, want suffix %q", err, want)
+	}
+
+	m = testMessage("<p>Hello!</p>\n<p>Welcome aboard.</p>", false)
+	m.SetHeader("Subject", "Welcome!")
+	err = snapshot(path, m, false)
+	if err == nil {
+		t.Fatal("The snapshot should not match")
+	}
+	for _, want := range []string{
+		`message (multipart/alternative): header Subject: got "Welcome!", want "Welcome"`,
+		`part 2 (text/html): body differs at line 2:`,
+		`got:  "<p>Welcome aboard.</p>"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q not found in:\n%v", want, err)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomailtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []Case{
+		{"welcome", func() (io.WriterTo, error) { return testMessage("<p>Hello!</p>", false), nil }},
+		{"terms", func() (io.WriterTo, error) { return testMessage("<p>Terms</p>", true), nil }},
+	}
+
+	*update = true
+	Snapshots(t, dir, cases)
+	*update = false
+	Snapshots(t, dir, cases)
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Errorf("Invalid snapshots, got %q", names)
+	}
+}
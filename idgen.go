@@ -0,0 +1,74 @@
+package gomail
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// An IDGenerator generates the unique identifiers of an email: the local part
+// of the generated Message-ID field, before the @, and the boundaries of the
+// multipart bodies. A generator can for example return identifiers that are
+// also logged by the application so the emails can be correlated with its
+// logs.
+//
+// The identifiers must be unique and made of at most 64 ASCII letters and
+// digits. The Content-ID of the embedded files is their name so the HTML
+// bodies can reference them and is only generated by EmbedWithCID.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// The IDGeneratorFunc type is an adapter to allow the use of ordinary
+// functions as IDGenerator.
+type IDGeneratorFunc func() (string, error)
+
+// NewID implements IDGenerator.
+func (f IDGeneratorFunc) NewID() (string, error) {
+	return f()
+}
+
+// SetIDGenerator is a message setting to set the generator of the identifiers
+// of the email. By default, NewULID is used.
+func SetIDGenerator(g IDGenerator) MessageSetting {
+	return func(m *Message) {
+		m.idGenerator = g
+	}
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a new ULID, a 26 characters identifier made of the current
+// time in milliseconds and 80 random bits, encoded in Crockford's base32. The
+// ULIDs sort in the order they were generated, at the millisecond, so the
+// emails can be sorted by their Message-ID.
+func NewULID() (string, error) {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(now().UnixNano()/1e6)<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+
+	// The 128 bits are encoded in 26 characters of 5 bits, the first one
+	// only having 3 bits.
+	var s [26]byte
+	for i := range s {
+		var v int
+		for j := 0; j < 5; j++ {
+			bit := i*5 - 2 + j
+			v <<= 1
+			if bit >= 0 && b[bit/8]&(0x80>>uint(bit%8)) != 0 {
+				v |= 1
+			}
+		}
+		s[i] = crockford[v]
+	}
+	return string(s[:]), nil
+}
+
+// newID returns a new identifier generated by g or else by NewULID.
+func newID(g IDGenerator) (string, error) {
+	if g == nil {
+		return NewULID()
+	}
+	return g.NewID()
+}
@@ -0,0 +1,58 @@
+package gomail
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewULID(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	current := time.Date(2014, 6, 25, 17, 46, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	a, err := NewULID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewULID()
+	current = current.Add(time.Millisecond)
+	c, _ := NewULID()
+
+	if len(a) != 26 || strings.Trim(a, crockford) != "" {
+		t.Errorf("Invalid ULID %q", a)
+	}
+	// 2014-06-25 17:46:00 UTC is 1403718360000 ms, 0x146D4225BC0.
+	if want := "018VA24PY0"; a[:10] != want {
+		t.Errorf("Invalid time of the ULID, got %q, want %q", a[:10], want)
+	}
+	if a == b {
+		t.Errorf("ULIDs should be unique, got %q twice", a)
+	}
+	if c <= a || c <= b {
+		t.Errorf("ULIDs should be sorted by time, got %q before %q", c, a)
+	}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	var n int
+	m := NewMessage(SetIDGenerator(IDGeneratorFunc(func() (string, error) {
+		n++
+		return "id" + strconv.Itoa(n), nil
+	})))
+	m.SetHeader("From", testFrom)
+	m.SetBody("text/plain", "Text")
+	m.AddAlternative("text/html", "HTML")
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Message-ID: <id1@example.com>\r\n", " boundary=id2\r\n", "--id2--\r\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("The email should contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
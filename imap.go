@@ -0,0 +1,257 @@
+package gomail
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An IMAPFetcher is a MailboxFetcher reading the emails of an IMAP mailbox. It
+// implements the small subset of IMAP4rev1 (RFC 3501) needed to drain a
+// mailbox.
+type IMAPFetcher struct {
+	// Host represents the host of the IMAP server.
+	Host string
+	// Port represents the port of the IMAP server.
+	Port int
+	// Username is the username used to log in.
+	Username string
+	// Password is the password used to log in.
+	Password string
+	// Mailbox is the mailbox to drain. It defaults to INBOX.
+	Mailbox string
+	// SSL defines whether an SSL connection is used. If false, the connection
+	// is upgraded with STARTTLS before logging in.
+	SSL bool
+	// TLSConfig represents the TLS configuration used for the TLS connection.
+	TLSConfig *tls.Config
+}
+
+// NewIMAPFetcher returns a new IMAP fetcher. SSL is enabled for port 993.
+func NewIMAPFetcher(host string, port int, username, password string) *IMAPFetcher {
+	return &IMAPFetcher{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		SSL:      port == 993,
+	}
+}
+
+// Fetch implements MailboxFetcher. The emails are deleted with an EXPUNGE
+// command once all of them have been processed.
+func (f *IMAPFetcher) Fetch(fn func(r io.Reader) error) error {
+	conn, err := netDialTimeout("tcp", addr(f.Host, f.Port), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	if f.SSL {
+		conn = tlsClient(conn, f.tlsConfig())
+	}
+
+	c := newIMAPConn(conn)
+	defer c.conn.Close()
+	if err := c.greeting(); err != nil {
+		return err
+	}
+
+	if !f.SSL {
+		if _, err := c.cmd("STARTTLS"); err != nil {
+			return err
+		}
+		c = newIMAPConn(tlsClient(c.conn, f.tlsConfig()))
+	}
+
+	return f.session(c, fn)
+}
+
+func (f *IMAPFetcher) tlsConfig() *tls.Config {
+	if f.TLSConfig == nil {
+		return &tls.Config{ServerName: f.Host}
+	}
+	return f.TLSConfig
+}
+
+func (f *IMAPFetcher) session(c *imapConn, fn func(r io.Reader) error) error {
+	user, err := imapQuote(f.Username)
+	if err != nil {
+		return err
+	}
+	pwd, err := imapQuote(f.Password)
+	if err != nil {
+		return err
+	}
+	if _, err := c.cmd("LOGIN %s %s", user, pwd); err != nil {
+		return err
+	}
+
+	mailbox := f.Mailbox
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	if mailbox, err = imapQuote(mailbox); err != nil {
+		return err
+	}
+	if _, err := c.cmd("SELECT %s", mailbox); err != nil {
+		return err
+	}
+
+	resps, err := c.cmd("UID SEARCH ALL")
+	if err != nil {
+		return err
+	}
+	var uids []string
+	for _, r := range resps {
+		if strings.HasPrefix(r.line, "* SEARCH") {
+			uids = append(uids, strings.Fields(r.line[len("* SEARCH"):])...)
+		}
+	}
+
+	deleted := 0
+	for _, uid := range uids {
+		if _, err := strconv.ParseUint(uid, 10, 32); err != nil {
+			return fmt.Errorf("gomail: invalid IMAP UID %q", uid)
+		}
+		resps, err := c.cmd("UID FETCH %s BODY.PEEK[]", uid)
+		if err != nil {
+			return err
+		}
+		var body []byte
+		for _, r := range resps {
+			if strings.Contains(r.line, " FETCH ") && len(r.literals) > 0 {
+				body = r.literals[0]
+			}
+		}
+		if body == nil || fn(bytes.NewReader(body)) != nil {
+			continue
+		}
+
+		if _, err := c.cmd(`UID STORE %s +FLAGS.SILENT (\Deleted)`, uid); err != nil {
+			return err
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		if _, err := c.cmd("EXPUNGE"); err != nil {
+			return err
+		}
+	}
+	_, err = c.cmd("LOGOUT")
+	return err
+}
+
+// imapQuote returns s as an IMAP quoted string.
+func imapQuote(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return "", errors.New("gomail: IMAP string contains CR or LF")
+	}
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`, nil
+}
+
+// maxIMAPLiteral is the size of the largest email read from an IMAP server.
+const maxIMAPLiteral = 64 << 20
+
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// An imapResponse is a response line with the literals it contains.
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+func newIMAPConn(conn net.Conn) *imapConn {
+	return &imapConn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *imapConn) greeting() error {
+	r, err := c.readResponse()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(r.line, "* OK") && !strings.HasPrefix(r.line, "* PREAUTH") {
+		return fmt.Errorf("gomail: unexpected IMAP greeting %q", r.line)
+	}
+	return nil
+}
+
+// cmd sends a command and returns the untagged responses received before its
+// completion.
+func (c *imapConn) cmd(format string, args ...interface{}) ([]*imapResponse, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := fmt.Fprintf(c.conn, tag+" "+format+"\r\n", args...); err != nil {
+		return nil, err
+	}
+
+	var resps []*imapResponse
+	for {
+		r, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(r.line, tag+" ") {
+			resps = append(resps, r)
+			continue
+		}
+
+		status := r.line[len(tag)+1:]
+		if !strings.HasPrefix(status, "OK") {
+			return nil, fmt.Errorf("gomail: IMAP command %s failed: %s", strings.Fields(format)[0], status)
+		}
+		return resps, nil
+	}
+}
+
+func (c *imapConn) readResponse() (*imapResponse, error) {
+	r := new(imapResponse)
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		r.line += line
+
+		// A line ending with {n} is followed by a literal of n bytes.
+		n, ok := literalSize(line)
+		if !ok {
+			return r, nil
+		}
+		if n > maxIMAPLiteral {
+			return nil, fmt.Errorf("gomail: IMAP literal too large (%d bytes)", n)
+		}
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return nil, err
+		}
+		r.literals = append(r.literals, lit)
+	}
+}
+
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndexByte(line, '{')
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(line[i+1 : len(line)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,86 @@
+package gomail
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIMAPFetcher(t *testing.T) {
+	client, server := net.Pipe()
+	var cmds []string
+	go func() {
+		defer server.Close()
+		io.WriteString(server, "* OK IMAP4rev1 ready\r\n")
+		r := newIMAPConn(server)
+		for {
+			line, err := r.r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmds = append(cmds, line)
+			fields := strings.SplitN(line, " ", 2)
+			tag, cmd := fields[0], fields[1]
+			switch {
+			case cmd == "UID SEARCH ALL":
+				io.WriteString(server, "* SEARCH 3 7\r\n")
+			case cmd == "UID FETCH 3 BODY.PEEK[]":
+				io.WriteString(server, "* 1 FETCH (UID 3 BODY[] {"+strconv.Itoa(len(testDSN))+"}\r\n"+testDSN+")\r\n")
+			case cmd == "UID FETCH 7 BODY.PEEK[]":
+				body := "Subject: hi\r\n\r\nhello\r\n"
+				io.WriteString(server, "* 2 FETCH (UID 7 BODY[] {"+strconv.Itoa(len(body))+"}\r\n"+body+")\r\n")
+			case strings.HasPrefix(cmd, "LOGIN") && !strings.Contains(cmd, `"pass\"word"`):
+				io.WriteString(server, tag+" NO invalid credentials\r\n")
+				continue
+			}
+			io.WriteString(server, tag+" OK done\r\n")
+			if cmd == "LOGOUT" {
+				return
+			}
+		}
+	}()
+
+	f := &IMAPFetcher{Username: "bounces", Password: `pass"word`, Mailbox: "Bounces", SSL: true}
+	c := newIMAPConn(client)
+	if err := c.greeting(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err := f.session(c, func(r io.Reader) error {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		got = append(got, string(b))
+		if _, err := ParseBounce(strings.NewReader(string(b))); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	if len(got) != 2 || got[0] != testDSN {
+		t.Errorf("Invalid emails, got %q", got)
+	}
+	want := []string{
+		`a1 LOGIN "bounces" "pass\"word"`,
+		`a2 SELECT "Bounces"`,
+		"a3 UID SEARCH ALL",
+		"a4 UID FETCH 3 BODY.PEEK[]",
+		`a5 UID STORE 3 +FLAGS.SILENT (\Deleted)`,
+		"a6 UID FETCH 7 BODY.PEEK[]",
+		"a7 EXPUNGE",
+		"a8 LOGOUT",
+	}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("Invalid commands, got:\n%s\nwant:\n%s", strings.Join(cmds, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,115 @@
+package gomail
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// hasControlBreak reports whether s contains CR, LF or NUL, which could inject
+// header fields or truncate the header.
+func hasControlBreak(s string) bool {
+	return strings.ContainsAny(s, "\r\n\x00")
+}
+
+// stripControlBreaks removes the CR, LF and NUL characters of s.
+func stripControlBreaks(s string) string {
+	if !hasControlBreak(s) {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == 0 {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// sanitizeFieldName removes the characters not allowed in a field name, as
+// defined in RFC 5322, section 2.2: only printable ASCII characters other than
+// the colon are.
+func sanitizeFieldName(field string) string {
+	for i := 0; i < len(field); i++ {
+		if c := field[i]; c < 33 || c > 126 || c == ':' {
+			return strings.Map(func(r rune) rune {
+				if r < 33 || r > 126 || r == ':' {
+					return -1
+				}
+				return r
+			}, field)
+		}
+	}
+	return field
+}
+
+// quoteParam escapes a MIME parameter value written between double quotes.
+var quoteParam = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", "", "\x00", "")
+
+// checkInjection records, in strict mode, an error for the field of the given
+// key, set as field, if its name or one of its values had to be sanitized. It
+// clears the previous error of the field otherwise.
+func (m *Message) checkInjection(key, field string, values ...string) {
+	if !m.strict {
+		return
+	}
+	delete(m.injectionErrors, key)
+	err := injectionError(field, values)
+	if err == nil {
+		return
+	}
+	if m.injectionErrors == nil {
+		m.injectionErrors = make(map[string]error)
+	}
+	m.injectionErrors[key] = err
+}
+
+// injectionError returns an error if the name of field or one of its values
+// has to be sanitized.
+func injectionError(field string, values []string) error {
+	invalid := sanitizeFieldName(field) != field
+	for _, v := range values {
+		invalid = invalid || hasControlBreak(v)
+	}
+	if !invalid {
+		return nil
+	}
+	return &wrappedError{ErrInvalidHeader,
+		fmt.Errorf("the %q field contains CR, LF, NUL or invalid name characters", field)}
+}
+
+// checkFile records, in strict mode, an error if the name or a header field
+// of an attached or embedded file had to be sanitized.
+func (m *Message) checkFile(f *file) {
+	if !m.strict {
+		return
+	}
+	if hasControlBreak(f.Name) {
+		m.fileErrors = append(m.fileErrors, &wrappedError{ErrInvalidHeader,
+			fmt.Errorf("the file name %q contains CR, LF or NUL", f.Name)})
+	}
+	fields := make([]string, 0, len(f.Header))
+	for k := range f.Header {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, k := range fields {
+		if err := injectionError(k, f.Header[k]); err != nil {
+			m.fileErrors = append(m.fileErrors, err)
+		}
+	}
+}
+
+// injectionErrs returns the errors recorded by checkInjection and checkFile,
+// sorted by field.
+func (m *Message) injectionErrs() []error {
+	keys := make([]string, 0, len(m.injectionErrors))
+	for k := range m.injectionErrors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, k := range keys {
+		errs = append(errs, m.injectionErrors[k])
+	}
+	return append(errs, m.fileErrors...)
+}
@@ -0,0 +1,113 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHeaderInjection(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetAddressHeader("To", "to@example.com\r\n", "To\r\nBcc: evil@example.com")
+	m.SetHeader("Subject", "Hello\r\nBcc: evil@example.com")
+	m.SetHeader("X-Foo\r\nBcc", "evil@example.com")
+	m.SetBody("text/plain", "Test")
+	m.Attach("test.pdf", SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write([]byte("Content"))
+		return err
+	}), Rename("a\"b\r\nX-Evil: 1.pdf"))
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") || strings.HasPrefix(line, "X-Evil:") {
+			t.Errorf("Injected header line %q in:\n%s", line, buf.String())
+		}
+	}
+
+	h := renderHeader(t, m)
+	to, err := h.AddressList("To")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(to) != 1 || to[0].Address != "to@example.com" || to[0].Name != "To\r\nBcc: evil@example.com" {
+		t.Errorf("Invalid To, got %v", to)
+	}
+	if got := h.Get("X-FooBcc"); got != "evil@example.com" {
+		t.Errorf("Invalid X-FooBcc, got %q", got)
+	}
+	if want := `filename="a\"bX-Evil: 1.pdf"`; !strings.Contains(buf.String(), want) {
+		t.Errorf("Missing %s in:\n%s", want, buf.String())
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("Validate should not fail when not strict, got %v", err)
+	}
+}
+
+func TestFileHeaderInjection(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.Attach("test.pdf", SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write([]byte("Content"))
+		return err
+	}), SetHeader(map[string][]string{
+		"X-Tag":        {"a\r\nBcc: evil@example.com"},
+		"X-Foo\r\nBcc": {"evil@example.com"},
+	}))
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("Injected header line %q in:\n%s", line, buf.String())
+		}
+	}
+	h := renderHeader(t, m)
+	if got := h.Get("X-Tag"); got != "aBcc: evil@example.com" {
+		t.Errorf("Invalid X-Tag, got %q", got)
+	}
+	if got := h.Get("X-FooBcc"); got != "evil@example.com" {
+		t.Errorf("Invalid X-FooBcc, got %q", got)
+	}
+}
+
+func TestStrictHeaderInjection(t *testing.T) {
+	m := NewMessage(StrictAddresses())
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetHeader("Subject", "Hello\r\nBcc: evil@example.com")
+	m.SetHeader("X-Foo\nBar", "value")
+	m.Attach("test.pdf", SetCopyFunc(func(io.Writer) error { return nil }), Rename("test\x00.pdf"),
+		SetHeader(map[string][]string{"X-Tag": {"a\r\nBcc: evil@example.com"}}))
+
+	err := m.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 4 {
+		t.Fatalf("Validate should return 4 errors, got %v", err)
+	}
+	for _, err := range verr.Errors {
+		if !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("Invalid error, got %v", err)
+		}
+	}
+	if _, err := m.Envelope(); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Envelope should fail, got %v", err)
+	}
+
+	// Setting the fields again with valid values clears their errors.
+	m.SetHeader("Subject", "Hello")
+	m.SetHeader("X-FooBar", "value")
+	m.attachments = nil
+	m.fileErrors = nil
+	if err := m.Validate(); err != nil {
+		t.Errorf("Validate should not fail, got %v", err)
+	}
+}
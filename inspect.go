@@ -0,0 +1,119 @@
+package gomail
+
+import (
+	"io"
+	"sort"
+)
+
+// Headers calls f for each header field of the message, sorted by field name,
+// until f returns false. The values passed to f are a copy and can be kept or
+// modified.
+//
+// The fields added when the email is rendered, like MIME-Version or Date when
+// it is not set, are not included.
+//
+// With Go 1.23 or newer, Headers can be used in a for range loop:
+//
+//	for field, values := range m.Headers {
+//		...
+//	}
+func (m *Message) Headers(f func(field string, values []string) bool) {
+	fields := make([]string, 0, len(m.header))
+	for field := range m.header {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if !f(typedFieldName(m.fieldNames, field), copyValues(m.header[field])) {
+			return
+		}
+	}
+}
+
+// A BodyPart is a read-only view of a body part of a message, as set by
+// SetBody, AddAlternative or AddAlternativeWriter.
+type BodyPart struct {
+	// ContentType is the content type of the part, for example "text/html".
+	ContentType string
+	// Encoding is the transfer encoding used when the part is rendered.
+	Encoding Encoding
+
+	copier func(io.Writer) error
+}
+
+// WriteTo writes the content of the part, before it is encoded, to w.
+func (p BodyPart) WriteTo(w io.Writer) (int64, error) {
+	return copyCounted(w, p.copier)
+}
+
+// Parts returns the body parts of the message in the order they were added.
+func (m *Message) Parts() []BodyPart {
+	parts := make([]BodyPart, len(m.parts))
+	for i, p := range m.parts {
+		parts[i] = BodyPart{ContentType: p.contentType, Encoding: p.encoding, copier: p.copier}
+	}
+	return parts
+}
+
+// AllParts calls yield for each body part of the message, in the order they
+// were added, until yield returns false. Unlike Parts, it does not copy the
+// list of parts. Like Headers, it can be used in a for range loop with Go 1.23
+// or newer.
+func (m *Message) AllParts(yield func(BodyPart) bool) {
+	for _, p := range m.parts {
+		if !yield(BodyPart{ContentType: p.contentType, Encoding: p.encoding, copier: p.copier}) {
+			return
+		}
+	}
+}
+
+// A FilePart is a read-only view of a file attached or embedded in a message.
+type FilePart struct {
+	// Name is the name of the file in the email.
+	Name string
+	// Header is a copy of the MIME header set with the SetHeader file
+	// setting. The mandatory fields are only added when the email is
+	// rendered.
+	Header map[string][]string
+
+	copier func(io.Writer) error
+}
+
+// WriteTo writes the content of the file, before it is encoded, to w. Like
+// when the email is sent, the file is read each time WriteTo is called.
+func (f FilePart) WriteTo(w io.Writer) (int64, error) {
+	return copyCounted(w, f.copier)
+}
+
+// Attachments returns the files attached to the message with Attach.
+func (m *Message) Attachments() []FilePart {
+	return fileParts(m.attachments)
+}
+
+// EmbeddedFiles returns the files embedded in the message with Embed.
+func (m *Message) EmbeddedFiles() []FilePart {
+	return fileParts(m.embedded)
+}
+
+func fileParts(list []*file) []FilePart {
+	files := make([]FilePart, len(list))
+	for i, f := range list {
+		h := make(map[string][]string, len(f.Header))
+		for k, v := range f.Header {
+			h[k] = copyValues(v)
+		}
+		files[i] = FilePart{Name: f.Name, Header: h, copier: f.CopyFunc}
+	}
+	return files
+}
+
+func copyValues(values []string) []string {
+	return append([]string(nil), values...)
+}
+
+func copyCounted(w io.Writer, copier func(io.Writer) error) (int64, error) {
+	cw := &countingWriter{}
+	err := copier(io.MultiWriter(w, cw))
+	return cw.n, err
+}
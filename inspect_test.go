@@ -0,0 +1,118 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("To", testTo1, testTo2)
+	m.SetHeader("From", testFrom)
+	m.SetHeader("Subject", "Hello")
+
+	var fields []string
+	m.Headers(func(field string, values []string) bool {
+		fields = append(fields, field)
+		values[0] = "modified"
+		return true
+	})
+	if got, want := fields, []string{"From", "Subject", "To"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid fields, got %v, want %v", got, want)
+	}
+	if got := m.GetHeader("From")[0]; got != testFrom {
+		t.Errorf("Headers should pass a copy of the values, got %q", got)
+	}
+
+	fields = nil
+	m.Headers(func(field string, values []string) bool {
+		fields = append(fields, field)
+		return false
+	})
+	if len(fields) != 1 {
+		t.Errorf("Headers should stop when f returns false, got %v", fields)
+	}
+}
+
+func TestParts(t *testing.T) {
+	m := NewMessage()
+	m.SetBody("text/plain", "Hello!")
+	m.AddAlternative("text/html", "<p>Hello!</p>", SetPartEncoding(Base64))
+
+	parts := m.Parts()
+	if len(parts) != 2 {
+		t.Fatalf("Invalid number of parts, got %d", len(parts))
+	}
+	if parts[0].ContentType != "text/plain" || parts[0].Encoding != QuotedPrintable {
+		t.Errorf("Invalid part, got %+v", parts[0])
+	}
+	if parts[1].ContentType != "text/html" || parts[1].Encoding != Base64 {
+		t.Errorf("Invalid part, got %+v", parts[1])
+	}
+
+	buf := new(bytes.Buffer)
+	n, err := parts[1].WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "<p>Hello!</p>"; got != want || n != int64(len(want)) {
+		t.Errorf("Invalid content, got %q (%d bytes), want %q", got, n, want)
+	}
+}
+
+func TestAllParts(t *testing.T) {
+	m := NewMessage()
+	m.SetBody("text/plain", "Hello!")
+	m.AddAlternative("text/html", "<p>Hello!</p>")
+
+	var types []string
+	m.AllParts(func(p BodyPart) bool {
+		types = append(types, p.ContentType)
+		return true
+	})
+	if want := []string{"text/plain", "text/html"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("Invalid parts, got %v, want %v", types, want)
+	}
+
+	types = nil
+	m.AllParts(func(p BodyPart) bool {
+		types = append(types, p.ContentType)
+		return false
+	})
+	if len(types) != 1 {
+		t.Errorf("AllParts should stop when yield returns false, got %v", types)
+	}
+}
+
+func TestFileParts(t *testing.T) {
+	m := NewMessage()
+	m.Attach("/tmp/report.pdf",
+		Rename("report-2014.pdf"),
+		SetHeader(map[string][]string{"Content-Type": {"application/pdf"}}),
+		SetCopyFunc(func(w io.Writer) error {
+			_, err := io.WriteString(w, "%PDF")
+			return err
+		}))
+	m.Embed("/tmp/logo.png")
+
+	files := m.Attachments()
+	if len(files) != 1 || files[0].Name != "report-2014.pdf" {
+		t.Fatalf("Invalid attachments, got %+v", files)
+	}
+	files[0].Header["Content-Type"][0] = "text/plain"
+	if got := m.Attachments()[0].Header["Content-Type"][0]; got != "application/pdf" {
+		t.Errorf("Header should be a copy, got %q", got)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := files[0].WriteTo(buf); err != nil || buf.String() != "%PDF" {
+		t.Errorf("Invalid content, got %q, %v", buf.String(), err)
+	}
+
+	embedded := m.EmbeddedFiles()
+	if len(embedded) != 1 || embedded[0].Name != "logo.png" {
+		t.Errorf("Invalid embedded files, got %+v", embedded)
+	}
+}
@@ -0,0 +1,164 @@
+package gomail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A SigningKey is a private key used to sign emails.
+type SigningKey struct {
+	// Selector is the DKIM selector under which the public key is published.
+	Selector string
+	// Signer is the private key. It can be backed by a KMS or an HSM so the key
+	// material never needs to be loaded in memory.
+	Signer crypto.Signer
+}
+
+// A KeyProvider provides the keys used to sign the emails of a sending domain.
+type KeyProvider interface {
+	// SigningKey returns the key used to sign the emails sent from the given
+	// domain.
+	SigningKey(domain string) (*SigningKey, error)
+}
+
+// The KeyProviderFunc type is an adapter to allow the use of ordinary
+// functions as key providers.
+type KeyProviderFunc func(domain string) (*SigningKey, error)
+
+// SigningKey calls f(domain).
+func (f KeyProviderFunc) SigningKey(domain string) (*SigningKey, error) {
+	return f(domain)
+}
+
+// KeyMap is a KeyProvider returning the keys of a fixed set of domains.
+// Domains are matched case-insensitively and must be lowercase in the map.
+type KeyMap map[string]*SigningKey
+
+// SigningKey implements KeyProvider.
+func (m KeyMap) SigningKey(domain string) (*SigningKey, error) {
+	k, ok := m[strings.ToLower(domain)]
+	if !ok {
+		return nil, fmt.Errorf("gomail: no signing key for domain %q", domain)
+	}
+	return k, nil
+}
+
+// A ScheduledKey is a signing key valid during a period of time.
+type ScheduledKey struct {
+	SigningKey
+	// NotBefore is the time from which the key is used. If zero, the key is
+	// valid from the start.
+	NotBefore time.Time
+	// NotAfter is the time after which the key is no longer used. If zero, the
+	// key never expires.
+	NotAfter time.Time
+}
+
+func (k *ScheduledKey) validAt(t time.Time) bool {
+	return !t.Before(k.NotBefore) && (k.NotAfter.IsZero() || !t.After(k.NotAfter))
+}
+
+// KeyRotation is a KeyProvider rotating the keys of several domains. When an
+// email is signed, the valid key with the latest NotBefore is chosen among the
+// keys of the sending domain, so a new selector can be scheduled while the
+// current one is still in use. Domains must be lowercase in the map.
+//
+// The public keys must be published before they become valid and should stay
+// published for a few days after they expire so the emails still in transit
+// can be verified. DNSRecords returns the records to publish.
+type KeyRotation map[string][]*ScheduledKey
+
+// SigningKey implements KeyProvider.
+func (r KeyRotation) SigningKey(domain string) (*SigningKey, error) {
+	t := now()
+	var key *ScheduledKey
+	for _, k := range r[strings.ToLower(domain)] {
+		if k.validAt(t) && (key == nil || k.NotBefore.After(key.NotBefore)) {
+			key = k
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("gomail: no valid signing key for domain %q", domain)
+	}
+	return &key.SigningKey, nil
+}
+
+// DNSRecords returns the DNS TXT records publishing the public keys of all the
+// keys of r, sorted by name.
+func (r KeyRotation) DNSRecords() ([]*DNSRecord, error) {
+	var records []*DNSRecord
+	for domain, keys := range r {
+		for _, k := range keys {
+			rec, err := k.DNSRecord(domain)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, rec)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Name < records[j].Name
+	})
+	return records, nil
+}
+
+// A DNSRecord is a DNS TXT record.
+type DNSRecord struct {
+	// Name is the fully qualified domain name of the record, for example
+	// "s1._domainkey.example.com".
+	Name string
+	// Value is the content of the record.
+	Value string
+}
+
+// String returns the record in the zone file format. The value is split in
+// strings of at most 255 characters as required by the DNS.
+func (r *DNSRecord) String() string {
+	var b bytes.Buffer
+	b.WriteString(r.Name)
+	b.WriteString(". IN TXT")
+	v := r.Value
+	for len(v) > 0 {
+		n := len(v)
+		if n > 255 {
+			n = 255
+		}
+		b.WriteString(` "`)
+		b.WriteString(v[:n])
+		b.WriteByte('"')
+		v = v[n:]
+	}
+	return b.String()
+}
+
+// DNSRecord returns the DKIM record publishing the public key of k for the
+// given domain, as defined in RFC 6376, section 3.6.1.
+func (k *SigningKey) DNSRecord(domain string) (*DNSRecord, error) {
+	var alg string
+	var pub []byte
+	switch key := k.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		alg, pub = "rsa", der
+	case ed25519.PublicKey:
+		alg, pub = "ed25519", key
+	default:
+		return nil, fmt.Errorf("gomail: unsupported DKIM key type %T", key)
+	}
+
+	return &DNSRecord{
+		Name:  k.Selector + "._domainkey." + strings.ToLower(domain),
+		Value: "v=DKIM1; k=" + alg + "; p=" + base64.StdEncoding.EncodeToString(pub),
+	}, nil
+}
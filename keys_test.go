@@ -0,0 +1,152 @@
+package gomail
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyMap(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := KeyMap{"example.com": {Selector: "s1", Signer: key}}
+
+	if k, err := keys.SigningKey("Example.COM"); err != nil || k.Selector != "s1" {
+		t.Errorf("Invalid key, got %v, %v", k, err)
+	}
+	if _, err := keys.SigningKey("example.org"); err == nil {
+		t.Error("SigningKey should fail for an unknown domain")
+	}
+}
+
+func TestDKIMKeyProvider(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var domains []string
+	s := &DKIMSigner{
+		Keys: KeyProviderFunc(func(domain string) (*SigningKey, error) {
+			domains = append(domains, domain)
+			return &SigningKey{Selector: "kms", Signer: key}, nil
+		}),
+	}
+
+	sig, msg := testDKIMSign(t, s)
+	if len(domains) != 1 || domains[0] != "example.com" {
+		t.Errorf("Invalid domains, got %q", domains)
+	}
+	for _, tag := range []string{"d=example.com;", "s=kms;"} {
+		if !strings.Contains(sig, tag) {
+			t.Errorf("Missing tag %q in %q", tag, sig)
+		}
+	}
+	verifyDKIM(t, sig, msg, key.Public())
+
+	s.Keys = KeyMap{}
+	if _, err := s.Sign(msg); err == nil {
+		t.Error("Sign should fail when the domain has no key")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	current := time.Date(2014, 6, 25, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switchAt := current.Add(24 * time.Hour)
+	r := KeyRotation{"example.com": {
+		{SigningKey: SigningKey{"2014a", key}, NotAfter: switchAt.Add(time.Hour)},
+		{SigningKey: SigningKey{"2014b", key}, NotBefore: switchAt},
+		{SigningKey: SigningKey{"old", key}, NotAfter: current.Add(-time.Hour)},
+	}}
+
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{current, "2014a"},
+		{switchAt.Add(-time.Second), "2014a"},
+		{switchAt, "2014b"},
+		{switchAt.Add(48 * time.Hour), "2014b"},
+	}
+	for _, test := range tests {
+		current = test.at
+		k, err := r.SigningKey("EXAMPLE.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k.Selector != test.want {
+			t.Errorf("Invalid selector at %v, got %q, want %q", test.at, k.Selector, test.want)
+		}
+	}
+
+	current = time.Date(2014, 6, 25, 0, 0, 0, 0, time.UTC)
+	r["example.com"] = r["example.com"][2:]
+	if _, err := r.SigningKey("example.com"); err == nil {
+		t.Error("SigningKey should fail when no key is valid")
+	}
+}
+
+func TestDNSRecords(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := KeyRotation{
+		"example.org": {{SigningKey: SigningKey{"ed", key}}},
+		"example.com": {{SigningKey: SigningKey{"rsa", rsaKey}}},
+	}
+
+	records, err := r.DNSRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Invalid number of records, got %d, want 2", len(records))
+	}
+
+	want := "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)
+	if records[0].Name != "ed._domainkey.example.org" || records[0].Value != want {
+		t.Errorf("Invalid ed25519 record, got %+v", records[0])
+	}
+
+	rec := records[1]
+	if rec.Name != "rsa._domainkey.example.com" || !strings.HasPrefix(rec.Value, "v=DKIM1; k=rsa; p=") {
+		t.Fatalf("Invalid RSA record, got %+v", rec)
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(rec.Value, "v=DKIM1; k=rsa; p="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := x509.ParsePKIXPublicKey(der); err != nil || p.(*rsa.PublicKey).N.Cmp(rsaKey.N) != 0 {
+		t.Errorf("Invalid RSA public key, got %v, %v", p, err)
+	}
+
+	// A 2048-bit RSA key does not fit in a single string.
+	s := rec.String()
+	if !strings.HasPrefix(s, `rsa._domainkey.example.com. IN TXT "v=DKIM1;`) {
+		t.Errorf("Invalid zone file format: %s", s)
+	}
+	if strings.Count(s, `"`) != 4 {
+		t.Errorf("The record should be split in two strings: %s", s)
+	}
+	if got := strings.Replace(strings.TrimPrefix(s, rec.Name+". IN TXT "), `" "`, "", -1); got != `"`+rec.Value+`"` {
+		t.Errorf("Invalid record strings, got %s", got)
+	}
+}
@@ -0,0 +1,174 @@
+package gomail
+
+import (
+	"fmt"
+	"io"
+)
+
+// A MailingList is a list address that an ExpandingSender expands into its
+// members.
+type MailingList struct {
+	// Address is the posting address of the list.
+	Address string
+	// ID is the list identifier used in the List-Id header as defined in
+	// RFC 2919, for example "news.example.com". If empty, it is derived from
+	// Address.
+	ID string
+	// Name is the optional description of the list shown in the List-Id
+	// header.
+	Name string
+	// Members are the addresses the emails sent to the list are delivered to.
+	Members []string
+	// Bounces is the envelope sender used for the emails sent to the list. If
+	// empty, the local part of Address suffixed with "-bounces" is used.
+	Bounces string
+	// Help, Subscribe, Unsubscribe, Owner and Archive are the URLs, usually
+	// mailto: or https: URLs, set in the List-* headers defined in RFC 2369.
+	// Empty values are omitted.
+	Help        string
+	Subscribe   string
+	Unsubscribe string
+	Owner       string
+	Archive     string
+}
+
+// A ListResolver resolves recipient addresses into mailing lists.
+type ListResolver interface {
+	// ResolveList returns the mailing list corresponding to the given
+	// address or nil if the address is not a list.
+	ResolveList(address string) (*MailingList, error)
+}
+
+// The ListResolverFunc type is an adapter to allow the use of ordinary
+// functions as list resolvers.
+type ListResolverFunc func(address string) (*MailingList, error)
+
+// ResolveList calls f(address).
+func (f ListResolverFunc) ResolveList(address string) (*MailingList, error) {
+	return f(address)
+}
+
+// An ExpandingSender is a Sender that expands the mailing lists found in the
+// recipients of an email into the list members.
+//
+// The emails sent to a list use the bounce address of the list as envelope
+// sender and get the List-* headers of the list. Recipients that are not lists
+// receive the email unchanged.
+type ExpandingSender struct {
+	// Sender is the Sender used to deliver the emails.
+	Sender Sender
+	// Resolver resolves the recipient addresses into mailing lists.
+	Resolver ListResolver
+	// VERP defines whether each list member gets its own email with an
+	// envelope sender encoding its address, for example
+	// "news-bounces+bob=example.org@example.com", so bounces can be matched
+	// with the member.
+	VERP bool
+}
+
+// Send implements Sender.
+func (s *ExpandingSender) Send(from string, to []string, msg io.WriterTo) error {
+	var direct []string
+	var lists []*MailingList
+	for _, addr := range to {
+		l, err := s.Resolver.ResolveList(addr)
+		if err != nil {
+			return fmt.Errorf("gomail: could not resolve list %q: %w", addr, err)
+		}
+		if l == nil {
+			direct = addAddress(direct, addr)
+		} else {
+			lists = append(lists, l)
+		}
+	}
+
+	if len(direct) > 0 {
+		if err := s.Sender.Send(from, direct, msg); err != nil {
+			return err
+		}
+	}
+
+	for _, l := range lists {
+		if err := s.sendList(l, msg); err != nil {
+			return fmt.Errorf("gomail: could not send to list %q: %w", l.Address, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *ExpandingSender) sendList(l *MailingList, msg io.WriterTo) error {
+	var members []string
+	for _, addr := range l.Members {
+		members = addAddress(members, addr)
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	lm := &listMessage{list: l, msg: msg}
+	if !s.VERP {
+		return s.Sender.Send(l.bounceAddress(), members, lm)
+	}
+
+	for _, addr := range members {
+		if err := s.Sender.Send(VERPAddress(l.bounceAddress(), addr), []string{addr}, lm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *MailingList) bounceAddress() string {
+	if l.Bounces != "" {
+		return l.Bounces
+	}
+	local, domain := splitAddress(l.Address)
+	return local + "-bounces@" + domain
+}
+
+func (l *MailingList) header() [][2]string {
+	id := l.ID
+	if id == "" {
+		local, domain := splitAddress(l.Address)
+		id = local + "." + domain
+	}
+	if l.Name != "" {
+		id = l.Name + " <" + id + ">"
+	} else {
+		id = "<" + id + ">"
+	}
+
+	h := [][2]string{
+		{"List-Id", id},
+		{"List-Post", "<mailto:" + l.Address + ">"},
+	}
+	for _, f := range [][2]string{
+		{"List-Help", l.Help},
+		{"List-Subscribe", l.Subscribe},
+		{"List-Unsubscribe", l.Unsubscribe},
+		{"List-Owner", l.Owner},
+		{"List-Archive", l.Archive},
+	} {
+		if f[1] != "" {
+			h = append(h, [2]string{f[0], "<" + f[1] + ">"})
+		}
+	}
+	return h
+}
+
+// listMessage prepends the List-* headers of a mailing list to a message.
+type listMessage struct {
+	list *MailingList
+	msg  io.WriterTo
+}
+
+func (m *listMessage) WriteTo(w io.Writer) (int64, error) {
+	mw := &messageWriter{w: w}
+	for _, f := range m.list.header() {
+		mw.writeHeader(f[0], f[1])
+	}
+
+	n, err := m.msg.WriteTo(w)
+	return mw.n + n, err
+}
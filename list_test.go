@@ -0,0 +1,111 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sentMessage struct {
+	from string
+	to   []string
+	msg  string
+}
+
+func recordSender(sent *[]sentMessage) SendFunc {
+	return func(from string, to []string, msg io.WriterTo) error {
+		buf := new(bytes.Buffer)
+		if _, err := msg.WriteTo(buf); err != nil {
+			return err
+		}
+		*sent = append(*sent, sentMessage{from, to, buf.String()})
+		return nil
+	}
+}
+
+var testList = &MailingList{
+	Address:     "news@example.com",
+	Name:        "News",
+	Members:     []string{"bob@example.org", "cora@example.net", "bob@example.org"},
+	Unsubscribe: "mailto:news-unsubscribe@example.com",
+}
+
+func testListResolver(addr string) (*MailingList, error) {
+	if addr == testList.Address {
+		return testList, nil
+	}
+	return nil, nil
+}
+
+func TestExpandingSender(t *testing.T) {
+	var sent []sentMessage
+	s := &ExpandingSender{
+		Sender:   recordSender(&sent),
+		Resolver: ListResolverFunc(testListResolver),
+	}
+
+	m := getTestMessage()
+	m.SetHeader("To", testTo1, "news@example.com")
+	if err := Send(s, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 2 {
+		t.Fatalf("Invalid number of emails sent, got %d, want 2", len(sent))
+	}
+	if sent[0].from != testFrom || !reflect.DeepEqual(sent[0].to, []string{testTo1}) {
+		t.Errorf("Invalid envelope, got %q %q", sent[0].from, sent[0].to)
+	}
+	if strings.Contains(sent[0].msg, "List-Id") {
+		t.Errorf("Direct recipient should not get List-* headers:\n%s", sent[0].msg)
+	}
+
+	if sent[1].from != "news-bounces@example.com" {
+		t.Errorf("Invalid envelope sender, got %q", sent[1].from)
+	}
+	if want := []string{"bob@example.org", "cora@example.net"}; !reflect.DeepEqual(sent[1].to, want) {
+		t.Errorf("Invalid recipients, got %q, want %q", sent[1].to, want)
+	}
+	for _, h := range []string{
+		"List-Id: News <news.example.com>\r\n",
+		"List-Post: <mailto:news@example.com>\r\n",
+		"List-Unsubscribe: <mailto:news-unsubscribe@example.com>\r\n",
+	} {
+		if !strings.HasPrefix(sent[1].msg, h) && !strings.Contains(sent[1].msg, "\r\n"+h) {
+			t.Errorf("Missing header %q in:\n%s", h, sent[1].msg)
+		}
+	}
+}
+
+func TestExpandingSenderVERP(t *testing.T) {
+	var sent []sentMessage
+	s := &ExpandingSender{
+		Sender:   recordSender(&sent),
+		Resolver: ListResolverFunc(testListResolver),
+		VERP:     true,
+	}
+
+	m := getTestMessage()
+	m.SetHeader("To", "news@example.com")
+	if err := Send(s, m); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		from string
+		to   []string
+	}{
+		{"news-bounces+bob=example.org@example.com", []string{"bob@example.org"}},
+		{"news-bounces+cora=example.net@example.com", []string{"cora@example.net"}},
+	}
+	if len(sent) != len(want) {
+		t.Fatalf("Invalid number of emails sent, got %d, want %d", len(sent), len(want))
+	}
+	for i, w := range want {
+		if sent[i].from != w.from || !reflect.DeepEqual(sent[i].to, w.to) {
+			t.Errorf("Invalid envelope, got %q %q, want %q %q", sent[i].from, sent[i].to, w.from, w.to)
+		}
+	}
+}
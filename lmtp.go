@@ -0,0 +1,304 @@
+package gomail
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+// lmtpConn is a client of the LMTP protocol defined in RFC 2033, the variant of
+// SMTP used to deliver emails to a mail store like Dovecot or Cyrus. It greets
+// the server with LHLO instead of EHLO and, after the content of the email,
+// reads one reply per accepted recipient.
+type lmtpConn struct {
+	conn      net.Conn
+	text      *textproto.Conn
+	host      string
+	localName string
+	tls       bool
+
+	didHello bool
+	helloErr error
+	ext      map[string]string
+	auth     []string
+	// rcpts is the number of recipients accepted in the current transaction.
+	rcpts int
+}
+
+// Stubbed out for tests.
+var lmtpNewClient = func(conn net.Conn, host string) (smtpClient, error) {
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		text.Close()
+		return nil, err
+	}
+	_, isTLS := conn.(*tls.Conn)
+	return &lmtpConn{conn: conn, text: text, host: host, localName: "localhost", tls: isTLS}, nil
+}
+
+func (c *lmtpConn) cmd(expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := c.text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+	return c.text.ReadResponse(expectCode)
+}
+
+// hello sends the LHLO command if it has not been sent yet.
+func (c *lmtpConn) hello() error {
+	if c.didHello {
+		return c.helloErr
+	}
+	c.didHello = true
+	_, msg, err := c.cmd(250, "LHLO %s", c.localName)
+	if err != nil {
+		c.helloErr = err
+		return err
+	}
+
+	c.ext = make(map[string]string)
+	for _, line := range strings.Split(msg, "\n")[1:] {
+		args := strings.SplitN(line, " ", 2)
+		if len(args) > 1 {
+			c.ext[args[0]] = args[1]
+		} else {
+			c.ext[args[0]] = ""
+		}
+	}
+	// The content is always sent with DATA.
+	delete(c.ext, "CHUNKING")
+	delete(c.ext, "BINARYMIME")
+	if mechs, ok := c.ext["AUTH"]; ok {
+		c.auth = strings.Split(mechs, " ")
+	}
+	return nil
+}
+
+func (c *lmtpConn) Hello(name string) error {
+	if strings.ContainsAny(name, "\r\n") {
+		return errors.New("gomail: a line must not contain CR or LF")
+	}
+	if c.didHello {
+		return errors.New("gomail: Hello called after other methods")
+	}
+	c.localName = name
+	return c.hello()
+}
+
+func (c *lmtpConn) Extension(name string) (bool, string) {
+	if err := c.hello(); err != nil {
+		return false, ""
+	}
+	param, ok := c.ext[strings.ToUpper(name)]
+	return ok, param
+}
+
+func (c *lmtpConn) extensions() map[string]string {
+	ext := make(map[string]string)
+	for _, name := range knownExtensions {
+		if ok, param := c.Extension(name); ok {
+			ext[name] = param
+		}
+	}
+	return ext
+}
+
+func (c *lmtpConn) StartTLS(config *tls.Config) error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	if _, _, err := c.cmd(220, "STARTTLS"); err != nil {
+		return err
+	}
+	c.conn = tlsClient(c.conn, config)
+	c.text = textproto.NewConn(c.conn)
+	c.tls = true
+	c.didHello = false
+	return c.hello()
+}
+
+// Auth authenticates like smtp.Client.Auth.
+func (c *lmtpConn) Auth(a smtp.Auth) error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	encoding := base64.StdEncoding
+	mech, resp, err := a.Start(&smtp.ServerInfo{Name: c.host, TLS: c.tls, Auth: c.auth})
+	if err != nil {
+		c.Quit()
+		return err
+	}
+	code, msg64, err := c.cmd(0, "%s", strings.TrimSpace("AUTH "+mech+" "+encoding.EncodeToString(resp)))
+	for err == nil {
+		var msg []byte
+		switch code {
+		case 334:
+			msg, err = encoding.DecodeString(msg64)
+		case 235:
+			msg = []byte(msg64)
+		default:
+			err = &textproto.Error{Code: code, Msg: msg64}
+		}
+		if err == nil {
+			resp, err = a.Next(msg, code == 334)
+		}
+		if err != nil {
+			// The authentication is canceled.
+			c.cmd(501, "*")
+			c.Quit()
+			break
+		}
+		if resp == nil {
+			break
+		}
+		code, msg64, err = c.cmd(0, "%s", encoding.EncodeToString(resp))
+	}
+	return err
+}
+
+func (c *lmtpConn) Mail(from string, params ...string) error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(from, "\r\n") {
+		return errors.New("gomail: a line must not contain CR or LF")
+	}
+	if _, ok := c.ext["8BITMIME"]; ok && (len(params) == 0 || !strings.HasPrefix(params[0], "BODY=")) {
+		params = append([]string{"BODY=8BITMIME"}, params...)
+	}
+	if _, ok := c.ext["SMTPUTF8"]; ok {
+		params = append(params, "SMTPUTF8")
+	}
+
+	c.rcpts = 0
+	_, _, err := c.cmd(250, "MAIL FROM:<%s>%s", from, joinParams(params))
+	return err
+}
+
+func (c *lmtpConn) Rcpt(to string, params ...string) error {
+	if strings.ContainsAny(to, "\r\n") {
+		return errors.New("gomail: a line must not contain CR or LF")
+	}
+	if _, _, err := c.cmd(25, "RCPT TO:<%s>%s", to, joinParams(params)); err != nil {
+		return err
+	}
+	c.rcpts++
+	return nil
+}
+
+func joinParams(params []string) string {
+	if len(params) == 0 {
+		return ""
+	}
+	return " " + strings.Join(params, " ")
+}
+
+func (c *lmtpConn) Data() (io.WriteCloser, error) {
+	if _, _, err := c.cmd(354, "DATA"); err != nil {
+		return nil, err
+	}
+	return &lmtpDataWriter{c: c, w: c.text.DotWriter()}, nil
+}
+
+func (c *lmtpConn) Bdat(binary bool) (io.WriteCloser, error) {
+	return nil, errors.New("gomail: BDAT is not supported with LMTP")
+}
+
+func (c *lmtpConn) Reset() error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	_, _, err := c.cmd(250, "RSET")
+	return err
+}
+
+func (c *lmtpConn) Quit() error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	if _, _, err := c.cmd(221, "QUIT"); err != nil {
+		return err
+	}
+	return c.text.Close()
+}
+
+func (c *lmtpConn) Close() error {
+	return c.text.Close()
+}
+
+// lmtpDataWriter writes the content of an email and reads the reply of each
+// recipient when closed.
+type lmtpDataWriter struct {
+	c *lmtpConn
+	w io.WriteCloser
+}
+
+func (w *lmtpDataWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w *lmtpDataWriter) Close() error {
+	if err := w.w.Close(); err != nil {
+		return err
+	}
+	replies := make(lmtpReplies, w.c.rcpts)
+	failed := false
+	for i := range replies {
+		_, _, err := w.c.text.ReadResponse(250)
+		var perr *textproto.Error
+		if err != nil && !errors.As(err, &perr) {
+			return err
+		}
+		replies[i] = perr
+		failed = failed || perr != nil
+	}
+	if failed {
+		return replies
+	}
+	return nil
+}
+
+// lmtpReplies are the replies of an LMTP server to the content of an email,
+// one per accepted recipient, nil for the recipients the email was delivered
+// to. It is returned when some of the replies are errors.
+type lmtpReplies []*textproto.Error
+
+func (r lmtpReplies) Error() string {
+	var n int
+	for _, err := range r {
+		if err != nil {
+			n++
+		}
+	}
+	return fmt.Sprintf("gomail: the email could not be delivered to %d of %d recipients", n, len(r))
+}
+
+// sendError returns the SendError of an LMTP transaction whose recipients
+// were accepted, and possibly rejected in serr, before the content was sent.
+func (r lmtpReplies) sendError(serr *SendError, accepted []string) *SendError {
+	if serr == nil {
+		serr = new(SendError)
+	}
+	serr.Accepted = nil
+	for i, addr := range accepted {
+		if i >= len(r) || r[i] == nil {
+			serr.Accepted = append(serr.Accepted, addr)
+			continue
+		}
+		serr.Rejected = append(serr.Rejected, &RecipientError{
+			Address: addr,
+			Code:    r[i].Code,
+			Message: r[i].Msg,
+		})
+	}
+	serr.Sent = len(serr.Accepted) > 0
+	return serr
+}
@@ -0,0 +1,84 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"reflect"
+	"testing"
+)
+
+// serveLMTP runs a fake LMTP server on the server side of conn, expecting the
+// given commands and sending the given replies. The replies to the content of
+// the email are sent after the "." line.
+func serveLMTP(t *testing.T, conn net.Conn, script []string) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 lmtp.example.com LMTP ready")
+	for i := 0; i < len(script); i += 2 {
+		want, reply := script[i], script[i+1]
+		if want == "." {
+			if _, err := ioutil.ReadAll(text.DotReader()); err != nil {
+				t.Error(err)
+				return
+			}
+		} else if got, err := text.ReadLine(); err != nil || got != want {
+			t.Errorf("Invalid command, got %q (%v), want %q", got, err, want)
+			return
+		}
+		text.PrintfLine("%s", reply)
+	}
+}
+
+func TestDialerLMTP(t *testing.T) {
+	d := &Dialer{
+		Host:      "localhost",
+		Network:   "unix",
+		Address:   "/var/run/dovecot/lmtp",
+		LMTP:      true,
+		TLSPolicy: NoTLS,
+		LocalName: "mail.example.com",
+	}
+	d.NetDialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveLMTP(t, server, []string{
+			"LHLO mail.example.com", "250-lmtp.example.com\r\n250-8BITMIME\r\n250-CHUNKING\r\n250 PIPELINING",
+			"MAIL FROM:<" + testFrom + "> BODY=8BITMIME", "250 2.1.0 OK",
+			"RCPT TO:<" + testTo1 + ">", "250 2.1.5 OK",
+			"RCPT TO:<" + testTo2 + ">", "250 2.1.5 OK",
+			"DATA", "354 OK",
+			".", "250 2.0.0 Saved\r\n452 4.2.2 Mailbox is full",
+			"QUIT", "221 2.0.0 Bye",
+		})
+		return client, nil
+	}
+
+	err := d.DialAndSend(getTestMessage())
+	var serr *SendError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Invalid error, got %v", err)
+	}
+	if !serr.Sent || !reflect.DeepEqual(serr.Accepted, []string{testTo1}) {
+		t.Errorf("The email should be delivered to the first recipient, got %+v", serr)
+	}
+	if len(serr.Rejected) != 1 || serr.Rejected[0].Address != testTo2 || serr.Rejected[0].Code != 452 {
+		t.Errorf("Invalid rejected recipients, got %+v", serr.Rejected)
+	}
+}
+
+func TestLMTPConnExtensions(t *testing.T) {
+	client, server := net.Pipe()
+	go serveLMTP(t, server, []string{
+		"LHLO localhost", "250-lmtp.example.com\r\n250-CHUNKING\r\n250-BINARYMIME\r\n250 SIZE 1000",
+	})
+	c, err := lmtpNewClient(client, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got, want := c.extensions(), map[string]string{"SIZE": "1000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid extensions, got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package gomail
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var imgSrcRegexp = regexp.MustCompile(`(?i)(<img\b[^>]*?\bsrc\s*=\s*)("|')([^"']*)("|')`)
+
+// EmbedLocalImages embeds the local images referenced by the <img> tags of the
+// text/html bodies and replaces their src attributes with cid: URLs, see
+// EmbedWithCID. The local images are the file: URLs, like
+// <img src="file:///var/www/logo.png">, and, if dirs are given, the relative
+// paths, like <img src="images/logo.png">, looked up in dirs in order. The
+// other images, like the http: and cid: URLs or the relative paths not found,
+// are left as is. An image referenced several times is embedded once.
+//
+// The files are read when the email is written.
+func (m *Message) EmbedLocalImages(dirs ...string) error {
+	cids := make(map[string]string)
+	for _, p := range m.parts {
+		if !strings.HasPrefix(p.contentType, "text/html") {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := p.copier(&buf); err != nil {
+			return err
+		}
+
+		var err error
+		body := imgSrcRegexp.ReplaceAllStringFunc(buf.String(), func(s string) string {
+			sub := imgSrcRegexp.FindStringSubmatch(s)
+			path, ferr := localImagePath(sub[3], dirs)
+			if ferr != nil {
+				if err == nil {
+					err = ferr
+				}
+				return s
+			}
+			if path == "" {
+				return s
+			}
+			cid, ok := cids[path]
+			if !ok {
+				cid = m.EmbedWithCID(path)
+				cids[path] = cid
+			}
+			return sub[1] + sub[2] + cid + sub[4]
+		})
+		if err != nil {
+			return err
+		}
+		p.copier = newCopier(body)
+	}
+	return nil
+}
+
+// localImagePath returns the path of the local image referenced by src, or an
+// empty string if src is not a local image. It returns an error if src is a
+// file: URL of a file that does not exist.
+func localImagePath(src string, dirs []string) (string, error) {
+	src = strings.TrimSpace(src)
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", nil
+	}
+
+	switch {
+	case strings.EqualFold(u.Scheme, "file"):
+		name := u.Path
+		if name == "" {
+			name = u.Opaque
+		}
+		if _, err := os.Stat(name); err != nil {
+			return "", fmt.Errorf("gomail: could not embed the image %q: %w", src, err)
+		}
+		return name, nil
+	case u.Scheme != "" || u.Host != "" || u.Path == "" || filepath.IsAbs(u.Path):
+		return "", nil
+	}
+
+	// The path is cleaned as an absolute path so it cannot go up out of the
+	// directories.
+	rel := filepath.FromSlash(path.Clean("/" + u.Path))
+	for _, dir := range dirs {
+		name := filepath.Join(dir, rel)
+		if fi, err := os.Stat(name); err == nil && !fi.IsDir() {
+			return name, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,71 @@
+package gomail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmbedLocalImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "images"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"logo.png", "images/photo.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewMessage(Deterministic(), SetMessageIDDomain("example.com"))
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", `<img src="images/photo.jpg">`)
+	m.AddAlternative("text/html", `<img alt="Logo" src="file://`+filepath.ToSlash(filepath.Join(dir, "logo.png"))+`">`+
+		`<IMG SRC='images/photo.jpg'><img src="../images/photo.jpg">`+
+		`<img src="https://example.com/a.png"><img src="missing.png"><a src="images/photo.jpg">`)
+	if err := m.EmbedLocalImages(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var html bytes.Buffer
+	if err := m.parts[1].copier(&html); err != nil {
+		t.Fatal(err)
+	}
+	want := `<img alt="Logo" src="cid:00000000000000000000000001@example.com">` +
+		`<IMG SRC='cid:00000000000000000000000002@example.com'><img src="cid:00000000000000000000000002@example.com">` +
+		`<img src="https://example.com/a.png"><img src="missing.png"><a src="images/photo.jpg">`
+	if got := html.String(); got != want {
+		t.Errorf("Invalid HTML body, got:\n%s\nwant:\n%s", got, want)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<img src=3D"images/photo.jpg">`,
+		`filename="logo.png"`,
+		`filename="photo.jpg"`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Missing %q in:\n%s", want, buf.String())
+		}
+	}
+	if len(m.embedded) != 2 {
+		t.Errorf("Invalid number of embedded files, got %d", len(m.embedded))
+	}
+
+	m = NewMessage()
+	m.SetBody("text/html", `<img src="file:///does/not/exist.png">`)
+	if err := m.EmbedLocalImages(); err == nil || !strings.Contains(err.Error(), "/does/not/exist.png") {
+		t.Errorf("Invalid error, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package gomail
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// A Logger logs the activity of a Dialer. A *log.Logger can be used.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+func (d *Dialer) logf(format string, v ...interface{}) {
+	if d.Logger != nil {
+		d.Logger.Printf(format, v...)
+	}
+	if d.Events != nil {
+		d.Events.Printf(format, v...)
+	}
+}
+
+// logPhase logs the duration of a phase of a connection or a send and its
+// error, if any.
+func (d *Dialer) logPhase(phase string, start time.Time, err error) {
+	if d.Logger == nil && d.Events == nil {
+		return
+	}
+	if err != nil {
+		d.logf("gomail: %s failed after %v: %v", phase, now().Sub(start), err)
+		return
+	}
+	d.logf("gomail: %s in %v", phase, now().Sub(start))
+}
+
+// tracingClient logs the SMTP commands sent by a client and the replies of
+// the server, and records their latency if the Metrics of the Dialer is a
+// CommandMetrics. The credentials and the content of the emails are not
+// logged.
+type tracingClient struct {
+	smtpClient
+	d *Dialer
+	// ehlo is true once the EHLO command has been sent. net/smtp sends it
+	// with the first command, usually an Extension call.
+	ehlo bool
+}
+
+// newTracingClient returns c wrapped in a tracingClient if the Dialer logs
+// or records the SMTP commands or records their latency.
+func (d *Dialer) newTracingClient(c smtpClient) smtpClient {
+	if _, ok := d.Metrics.(CommandMetrics); ok || (d.Logger != nil && d.Debug) || d.Events != nil {
+		return &tracingClient{smtpClient: c, d: d}
+	}
+	return c
+}
+
+// trace records the latency of the command name and logs cmd.
+func (c *tracingClient) trace(name, cmd string, start time.Time, err error) {
+	latency := now().Sub(start)
+	if cm, ok := c.d.Metrics.(CommandMetrics); ok {
+		cm.ObserveCommand(c.d.Host, name, latency, err)
+	}
+	c.log(cmd, latency, err)
+}
+
+func (c *tracingClient) log(cmd string, latency time.Duration, err error) {
+	debug := c.d.Logger != nil && c.d.Debug
+	if !debug && c.d.Events == nil {
+		return
+	}
+	reply := "ok"
+	var perr *textproto.Error
+	if errors.As(err, &perr) {
+		reply = fmt.Sprintf("%d %s", perr.Code, perr.Msg)
+	} else if err != nil {
+		reply = "error: " + err.Error()
+	}
+	if debug {
+		c.d.Logger.Printf("gomail: %s => %s (%v)", cmd, reply, latency)
+	}
+	if c.d.Events != nil {
+		c.d.Events.Printf("gomail: %s => %s (%v)", cmd, reply, latency)
+	}
+}
+
+// dumpEvents logs the events of the Dialer after err made DialAndSend fail,
+// unless the Logger already logged the SMTP commands.
+func (d *Dialer) dumpEvents(err error) {
+	if d.Events == nil || d.Logger == nil || d.Debug {
+		return
+	}
+	var buf strings.Builder
+	d.Events.WriteTo(&buf)
+	d.Logger.Printf("%v; recent events:\n%s", err, strings.TrimSuffix(buf.String(), "\n"))
+}
+
+func (c *tracingClient) Hello(localName string) error {
+	start := now()
+	err := c.smtpClient.Hello(localName)
+	c.ehlo = true
+	c.trace("EHLO", "EHLO "+localName, start, err)
+	return err
+}
+
+func (c *tracingClient) Extension(ext string) (bool, string) {
+	if c.ehlo {
+		return c.smtpClient.Extension(ext)
+	}
+	start := now()
+	ok, param := c.smtpClient.Extension(ext)
+	c.ehlo = true
+	c.trace("EHLO", "EHLO", start, nil)
+	return ok, param
+}
+
+func (c *tracingClient) StartTLS(config *tls.Config) error {
+	start := now()
+	err := c.smtpClient.StartTLS(config)
+	c.trace("STARTTLS", "STARTTLS", start, err)
+	return err
+}
+
+func (c *tracingClient) Auth(a smtp.Auth) error {
+	start := now()
+	err := c.smtpClient.Auth(a)
+	c.trace("AUTH", "AUTH [credentials redacted]", start, err)
+	return err
+}
+
+func (c *tracingClient) Mail(from string, params ...string) error {
+	start := now()
+	err := c.smtpClient.Mail(from, params...)
+	c.trace("MAIL", strings.TrimSpace("MAIL FROM:<"+from+"> "+strings.Join(params, " ")), start, err)
+	return err
+}
+
+func (c *tracingClient) Rcpt(to string, params ...string) error {
+	start := now()
+	err := c.smtpClient.Rcpt(to, params...)
+	c.trace("RCPT", strings.TrimSpace("RCPT TO:<"+to+"> "+strings.Join(params, " ")), start, err)
+	return err
+}
+
+func (c *tracingClient) Data() (io.WriteCloser, error) {
+	start := now()
+	w, err := c.smtpClient.Data()
+	if err != nil {
+		c.trace("DATA", "DATA", start, err)
+		return nil, err
+	}
+	c.log("DATA", now().Sub(start), nil)
+	return &tracingWriter{w: w, c: c, name: "DATA", end: ".", start: start}, nil
+}
+
+func (c *tracingClient) Bdat(binary bool) (io.WriteCloser, error) {
+	w, err := c.smtpClient.Bdat(binary)
+	if err != nil {
+		return nil, err
+	}
+	return &tracingWriter{w: w, c: c, name: "BDAT", end: "in BDAT chunks", start: now()}, nil
+}
+
+func (c *tracingClient) Reset() error {
+	start := now()
+	err := c.smtpClient.Reset()
+	c.trace("RSET", "RSET", start, err)
+	return err
+}
+
+func (c *tracingClient) Quit() error {
+	start := now()
+	err := c.smtpClient.Quit()
+	c.trace("QUIT", "QUIT", start, err)
+	return err
+}
+
+// tracingWriter counts the bytes of an email so only its size is logged. The
+// latency of the DATA command includes the transfer of the email.
+type tracingWriter struct {
+	w     io.WriteCloser
+	c     *tracingClient
+	n     int64
+	name  string
+	end   string
+	start time.Time
+}
+
+func (w *tracingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *tracingWriter) Close() error {
+	err := w.w.Close()
+	w.c.trace(w.name, fmt.Sprintf("[%d bytes of message data] %s", w.n, w.end), w.start, err)
+	return err
+}
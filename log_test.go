@@ -0,0 +1,92 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type recordLogger []string
+
+func (l *recordLogger) Printf(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+func TestDialerLogger(t *testing.T) {
+	logs := new(recordLogger)
+	d := NewDialer(testHost, testPort, "user", "pwd")
+	d.Logger = logs
+	testSendMail(t, d, []string{
+		"Extension STARTTLS",
+		"StartTLS",
+		"Extension AUTH",
+		"Auth",
+		"Mail " + testFrom,
+		"Rcpt " + testTo1,
+		"Rcpt " + testTo2,
+		"Data",
+		"Write message",
+		"Close writer",
+		"Quit",
+		"Close",
+	})
+
+	want := recordLogger{
+		"gomail: dial smtp.example.com:587 in 0s",
+		"gomail: TLS in 0s",
+		"gomail: authentication in 0s",
+		"gomail: envelope in 0s",
+		"gomail: data in 0s",
+		"gomail: send to 2 recipients in 0s",
+	}
+	if !reflect.DeepEqual(*logs, want) {
+		t.Errorf("Invalid logs, got %q, want %q", *logs, want)
+	}
+}
+
+func TestDialerDebug(t *testing.T) {
+	logs := new(recordLogger)
+	d := NewDialer(testHost, testPort, "user", "pwd")
+	d.Logger = logs
+	d.Debug = true
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Extension AUTH",
+			"Auth",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Reset",
+			"Quit",
+			"Close",
+		},
+		rejected: map[string]bool{testTo1: true, testTo2: true},
+	})
+	var serr *SendError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Invalid error, got %v, want a *SendError", err)
+	}
+
+	want := recordLogger{
+		"gomail: dial smtp.example.com:587 in 0s",
+		"gomail: EHLO => ok (0s)",
+		"gomail: STARTTLS => ok (0s)",
+		"gomail: TLS in 0s",
+		"gomail: AUTH [credentials redacted] => ok (0s)",
+		"gomail: authentication in 0s",
+		"gomail: MAIL FROM:<" + testFrom + "> => ok (0s)",
+		"gomail: RCPT TO:<" + testTo1 + "> => 550 No such user (0s)",
+		"gomail: RCPT TO:<" + testTo2 + "> => 550 No such user (0s)",
+		"gomail: envelope in 0s",
+		"gomail: RSET => ok (0s)",
+		"gomail: send to 2 recipients failed after 0s: " + serr.Error(),
+		"gomail: QUIT => ok (0s)",
+	}
+	if !reflect.DeepEqual(*logs, want) {
+		t.Errorf("Invalid logs, got %q, want %q", *logs, want)
+	}
+}
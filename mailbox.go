@@ -0,0 +1,267 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Maildir delivers emails into a local maildir instead of sending them. It
+// can be used to archive every email sent or to test an application without an
+// SMTP server. It implements SendCloser and SendDialer so it can be used with
+// a Queue.
+//
+// Emails are written in the tmp subdirectory and atomically moved to the new
+// subdirectory once complete, as required by the maildir format.
+type Maildir struct {
+	dir string
+}
+
+// NewMaildir returns a Maildir delivering into dir, creating its tmp, new and
+// cur subdirectories if needed.
+func NewMaildir(dir string) (*Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &Maildir{dir: dir}, nil
+}
+
+// Dial implements SendDialer.
+func (md *Maildir) Dial() (SendCloser, error) {
+	return md, nil
+}
+
+// Send implements Sender. The envelope is not written in the maildir.
+func (md *Maildir) Send(from string, to []string, msg io.WriterTo) error {
+	name := maildirName()
+	tmp := filepath.Join(md.dir, "tmp", name)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	b, err := renderLF(msg)
+	if err == nil {
+		_, err = f.Write(b)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(md.dir, "new", name))
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Close implements SendCloser.
+func (md *Maildir) Close() error {
+	return nil
+}
+
+var maildirSeq uint32
+
+// maildirName returns a unique file name as recommended by the maildir
+// specification: time.MusecPpidQseq.hostname.
+func maildirName() string {
+	t := time.Now()
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+	seq := atomic.AddUint32(&maildirSeq, 1)
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s", t.Unix(), t.Nanosecond()/1000, os.Getpid(), seq, host)
+}
+
+// A PickupDir delivers emails by writing them in the pickup directory of a
+// local MTA, which then sends them, like the Pickup directory of the IIS SMTP
+// service or the Replay directory of Exchange. It implements SendCloser and
+// SendDialer so it can be used with a Queue.
+//
+// Each email is written in a .eml file with CRLF line endings, preceded by the
+// envelope in X-Sender and X-Receiver fields so the Bcc recipients are not
+// lost. The file is written in the tmp subdirectory, which the MTA ignores, and
+// atomically renamed into the pickup directory once complete so the MTA never
+// reads a partial email.
+//
+// The maildrop directory of Postfix uses its own format and is written by the
+// postdrop program: use a SendmailSender instead.
+type PickupDir struct {
+	dir string
+}
+
+// NewPickupDir returns a PickupDir delivering into dir, creating its tmp
+// subdirectory if needed. dir and tmp must be on the same file system.
+func NewPickupDir(dir string) (*PickupDir, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0700); err != nil {
+		return nil, err
+	}
+	return &PickupDir{dir: dir}, nil
+}
+
+// Dial implements SendDialer.
+func (pd *PickupDir) Dial() (SendCloser, error) {
+	return pd, nil
+}
+
+// Send implements Sender.
+func (pd *PickupDir) Send(from string, to []string, msg io.WriterTo) error {
+	if len(to) == 0 {
+		return errors.New("gomail: no recipient")
+	}
+	var buf bytes.Buffer
+	if err := writeEnvelopeFields(&buf, from, to); err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	id, err := NewULID()
+	if err != nil {
+		return err
+	}
+	name := id + ".eml"
+	tmp := filepath.Join(pd.dir, "tmp", name)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(pd.dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Close implements SendCloser.
+func (pd *PickupDir) Close() error {
+	return nil
+}
+
+// writeEnvelopeFields writes the envelope in X-Sender and X-Receiver fields.
+func writeEnvelopeFields(w io.Writer, from string, to []string) error {
+	var buf bytes.Buffer
+	for i, addr := range append([]string{from}, to...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return errors.New("gomail: an address must not contain CR or LF")
+		}
+		if i == 0 {
+			buf.WriteString("X-Sender: <" + addr + ">\r\n")
+		} else {
+			buf.WriteString("X-Receiver: <" + addr + ">\r\n")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// An Mbox delivers emails by appending them to a local mbox file instead of
+// sending them. Lines starting with "From " are escaped with ">" as in the
+// mboxrd format. It implements SendCloser and SendDialer so it can be used
+// with a Queue.
+//
+// The file is not locked, so it must not be written by other processes at the
+// same time.
+type Mbox struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewMbox returns an Mbox appending to the file at path. The file is created
+// on the first delivery if needed.
+func NewMbox(path string) *Mbox {
+	return &Mbox{path: path}
+}
+
+// Dial implements SendDialer.
+func (mb *Mbox) Dial() (SendCloser, error) {
+	return mb, nil
+}
+
+// Send implements Sender. from is written in the From_ line separating the
+// emails.
+func (mb *Mbox) Send(from string, to []string, msg io.WriterTo) error {
+	b, err := renderLF(msg)
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		from = "MAILER-DAEMON"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From %s %s\n", from, now().UTC().Format("Mon Jan _2 15:04:05 2006"))
+	mboxEscape(&buf, b)
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	f, err := os.OpenFile(mb.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Close implements SendCloser.
+func (mb *Mbox) Close() error {
+	return nil
+}
+
+// renderLF renders msg with LF line endings, which are used by local
+// mailboxes.
+func renderLF(msg io.WriterTo) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return bytes.Replace(buf.Bytes(), []byte("\r\n"), []byte("\n"), -1), nil
+}
+
+// mboxEscape escapes the lines matching ^>*From with a ">".
+func mboxEscape(w *bytes.Buffer, b []byte) {
+	for len(b) > 0 {
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line = b[:i+1]
+		}
+		b = b[len(line):]
+
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			w.WriteByte('>')
+		}
+		w.Write(line)
+	}
+}
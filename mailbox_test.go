@@ -0,0 +1,138 @@
+package gomail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMaildir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	md, err := NewMaildir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Send(md, getTestMessage(), getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sub := range []string{"tmp", "cur"} {
+		if names, err := readDirNames(filepath.Join(dir, sub)); err != nil {
+			t.Fatal(err)
+		} else if len(names) != 0 {
+			t.Errorf("%s should be empty, got %q", sub, names)
+		}
+	}
+	names, err := readDirNames(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] == names[1] {
+		t.Fatalf("Invalid delivered emails, got %q", names)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "new", names[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "\r") {
+		t.Error("Delivered emails should use LF line endings")
+	}
+	compareBodies(t, strings.Replace(string(b), "\n", "\r\n", -1), testMsg)
+}
+
+func TestMaildirName(t *testing.T) {
+	name := maildirName()
+	if strings.ContainsAny(name, "/:") {
+		t.Errorf("Invalid characters in %q", name)
+	}
+	if parts := strings.SplitN(name, ".", 3); len(parts) != 3 || !strings.HasPrefix(parts[1], "M") {
+		t.Errorf("Invalid maildir name %q", name)
+	}
+}
+
+func TestPickupDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pd, err := NewPickupDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Send(pd, getTestMessage(), getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	if names, err := readDirNames(filepath.Join(dir, "tmp")); err != nil {
+		t.Fatal(err)
+	} else if len(names) != 0 {
+		t.Errorf("tmp should be empty, got %q", names)
+	}
+	names, err := readDirNames(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 || names[0] == names[1] || names[2] != "tmp" {
+		t.Fatalf("Invalid delivered emails, got %q", names)
+	}
+	for _, name := range names[:2] {
+		if filepath.Ext(name) != ".eml" {
+			t.Errorf("Invalid file name %q", name)
+		}
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, names[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope := "X-Sender: <" + testFrom + ">\r\n" +
+		"X-Receiver: <" + testTo1 + ">\r\n" +
+		"X-Receiver: <" + testTo2 + ">\r\n"
+	if !strings.HasPrefix(string(b), envelope) {
+		t.Fatalf("Invalid envelope, got:\n%s", b)
+	}
+	compareBodies(t, strings.TrimPrefix(string(b), envelope), testMsg)
+}
+
+func TestMbox(t *testing.T) {
+	f, err := ioutil.TempFile("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	mb := NewMbox(f.Name())
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", "Hi\r\nFrom here\r\n>From there")
+	if err := Send(mb, m, m); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	from := "From " + testFrom + " Wed Jun 25 17:46:00 2014\n"
+	if !strings.HasPrefix(got, from) || strings.Count(got, from) != 2 {
+		t.Errorf("Invalid From_ lines in %q", got)
+	}
+	if !strings.Contains(got, "\n>From here\n>>From there\n\n"+from) {
+		t.Errorf("Invalid escaping in %q", got)
+	}
+	if !strings.HasSuffix(got, ">>From there\n\n") {
+		t.Errorf("Emails should end with a blank line, got %q", got)
+	}
+}
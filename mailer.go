@@ -0,0 +1,181 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrMailerNotStarted is returned when an email is sent with a Mailer that has
+// not been started.
+var ErrMailerNotStarted = errors.New("gomail: mailer is not started")
+
+// A Mailer owns a Queue and the background components of an application, so
+// they can be started and stopped together with a single lifecycle.
+//
+// A Mailer implements Sender and EnvelopeSender by enqueuing the emails, so it
+// can be used as the Sender of its Digester.
+type Mailer struct {
+	// Digester, if set, is started with the Mailer and its pending digests are
+	// sent when the Mailer stops.
+	Digester *Digester
+	// DigestInterval is the interval at which the digests are sent. It
+	// defaults to 1 hour.
+	DigestInterval time.Duration
+	// BouncePoller, if set, is started with the Mailer.
+	BouncePoller *BouncePoller
+	// BounceInterval is the interval at which the bounce mailbox is polled. It
+	// defaults to 5 minutes.
+	BounceInterval time.Duration
+	// Signatures are the default signatures of the senders, indexed by the
+	// address of the From field. They are added to the emails built with
+	// Message that have no signature, see Message.SetSignature.
+	Signatures map[string]Signature
+
+	d        SendDialer
+	settings []QueueSetting
+
+	mu     sync.RWMutex
+	q      *Queue
+	stopMu sync.Mutex
+}
+
+// NewMailer returns a new Mailer sending emails with connections opened by d
+// through a queue configured with the given settings. Use the Spool setting so
+// the emails still pending when the Mailer stops are sent on the next start.
+func NewMailer(d SendDialer, settings ...QueueSetting) *Mailer {
+	return &Mailer{d: d, settings: settings}
+}
+
+// Start starts the queue and the background components of the Mailer. The
+// fields of the Mailer must not be modified after Start is called. A Mailer can
+// be started again after it has been stopped.
+func (m *Mailer) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.q != nil {
+		return errors.New("gomail: mailer already started")
+	}
+	m.q = NewQueue(m.d, m.settings...)
+
+	if m.Digester != nil {
+		interval := m.DigestInterval
+		if interval == 0 {
+			interval = time.Hour
+		}
+		m.Digester.Start(interval)
+	}
+	if m.BouncePoller != nil {
+		interval := m.BounceInterval
+		if interval == 0 {
+			interval = 5 * time.Minute
+		}
+		m.BouncePoller.Start(interval)
+	}
+	return nil
+}
+
+// Stop stops the background components, sends the pending digests and shuts
+// down the queue: the enqueued emails are sent, the stored ones are kept for the
+// next start and the connections are closed. If ctx expires first, the pending
+// retries are aborted and ctx.Err() is returned.
+func (m *Mailer) Stop(ctx context.Context) error {
+	m.stopMu.Lock()
+	defer m.stopMu.Unlock()
+
+	q := m.Queue()
+	if q == nil {
+		return ErrMailerNotStarted
+	}
+
+	var firstErr error
+	if m.BouncePoller != nil {
+		firstErr = m.BouncePoller.Close()
+	}
+	// The digests are enqueued so the queue must still be running.
+	if m.Digester != nil {
+		if err := m.Digester.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := q.Shutdown(ctx); err != nil && firstErr == nil {
+		firstErr = err
+	}
+
+	m.mu.Lock()
+	m.q = nil
+	m.mu.Unlock()
+	return firstErr
+}
+
+// Queue returns the queue of the Mailer, or nil if it is not started. It can
+// be used to monitor the queue with Queue.Stats.
+func (m *Mailer) Queue() *Queue {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.q
+}
+
+// Enqueue adds the emails to the queue of the Mailer.
+func (m *Mailer) Enqueue(msg ...*Message) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.q == nil {
+		return ErrMailerNotStarted
+	}
+	for _, msg := range msg {
+		if err := m.q.Enqueue(m.sign(msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send implements Sender. The email is enqueued.
+func (m *Mailer) Send(from string, to []string, msg io.WriterTo) error {
+	return m.SendEnvelope(&Envelope{From: from, To: to}, msg)
+}
+
+// SendEnvelope implements EnvelopeSender. The email is enqueued.
+func (m *Mailer) SendEnvelope(e *Envelope, msg io.WriterTo) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.q == nil {
+		return ErrMailerNotStarted
+	}
+	if msg, ok := msg.(*Message); ok {
+		return m.q.EnqueueEnvelope(e, m.sign(msg))
+	}
+	return m.q.EnqueueEnvelope(e, msg)
+}
+
+// sign returns msg with the default signature of its sender, if any. msg is
+// copied so it is not modified.
+func (m *Mailer) sign(msg *Message) *Message {
+	if len(m.Signatures) == 0 || msg.signature != nil {
+		return msg
+	}
+	from := msg.header["From"]
+	if len(from) == 0 {
+		return msg
+	}
+	addr, err := parseAddress(from[0])
+	if err != nil {
+		return msg
+	}
+	for k, sig := range m.Signatures {
+		if strings.EqualFold(k, addr) {
+			msg = msg.Clone()
+			msg.SetSignature(sig.HTML, sig.Text)
+			break
+		}
+	}
+	return msg
+}
@@ -0,0 +1,125 @@
+package gomail
+
+import (
+	"context"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestMailer(t *testing.T) {
+	d := &fakeDialer{}
+	m := NewMailer(d, Workers(2))
+	m.Digester = &Digester{Sender: m, From: testFrom}
+	m.BouncePoller = &BouncePoller{Fetcher: &fakeFetcher{}}
+	m.BounceInterval = time.Hour
+
+	if err := m.Enqueue(testQueueMessage(testTo1)); err != ErrMailerNotStarted {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMailerNotStarted)
+	}
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start(context.Background()); err == nil {
+		t.Error("Start should fail when the mailer is started")
+	}
+
+	if err := m.Enqueue(testQueueMessage(testTo1), testQueueMessage(testTo2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Digester.Add(testTo2, testQueueMessage(testTo2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pending digest is sent through the queue before it is shut down.
+	if len(d.sent) != 3 {
+		t.Errorf("Invalid number of emails sent, got %d, want 3", len(d.sent))
+	}
+	if m.Queue() != nil {
+		t.Error("Queue should be nil once the mailer is stopped")
+	}
+	if err := Send(m, testQueueMessage(testTo1)); err == nil {
+		t.Error("Send should fail once the mailer is stopped")
+	}
+	if err := m.Stop(context.Background()); err != ErrMailerNotStarted {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMailerNotStarted)
+	}
+}
+
+func TestMailerRestart(t *testing.T) {
+	s, cleanup := testDirStore(t)
+	defer cleanup()
+
+	down := true
+	d := &fakeDialer{fail: func(int) error {
+		if down {
+			return &textproto.Error{Code: 421, Msg: "Service not available"}
+		}
+		return nil
+	}}
+	m := NewMailer(d, Spool(s))
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Enqueue(testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.sent) != 0 {
+		t.Fatalf("No email should be sent, got %d", len(d.sent))
+	}
+
+	// The stored email is sent when the mailer starts again.
+	down = false
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.sent) != 1 {
+		t.Errorf("Invalid number of emails sent, got %d, want 1", len(d.sent))
+	}
+}
+
+func TestMailerStartCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := NewMailer(&fakeDialer{})
+	if err := m.Start(ctx); err != context.Canceled {
+		t.Errorf("Invalid error, got %v, want %v", err, context.Canceled)
+	}
+	if m.Queue() != nil {
+		t.Error("The mailer should not be started")
+	}
+}
+
+func TestMailerSignatures(t *testing.T) {
+	m := NewMailer(&fakeDialer{})
+	m.Signatures = map[string]Signature{"From@Example.com": {Text: "Alice"}}
+
+	msg := testQueueMessage(testTo1)
+	signed := m.sign(msg)
+	if signed == msg || signed.signature == nil || signed.signature.Text != "Alice" {
+		t.Errorf("The default signature should be added to a copy, got %+v", signed.signature)
+	}
+	if msg.signature != nil {
+		t.Error("The message should not be modified")
+	}
+
+	msg.SetSignature("", "Bob")
+	if m.sign(msg) != msg {
+		t.Error("The signature of the message should be kept")
+	}
+
+	other := testQueueMessage(testTo1)
+	other.SetHeader("From", "other@example.com")
+	if m.sign(other) != other {
+		t.Error("Other senders should not get a signature")
+	}
+}
@@ -0,0 +1,83 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// A MailgunSender sends emails with the messages.mime endpoint of the Mailgun
+// API. The emails are rendered as MIME messages so all the features of Message
+// are supported. It implements SendCloser and SendDialer so it can be used with
+// a Queue.
+type MailgunSender struct {
+	// Domain is the sending domain registered in Mailgun.
+	Domain string
+	// APIKey is the private API key.
+	APIKey string
+	// BaseURL is the URL of the API. It defaults to https://api.mailgun.net,
+	// use https://api.eu.mailgun.net for domains in the EU region.
+	BaseURL string
+	// Client is the HTTP client used to call the API. It defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+// NewMailgunSender returns a new Mailgun sender.
+func NewMailgunSender(domain, apiKey string) *MailgunSender {
+	return &MailgunSender{Domain: domain, APIKey: apiKey}
+}
+
+// Dial implements SendDialer. No connection is opened since every email is
+// sent with its own HTTP request.
+func (s *MailgunSender) Dial() (SendCloser, error) {
+	return s, nil
+}
+
+// Send implements Sender. Mailgun uses the From header of the message as
+// envelope sender, so from is ignored.
+func (s *MailgunSender) Send(from string, to []string, msg io.WriterTo) error {
+	if s.Domain == "" {
+		return errors.New("gomail: Mailgun domain is empty")
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, addr := range to {
+		if err := form.WriteField("to", addr); err != nil {
+			return err
+		}
+	}
+	fw, err := form.CreateFormFile("message", "message.mime")
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(fw); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.mailgun.net"
+	}
+	url := strings.TrimSuffix(baseURL, "/") + "/v3/" + s.Domain + "/messages.mime"
+	req, err := http.NewRequest("POST", url, &body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("api", s.APIKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	return doAPIRequest(s.Client, "Mailgun", req)
+}
+
+// Close implements SendCloser.
+func (s *MailgunSender) Close() error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package gomail
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMailgunSender(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/example.com/messages.mime" {
+			t.Errorf("Invalid path, got %q", r.URL.Path)
+		}
+		if user, pwd, ok := r.BasicAuth(); !ok || user != "api" || pwd != "key" {
+			t.Errorf("Invalid auth, got %q, %q", user, pwd)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+			return
+		}
+		if to := r.MultipartForm.Value["to"]; !reflect.DeepEqual(to, []string{testTo1, testTo2}) {
+			t.Errorf("Invalid recipients, got %q", to)
+		}
+		f, _, err := r.FormFile("message")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		msg, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		compareBodies(t, string(msg), testMsg)
+		w.Write([]byte(`{"id":"<1@example.com>","message":"Queued. Thank you."}`))
+	}))
+	defer srv.Close()
+
+	d := NewMailgunSender("example.com", "key")
+	d.BaseURL = srv.URL + "/"
+	s, err := d.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Send(s, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMailgunSenderError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Forbidden", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	s := &MailgunSender{Domain: "example.com", BaseURL: srv.URL}
+	err := s.Send(testFrom, []string{testTo1}, getTestMessage())
+	var aerr *APIError
+	if !errors.As(err, &aerr) || aerr.StatusCode != 401 || aerr.Message != "Forbidden" {
+		t.Fatalf("Invalid error, got %v", err)
+	}
+	if IsTemporary(err) {
+		t.Error("A 401 response should not be temporary")
+	}
+}
@@ -3,6 +3,7 @@ package gomail
 import (
 	"bytes"
 	"io"
+	"net/mail"
 	"os"
 	"path/filepath"
 	"time"
@@ -18,6 +19,42 @@ type Message struct {
 	encoding    Encoding
 	hEncoder    mimeEncoder
 	buf         bytes.Buffer
+	dsn         *DSN
+	sendAt      time.Time
+	envFrom     string
+	signature   *Signature
+	requireTLS  bool
+	retry       *RetryPolicy
+	idGenerator IDGenerator
+
+	// fieldNames are the names of the header fields, by key, when they were
+	// not set with their canonical name, see fieldKey.
+	fieldNames map[string]string
+	// rawFields are the keys of the fields set with SetRawHeader.
+	rawFields map[string]bool
+	// injectionErrors and fileErrors are the header fields and the file
+	// names containing CR, LF or NUL in strict mode, see checkInjection.
+	injectionErrors map[string]error
+	fileErrors      []error
+
+	messageIDDomain string
+	noMessageID     bool
+	noMimeVersion   bool
+	noDate          bool
+	sortHeaders     bool
+	headerOrder     []string
+	deterministic   bool
+
+	strict     bool
+	addrErrors map[string][]error
+	maxSize    int64
+	oversize   OversizeFunc
+
+	// reportType is the report-type parameter of a multipart/report message,
+	// see SetDeliveryReport.
+	reportType string
+	// root is the custom MIME structure of the message, see Root.
+	root *Part
 }
 
 type header map[string][]string
@@ -26,6 +63,9 @@ type part struct {
 	contentType string
 	copier      func(io.Writer) error
 	encoding    Encoding
+	// header are the additional header fields of the part, see
+	// SetPartHeader.
+	header map[string][]string
 }
 
 // NewMessage creates a new message. It uses UTF-8 and quoted-printable encoding
@@ -54,9 +94,116 @@ func (m *Message) Reset() {
 	for k := range m.header {
 		delete(m.header, k)
 	}
+	m.fieldNames = nil
+	m.rawFields = nil
+	m.injectionErrors = nil
+	m.fileErrors = nil
 	m.parts = nil
 	m.attachments = nil
 	m.embedded = nil
+	m.reportType = ""
+	m.root = nil
+	m.sendAt = time.Time{}
+	m.envFrom = ""
+	m.signature = nil
+	m.addrErrors = nil
+}
+
+// Clone returns a deep copy of the message with the same settings. The
+// headers, parts and files of the copy can be changed without affecting m, so
+// a base message can be cloned and personalized in several goroutines.
+//
+// The body and file contents are not copied: the copy uses the same functions
+// to write them, which must be safe for concurrent use if the copies are sent
+// concurrently. Clone must not be called while m is being modified or sent.
+func (m *Message) Clone() *Message {
+	c := &Message{
+		header:          make(header, len(m.header)),
+		parts:           make([]*part, len(m.parts)),
+		attachments:     cloneFiles(m.attachments),
+		embedded:        cloneFiles(m.embedded),
+		charset:         m.charset,
+		encoding:        m.encoding,
+		hEncoder:        m.hEncoder,
+		sendAt:          m.sendAt,
+		envFrom:         m.envFrom,
+		signature:       m.signature,
+		requireTLS:      m.requireTLS,
+		retry:           m.retry,
+		idGenerator:     m.idGenerator,
+		messageIDDomain: m.messageIDDomain,
+		noMessageID:     m.noMessageID,
+		noMimeVersion:   m.noMimeVersion,
+		noDate:          m.noDate,
+		sortHeaders:     m.sortHeaders,
+		headerOrder:     m.headerOrder,
+		deterministic:   m.deterministic,
+		strict:          m.strict,
+		maxSize:         m.maxSize,
+		oversize:        m.oversize,
+		reportType:      m.reportType,
+	}
+	for k, v := range m.header {
+		c.header[k] = copyValues(v)
+	}
+	if m.fieldNames != nil {
+		c.fieldNames = make(map[string]string, len(m.fieldNames))
+		for k, v := range m.fieldNames {
+			c.fieldNames[k] = v
+		}
+	}
+	if m.injectionErrors != nil {
+		c.injectionErrors = make(map[string]error, len(m.injectionErrors))
+		for k, v := range m.injectionErrors {
+			c.injectionErrors[k] = v
+		}
+	}
+	c.fileErrors = append([]error(nil), m.fileErrors...)
+	if m.root != nil {
+		c.root = m.root.clone(c)
+	}
+	if m.rawFields != nil {
+		c.rawFields = make(map[string]bool, len(m.rawFields))
+		for k := range m.rawFields {
+			c.rawFields[k] = true
+		}
+	}
+	for i, p := range m.parts {
+		cp := *p
+		if p.header != nil {
+			cp.header = make(map[string][]string, len(p.header))
+			for k, v := range p.header {
+				cp.header[k] = copyValues(v)
+			}
+		}
+		c.parts[i] = &cp
+	}
+	if m.dsn != nil {
+		dsn := *m.dsn
+		c.dsn = &dsn
+	}
+	if m.addrErrors != nil {
+		c.addrErrors = make(map[string][]error, len(m.addrErrors))
+		for k, v := range m.addrErrors {
+			c.addrErrors[k] = append([]error(nil), v...)
+		}
+	}
+	return c
+}
+
+func cloneFiles(list []*file) []*file {
+	if list == nil {
+		return nil
+	}
+	files := make([]*file, len(list))
+	for i, f := range list {
+		h := make(map[string][]string, len(f.Header))
+		for k, v := range f.Header {
+			h[k] = copyValues(v)
+		}
+		files[i] = &file{Name: f.Name, Header: h, CopyFunc: f.CopyFunc, MediaType: f.MediaType, repeatable: f.repeatable}
+	}
+	return files
 }
 
 func (m *Message) applySettings(settings []MessageSetting) {
@@ -83,6 +230,24 @@ func SetEncoding(enc Encoding) MessageSetting {
 	}
 }
 
+// DisableMimeVersion is a message setting to not add the Mime-Version header
+// when the message does not have one, for example when a relay adds its own.
+// The header is required by MIME, so it must be added downstream.
+func DisableMimeVersion() MessageSetting {
+	return func(m *Message) {
+		m.noMimeVersion = true
+	}
+}
+
+// DisableDate is a message setting to not add the Date header with the
+// current time when the message does not have one, for example when a relay
+// or a signer adds its own. Use SetDateHeader to set a custom date instead.
+func DisableDate() MessageSetting {
+	return func(m *Message) {
+		m.noDate = true
+	}
+}
+
 // Encoding represents a MIME encoding scheme like quoted-printable or base64.
 type Encoding string
 
@@ -98,17 +263,45 @@ const (
 )
 
 // SetHeader sets a value to the given header field.
+//
+// In the address fields, like From or To, only the names are encoded so that
+// addresses with a non-ASCII local part or domain, as defined in RFC 6532, are
+// kept intact.
+//
+// With the StrictAddresses setting, the addresses are also validated.
+//
+// The field is written with the exact case of field, for example "X-API-Key"
+// instead of its canonical form "X-Api-Key", but it is found whatever the case
+// used, by GetHeader for example.
 func (m *Message) SetHeader(field string, value ...string) {
-	m.encodeHeader(value)
-	m.header[field] = value
+	key := m.setFieldName(field)
+	m.checkInjection(key, field, value...)
+	m.checkAddresses(key, value)
+	m.encodeHeader(key, value)
+	m.header[key] = value
 }
 
-func (m *Message) encodeHeader(values []string) {
-	for i := range values {
-		values[i] = m.encodeString(values[i])
+func (m *Message) encodeHeader(field string, values []string) {
+	for i, v := range values {
+		if addressFields[field] && !isASCII(v) {
+			if addr, err := mail.ParseAddress(v); err == nil {
+				values[i] = m.FormatAddress(addr.Address, addr.Name)
+				continue
+			}
+		}
+		values[i] = m.encodeString(v)
 	}
 }
 
+var addressFields = map[string]bool{
+	"From":     true,
+	"Sender":   true,
+	"Reply-To": true,
+	"To":       true,
+	"Cc":       true,
+	"Bcc":      true,
+}
+
 func (m *Message) encodeString(value string) string {
 	return m.hEncoder.Encode(m.charset, value)
 }
@@ -122,11 +315,19 @@ func (m *Message) SetHeaders(h map[string][]string) {
 
 // SetAddressHeader sets an address to the given header field.
 func (m *Message) SetAddressHeader(field, address, name string) {
+	key := m.setFieldName(field)
+	m.checkInjection(key, field, address, name)
+	field = key
+	m.checkAddresses(field, []string{address})
 	m.header[field] = []string{m.FormatAddress(address, name)}
 }
 
 // FormatAddress formats an address and a name as a valid RFC 5322 address.
+//
+// The CR, LF and NUL characters of address are removed, those of name are
+// encoded.
 func (m *Message) FormatAddress(address, name string) string {
+	address = stripControlBreaks(address)
 	if name == "" {
 		return address
 	}
@@ -169,7 +370,9 @@ func hasSpecials(text string) bool {
 
 // SetDateHeader sets a date to the given header field.
 func (m *Message) SetDateHeader(field string, date time.Time) {
-	m.header[field] = []string{m.FormatDate(date)}
+	key := m.setFieldName(field)
+	m.checkInjection(key, field)
+	m.header[key] = []string{m.FormatDate(date)}
 }
 
 // FormatDate formats a date as a valid RFC 5322 date.
@@ -177,9 +380,9 @@ func (m *Message) FormatDate(date time.Time) string {
 	return date.Format(time.RFC1123Z)
 }
 
-// GetHeader gets a header field.
+// GetHeader gets a header field. The case of field does not matter.
 func (m *Message) GetHeader(field string) []string {
-	return m.header[field]
+	return m.header[fieldKey(field)]
 }
 
 // SetBody sets the body of the message. It replaces any content previously set
@@ -221,6 +424,11 @@ func (m *Message) newPart(contentType string, f func(io.Writer) error, settings
 	for _, s := range settings {
 		s(p)
 	}
+	for _, v := range p.header {
+		for i := range v {
+			v[i] = m.encodeString(v[i])
+		}
+	}
 
 	return p
 }
@@ -238,10 +446,28 @@ func SetPartEncoding(e Encoding) PartSetting {
 	})
 }
 
+// SetPartHeader sets a header field of the part added to the message, for
+// example Content-Language or Content-ID. The Content-Type and
+// Content-Transfer-Encoding fields are set by the message and cannot be
+// replaced.
+func SetPartHeader(field string, value ...string) PartSetting {
+	return PartSetting(func(p *part) {
+		if p.header == nil {
+			p.header = make(map[string][]string)
+		}
+		p.header[field] = append([]string(nil), value...)
+	})
+}
+
 type file struct {
-	Name     string
-	Header   map[string][]string
-	CopyFunc func(w io.Writer) error
+	Name      string
+	Header    map[string][]string
+	CopyFunc  func(w io.Writer) error
+	MediaType string
+	// repeatable is true when CopyFunc can run several times, so the file can
+	// be read before it is written, to detect its media type or to measure
+	// the message.
+	repeatable bool
 }
 
 func (f *file) setHeader(field, value string) {
@@ -277,9 +503,28 @@ func Rename(name string) FileSetting {
 //
 // The default copy function opens the file with the given filename, and copy
 // its content to the io.Writer.
+//
+// The function may run only once per sending, so the file is not read
+// beforehand: its media type is not detected from the content it copies but
+// guessed from the name of the file, or set with SetContentType, and is
+// application/octet-stream otherwise, and the message is not checked against
+// the maximum size advertised by the server.
 func SetCopyFunc(f func(io.Writer) error) FileSetting {
 	return func(fi *file) {
 		fi.CopyFunc = f
+		fi.repeatable = false
+	}
+}
+
+// setContent is a file setting to write content instead of the file on disk.
+// Unlike with SetCopyFunc, the file can be read several times.
+func setContent(b []byte) FileSetting {
+	return func(fi *file) {
+		fi.CopyFunc = func(w io.Writer) error {
+			_, err := w.Write(b)
+			return err
+		}
+		fi.repeatable = true
 	}
 }
 
@@ -298,11 +543,13 @@ func (m *Message) appendFile(list []*file, name string, settings []FileSetting)
 			}
 			return h.Close()
 		},
+		repeatable: true,
 	}
 
 	for _, s := range settings {
 		s(f)
 	}
+	m.checkFile(f)
 
 	if list == nil {
 		return []*file{f}
@@ -3,12 +3,15 @@ package gomail
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"io"
 	"io/ioutil"
+	"mime"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -17,6 +20,9 @@ func init() {
 	now = func() time.Time {
 		return time.Date(2014, 06, 25, 17, 46, 0, 0, time.UTC)
 	}
+	newMessageID = func(domain string) (string, error) {
+		return "<1234@" + domain + ">", nil
+	}
 }
 
 type message struct {
@@ -564,6 +570,68 @@ func TestBase64LineLength(t *testing.T) {
 	testMessage(t, m, 0, want)
 }
 
+func TestBase64Streaming(t *testing.T) {
+	data := make([]byte, 1<<20+7)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	buf := new(bytes.Buffer)
+	w := newBase64LineWriter(buf)
+	for p := data; len(p) > 0; {
+		n := 1000
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	enc := base64.StdEncoding.EncodeToString(data)
+	var lines []string
+	for len(enc) > 76 {
+		lines = append(lines, enc[:76])
+		enc = enc[76:]
+	}
+	lines = append(lines, enc)
+	if want := strings.Join(lines, "\r\n"); buf.String() != want {
+		t.Errorf("Invalid base64 output, got %d bytes, want %d bytes", buf.Len(), len(want))
+	}
+}
+
+func TestBase64WriteError(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", "from@example.com")
+	m.SetHeader("To", "to@example.com")
+	m.Attach("test.bin", SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write(make([]byte, 1<<20))
+		return err
+	}))
+
+	if _, err := m.WriteTo(&errorWriter{limit: 10000}); err != errTestWrite {
+		t.Errorf("Invalid error, got %v, want %v", err, errTestWrite)
+	}
+}
+
+var errTestWrite = errors.New("gomail: test write error")
+
+type errorWriter struct {
+	limit int
+}
+
+func (w *errorWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		return 0, errTestWrite
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
 func TestEmptyName(t *testing.T) {
 	m := NewMessage()
 	m.SetAddressHeader("From", "from@example.com", "")
@@ -592,6 +660,133 @@ func TestEmptyHeader(t *testing.T) {
 	testMessage(t, m, 0, want)
 }
 
+func TestLongHeaderLines(t *testing.T) {
+	ids := make([]string, 40)
+	for i := range ids {
+		ids[i] = "<" + strconv.Itoa(i) + ".0123456789abcdef@example.com>"
+	}
+	token := strings.Repeat("a", 2500)
+
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("References", strings.Join(ids, " "))
+	m.SetHeader("X-Token", token)
+	m.SetHeader("Subject", strings.Repeat("é", 600))
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	header := buf.String()
+	for _, line := range strings.Split(header, "\r\n") {
+		if len(line) > maxHeaderLineLen {
+			t.Errorf("Header line too long (%d octets): %q", len(line), line)
+		}
+		if strings.HasPrefix(line, "References:") && len(line) > 78 {
+			t.Errorf("Header line should be folded at whitespace: %q", line)
+		}
+	}
+
+	h := renderHeader(t, m)
+	if got := h.Get("References"); got != strings.Join(ids, " ") {
+		t.Errorf("Invalid References, got %q", got)
+	}
+	if got := strings.Replace(h.Get("X-Token"), " ", "", -1); got != token {
+		t.Errorf("Invalid X-Token, got %q", got)
+	}
+	if got, err := new(mime.WordDecoder).DecodeHeader(h.Get("Subject")); err != nil || got != strings.Repeat("é", 600) {
+		t.Errorf("Invalid Subject, got %q, %v", got, err)
+	}
+}
+
+func TestHardBreak(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want int
+	}{
+		{"abcdef", 4, 4},
+		{"abé", 3, 2},
+		{"=?UTF-8?q?=C3=A9?==?UTF-8?q?=C3=A9?=", 30, 18},
+		{"abc=?UTF-8?q?=C3=A9?=", 10, 3},
+		{"=?UTF-8?q?=C3=A9", 10, 10},
+	}
+
+	for _, test := range tests {
+		if got := hardBreak(test.s, test.n); got != test.want {
+			t.Errorf("hardBreak(%q, %d) = %d, want %d", test.s, test.n, got, test.want)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	base := NewMessage(SetEncoding(Base64), SetDSN(&DSN{Notify: NotifyFailure}))
+	base.SetHeader("From", "from@example.com")
+	base.SetHeader("Subject", "Café")
+	base.SetBody("text/plain", "Hello!")
+	base.Attach(mockCopyFile("/tmp/test.pdf"))
+
+	var wg sync.WaitGroup
+	for _, to := range []string{testTo1, testTo2} {
+		c := base.Clone()
+		c.SetHeader("To", to)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.WriteTo(ioutil.Discard); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c := base.Clone()
+	c.SetHeader("To", "to@example.com")
+	c.GetHeader("Subject")[0] = "Changed"
+	c.parts[0].contentType = "text/html"
+	c.attachments[0].Header["Content-Type"] = []string{"text/plain"}
+	c.dsn.Notify = NotifyNever
+
+	if got := base.GetHeader("To"); len(got) != 0 {
+		t.Errorf("The clone headers should not be shared, got %v", got)
+	}
+	if base.GetHeader("Subject")[0] != "=?UTF-8?b?Q2Fmw6k=?=" || base.parts[0].contentType != "text/plain" {
+		t.Error("The clone should be a deep copy")
+	}
+	if _, ok := base.attachments[0].Header["Content-Type"]; ok {
+		t.Error("The clone file headers should not be shared")
+	}
+	if base.dsn.Notify != NotifyFailure {
+		t.Error("The clone DSN should not be shared")
+	}
+
+	c = base.Clone()
+	c.SetHeader("To", "to@example.com")
+	c.SetHeader("Subject", "Hello")
+	testMessage(t, c, 1, &message{
+		from: "from@example.com",
+		to:   []string{"to@example.com"},
+		content: "From: from@example.com\r\n" +
+			"To: to@example.com\r\n" +
+			"Subject: Hello\r\n" +
+			"Content-Type: multipart/mixed;\r\n" +
+			" boundary=_BOUNDARY_1_\r\n" +
+			"\r\n" +
+			"--_BOUNDARY_1_\r\n" +
+			"Content-Type: text/plain; charset=UTF-8\r\n" +
+			"Content-Transfer-Encoding: base64\r\n" +
+			"\r\n" +
+			base64.StdEncoding.EncodeToString([]byte("Hello!")) + "\r\n" +
+			"--_BOUNDARY_1_\r\n" +
+			"Content-Type: application/pdf; name=\"test.pdf\"\r\n" +
+			"Content-Disposition: attachment; filename=\"test.pdf\"\r\n" +
+			"Content-Transfer-Encoding: base64\r\n" +
+			"\r\n" +
+			base64.StdEncoding.EncodeToString([]byte("Content of test.pdf")) + "\r\n" +
+			"--_BOUNDARY_1_--\r\n",
+	})
+}
+
 func testMessage(t *testing.T, m *Message, bCount int, want *message) {
 	err := Send(stubSendMail(t, bCount, want), m)
 	if err != nil {
@@ -627,6 +822,7 @@ func stubSendMail(t *testing.T, bCount int, want *message) SendFunc {
 		got := buf.String()
 		wantMsg := string("Mime-Version: 1.0\r\n" +
 			"Date: Wed, 25 Jun 2014 17:46:00 +0000\r\n" +
+			"Message-ID: <1234@example.com>\r\n" +
 			want.content)
 		if bCount > 0 {
 			boundaries := getBoundaries(t, bCount, got)
@@ -743,3 +939,70 @@ func BenchmarkFull(b *testing.B) {
 		m.Reset()
 	}
 }
+
+func TestDisableAutomaticHeaders(t *testing.T) {
+	m := NewMessage(DisableMimeVersion(), DisableDate())
+	m.SetHeader("From", testFrom)
+	m.SetBody("text/plain", testBody)
+	h := renderHeader(t, m)
+	if _, ok := h["Mime-Version"]; ok {
+		t.Errorf("The Mime-Version field should not be added, got %q", h["Mime-Version"])
+	}
+	if _, ok := h["Date"]; ok {
+		t.Errorf("The Date field should not be added, got %q", h["Date"])
+	}
+
+	// The fields set explicitly are still written, also in a clone.
+	c := m.Clone()
+	c.SetHeader("Mime-Version", "1.0")
+	c.SetDateHeader("Date", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	h = renderHeader(t, c)
+	if got := h.Get("Mime-Version"); got != "1.0" {
+		t.Errorf("Invalid Mime-Version, got %q", got)
+	}
+	if got, want := h.Get("Date"), "Thu, 02 Jan 2020 03:04:05 +0000"; got != want {
+		t.Errorf("Invalid Date, got %q, want %q", got, want)
+	}
+	c.Reset()
+	c.SetHeader("From", testFrom)
+	if h := renderHeader(t, c); h.Get("Date") != "" || h.Get("Mime-Version") != "" {
+		t.Errorf("The settings should be kept by Clone, got %v", h)
+	}
+}
+
+func TestPartHeader(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetBody("text/plain", "Bonjour", SetPartHeader("Content-Language", "fr"))
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\r\nContent-Language: fr\r\n") {
+		t.Errorf("The part header should be written in a single part email, got:\n%s", buf.String())
+	}
+
+	m.AddAlternative("text/html", "<p>Bonjour</p>",
+		SetPartHeader("X-Part-Ref", "html-1"),
+		SetPartHeader("Content-Type", "text/plain"),
+		SetPartHeader("Content-Description", "Café"))
+	c := m.Clone()
+	buf.Reset()
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"\r\nContent-Language: fr\r\n",
+		"\r\nContent-Type: text/html; charset=UTF-8\r\n",
+		"\r\nX-Part-Ref: html-1\r\n",
+		"\r\nContent-Description: =?UTF-8?q?Caf=C3=A9?=\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q not found in:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "Content-Type: text/plain") != 1 {
+		t.Errorf("The Content-Type of a part should not be replaced, got:\n%s", got)
+	}
+}
@@ -0,0 +1,68 @@
+package gomail
+
+import (
+	"os"
+	"strings"
+)
+
+// SetMessageIDDomain is a message setting to set the domain of the generated
+// Message-ID. By default the domain of the From address is used.
+//
+// When a message has no Message-ID header, a new unique Message-ID is
+// generated each time it is written. Set the header explicitly to keep the
+// same Message-ID when the message is sent again.
+func SetMessageIDDomain(domain string) MessageSetting {
+	return func(m *Message) {
+		m.messageIDDomain = domain
+	}
+}
+
+// DisableMessageID is a message setting to not generate a Message-ID when the
+// message does not have one.
+func DisableMessageID() MessageSetting {
+	return func(m *Message) {
+		m.noMessageID = true
+	}
+}
+
+func (m *Message) hasMessageID() bool {
+	for k := range m.header {
+		if strings.EqualFold(k, "Message-ID") {
+			return true
+		}
+	}
+	return false
+}
+
+// messageIDDomainOrDefault returns the domain used in the generated
+// Message-ID: the configured one, the domain of the From address or the host
+// name.
+func (m *Message) messageIDDomainOrDefault() string {
+	if m.messageIDDomain != "" {
+		return m.messageIDDomain
+	}
+	if from := m.header["From"]; len(from) > 0 {
+		if addr, err := parseAddress(from[0]); err == nil {
+			if i := strings.LastIndexByte(addr, '@'); i >= 0 && i < len(addr)-1 {
+				return addr[i+1:]
+			}
+		}
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "localhost"
+}
+
+// generateMessageID returns a new msg-id as defined in RFC 5322, section 3.6.4,
+// made of a ULID, see NewULID.
+func generateMessageID(domain string) (string, error) {
+	id, err := NewULID()
+	if err != nil {
+		return "", err
+	}
+	return "<" + id + "@" + domain + ">", nil
+}
+
+// Stubbed out for tests.
+var newMessageID = generateMessageID
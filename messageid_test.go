@@ -0,0 +1,80 @@
+package gomail
+
+import (
+	"bytes"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func renderHeader(t *testing.T, m *Message) mail.Header {
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.Header
+}
+
+func TestMessageID(t *testing.T) {
+	tests := []struct {
+		settings []MessageSetting
+		from     string
+		header   string
+		want     string
+	}{
+		{nil, "Alice <alice@example.org>", "", "<1234@example.org>"},
+		{[]MessageSetting{SetMessageIDDomain("mail.example.com")}, testFrom, "", "<1234@mail.example.com>"},
+		{nil, testFrom, "<custom@example.com>", "<custom@example.com>"},
+		{[]MessageSetting{DisableMessageID()}, testFrom, "", ""},
+	}
+
+	for _, test := range tests {
+		m := NewMessage(test.settings...)
+		m.SetHeader("From", test.from)
+		if test.header != "" {
+			m.SetHeader("Message-Id", test.header)
+		}
+		m.SetBody("text/plain", testBody)
+
+		h := renderHeader(t, m)
+		if got := h.Get("Message-ID"); got != test.want {
+			t.Errorf("Invalid Message-ID, got %q, want %q", got, test.want)
+		}
+		if n := len(h["Message-Id"]); test.want != "" && n != 1 {
+			t.Errorf("Invalid number of Message-ID fields, got %d, want 1", n)
+		}
+
+		// The settings are kept after Reset.
+		m.Reset()
+		m.SetHeader("From", test.from)
+		if test.header == "" {
+			if got := renderHeader(t, m).Get("Message-ID"); got != test.want {
+				t.Errorf("Invalid Message-ID after Reset, got %q, want %q", got, test.want)
+			}
+		}
+	}
+}
+
+func TestGenerateMessageID(t *testing.T) {
+	a, err := generateMessageID("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := generateMessageID("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Errorf("Message-IDs should be unique, got %q twice", a)
+	}
+	if !strings.HasPrefix(a, "<") || !strings.HasSuffix(a, "@example.com>") {
+		t.Errorf("Invalid Message-ID %q", a)
+	}
+	if _, err := mail.ParseAddress(strings.Trim(a, "<>")); err != nil {
+		t.Errorf("Message-ID %q is not a valid msg-id: %v", a, err)
+	}
+}
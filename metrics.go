@@ -0,0 +1,216 @@
+package gomail
+
+import (
+	"errors"
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives the measurements of a Dialer, see Dialer.Metrics. Stats is
+// an implementation keeping counters and histograms in memory, and adapters to
+// metrics or tracing libraries, for example creating an OpenTelemetry span per
+// send, only take a few lines.
+//
+// The methods are called concurrently by the connections of the Dialer.
+type Metrics interface {
+	// StartSend is called before each SMTP transaction. The returned function
+	// is called at its end with the size of the email written, 0 if the
+	// DATA command was not reached, and the error, nil if the email was
+	// sent.
+	StartSend(e *Envelope) func(size int64, err error)
+	// Connections is called when a connection is opened or closed with the
+	// number of connections of the Dialer that are open.
+	Connections(open int)
+}
+
+// CommandMetrics can be implemented by a Metrics to also receive the latency
+// of each SMTP command, so a slow server can be diagnosed. The command is
+// "CONNECT" for the connection, including the DNS resolution and the greeting
+// of the server, or the name of the SMTP command: "EHLO", "STARTTLS", "AUTH",
+// "MAIL", "RCPT", "DATA", "BDAT", "RSET" or "QUIT". The latency of DATA and
+// BDAT includes the transfer of the email.
+type CommandMetrics interface {
+	ObserveCommand(host, command string, latency time.Duration, err error)
+}
+
+// LatencyBuckets are the upper bounds of the buckets of the latency
+// histograms of Stats. The last bucket of a histogram counts the durations
+// longer than the last bound.
+var LatencyBuckets = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Stats is a Metrics and a CommandMetrics keeping the counters and histograms
+// of a Dialer in memory. The zero value is ready to use.
+type Stats struct {
+	mu sync.Mutex
+	s  StatsSnapshot
+}
+
+// A StatsSnapshot is the state of a Stats at a given time.
+type StatsSnapshot struct {
+	// Sent is the number of emails sent.
+	Sent int64
+	// Failed is the number of emails that could not be sent, including the
+	// emails rejected for some recipients only.
+	Failed int64
+	// Bytes is the total size of the emails written.
+	Bytes int64
+	// Errors counts the failures by SMTP reply code, 0 for the errors without
+	// a reply code, like network errors.
+	Errors map[int]int64
+	// Latency counts the sends by duration, in the buckets defined by
+	// LatencyBuckets plus a last bucket for the slower sends.
+	Latency []int64
+	// LatencySum is the total duration of the sends.
+	LatencySum time.Duration
+	// Connections is the number of open connections and MaxConnections the
+	// highest number reached, which shows the utilization of the
+	// connections of a Queue.
+	Connections    int
+	MaxConnections int
+	// Commands are the statistics of the SMTP commands, by host and then by
+	// command, see CommandMetrics.
+	Commands map[string]map[string]CommandStats
+}
+
+// CommandStats are the statistics of an SMTP command.
+type CommandStats struct {
+	// Count is the number of commands and Errors the number of commands
+	// that failed.
+	Count  int64
+	Errors int64
+	// Latency counts the commands by duration, like StatsSnapshot.Latency.
+	Latency    []int64
+	LatencySum time.Duration
+}
+
+// StartSend implements Metrics.
+func (s *Stats) StartSend(e *Envelope) func(size int64, err error) {
+	start := now()
+	return func(size int64, err error) {
+		latency := now().Sub(start)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.init()
+		if err != nil {
+			s.s.Failed++
+			s.s.Errors[replyCode(err)]++
+		} else {
+			s.s.Sent++
+		}
+		s.s.Bytes += size
+		s.s.LatencySum += latency
+		observeLatency(s.s.Latency, latency)
+	}
+}
+
+// ObserveCommand implements CommandMetrics.
+func (s *Stats) ObserveCommand(host, command string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	cmds := s.s.Commands[host]
+	if cmds == nil {
+		cmds = make(map[string]CommandStats)
+		s.s.Commands[host] = cmds
+	}
+	cs := cmds[command]
+	if cs.Latency == nil {
+		cs.Latency = make([]int64, len(LatencyBuckets)+1)
+	}
+	cs.Count++
+	if err != nil {
+		cs.Errors++
+	}
+	cs.LatencySum += latency
+	observeLatency(cs.Latency, latency)
+	cmds[command] = cs
+}
+
+// observeLatency increments the bucket of the histogram h counting latency.
+func observeLatency(h []int64, latency time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && latency > LatencyBuckets[i] {
+		i++
+	}
+	h[i]++
+}
+
+// Connections implements Metrics.
+func (s *Stats) Connections(open int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.s.Connections = open
+	if open > s.s.MaxConnections {
+		s.s.MaxConnections = open
+	}
+}
+
+func (s *Stats) init() {
+	if s.s.Errors == nil {
+		s.s.Errors = make(map[int]int64)
+		s.s.Latency = make([]int64, len(LatencyBuckets)+1)
+		s.s.Commands = make(map[string]map[string]CommandStats)
+	}
+}
+
+// Snapshot returns a copy of the current state.
+func (s *Stats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	snap := s.s
+	snap.Errors = make(map[int]int64, len(s.s.Errors))
+	for code, n := range s.s.Errors {
+		snap.Errors[code] = n
+	}
+	snap.Latency = append([]int64(nil), s.s.Latency...)
+	snap.Commands = make(map[string]map[string]CommandStats, len(s.s.Commands))
+	for host, cmds := range s.s.Commands {
+		m := make(map[string]CommandStats, len(cmds))
+		for name, cs := range cmds {
+			cs.Latency = append([]int64(nil), cs.Latency...)
+			m[name] = cs
+		}
+		snap.Commands[host] = m
+	}
+	return snap
+}
+
+// replyCode returns the SMTP reply code of err, or 0 if it has none. The code
+// of a SendError is the code of its first rejected recipient.
+func replyCode(err error) int {
+	var perr *textproto.Error
+	if errors.As(err, &perr) {
+		return perr.Code
+	}
+	var serr *SendError
+	if errors.As(err, &serr) && len(serr.Rejected) > 0 {
+		return serr.Rejected[0].Code
+	}
+	return 0
+}
+
+// connOpened and connClosed count the open connections of the Dialer.
+func (d *Dialer) connOpened() {
+	n := atomic.AddInt32(&d.open, 1)
+	if d.Metrics != nil {
+		d.Metrics.Connections(int(n))
+	}
+}
+
+func (d *Dialer) connClosed() {
+	n := atomic.AddInt32(&d.open, -1)
+	if d.Metrics != nil {
+		d.Metrics.Connections(int(n))
+	}
+}
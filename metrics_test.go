@@ -0,0 +1,115 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	current := time.Date(2014, 6, 25, 17, 46, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	s := new(Stats)
+	done := s.StartSend(&Envelope{From: testFrom, To: []string{testTo1}})
+	current = current.Add(200 * time.Millisecond)
+	done(100, nil)
+
+	done = s.StartSend(&Envelope{From: testFrom, To: []string{testTo1}})
+	current = current.Add(time.Minute)
+	done(0, &textproto.Error{Code: 421, Msg: "Service not available"})
+
+	done = s.StartSend(&Envelope{From: testFrom, To: []string{testTo1}})
+	done(0, io.EOF)
+
+	s.Connections(2)
+	s.Connections(1)
+
+	want := StatsSnapshot{
+		Sent:           1,
+		Failed:         2,
+		Bytes:          100,
+		Errors:         map[int]int64{421: 1, 0: 1},
+		Latency:        []int64{1, 1, 0, 0, 0, 0, 0, 1},
+		LatencySum:     time.Minute + 200*time.Millisecond,
+		Connections:    1,
+		MaxConnections: 2,
+		Commands:       map[string]map[string]CommandStats{},
+	}
+	if got := s.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid snapshot, got %+v, want %+v", got, want)
+	}
+}
+
+func TestDialerMetrics(t *testing.T) {
+	s := new(Stats)
+	d := &Dialer{Host: testHost, Port: testPort, Metrics: s}
+	if err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+			"Close",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Reset",
+			"Quit",
+			"Close",
+		},
+		rejected: map[string]bool{testTo1: true, testTo2: true},
+	})
+	if !errors.Is(err, ErrRecipientRejected) {
+		t.Fatalf("Invalid error, got %v, want %v", err, ErrRecipientRejected)
+	}
+
+	got := s.Snapshot()
+	if got.Sent != 1 || got.Failed != 1 || got.Errors[550] != 1 {
+		t.Errorf("Invalid counters, got %+v", got)
+	}
+	if got.Bytes != int64(len(testMsg)) {
+		t.Errorf("Invalid size, got %d, want %d", got.Bytes, len(testMsg))
+	}
+	if got.Connections != 0 || got.MaxConnections != 1 {
+		t.Errorf("Invalid connections, got %d open and %d max, want 0 and 1", got.Connections, got.MaxConnections)
+	}
+
+	counts := make(map[string][2]int64)
+	for name, cs := range got.Commands[testHost] {
+		counts[name] = [2]int64{cs.Count, cs.Errors}
+	}
+	want := map[string][2]int64{
+		"CONNECT":  {2, 0},
+		"EHLO":     {2, 0},
+		"STARTTLS": {2, 0},
+		"MAIL":     {2, 0},
+		"RCPT":     {4, 2},
+		"DATA":     {1, 0},
+		"RSET":     {1, 0},
+		"QUIT":     {2, 0},
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Invalid command counts, got %v, want %v", counts, want)
+	}
+}
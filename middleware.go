@@ -0,0 +1,32 @@
+package gomail
+
+import "io"
+
+// A Middleware wraps the function sending an email, for example to log or
+// measure the sends, add header fields, sign the emails or enforce a policy.
+// It returns a SendFunc that can change the envelope or the email and then
+// calls next, or return an error without calling next to cancel the send.
+type Middleware func(next SendFunc) SendFunc
+
+// Use adds middleware to the senders returned by the Dialer. They wrap each
+// SMTP transaction, so with SplitRecipients they are called for each copy of
+// an email. The first middleware added is the outermost one. Use must not be
+// called while the Dialer is in use.
+func (d *Dialer) Use(mw ...Middleware) {
+	d.middleware = append(d.middleware, mw...)
+}
+
+// sendChain sends the email through the middleware of the Dialer. The options
+// of the envelope are kept, and the DSN and RequireTLS settings of a Message are
+// resolved beforehand so they are not lost if a middleware replaces the
+// Message.
+func (c *smtpSender) sendChain(e *Envelope, msg io.WriterTo) error {
+	opts := resolvedOptions(e, msg)
+	f := SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		return c.sendLimited(&Envelope{From: from, To: to, Options: opts}, msg)
+	})
+	for i := len(c.d.middleware) - 1; i >= 0; i-- {
+		f = c.d.middleware[i](f)
+	}
+	return f(e.From, e.To, msg)
+}
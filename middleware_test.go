@@ -0,0 +1,79 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDialerUse(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next SendFunc) SendFunc {
+			return func(from string, to []string, msg io.WriterTo) error {
+				calls = append(calls, name+" before")
+				err := next(from, to, msg)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	inject := func(next SendFunc) SendFunc {
+		return func(from string, to []string, msg io.WriterTo) error {
+			m := msg.(*Message).Clone()
+			m.SetHeader("X-Mailer", "test")
+			return next(from, to[:1], m)
+		}
+	}
+
+	d := &Dialer{Host: testHost, Port: testPort}
+	d.Use(record("first"), record("second"))
+	d.Use(inject)
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+			"Close",
+		},
+		bodies: []string{strings.Replace(testMsg, "Content-Type:", "X-Mailer: test\r\nContent-Type:", 1)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first before", "second before", "second after", "first after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Invalid calls, got %q, want %q", calls, want)
+	}
+}
+
+func TestDialerUseCancel(t *testing.T) {
+	errBlocked := errors.New("blocked")
+	d := &Dialer{Host: testHost, Port: testPort}
+	d.Use(func(next SendFunc) SendFunc {
+		return func(from string, to []string, msg io.WriterTo) error {
+			return errBlocked
+		}
+	})
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Quit",
+			"Close",
+		},
+	})
+	if !errors.Is(err, errBlocked) {
+		t.Errorf("Invalid error, got %v, want %v", err, errBlocked)
+	}
+}
@@ -0,0 +1,186 @@
+package gomail
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MTA-STS policy modes, see MTASTSPolicy.
+const (
+	MTASTSEnforce = "enforce"
+	MTASTSTesting = "testing"
+	MTASTSNone    = "none"
+)
+
+// An MTASTSPolicy is the MTA-STS policy of a domain, defined in RFC 8461. It
+// lists the mail exchangers of the domain and requires that the emails sent to
+// them are encrypted with TLS and a valid certificate, so an active attacker
+// cannot redirect the emails or strip TLS.
+//
+// When a Dialer sending directly to a mail exchanger has an MTASTS policy, Dial
+// verifies that its Host is listed by the policy, that the connection is
+// encrypted with STARTTLS or SSL and that the certificate is verified. In
+// enforce mode, a connection not satisfying the policy fails with an error
+// wrapping ErrMTASTSFailed. In testing mode, the failure is only logged by the
+// Logger of the Dialer.
+type MTASTSPolicy struct {
+	// Domain is the domain of the recipients.
+	Domain string
+	// ID identifies the version of the policy. It changes when the policy is
+	// updated.
+	ID string
+	// Mode is MTASTSEnforce, MTASTSTesting or MTASTSNone.
+	Mode string
+	// MX are the host names of the mail exchangers. A name starting with
+	// "*." matches the hosts of exactly one more label.
+	MX []string
+	// MaxAge is how long the policy can be cached.
+	MaxAge time.Duration
+}
+
+// maxMTASTSPolicySize is the maximum size of a policy file.
+const maxMTASTSPolicySize = 64 * 1024
+
+// mtastsClient fetches the policy files. As required by RFC 8461, it does not
+// follow redirects. It is a variable so tests can stub the transport.
+var mtastsClient = &http.Client{
+	Timeout: time.Minute,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// FetchMTASTSPolicy fetches the MTA-STS policy of domain: it looks up the
+// _mta-sts TXT record of the domain and downloads the policy file from its
+// mta-sts subdomain over HTTPS. It returns nil and no error if the domain has
+// no MTA-STS policy.
+//
+// The policy can be cached for its MaxAge as long as the ID of the TXT record
+// does not change.
+func FetchMTASTSPolicy(domain string) (*MTASTSPolicy, error) {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	records, err := txtRecords("_mta-sts."+domain, "v=STSv1")
+	if err != nil {
+		return nil, fmt.Errorf("gomail: could not look up the MTA-STS record of %s: %w", domain, err)
+	}
+	switch len(records) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("gomail: %d MTA-STS records published for %s, only one is allowed", len(records), domain)
+	}
+	id := parseTagList(records[0])["id"]
+	if id == "" {
+		return nil, fmt.Errorf("gomail: invalid MTA-STS record for %s, the id is missing", domain)
+	}
+
+	url := "https://mta-sts." + domain + "/.well-known/mta-sts.txt"
+	resp, err := mtastsClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("gomail: could not fetch the MTA-STS policy of %s: %w", domain, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gomail: could not fetch the MTA-STS policy of %s: %s", domain, resp.Status)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || mediaType != "text/plain" {
+		return nil, fmt.Errorf("gomail: invalid MTA-STS policy of %s, the content type is %q", domain, resp.Header.Get("Content-Type"))
+	}
+
+	p, err := parseMTASTSPolicy(io.LimitReader(resp.Body, maxMTASTSPolicySize))
+	if err != nil {
+		return nil, fmt.Errorf("gomail: invalid MTA-STS policy of %s: %w", domain, err)
+	}
+	p.Domain, p.ID = domain, id
+	return p, nil
+}
+
+// parseMTASTSPolicy parses a policy file.
+func parseMTASTSPolicy(r io.Reader) (*MTASTSPolicy, error) {
+	p := new(MTASTSPolicy)
+	var version, maxAge string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "version":
+			version = value
+		case "mode":
+			p.Mode = value
+		case "mx":
+			p.MX = append(p.MX, strings.ToLower(strings.TrimSuffix(value, ".")))
+		case "max_age":
+			maxAge = value
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if version != "STSv1" {
+		return nil, fmt.Errorf("unsupported version %q", version)
+	}
+	switch p.Mode {
+	case MTASTSEnforce, MTASTSTesting:
+		if len(p.MX) == 0 {
+			return nil, errors.New("no mx is listed")
+		}
+	case MTASTSNone:
+	default:
+		return nil, fmt.Errorf("unknown mode %q", p.Mode)
+	}
+	// The maximum age is one year.
+	n, err := strconv.ParseUint(maxAge, 10, 32)
+	if err != nil || n > 31557600 {
+		return nil, fmt.Errorf("invalid max_age %q", maxAge)
+	}
+	p.MaxAge = time.Duration(n) * time.Second
+	return p, nil
+}
+
+// Match reports whether host is a mail exchanger listed by the policy.
+func (p *MTASTSPolicy) Match(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, mx := range p.MX {
+		if strings.HasPrefix(mx, "*.") {
+			label := strings.TrimSuffix(host, mx[1:])
+			if label != host && label != "" && !strings.Contains(label, ".") {
+				return true
+			}
+		} else if host == mx {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMTASTS returns an error if the connection described by reason does not
+// satisfy the MTA-STS policy of the Dialer. In testing mode, the failure is
+// logged and nil is returned.
+func (d *Dialer) checkMTASTS(reason string) error {
+	p := d.MTASTS
+	err := fmt.Errorf("gomail: %s does not satisfy the MTA-STS policy of %s: %s", d.Host, p.Domain, reason)
+	if p.Mode != MTASTSEnforce {
+		d.logf("%v (%s mode)", err, p.Mode)
+		return nil
+	}
+	return &wrappedError{ErrMTASTSFailed, err}
+}
+
+// mtastsActive reports whether the Dialer has an MTA-STS policy to verify.
+func (d *Dialer) mtastsActive() bool {
+	return d.MTASTS != nil && d.MTASTS.Mode != MTASTSNone
+}
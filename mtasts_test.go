@@ -0,0 +1,149 @@
+package gomail
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+const testMTASTSPolicy = "version: STSv1\r\n" +
+	"mode: enforce\r\n" +
+	"mx: mx1.example.com\r\n" +
+	"mx: *.mail.example.com\r\n" +
+	"max_age: 86400\r\n"
+
+func TestFetchMTASTSPolicy(t *testing.T) {
+	defer stubDNS(t, map[string][]string{
+		"_mta-sts.example.com": {"v=STSv1; id=20190429T010101;"},
+	}, nil)()
+	defer func(rt http.RoundTripper) { mtastsClient.Transport = rt }(mtastsClient.Transport)
+	mtastsClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if got, want := r.URL.String(), "https://mta-sts.example.com/.well-known/mta-sts.txt"; got != want {
+			t.Errorf("Invalid URL, got %q, want %q", got, want)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			Body:       ioutil.NopCloser(strings.NewReader(testMTASTSPolicy)),
+		}, nil
+	})
+
+	p, err := FetchMTASTSPolicy("Example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &MTASTSPolicy{
+		Domain: "example.com",
+		ID:     "20190429T010101",
+		Mode:   MTASTSEnforce,
+		MX:     []string{"mx1.example.com", "*.mail.example.com"},
+		MaxAge: 24 * time.Hour,
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Invalid policy, got %+v, want %+v", p, want)
+	}
+
+	if p, err := FetchMTASTSPolicy("other.example"); p != nil || err != nil {
+		t.Errorf("A domain without record should have no policy, got %v, %v", p, err)
+	}
+}
+
+func TestParseMTASTSPolicyInvalid(t *testing.T) {
+	for _, s := range []string{
+		"mode: enforce\nmx: mx.example.com\nmax_age: 60\n",
+		"version: STSv1\nmode: strict\nmx: mx.example.com\nmax_age: 60\n",
+		"version: STSv1\nmode: enforce\nmax_age: 60\n",
+		"version: STSv1\nmode: enforce\nmx: mx.example.com\nmax_age: 99999999\n",
+	} {
+		if _, err := parseMTASTSPolicy(strings.NewReader(s)); err == nil {
+			t.Errorf("parseMTASTSPolicy(%q) should fail", s)
+		}
+	}
+}
+
+func TestMTASTSPolicyMatch(t *testing.T) {
+	p := &MTASTSPolicy{MX: []string{"mx1.example.com", "*.mail.example.com"}}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"mx1.example.com", true},
+		{"MX1.example.com.", true},
+		{"mx2.example.com", false},
+		{"a.mail.example.com", true},
+		{"a.b.mail.example.com", false},
+		{"mail.example.com", false},
+	}
+	for _, test := range tests {
+		if got := p.Match(test.host); got != test.want {
+			t.Errorf("Match(%q) = %v, want %v", test.host, got, test.want)
+		}
+	}
+}
+
+func TestDialerMTASTS(t *testing.T) {
+	policy := &MTASTSPolicy{Domain: "example.com", Mode: MTASTSEnforce, MX: []string{"mx1.example.com"}}
+
+	d := &Dialer{Host: testHost, Port: testPort, MTASTS: policy}
+	if _, err := d.Dial(); !errors.Is(err, ErrMTASTSFailed) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMTASTSFailed)
+	}
+
+	policy.MX = []string{testHost}
+	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	if _, err := d.Dial(); !errors.Is(err, ErrMTASTSFailed) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMTASTSFailed)
+	}
+
+	d.TLSConfig = nil
+	err := sendMailWithClient(t, d, &mockClient{
+		t:           t,
+		want:        []string{"Extension STARTTLS", "Close"},
+		unsupported: map[string]bool{"STARTTLS": true},
+	})
+	if !errors.Is(err, ErrMTASTSFailed) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMTASTSFailed)
+	}
+
+	// In testing mode, the failure is only logged.
+	policy.Mode = MTASTSTesting
+	l := new(recordLogger)
+	d.Logger = l
+	err = sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+			"Close",
+		},
+		unsupported: map[string]bool{"STARTTLS": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "gomail: " + testHost + " does not satisfy the MTA-STS policy of example.com: STARTTLS is not supported (testing mode)"
+	found := false
+	for _, line := range *l {
+		found = found || line == want
+	}
+	if !found {
+		t.Errorf("The failure should be logged, got %q", *l)
+	}
+}
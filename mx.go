@@ -0,0 +1,195 @@
+package gomail
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// An MXSender sends emails directly to the mail exchangers of the domains of
+// the recipients, without a relay, also known as a smarthost. It is useful for
+// small self-hosted senders. The host sending the emails must be allowed to
+// connect to port 25 and should have a reverse DNS name matching LocalName,
+// otherwise most providers will reject or flag its emails.
+//
+// The recipients are grouped by domain and the email is sent once per domain,
+// trying its mail exchangers by order of preference.
+type MXSender struct {
+	// LocalName is the host name sent with the EHLO command. It should be the
+	// fully qualified domain name of the sending host.
+	LocalName string
+	// Port is the port of the mail exchangers. It defaults to 25.
+	Port int
+	// TLSPolicy defines whether STARTTLS is used, see Dialer.TLSPolicy.
+	TLSPolicy TLSPolicy
+	// TLSConfigFor, if set, returns the TLS configuration used with a mail
+	// exchanger. By default, the certificate must be valid for its host name.
+	TLSConfigFor func(host string) *tls.Config
+	// MTASTS defines whether the MTA-STS policy of each domain is fetched and
+	// verified, see MTASTSPolicy.
+	MTASTS bool
+	// Logger, if set, logs the connections and the emails sent, see
+	// Dialer.Logger.
+	Logger Logger
+}
+
+// A DomainResult is the result of sending an email to the recipients of a
+// domain.
+type DomainResult struct {
+	// Domain is the domain of the recipients.
+	Domain string
+	// Recipients are the addresses of the recipients of the domain.
+	Recipients []string
+	// MX is the mail exchanger the email was sent to or, if it could not be
+	// sent, the last one tried.
+	MX string
+	// MTASTS is the MTA-STS policy of the domain, nil if MXSender.MTASTS is
+	// not set or if the domain has no policy.
+	MTASTS *MTASTSPolicy
+	// Err is nil if the email was sent.
+	Err error
+}
+
+// An MXError is returned by MXSender when the email could not be sent to some
+// domains.
+type MXError struct {
+	// Results are the results of all the domains, including the domains the
+	// email was sent to.
+	Results []*DomainResult
+}
+
+func (e *MXError) Error() string {
+	var failed []string
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, r.Domain+": "+strings.TrimPrefix(r.Err.Error(), "gomail: "))
+		}
+	}
+	return fmt.Sprintf("gomail: could not send the email to %d of %d domains: %s",
+		len(failed), len(e.Results), strings.Join(failed, "; "))
+}
+
+// errNullMX is returned for the domains publishing a null MX record, defined in
+// RFC 7505, to declare that they do not accept emails.
+var errNullMX = errors.New("gomail: the domain does not accept emails")
+
+// Send implements Sender.
+func (s *MXSender) Send(from string, to []string, msg io.WriterTo) error {
+	return s.SendEnvelope(&Envelope{From: from, To: to}, msg)
+}
+
+// SendEnvelope implements EnvelopeSender. If the email could not be sent to
+// some domains, it returns an *MXError.
+func (s *MXSender) SendEnvelope(e *Envelope, msg io.WriterTo) error {
+	results := s.Deliver(e, msg)
+	for _, r := range results {
+		if r.Err != nil {
+			return &MXError{Results: results}
+		}
+	}
+	return nil
+}
+
+// Deliver sends msg to the recipients of e and returns the result of each
+// domain, in the order the domains first appear in the recipients.
+func (s *MXSender) Deliver(e *Envelope, msg io.WriterTo) []*DomainResult {
+	var results []*DomainResult
+	byDomain := make(map[string]*DomainResult)
+	for _, addr := range e.To {
+		_, domain := splitAddress(addr)
+		domain = strings.ToLower(domain)
+		r, ok := byDomain[domain]
+		if !ok {
+			r = &DomainResult{Domain: domain}
+			byDomain[domain] = r
+			results = append(results, r)
+		}
+		r.Recipients = append(r.Recipients, addr)
+	}
+
+	for _, r := range results {
+		env := &Envelope{From: e.From, To: r.Recipients, Options: e.Options}
+		r.Err = s.deliver(r, env, msg)
+	}
+	return results
+}
+
+// deliver sends the email to a domain, trying its mail exchangers until a
+// connection can be opened.
+func (s *MXSender) deliver(r *DomainResult, e *Envelope, msg io.WriterTo) error {
+	hosts, err := lookupMailExchangers(r.Domain)
+	if err != nil {
+		return err
+	}
+
+	if s.MTASTS {
+		r.MTASTS, err = FetchMTASTSPolicy(r.Domain)
+		if err != nil {
+			// Without a cached policy, RFC 8461 requires the email to be
+			// sent as if the domain had no policy.
+			if s.Logger != nil {
+				s.Logger.Printf("%v", err)
+			}
+			r.MTASTS = nil
+		}
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = 25
+	}
+	for _, host := range hosts {
+		r.MX = host
+		d := &Dialer{
+			Host:         host,
+			Port:         port,
+			LocalName:    s.LocalName,
+			TLSPolicy:    s.TLSPolicy,
+			TLSConfigFor: s.TLSConfigFor,
+			MTASTS:       r.MTASTS,
+			Logger:       s.Logger,
+		}
+		var sc SendCloser
+		if sc, err = d.Dial(); err != nil {
+			continue
+		}
+		// The email is not sent to another mail exchanger if the transaction
+		// fails, since it might have been accepted.
+		err = sendEnvelope(sc, e, msg)
+		sc.Close()
+		return err
+	}
+	return err
+}
+
+// lookupMailExchangers returns the mail exchangers of domain by order of
+// preference. Without MX record, the domain itself is used, as defined in RFC
+// 5321.
+func lookupMailExchangers(domain string) ([]string, error) {
+	name, err := asciiDomain(domain)
+	if err != nil {
+		return nil, fmt.Errorf("gomail: invalid domain %q: %w", domain, err)
+	}
+	mxs, err := lookupMX(name)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+			return nil, fmt.Errorf("gomail: could not look up the mail exchangers of %s: %w", domain, err)
+		}
+	}
+	if len(mxs) == 0 {
+		return []string{name}, nil
+	}
+
+	hosts := make([]string, 0, len(mxs))
+	for _, mx := range mxs {
+		host := strings.TrimSuffix(mx.Host, ".")
+		if host == "" {
+			return nil, errNullMX
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
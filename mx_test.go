@@ -0,0 +1,74 @@
+package gomail
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMXSender(t *testing.T) {
+	oldMX, oldDial, oldClient := lookupMX, netDialTimeout, smtpNewClient
+	defer func() { lookupMX, netDialTimeout, smtpNewClient = oldMX, oldDial, oldClient }()
+
+	lookupMX = func(name string) ([]*net.MX, error) {
+		switch name {
+		case "example.com":
+			return []*net.MX{{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}}, nil
+		case "null.example":
+			return []*net.MX{{Host: ".", Pref: 0}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	netDialTimeout = func(network, address string, d time.Duration) (net.Conn, error) {
+		if address == "mx1.example.com:25" {
+			return nil, errors.New("connection refused")
+		}
+		return testConn, nil
+	}
+	mock := func(host string, rcpts ...string) *mockClient {
+		want := []string{"Hello mail.example.net", "Extension STARTTLS", "StartTLS", "Mail " + testFrom}
+		for _, rcpt := range rcpts {
+			want = append(want, "Rcpt "+rcpt)
+		}
+		want = append(want, "Data", "Write message", "Close writer", "Quit", "Close")
+		return &mockClient{t: t, want: want, config: &tls.Config{ServerName: host}}
+	}
+	clients := map[string]*mockClient{
+		"mx2.example.com": mock("mx2.example.com", testTo1, testTo2),
+		"example.org":     mock("example.org", "to3@example.org"),
+	}
+	smtpNewClient = func(conn net.Conn, host string) (smtpClient, error) {
+		c, ok := clients[host]
+		if !ok {
+			t.Fatalf("Invalid host %q", host)
+		}
+		return c, nil
+	}
+
+	s := &MXSender{LocalName: "mail.example.net"}
+	e := &Envelope{From: testFrom, To: []string{testTo1, "to3@example.org", testTo2, "to4@null.example"}}
+	err := SendEnvelope(s, e, getTestMessage())
+	var mxErr *MXError
+	if !errors.As(err, &mxErr) {
+		t.Fatalf("Invalid error, got %v", err)
+	}
+
+	results := mxErr.Results
+	if len(results) != 3 {
+		t.Fatalf("Invalid number of results, got %d, want 3", len(results))
+	}
+	if r := results[0]; r.Domain != "example.com" || r.MX != "mx2.example.com" || len(r.Recipients) != 2 || r.Err != nil {
+		t.Errorf("Invalid result, got %+v", r)
+	}
+	if r := results[1]; r.Domain != "example.org" || r.MX != "example.org" || r.Err != nil {
+		t.Errorf("Invalid result, got %+v", r)
+	}
+	if r := results[2]; r.Domain != "null.example" || r.Err != errNullMX {
+		t.Errorf("Invalid result, got %+v", r)
+	}
+	if want := "gomail: could not send the email to 1 of 3 domains: null.example: the domain does not accept emails"; err.Error() != want {
+		t.Errorf("Invalid error message, got %q, want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,183 @@
+package gomail
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A Part is a node of a custom MIME structure built from Message.Root, for the
+// nestings the message does not build itself, like an alternative inside a
+// related part inside a signed part. A multipart Part has children; the other
+// parts have a body.
+type Part struct {
+	contentType string
+	header      map[string][]string
+	copier      func(io.Writer) error
+	encoding    Encoding
+	children    []*Part
+	// msg is the message the part belongs to and path the indexes of the
+	// part and of its ancestors in their parents, the key of the injection
+	// errors of its header.
+	msg  *Message
+	path string
+}
+
+// Root returns the root part of a custom MIME structure of the message,
+// multipart/mixed by default. Once Root is called, the message is written
+// with this structure and its bodies, attached and embedded files are
+// ignored:
+//
+//	root := m.Root()
+//	root.SetContentType("multipart/related")
+//	alt := root.AddChild("multipart/alternative")
+//	alt.AddChild("text/plain").SetBody("Hello!")
+//	alt.AddChild("text/html").SetBody(`<img src="cid:logo"> Hello!`)
+//	logo := root.AddChild("image/png")
+//	logo.SetHeader("Content-ID", "<logo>")
+//	logo.SetBodyWriter(writeLogo)
+func (m *Message) Root() *Part {
+	if m.root == nil {
+		m.root = &Part{contentType: "multipart/mixed", msg: m}
+	}
+	return m.root
+}
+
+// ContentType returns the content type of the part.
+func (p *Part) ContentType() string {
+	return p.contentType
+}
+
+// SetContentType sets the content type of the part, with its parameters other
+// than the boundary of the multipart parts, for example
+// `multipart/signed; protocol="application/pkcs7-signature"`.
+func (p *Part) SetContentType(contentType string) {
+	p.contentType = contentType
+}
+
+// AddChild adds a part of the given content type at the end of the children of
+// p and returns it. p must be a multipart part.
+func (p *Part) AddChild(contentType string) *Part {
+	c := &Part{contentType: contentType, msg: p.msg, path: p.path + "/" + strconv.Itoa(len(p.children))}
+	p.children = append(p.children, c)
+	return c
+}
+
+// Children returns the children of the part.
+func (p *Part) Children() []*Part {
+	return p.children
+}
+
+// SetHeader sets a header field of the part. The Content-Type field is set
+// with SetContentType and the Content-Transfer-Encoding field with
+// SetEncoding.
+//
+// The field replaces the one of the same canonical name. As with
+// Message.SetHeader, the CR, LF and NUL characters are removed and, if the
+// message uses StrictAddresses, reported by Message.Validate.
+func (p *Part) SetHeader(field string, value ...string) {
+	name := sanitizeFieldName(field)
+	key := fieldKey(name)
+	if p.msg != nil {
+		p.msg.checkInjection("part "+p.path+" "+key, field, value...)
+	}
+	if p.header == nil {
+		p.header = make(map[string][]string)
+	}
+	for k := range p.header {
+		if fieldKey(k) == key {
+			delete(p.header, k)
+		}
+	}
+	values := make([]string, len(value))
+	for i, v := range value {
+		values[i] = stripControlBreaks(v)
+	}
+	p.header[name] = values
+}
+
+// SetBody sets the body of the part.
+func (p *Part) SetBody(body string) {
+	p.copier = newCopier(body)
+}
+
+// SetBodyWriter sets the function writing the body of the part, like
+// AddAlternativeWriter. It can be used to write a file.
+func (p *Part) SetBodyWriter(f func(io.Writer) error) {
+	p.copier = f
+}
+
+// SetEncoding sets the transfer encoding of the body of the part. By default,
+// the text parts use the encoding of the message and the other parts Base64.
+func (p *Part) SetEncoding(enc Encoding) {
+	p.encoding = enc
+}
+
+func isMultipart(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(contentType), "multipart/")
+}
+
+// clone returns a deep copy of the part, belonging to msg.
+func (p *Part) clone(msg *Message) *Part {
+	c := *p
+	c.msg = msg
+	if p.header != nil {
+		c.header = make(map[string][]string, len(p.header))
+		for k, v := range p.header {
+			c.header[k] = copyValues(v)
+		}
+	}
+	c.children = make([]*Part, len(p.children))
+	for i, child := range p.children {
+		c.children[i] = child.clone(msg)
+	}
+	return &c
+}
+
+// writeTree writes the part p of a custom MIME structure and its children.
+func (w *messageWriter) writeTree(p *Part, m *Message) {
+	if isMultipart(p.contentType) {
+		w.openMultipart(p.contentType[len("multipart/"):], p.header)
+		for _, c := range p.children {
+			w.writeTree(c, m)
+		}
+		w.closeMultipart()
+		return
+	}
+	if len(p.children) > 0 && w.err == nil {
+		w.err = fmt.Errorf("gomail: the %s part cannot have children", p.contentType)
+		return
+	}
+
+	contentType, enc := p.contentType, p.encoding
+	isText := strings.HasPrefix(strings.ToLower(contentType), "text/")
+	if isText && !strings.Contains(strings.ToLower(contentType), "charset=") {
+		contentType += "; charset=" + m.charset
+	}
+	if enc == "" {
+		if isText {
+			enc = m.encoding
+		} else {
+			enc = Base64
+		}
+	}
+	h := map[string][]string{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {string(enc)},
+	}
+	for k, v := range p.header {
+		if _, ok := h[fieldKey(k)]; !ok {
+			h[k] = v
+		}
+	}
+	copier := p.copier
+	if copier == nil {
+		copier = newCopier("")
+	}
+	if isIdentityEncoding(string(enc)) {
+		enc = Unencoded
+	}
+	w.writeHeaders(h)
+	w.writeBody(copier, enc)
+}
@@ -0,0 +1,187 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// mimeTree returns the content types of the parts of a MIME entity, depth
+// first, indented by depth, and the bodies of its leaf parts.
+func mimeTree(t *testing.T, header func(string) string, body io.Reader, depth int) string {
+	mediaType, params, err := mime.ParseMediaType(header("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Repeat("  ", depth) + mediaType + "\n"
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return s
+	}
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return s
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		s += mimeTree(t, p.Header.Get, p, depth+1)
+	}
+}
+
+func TestRoot(t *testing.T) {
+	m := NewMessage(Deterministic())
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", "Ignored")
+
+	root := m.Root()
+	root.SetContentType(`multipart/signed; protocol="application/pkcs7-signature"; micalg=sha-256`)
+	related := root.AddChild("multipart/related")
+	alt := related.AddChild("multipart/alternative")
+	alt.AddChild("text/plain").SetBody("Hello!")
+	alt.AddChild("text/html").SetBody(`<img src="cid:logo"> Hello!`)
+	logo := related.AddChild("image/png")
+	logo.SetHeader("Content-ID", "<logo>")
+	logo.SetBodyWriter(func(w io.Writer) error {
+		_, err := io.WriteString(w, "PNG")
+		return err
+	})
+	sig := root.AddChild("application/pkcs7-signature")
+	sig.SetBody("signature")
+
+	c := m.Clone()
+	c.Root().AddChild("text/plain")
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "multipart/signed\n" +
+		"  multipart/related\n" +
+		"    multipart/alternative\n" +
+		"      text/plain\n" +
+		"      text/html\n" +
+		"    image/png\n" +
+		"  application/pkcs7-signature\n"
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || params["protocol"] != "application/pkcs7-signature" || params["micalg"] != "sha-256" {
+		t.Errorf("Invalid Content-Type parameters, got %v", params)
+	}
+	if tree := mimeTree(t, msg.Header.Get, msg.Body, 0); tree != want {
+		t.Errorf("Invalid structure, got:\n%swant:\n%s", tree, want)
+	}
+	for _, want := range []string{
+		"Content-Transfer-Encoding: quoted-printable\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nHello!\r\n",
+		"Content-ID: <logo>\r\nContent-Transfer-Encoding: base64\r\nContent-Type: image/png\r\n\r\nUE5H\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Ignored") {
+		t.Errorf("The body of the message should be ignored:\n%s", got)
+	}
+	if len(m.Root().Children()) != 2 || len(c.Root().Children()) != 3 {
+		t.Error("The clone should not share the structure of the message")
+	}
+}
+
+func TestRootLeaf(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	root := m.Root()
+	root.SetContentType("text/calendar; method=REQUEST")
+	root.SetEncoding(Unencoded)
+	root.SetBody("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n")
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.Header.Get("Content-Type"), "text/calendar; method=REQUEST; charset=UTF-8"; got != want {
+		t.Errorf("Invalid Content-Type, got %q, want %q", got, want)
+	}
+	if body, _ := ioutil.ReadAll(msg.Body); string(body) != "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n" {
+		t.Errorf("Invalid body, got %q", body)
+	}
+
+	root.AddChild("text/plain")
+	if _, err := m.WriteTo(ioutil.Discard); err == nil || !strings.Contains(err.Error(), "cannot have children") {
+		t.Errorf("Invalid error, got %v", err)
+	}
+}
+
+func TestRootHeader(t *testing.T) {
+	m := NewMessage(StrictAddresses())
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	root := m.Root()
+	root.SetHeader("content-type", "text/plain")
+	root.SetHeader("x-tag", "a\r\nBcc: evil@example.com")
+	child := root.AddChild("text/plain")
+	child.SetBody("Hello!")
+	child.SetHeader("Content-ID\n", "<text>")
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := msg.Header["Content-Type"]; len(ct) != 1 || !strings.HasPrefix(ct[0], "multipart/mixed;") {
+		t.Errorf("Invalid Content-Type, got %q", ct)
+	}
+	if tag := msg.Header.Get("X-Tag"); tag != "aBcc: evil@example.com" {
+		t.Errorf("Invalid X-Tag, got %q", tag)
+	}
+	for _, line := range strings.Split(got, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("Injected header line %q in:\n%s", line, got)
+		}
+	}
+	if !strings.Contains(got, "Content-ID: <text>\r\n") {
+		t.Errorf("Missing the Content-ID of the child in:\n%s", got)
+	}
+
+	err = m.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 2 || !errors.Is(verr.Errors[0], ErrInvalidHeader) || !errors.Is(verr.Errors[1], ErrInvalidHeader) {
+		t.Fatalf("Validate should return 2 header errors, got %v", err)
+	}
+
+	// Setting the fields again with valid values clears their errors, in the
+	// clone only.
+	c := m.Clone()
+	c.Root().SetHeader("X-Tag", "a")
+	c.Root().Children()[0].SetHeader("content-id", "<text>")
+	if h := c.Root().Children()[0].header; len(h) != 1 || h["content-id"] == nil {
+		t.Errorf("The field should be replaced, got %v", h)
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate should not fail, got %v", err)
+	}
+	if err := m.Validate(); err == nil {
+		t.Error("The errors of the message should be kept")
+	}
+}
@@ -0,0 +1,49 @@
+package gomail
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// isPipeName reports whether name is the name of a Windows named pipe, like
+// \\.\pipe\smtp.
+func isPipeName(name string) bool {
+	return strings.HasPrefix(name, `\\.\pipe\`) || strings.HasPrefix(name, `//./pipe/`)
+}
+
+// dialPipe opens the named pipe at name. The client end of a named pipe is
+// opened like a file, so this works without a Windows specific dependency.
+func dialPipe(name string) (net.Conn, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &pipeConn{File: f, addr: pipeAddr(name)}, nil
+}
+
+// A pipeConn is a named pipe used as a net.Conn. The deadlines are those of
+// the file, which fails to set them if the pipe cannot be polled.
+type pipeConn struct {
+	*os.File
+	addr pipeAddr
+}
+
+func (c *pipeConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// A pipeAddr is the name of a named pipe.
+type pipeAddr string
+
+func (a pipeAddr) Network() string {
+	return "pipe"
+}
+
+func (a pipeAddr) String() string {
+	return string(a)
+}
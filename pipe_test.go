@@ -0,0 +1,64 @@
+package gomail
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestDialerNetwork(t *testing.T) {
+	tests := []struct {
+		network, address, want string
+	}{
+		{"", "", "tcp"},
+		{"", "smtp.example.com:25", "tcp"},
+		{"", "/var/run/smtpd.sock", "unix"},
+		{"", `\\.\pipe\smtp`, "pipe"},
+		{"", "//./pipe/smtp", "pipe"},
+		{"tcp6", "[::1]:25", "tcp6"},
+	}
+	for _, test := range tests {
+		d := &Dialer{Host: "localhost", Network: test.network, Address: test.address}
+		d.NetDialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if network != test.want {
+				t.Errorf("Invalid network for %q, got %q, want %q", test.address, network, test.want)
+			}
+			return testConn, nil
+		}
+		if _, err := d.dial(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDialPipe(t *testing.T) {
+	f, err := ioutil.TempFile("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	conn, err := defaultDial(context.Background(), "pipe", f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("EHLO localhost\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if a := conn.RemoteAddr(); a.Network() != "pipe" || a.String() != f.Name() {
+		t.Errorf("Invalid address, got %s %s", a.Network(), a)
+	}
+	if b, err := ioutil.ReadFile(f.Name()); err != nil || string(b) != "EHLO localhost\r\n" {
+		t.Errorf("Invalid content, got %q (%v)", b, err)
+	}
+
+	if _, err := dialPipe(f.Name() + ".missing"); err == nil {
+		t.Error("Opening a missing pipe should fail")
+	}
+}
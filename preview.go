@@ -0,0 +1,157 @@
+package gomail
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode"
+)
+
+// previewBase64Lines is the number of lines of a base64 encoded part kept by
+// WritePreview.
+const previewBase64Lines = 2
+
+// WritePreview writes a readable rendering of msg to w, meant to be printed
+// while debugging rather than sent:
+//   - lines end with LF instead of CRLF,
+//   - folded header fields are unfolded and their encoded-words decoded,
+//   - base64 encoded parts are truncated to their first lines,
+//   - control characters that could be interpreted by a terminal are replaced
+//     with U+FFFD.
+//
+// The output is not a valid email, use msg.WriteTo to get the wire format.
+func WritePreview(w io.Writer, msg io.WriterTo) error {
+	b, err := renderLF(msg)
+	if err != nil {
+		return err
+	}
+
+	p := &previewWriter{w: bufio.NewWriter(w), inHeader: true}
+	for len(b) > 0 {
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line = b[:i]
+			b = b[i+1:]
+		} else {
+			b = nil
+		}
+		p.line(string(line))
+	}
+	p.endBody()
+	return p.w.Flush()
+}
+
+type previewWriter struct {
+	w          *bufio.Writer
+	inHeader   bool
+	field      string // Current header field, not yet written.
+	base64     bool
+	boundaries []string
+	skipped    int // Base64 lines skipped in the current part.
+	kept       int // Base64 lines written in the current part.
+}
+
+var previewDecoder = new(mime.WordDecoder)
+
+func (p *previewWriter) line(line string) {
+	if p.inHeader {
+		p.headerLine(line)
+		return
+	}
+
+	if p.isBoundary(line) {
+		p.endBody()
+		p.writeLine(line)
+		p.inHeader = !strings.HasSuffix(line, "--")
+		p.base64 = false
+		return
+	}
+
+	if p.base64 && line != "" {
+		if p.kept == previewBase64Lines {
+			p.skipped++
+			return
+		}
+		p.kept++
+	}
+	p.writeLine(line)
+}
+
+func (p *previewWriter) headerLine(line string) {
+	if line != "" && (line[0] == ' ' || line[0] == '\t') {
+		p.field += " " + strings.TrimLeft(line, " \t")
+		return
+	}
+	p.endField()
+	if line == "" {
+		p.inHeader = false
+		p.writeLine("")
+		return
+	}
+	p.field = line
+}
+
+// endField writes the current header field and records the MIME parameters
+// needed to render the body.
+func (p *previewWriter) endField() {
+	if p.field == "" {
+		return
+	}
+	field := p.field
+	p.field = ""
+
+	if i := strings.IndexByte(field, ':'); i > 0 {
+		name := strings.TrimSpace(field[:i])
+		value := strings.TrimSpace(field[i+1:])
+		switch strings.ToLower(name) {
+		case "content-type":
+			if _, params, err := mime.ParseMediaType(value); err == nil && params["boundary"] != "" {
+				p.boundaries = append(p.boundaries, params["boundary"])
+			}
+		case "content-transfer-encoding":
+			p.base64 = strings.EqualFold(value, "base64")
+		}
+		if decoded, err := previewDecoder.DecodeHeader(value); err == nil {
+			field = name + ": " + decoded
+		}
+	}
+	p.writeLine(field)
+}
+
+func (p *previewWriter) endBody() {
+	if p.inHeader {
+		p.endField()
+	}
+	if p.skipped > 0 {
+		p.writeLine(fmt.Sprintf("[... %d more base64 lines]", p.skipped))
+	}
+	p.skipped, p.kept = 0, 0
+}
+
+func (p *previewWriter) isBoundary(line string) bool {
+	if !strings.HasPrefix(line, "--") {
+		return false
+	}
+	line = strings.TrimSuffix(strings.TrimRight(line[2:], " \t"), "--")
+	for _, b := range p.boundaries {
+		if line == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *previewWriter) writeLine(line string) {
+	p.w.WriteString(strings.Map(consoleSafe, line))
+	p.w.WriteByte('\n')
+}
+
+func consoleSafe(r rune) rune {
+	if r != '\t' && unicode.IsControl(r) {
+		return unicode.ReplacementChar
+	}
+	return r
+}
@@ -0,0 +1,50 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWritePreview(t *testing.T) {
+	m := NewMessage(DisableMessageID())
+	m.SetHeader("From", testFrom)
+	m.SetHeader("Subject", "Café \x1b[31mrouge")
+	m.SetBody("text/plain", "Hello!")
+	m.Attach("test.bin", SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write(bytes.Repeat([]byte{0xff}, 1000))
+		return err
+	}))
+
+	var buf bytes.Buffer
+	if err := WritePreview(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+
+	if strings.Contains(got, "\r") {
+		t.Error("The preview should not contain CR")
+	}
+	if !strings.Contains(got, "\nSubject: Café �[31mrouge\n") {
+		t.Errorf("The Subject should be decoded and escaped, got:\n%s", got)
+	}
+	if !strings.Contains(got, "\nContent-Type: multipart/mixed; boundary=") {
+		t.Errorf("The Content-Type should be unfolded, got:\n%s", got)
+	}
+	if !strings.Contains(got, "\nHello!\n") {
+		t.Errorf("The body should be kept, got:\n%s", got)
+	}
+
+	// 1000 bytes are 1336 base64 characters, that is 18 lines of 76
+	// characters.
+	if n := strings.Count(got, "\n////"); n != previewBase64Lines {
+		t.Errorf("Invalid number of base64 lines, got %d, want %d", n, previewBase64Lines)
+	}
+	if !strings.Contains(got, "\n[... 16 more base64 lines]\n--") {
+		t.Errorf("The base64 part should be truncated, got:\n%s", got)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(got), "--") {
+		t.Errorf("The closing boundary should be kept, got:\n%s", got)
+	}
+}
@@ -0,0 +1,277 @@
+package gomail
+
+import (
+	"encoding/base64"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+// A PreviewHandler is an http.Handler displaying the emails of a Store in a
+// browser, for example the emails queued by a development server, to iterate
+// quickly on email templates. Each email has a tab for its HTML part, its text
+// part, its attachments and its raw source.
+//
+// It is meant for development only: it has no authentication and shows the
+// full content of the emails.
+//
+// The handler serves the list of emails at its root, so it is usually mounted
+// with http.StripPrefix:
+//
+//	http.Handle("/emails/", http.StripPrefix("/emails", &gomail.PreviewHandler{Store: store}))
+type PreviewHandler struct {
+	// Store is the Store containing the emails.
+	Store Store
+}
+
+// ServeHTTP implements http.Handler.
+func (h *PreviewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	list, err := h.Store.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "" {
+		h.serveList(w, list)
+		return
+	}
+
+	id, view := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		id, view = path[:i], path[i+1:]
+	}
+	// Only the IDs listed by the Store are opened, so a crafted ID cannot
+	// reach another file.
+	var env *StoredEnvelope
+	for _, e := range list {
+		if e.ID == id {
+			env = e
+			break
+		}
+	}
+	if env == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	raw, err := h.read(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch view {
+	case "":
+		p := parsePreview(raw)
+		p.Envelope = env
+		p.Tab = r.URL.Query().Get("tab")
+		if p.Tab == "" {
+			p.Tab = "html"
+			if p.HTML == "" {
+				p.Tab = "text"
+			}
+		}
+		h.render(w, previewEmailTemplate, p)
+	case "html":
+		// The HTML part is displayed in a sandboxed iframe: it cannot run
+		// scripts or access the preview pages.
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		io.WriteString(w, parsePreview(raw).HTML)
+	case "raw":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(raw)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *PreviewHandler) read(id string) ([]byte, error) {
+	rc, err := h.Store.Open(id)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+type previewListItem struct {
+	*StoredEnvelope
+	Subject string
+}
+
+func (h *PreviewHandler) serveList(w http.ResponseWriter, list []*StoredEnvelope) {
+	items := make([]previewListItem, len(list))
+	// The newest emails are shown first.
+	for i, e := range list {
+		item := previewListItem{StoredEnvelope: e}
+		if raw, err := h.read(e.ID); err == nil {
+			item.Subject = parsePreview(raw).Subject
+		}
+		items[len(list)-1-i] = item
+	}
+	h.render(w, previewListTemplate, items)
+}
+
+func (h *PreviewHandler) render(w http.ResponseWriter, t *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// A previewEmail is an email split into the parts shown by a PreviewHandler.
+type previewEmail struct {
+	Envelope    *StoredEnvelope
+	Tab         string
+	Subject     string
+	HTML        string
+	Text        string
+	Attachments []previewAttachment
+	Raw         string
+	Err         error
+}
+
+// Tabs returns the tabs of the email page.
+func (p *previewEmail) Tabs() []string {
+	return []string{"html", "text", "attachments", "raw"}
+}
+
+type previewAttachment struct {
+	Name        string
+	ContentType string
+	Size        int
+}
+
+// parsePreview parses raw. A malformed email is still returned, with the
+// parsing error in Err, so its raw source can be inspected.
+func parsePreview(raw []byte) *previewEmail {
+	p := &previewEmail{Raw: string(raw)}
+	msg, err := mail.ReadMessage(strings.NewReader(p.Raw))
+	if err != nil {
+		p.Err = err
+		return p
+	}
+	p.Subject = decodePreviewHeader(msg.Header.Get("Subject"))
+	p.Err = p.walk(msg.Header, msg.Body)
+	return p
+}
+
+type mimeHeader interface {
+	Get(key string) string
+}
+
+func (p *previewEmail) walk(h mimeHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := p.walk(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	// multipart.Reader already decodes quoted-printable parts and removes
+	// their Content-Transfer-Encoding header.
+	switch strings.ToLower(h.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	name := params["name"]
+	if _, dparams, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil && dparams["filename"] != "" {
+		name = dparams["filename"]
+	}
+	switch {
+	case name == "" && mediaType == "text/html" && p.HTML == "":
+		p.HTML = string(b)
+	case name == "" && mediaType == "text/plain" && p.Text == "":
+		p.Text = string(b)
+	default:
+		p.Attachments = append(p.Attachments, previewAttachment{
+			Name:        decodePreviewHeader(name),
+			ContentType: mediaType,
+			Size:        len(b),
+		})
+	}
+	return nil
+}
+
+func decodePreviewHeader(s string) string {
+	if d, err := previewDecoder.DecodeHeader(s); err == nil {
+		return d
+	}
+	return s
+}
+
+const previewStyle = `<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border-bottom: 1px solid #ddd; padding: .4em .8em; text-align: left; }
+nav a { display: inline-block; padding: .4em .8em; border: 1px solid #ddd; }
+nav a.active { background: #eee; }
+pre { white-space: pre-wrap; }
+iframe { width: 100%; height: 80vh; border: 1px solid #ddd; }
+</style>`
+
+var previewListTemplate = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Emails</title>` + previewStyle + `</head>
+<body><h1>Emails</h1>
+{{if .}}<table><tr><th>From</th><th>To</th><th>Subject</th></tr>
+{{range .}}<tr><td>{{.From}}</td><td>{{range $i, $to := .To}}{{if $i}}, {{end}}{{$to}}{{end}}</td><td><a href="{{.ID}}">{{or .Subject "(no subject)"}}</a></td></tr>
+{{end}}</table>{{else}}<p>No email.</p>{{end}}
+</body></html>
+`))
+
+var previewEmailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Subject}}</title>` + previewStyle + `</head>
+<body><p><a href="./">All emails</a></p>
+<h1>{{or .Subject "(no subject)"}}</h1>
+<table>
+<tr><th>From</th><td>{{.Envelope.From}}</td></tr>
+<tr><th>To</th><td>{{range $i, $to := .Envelope.To}}{{if $i}}, {{end}}{{$to}}{{end}}</td></tr>
+</table>
+{{with .Err}}<p>Could not parse the email: {{.}}</p>{{end}}
+<nav>
+{{- range $tab := .Tabs}}<a href="?tab={{$tab}}"{{if eq $tab $.Tab}} class="active"{{end}}>{{$tab}}</a>{{end -}}
+</nav>
+{{if eq .Tab "html"}}{{if .HTML}}<iframe sandbox src="{{.Envelope.ID}}/html"></iframe>{{else}}<p>No HTML part.</p>{{end}}
+{{else if eq .Tab "text"}}{{if .Text}}<pre>{{.Text}}</pre>{{else}}<p>No text part.</p>{{end}}
+{{else if eq .Tab "attachments"}}{{if .Attachments}}<table><tr><th>Name</th><th>Type</th><th>Size</th></tr>
+{{range .Attachments}}<tr><td>{{or .Name "(unnamed)"}}</td><td>{{.ContentType}}</td><td>{{.Size}} bytes</td></tr>
+{{end}}</table>{{else}}<p>No attachment.</p>{{end}}
+{{else}}<pre>{{.Raw}}</pre>{{end}}
+</body></html>
+`))
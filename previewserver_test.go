@@ -0,0 +1,84 @@
+package gomail
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPreviewHandler(t *testing.T) {
+	s, cleanup := testDirStore(t)
+	defer cleanup()
+
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetHeader("Subject", "Café <b>")
+	m.SetBody("text/plain", "Hello é!")
+	m.AddAlternative("text/html", "<p>Hello <script>alert(1)</script></p>")
+	m.Attach("invoice.pdf", SetCopyFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, "%PDF-1.4")
+		return err
+	}))
+	e := &StoredEnvelope{From: testFrom, To: []string{testTo1}}
+	if err := s.Put(e, m); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(&PreviewHandler{Store: s})
+	defer srv.Close()
+
+	get := func(path string, wantCode int) (*http.Response, string) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != wantCode {
+			t.Errorf("GET %s: invalid status code, got %d, want %d", path, resp.StatusCode, wantCode)
+		}
+		return resp, string(b)
+	}
+	contains := func(path, body string, want ...string) {
+		for _, w := range want {
+			if !strings.Contains(body, w) {
+				t.Errorf("GET %s: %q not found in:\n%s", path, w, body)
+			}
+		}
+	}
+
+	_, body := get("/", http.StatusOK)
+	contains("/", body, `href="`+e.ID+`"`, "Café &lt;b&gt;", testTo1)
+
+	_, body = get("/"+e.ID, http.StatusOK)
+	contains("/"+e.ID, body, `<iframe sandbox src="`+e.ID+`/html">`)
+
+	_, body = get("/"+e.ID+"?tab=text", http.StatusOK)
+	contains("?tab=text", body, "<pre>Hello é!</pre>")
+
+	_, body = get("/"+e.ID+"?tab=attachments", http.StatusOK)
+	contains("?tab=attachments", body, "invoice.pdf", "application/pdf", "8 bytes")
+
+	_, body = get("/"+e.ID+"?tab=raw", http.StatusOK)
+	contains("?tab=raw", body, "Content-Transfer-Encoding: quoted-printable")
+
+	resp, body := get("/"+e.ID+"/html", http.StatusOK)
+	contains("/html", body, "<p>Hello <script>alert(1)</script></p>")
+	if csp := resp.Header.Get("Content-Security-Policy"); csp != "sandbox" {
+		t.Errorf("Invalid Content-Security-Policy, got %q", csp)
+	}
+
+	_, body = get("/"+e.ID+"/raw", http.StatusOK)
+	contains("/raw", body, "Subject: =?UTF-8?q?Caf=C3=A9_<b>?=")
+
+	get("/../../etc/passwd", http.StatusNotFound)
+	get("/unknown", http.StatusNotFound)
+	get("/"+e.ID+"/unknown", http.StatusNotFound)
+}
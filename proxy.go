@@ -0,0 +1,305 @@
+package gomail
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dialTimeout is the maximum time spent opening a connection, including the
+// handshake with the proxy.
+const dialTimeout = 10 * time.Second
+
+// dial opens the connection to the SMTP server, through the proxy of the
+// Dialer if any.
+func (d *Dialer) dial(ctx context.Context) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	dial := d.NetDialContext
+	if dial == nil {
+		dial = defaultDial
+	}
+	network := d.network()
+	if d.Proxy == nil {
+		return dial(ctx, network, d.address())
+	}
+	if !strings.HasPrefix(network, "tcp") {
+		return nil, fmt.Errorf("gomail: a proxy cannot be used with the %s network", network)
+	}
+	return dialProxy(ctx, dial, d.Proxy, d.address())
+}
+
+// address returns the address of the SMTP server: Address or Host:Port.
+func (d *Dialer) address() string {
+	if d.Address != "" {
+		return d.Address
+	}
+	return addr(d.Host, d.Port)
+}
+
+// network returns the network of the SMTP server: Network or, if it is empty,
+// "unix" if Address is an absolute path, "pipe" if it is a Windows named pipe
+// and "tcp" otherwise.
+func (d *Dialer) network() string {
+	switch {
+	case d.Network != "":
+		return d.Network
+	case isPipeName(d.Address):
+		return "pipe"
+	case strings.HasPrefix(d.Address, "/"):
+		return "unix"
+	}
+	return "tcp"
+}
+
+// defaultDial opens a connection with net.DialTimeout, until the deadline of
+// ctx. The "pipe" network is opened with dialPipe.
+func defaultDial(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if network == "pipe" {
+		return dialPipe(address)
+	}
+	timeout := dialTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return netDialTimeout(network, address, timeout)
+}
+
+// parseProxyURL parses the URL of a proxy and checks its scheme.
+func parseProxyURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("gomail: invalid proxy URL: %w", err)
+	}
+	if err := checkProxyURL(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func checkProxyURL(u *url.URL) error {
+	switch u.Scheme {
+	case "socks5", "socks5h", "http", "https":
+	default:
+		return fmt.Errorf("gomail: unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("gomail: invalid proxy URL, the host is empty")
+	}
+	return nil
+}
+
+// dialProxy opens a connection to address through the proxy.
+func dialProxy(ctx context.Context, dial func(ctx context.Context, network, address string) (net.Conn, error), proxy *url.URL, address string) (net.Conn, error) {
+	if err := checkProxyURL(proxy); err != nil {
+		return nil, err
+	}
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		port := "1080"
+		switch proxy.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), port)
+	}
+
+	conn, err := dial(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("gomail: could not connect to the proxy: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if proxy.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+	}
+
+	tunnel := conn
+	if proxy.Scheme == "socks5" || proxy.Scheme == "socks5h" {
+		err = socks5Connect(conn, proxy.User, address)
+	} else {
+		tunnel, err = httpConnect(conn, proxy.User, address)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tunnel.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// socks5Connect asks a SOCKS5 proxy to connect to address, as defined in RFC
+// 1928, with the username and password authentication of RFC 1929 if user is
+// not nil. The host name is resolved by the proxy.
+func socks5Connect(conn net.Conn, user *url.Userinfo, address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("gomail: invalid port %q", portStr)
+	}
+
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 || reply[1] != methods[0] {
+		return errors.New("gomail: the SOCKS5 proxy refused the authentication method")
+	}
+
+	if user != nil {
+		password, _ := user.Password()
+		username := user.Username()
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("gomail: the SOCKS5 username or password is too long")
+		}
+		req := []byte{0x01, byte(len(username))}
+		req = append(req, username...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return errors.New("gomail: the SOCKS5 proxy rejected the credentials")
+		}
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("gomail: the host name %q is too long", host)
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 0x01)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 0x04)
+		req = append(req, ip...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	resp := make([]byte, 4)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[1] != 0x00 {
+		return fmt.Errorf("gomail: the SOCKS5 proxy could not connect to %s: %s", address, socks5Error(resp[1]))
+	}
+	// The bound address is not used.
+	var n int
+	switch resp[3] {
+	case 0x01:
+		n = net.IPv4len
+	case 0x04:
+		n = net.IPv6len
+	case 0x03:
+		if _, err := io.ReadFull(conn, resp[:1]); err != nil {
+			return err
+		}
+		n = int(resp[0])
+	default:
+		return errors.New("gomail: invalid reply from the SOCKS5 proxy")
+	}
+	_, err = io.ReadFull(conn, make([]byte, n+2))
+	return err
+}
+
+func socks5Error(code byte) string {
+	switch code {
+	case 0x01:
+		return "general failure"
+	case 0x02:
+		return "connection not allowed by ruleset"
+	case 0x03:
+		return "network unreachable"
+	case 0x04:
+		return "host unreachable"
+	case 0x05:
+		return "connection refused"
+	case 0x06:
+		return "TTL expired"
+	case 0x07:
+		return "command not supported"
+	case 0x08:
+		return "address type not supported"
+	}
+	return "error " + strconv.Itoa(int(code))
+}
+
+// httpConnect asks an HTTP proxy to open a tunnel to address with the CONNECT
+// method.
+func httpConnect(conn net.Conn, user *url.Userinfo, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, fmt.Errorf("gomail: invalid reply from the HTTP proxy: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gomail: the HTTP proxy could not connect to %s: %s", address, resp.Status)
+	}
+	// The greeting of the SMTP server might already be buffered.
+	if r.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+// A bufferedConn is a connection whose first bytes have been read in a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,477 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrQueueClosed is returned by Queue.Enqueue after the queue has been shut
+// down.
+var ErrQueueClosed = errors.New("gomail: queue is closed")
+
+// A SendDialer opens connections able to send emails. *Dialer implements it.
+type SendDialer interface {
+	Dial() (SendCloser, error)
+}
+
+// A Queue sends emails in the background using a pool of workers. Each worker
+// keeps its own connection open between emails and closes it when it has been
+// idle for a while.
+type Queue struct {
+	d           SendDialer
+	workers     int
+	minWorkers  int
+	adaptive    *adaptiveLimit
+	buffer      int
+	idleTimeout time.Duration
+	retry       *RetryPolicy
+	errorFunc   func(m *Message, err error)
+	store       Store
+
+	maxPending      int
+	maxPendingBytes int64
+	nonBlocking     bool
+
+	ch     chan *queueItem
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	recovering sync.WaitGroup
+	closeOnce  sync.Once
+
+	schedMu   sync.Mutex
+	sched     schedule
+	wake      chan struct{}
+	stop      chan struct{}
+	schedDone chan struct{}
+
+	limitMu     sync.Mutex
+	limitCond   *sync.Cond
+	limitClosed bool
+	stats       QueueStats
+}
+
+type queueItem struct {
+	msg  io.WriterTo
+	m    *Message
+	id   string
+	env  *Envelope
+	at   time.Time
+	size int64
+}
+
+// A QueueSetting can be used as an argument in NewQueue to configure a queue.
+type QueueSetting func(q *Queue)
+
+// Workers is a queue setting to set the number of emails sent concurrently.
+// The default is 1.
+func Workers(n int) QueueSetting {
+	return func(q *Queue) {
+		q.workers = n
+	}
+}
+
+// Buffer is a queue setting to set the number of emails that can be enqueued
+// before Enqueue blocks. The default is 100.
+func Buffer(n int) QueueSetting {
+	return func(q *Queue) {
+		q.buffer = n
+	}
+}
+
+// IdleTimeout is a queue setting to set the duration after which an idle
+// connection is closed. The default is 30 seconds.
+func IdleTimeout(d time.Duration) QueueSetting {
+	return func(q *Queue) {
+		q.idleTimeout = d
+	}
+}
+
+// Retry is a queue setting to set how emails are retried after a temporary
+// failure. If the queue uses a *Dialer, its RetryPolicy is used by default. It
+// can be overridden for an email with SetRetryPolicy.
+//
+// A retried email is always sent again in full. SMTP cannot resume an
+// interrupted transfer: the server discards a mail transaction that is not
+// completed, including the chunks already acknowledged in a BDAT transfer as
+// defined in RFC 3030, and a new connection starts a new transaction.
+func Retry(p *RetryPolicy) QueueSetting {
+	return func(q *Queue) {
+		q.retry = p
+	}
+}
+
+// OnError is a queue setting to set a function called when an email could not
+// be sent. The message is nil for the emails recovered from a Store.
+func OnError(f func(m *Message, err error)) QueueSetting {
+	return func(q *Queue) {
+		q.errorFunc = f
+	}
+}
+
+// Spool is a queue setting to persist the enqueued emails in s until they are
+// sent. The emails left in s by a previous queue, because of a crash or an SMTP
+// outage, are sent again when the queue starts.
+//
+// An email is removed from s once sent or after a permanent failure. Emails
+// that still fail temporarily after all retries are kept and sent again on the
+// next start.
+func Spool(s Store) QueueSetting {
+	return func(q *Queue) {
+		q.store = s
+	}
+}
+
+// NewQueue creates a queue sending emails with connections opened by d and
+// starts its workers.
+func NewQueue(d SendDialer, settings ...QueueSetting) *Queue {
+	q := &Queue{
+		d:           d,
+		workers:     1,
+		buffer:      100,
+		idleTimeout: 30 * time.Second,
+	}
+	if dialer, ok := d.(*Dialer); ok {
+		q.retry = dialer.RetryPolicy
+	}
+	for _, s := range settings {
+		s(q)
+	}
+
+	// The stored emails must be listed before any new email is stored.
+	var pending []*StoredEnvelope
+	var err error
+	if q.store != nil {
+		pending, err = q.store.List()
+	}
+
+	if q.minWorkers > 0 {
+		q.adaptive = newAdaptiveLimit(q.minWorkers, q.workers)
+	}
+	q.limitCond = sync.NewCond(&q.limitMu)
+	q.ch = make(chan *queueItem, q.buffer)
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	q.wake = make(chan struct{}, 1)
+	q.stop = make(chan struct{})
+	q.schedDone = make(chan struct{})
+	go q.runSchedule()
+
+	if err != nil {
+		q.reportError(nil, fmt.Errorf("gomail: could not list stored emails: %w", err))
+	} else if len(pending) > 0 {
+		q.recovering.Add(1)
+		go q.recover(pending)
+	}
+
+	return q
+}
+
+// recover enqueues the emails left in the store by a previous queue.
+func (q *Queue) recover(pending []*StoredEnvelope) {
+	defer q.recovering.Done()
+	for _, e := range pending {
+		item := &queueItem{
+			msg: &storedMessage{store: q.store, id: e.ID},
+			id:  e.ID,
+			env: &Envelope{From: e.From, To: e.To, Options: EnvelopeOptions{DSN: e.DSN, RequireTLS: e.RequireTLS}},
+			at:  e.SendAt,
+		}
+		if err := q.acquire(0, true); err != nil {
+			return
+		}
+		if item.at.After(now()) {
+			q.schedule(item)
+			continue
+		}
+		select {
+		case q.ch <- item:
+		case <-q.ctx.Done():
+			q.release(item)
+			return
+		}
+	}
+}
+
+// Enqueue adds the email to the queue. It blocks if the queue buffer is full or
+// if a limit set with MaxPending or MaxPendingBytes is reached, unless the
+// NonBlocking setting is used. The email must not be modified after it has
+// been enqueued.
+//
+// If a send time has been set with Message.SetSendTime, the email is sent at
+// that time.
+func (q *Queue) Enqueue(m *Message) error {
+	return q.EnqueueAt(m.sendAt, m)
+}
+
+// EnqueueAt adds the email to the queue to be sent at t. If t is zero or in the
+// past, it behaves like Enqueue. Otherwise it only blocks if a limit set with
+// MaxPending or MaxPendingBytes is reached and, if the email has no Date
+// header, it is set to t.
+//
+// The emails still waiting for their send time when the queue is shut down are
+// kept in the queue Store if it has one and are otherwise reported to the
+// OnError function with ErrQueueClosed.
+func (q *Queue) EnqueueAt(t time.Time, m *Message) error {
+	e, err := m.Envelope()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := m.header["Date"]; t.After(now()) && !ok {
+		m.SetDateHeader("Date", t)
+	}
+
+	return q.enqueue(&queueItem{msg: m, m: m, env: e, at: t})
+}
+
+// EnqueueEnvelope adds an email with the given envelope to the queue, for
+// example an email being relayed that was not built with Message. It blocks
+// like Enqueue. The message passed to the OnError function is nil unless msg
+// is a *Message.
+func (q *Queue) EnqueueEnvelope(e *Envelope, msg io.WriterTo) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	m, _ := msg.(*Message)
+	return q.enqueue(&queueItem{msg: msg, m: m, env: e})
+}
+
+func (q *Queue) enqueue(item *queueItem) error {
+	if item.m != nil {
+		m, err := item.m.fitSize()
+		if err != nil {
+			return err
+		}
+		item.msg, item.m = m, m
+	}
+	scheduled := item.at.After(now())
+	if q.maxPendingBytes > 0 && item.m != nil {
+		n, err := messageSize(item.m)
+		if err != nil {
+			return err
+		}
+		item.size = n
+	}
+
+	if q.store != nil {
+		e := &StoredEnvelope{
+			From:       item.env.From,
+			To:         item.env.To,
+			DSN:        item.env.Options.DSN,
+			RequireTLS: item.env.Options.RequireTLS,
+			SendAt:     item.at,
+		}
+		msg := &countingWriterTo{WriterTo: item.msg}
+		if err := q.store.Put(e, msg); err != nil {
+			return fmt.Errorf("gomail: could not store email: %w", err)
+		}
+		item.msg = &storedMessage{store: q.store, id: e.ID}
+		item.id = e.ID
+		if item.m == nil {
+			item.size = msg.n
+		}
+	}
+
+	if err := q.acquire(item.size, !q.nonBlocking); err != nil {
+		q.unstore(item)
+		return err
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		q.drop(item)
+		return ErrQueueClosed
+	}
+	if scheduled {
+		q.schedule(item)
+		return nil
+	}
+	if !q.nonBlocking {
+		q.ch <- item
+		return nil
+	}
+	select {
+	case q.ch <- item:
+		return nil
+	default:
+		q.drop(item)
+		q.limitMu.Lock()
+		q.stats.Rejected++
+		q.limitMu.Unlock()
+		return ErrQueueFull
+	}
+}
+
+// drop removes an email that was not accepted by the queue.
+func (q *Queue) drop(item *queueItem) {
+	q.release(item)
+	q.unstore(item)
+}
+
+func (q *Queue) unstore(item *queueItem) {
+	if item.id != "" {
+		q.store.Delete(item.id)
+	}
+}
+
+// Shutdown stops accepting new emails and waits until the enqueued emails are
+// sent and the connections are closed. It does not wait for the emails
+// scheduled later. If ctx expires first, the pending retries are aborted and
+// ctx.Err() is returned.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	q.limitMu.Lock()
+	q.limitClosed = true
+	q.limitMu.Unlock()
+	q.limitCond.Broadcast()
+
+	done := make(chan struct{})
+	go func() {
+		// The recovered emails are still being enqueued.
+		q.recovering.Wait()
+		q.closeOnce.Do(func() {
+			close(q.stop)
+			<-q.schedDone
+			close(q.ch)
+		})
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	w := &queueWorker{q: q}
+	defer w.close()
+
+	timer := time.NewTimer(q.idleTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case item, ok := <-q.ch:
+			if !ok {
+				return
+			}
+			err := w.send(item)
+			if item.id != "" && (err == nil || !q.retryPolicy(item).retryable(err)) {
+				if derr := q.store.Delete(item.id); derr != nil {
+					q.reportError(item.m, fmt.Errorf("gomail: could not delete stored email: %w", derr))
+				}
+			}
+			q.release(item)
+			if err != nil {
+				q.reportError(item.m, err)
+			}
+		case <-timer.C:
+			w.close()
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(q.idleTimeout)
+	}
+}
+
+// retryPolicy returns the RetryPolicy of the email, set with SetRetryPolicy, or
+// else the one of the queue. The policy of the emails recovered from a Store is
+// not known and the one of the queue is used.
+func (q *Queue) retryPolicy(item *queueItem) *RetryPolicy {
+	if item.m != nil && item.m.retry != nil {
+		return item.m.retry
+	}
+	return q.retry
+}
+
+func (q *Queue) reportError(m *Message, err error) {
+	if q.errorFunc != nil {
+		q.errorFunc(m, err)
+	}
+}
+
+type queueWorker struct {
+	q *Queue
+	s SendCloser
+}
+
+func (w *queueWorker) send(item *queueItem) error {
+	retry := w.q.retryPolicy(item)
+	for attempt := 1; ; attempt++ {
+		err := w.trySend(item.env, item.msg)
+		if err == nil {
+			return nil
+		}
+		// The connection might be broken.
+		w.close()
+
+		delay, ok := retry.next(attempt, err)
+		if !ok {
+			return err
+		}
+		if retry.OnRetry != nil {
+			retry.OnRetry(attempt, err, delay)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-w.q.ctx.Done():
+			t.Stop()
+			return err
+		}
+	}
+}
+
+func (w *queueWorker) trySend(e *Envelope, msg io.WriterTo) (err error) {
+	if a := w.q.adaptive; a != nil {
+		var epoch int
+		if epoch, err = a.acquire(w.q.ctx, w.close); err != nil {
+			return err
+		}
+		defer func() { a.release(epoch, err) }()
+	}
+	if w.s == nil {
+		s, err := w.q.d.Dial()
+		if err != nil {
+			return err
+		}
+		w.s = s
+	}
+	return sendEnvelope(w.s, e, msg)
+}
+
+func (w *queueWorker) close() {
+	if w.s != nil {
+		w.s.Close()
+		w.s = nil
+	}
+}
@@ -0,0 +1,219 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDialer opens connections recording the emails sent in a shared list.
+type fakeDialer struct {
+	mu     sync.Mutex
+	dials  int
+	closes int
+	sent   []string
+	// fail returns the error returned by the n-th call to Send.
+	fail  func(n int) error
+	calls int
+	delay time.Duration
+}
+
+func (d *fakeDialer) Dial() (SendCloser, error) {
+	d.mu.Lock()
+	d.dials++
+	d.mu.Unlock()
+	return &fakeSendCloser{d}, nil
+}
+
+type fakeSendCloser struct {
+	d *fakeDialer
+}
+
+func (s *fakeSendCloser) Send(from string, to []string, msg io.WriterTo) error {
+	time.Sleep(s.d.delay)
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.calls++
+	if s.d.fail != nil {
+		if err := s.d.fail(s.d.calls); err != nil {
+			return err
+		}
+	}
+	s.d.sent = append(s.d.sent, to[0])
+	return nil
+}
+
+func (s *fakeSendCloser) Close() error {
+	s.d.mu.Lock()
+	s.d.closes++
+	s.d.mu.Unlock()
+	return nil
+}
+
+func testQueueMessage(to string) *Message {
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", to)
+	m.SetBody("text/plain", testBody)
+	return m
+}
+
+func TestQueue(t *testing.T) {
+	d := &fakeDialer{}
+	q := NewQueue(d, Workers(4), Buffer(10))
+	for i := 0; i < 50; i++ {
+		if err := q.Enqueue(testQueueMessage(testTo1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.sent) != 50 {
+		t.Errorf("Invalid number of emails sent, got %d, want 50", len(d.sent))
+	}
+	if d.dials > 4 || d.dials != d.closes {
+		t.Errorf("Connections should be reused and closed, got %d dials and %d closes", d.dials, d.closes)
+	}
+
+	if err := q.Enqueue(testQueueMessage(testTo1)); err != ErrQueueClosed {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestQueueInvalidMessage(t *testing.T) {
+	q := NewQueue(&fakeDialer{})
+	defer q.Shutdown(context.Background())
+	if err := q.Enqueue(NewMessage()); err == nil {
+		t.Error("Enqueue should fail without From field")
+	}
+}
+
+func TestQueueMaxSize(t *testing.T) {
+	r := new(envelopeRecorder)
+	q := NewQueue(r)
+	m := NewMessage(MaxSize(2000))
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", "Hello!")
+	m.Attach("big.bin", copyBytes(2000))
+	if err := q.Enqueue(m); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMessageTooLarge)
+	}
+
+	OnOversize(func(string, int64) (string, bool) { return "", true })(m)
+	if err := q.Enqueue(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.bodies) != 1 || strings.Contains(r.bodies[0], "big.bin") {
+		t.Errorf("The email should be sent without the attachment, got %q", r.bodies)
+	}
+}
+
+func TestQueueRetry(t *testing.T) {
+	d := &fakeDialer{
+		fail: func(n int) error {
+			switch n {
+			case 1:
+				return &textproto.Error{Code: 421, Msg: "Try again later"}
+			case 3:
+				return &textproto.Error{Code: 550, Msg: "No such user"}
+			}
+			return nil
+		},
+	}
+
+	var retries int
+	var mu sync.Mutex
+	var failed []error
+	q := NewQueue(d,
+		Retry(&RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			OnRetry:        func(int, error, time.Duration) { retries++ },
+		}),
+		OnError(func(m *Message, err error) {
+			mu.Lock()
+			failed = append(failed, err)
+			mu.Unlock()
+		}),
+	)
+	q.Enqueue(testQueueMessage(testTo1))
+	q.Enqueue(testQueueMessage(testTo2))
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if retries != 1 {
+		t.Errorf("Invalid number of retries, got %d, want 1", retries)
+	}
+	if len(d.sent) != 1 || d.sent[0] != testTo1 {
+		t.Errorf("Invalid emails sent, got %v", d.sent)
+	}
+	var perr *textproto.Error
+	if len(failed) != 1 || !errors.As(failed[0], &perr) || perr.Code != 550 {
+		t.Errorf("Invalid errors, got %v", failed)
+	}
+}
+
+func TestQueueMessageRetryPolicy(t *testing.T) {
+	d := &fakeDialer{
+		fail: func(n int) error {
+			if n <= 2 {
+				return &textproto.Error{Code: 421, Msg: "Try again later"}
+			}
+			return nil
+		},
+	}
+
+	var failed int
+	q := NewQueue(d, OnError(func(m *Message, err error) { failed++ }))
+	// The queue has no RetryPolicy so the first email is not retried.
+	q.Enqueue(testQueueMessage(testTo1))
+	m := testQueueMessage(testTo2)
+	SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})(m)
+	q.Enqueue(m)
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if failed != 1 || len(d.sent) != 1 || d.sent[0] != testTo2 {
+		t.Errorf("Only the second email should be retried and sent, got %d failures and %v sent", failed, d.sent)
+	}
+}
+
+func TestQueueIdleTimeout(t *testing.T) {
+	d := &fakeDialer{}
+	q := NewQueue(d, IdleTimeout(10*time.Millisecond))
+	q.Enqueue(testQueueMessage(testTo1))
+	time.Sleep(50 * time.Millisecond)
+
+	d.mu.Lock()
+	closes := d.closes
+	d.mu.Unlock()
+	if closes != 1 {
+		t.Errorf("Idle connection should be closed, got %d closes", closes)
+	}
+	q.Shutdown(context.Background())
+}
+
+func TestQueueShutdownTimeout(t *testing.T) {
+	d := &fakeDialer{delay: 50 * time.Millisecond}
+	q := NewQueue(d)
+	q.Enqueue(testQueueMessage(testTo1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Invalid error, got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,62 @@
+package gomail
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// A Limiter limits the rate at which emails are sent.
+type Limiter interface {
+	// Wait blocks until the next email can be sent.
+	Wait() error
+}
+
+// NewRateLimiter returns a token bucket Limiter allowing n emails per interval
+// on average, with bursts of up to n emails. It is safe for concurrent use so
+// it can be shared by several connections. It panics if n or interval is not
+// positive.
+func NewRateLimiter(n int, interval time.Duration) Limiter {
+	if n <= 0 || interval <= 0 {
+		panic("gomail: NewRateLimiter needs a positive number of emails and interval")
+	}
+	return &rateLimiter{
+		rate:   float64(n) / float64(interval),
+		burst:  float64(n),
+		tokens: float64(n),
+		last:   now(),
+	}
+}
+
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per nanosecond
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (l *rateLimiter) Wait() error {
+	l.mu.Lock()
+	t := now()
+	if elapsed := t.Sub(l.last); elapsed > 0 {
+		l.tokens += float64(elapsed) * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = t
+	}
+	// Reserve a token even if the bucket is empty so concurrent callers are
+	// served in order.
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(math.Ceil(-l.tokens / l.rate))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		sleep(delay)
+	}
+	return nil
+}
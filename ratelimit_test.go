@@ -0,0 +1,101 @@
+package gomail
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	l := NewRateLimiter(2, time.Second)
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []time.Duration{500 * time.Millisecond, time.Second}
+	if !reflect.DeepEqual(delays, want) {
+		t.Errorf("Invalid delays, got %v, want %v", delays, want)
+	}
+}
+
+func TestRateLimiterInvalid(t *testing.T) {
+	tests := []struct {
+		n        int
+		interval time.Duration
+	}{
+		{0, time.Second},
+		{-1, time.Second},
+		{10, 0},
+		{10, -time.Second},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewRateLimiter(%d, %v) should panic", test.n, test.interval)
+				}
+			}()
+			NewRateLimiter(test.n, test.interval)
+		}()
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	start := now()
+	defer func(f func() time.Time) { now = f }(now)
+	current := start
+	now = func() time.Time { return current }
+
+	l := NewRateLimiter(10, time.Second)
+	for i := 0; i < 10; i++ {
+		l.Wait()
+	}
+	current = start.Add(time.Second)
+	for i := 0; i < 10; i++ {
+		l.Wait()
+	}
+	if len(delays) != 0 {
+		t.Errorf("The bucket should be refilled, got delays %v", delays)
+	}
+
+	l.Wait()
+	if want := []time.Duration{100 * time.Millisecond}; !reflect.DeepEqual(delays, want) {
+		t.Errorf("Invalid delays, got %v, want %v", delays, want)
+	}
+}
+
+type countLimiter int
+
+func (l *countLimiter) Wait() error {
+	*l++
+	return nil
+}
+
+func TestDialerLimiter(t *testing.T) {
+	l := new(countLimiter)
+	d := &Dialer{Host: testHost, Port: testPort, Limiter: l}
+	testSendMail(t, d, []string{
+		"Extension STARTTLS",
+		"StartTLS",
+		"Mail " + testFrom,
+		"Rcpt " + testTo1,
+		"Rcpt " + testTo2,
+		"Data",
+		"Write message",
+		"Close writer",
+		"Quit",
+	})
+	if *l != 1 {
+		t.Errorf("Limiter should be called once, got %d", *l)
+	}
+}
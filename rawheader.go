@@ -0,0 +1,53 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SetRawHeader sets a header field whose value is written as is, without
+// encoding or folding, for example a pre-encoded field or a Received or ARC
+// field that is already folded. It replaces the values set by SetHeader.
+//
+// The value must be folded with CRLF followed by a space or a tab: any other
+// CR or LF, and any other control character than tab, is rejected so the
+// field cannot inject other fields. The field name must be made of printable
+// ASCII characters other than the colon.
+func (m *Message) SetRawHeader(field, value string) error {
+	if err := checkRawHeader(field, value); err != nil {
+		return err
+	}
+	key := m.setFieldName(field)
+	m.header[key] = []string{value}
+	if m.rawFields == nil {
+		m.rawFields = make(map[string]bool)
+	}
+	m.rawFields[key] = true
+	return nil
+}
+
+func checkRawHeader(field, value string) error {
+	if field == "" {
+		return errors.New("gomail: empty header field name")
+	}
+	for i := 0; i < len(field); i++ {
+		if c := field[i]; c < 33 || c > 126 || c == ':' {
+			return fmt.Errorf("gomail: invalid character %q in header field name %q", c, field)
+		}
+	}
+
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\r':
+			if i+2 >= len(value) || value[i+1] != '\n' || (value[i+2] != ' ' && value[i+2] != '\t') {
+				return fmt.Errorf("gomail: the %s field must only contain folding line breaks", field)
+			}
+			i++
+		case c == '\n':
+			return fmt.Errorf("gomail: the %s field must only contain folding line breaks", field)
+		case c < 32 && c != '\t' || c == 127:
+			return fmt.Errorf("gomail: invalid control character %q in the %s field", c, field)
+		}
+	}
+	return nil
+}
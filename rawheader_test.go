@@ -0,0 +1,74 @@
+package gomail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSetRawHeader(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", testBody)
+
+	received := "from mail.example.com (mail.example.com [192.0.2.1])\r\n\tby mx.example.org with ESMTPS id 1234;\r\n\tWed, 25 Jun 2014 17:46:00 +0000"
+	if err := m.SetRawHeader("Received", received); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetRawHeader("Subject", "=?UTF-8?q?Caf=C3=A9?="); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetRawHeader("X-Long", strings.Repeat("a", 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.Clone().WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"\r\nReceived: " + received + "\r\n",
+		"\r\nSubject: =?UTF-8?q?Caf=C3=A9?=\r\n",
+		"\r\nX-Long: " + strings.Repeat("a", 100) + "\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q not found in:\n%s", want, got)
+		}
+	}
+
+	// SetHeader encodes the field again.
+	m.SetHeader("Subject", "=?UTF-8?q?Caf=C3=A9?= café")
+	buf.Reset()
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "\r\nSubject: =?UTF-8?q?Caf=C3=A9?= café") {
+		t.Errorf("The Subject field should be encoded, got:\n%s", buf.String())
+	}
+}
+
+func TestSetRawHeaderInvalid(t *testing.T) {
+	tests := []struct {
+		field, value string
+	}{
+		{"", "value"},
+		{"X Field", "value"},
+		{"X:Field", "value"},
+		{"X-Field", "value\r\nBcc: attacker@example.com"},
+		{"X-Field", "value\nBcc: attacker@example.com"},
+		{"X-Field", "value\r"},
+		{"X-Field", "value\r\n"},
+		{"X-Field", "value\x00"},
+	}
+	for _, test := range tests {
+		m := NewMessage()
+		if err := m.SetRawHeader(test.field, test.value); err == nil {
+			t.Errorf("SetRawHeader(%q, %q) should fail", test.field, test.value)
+		}
+		if len(m.GetHeader(test.field)) != 0 {
+			t.Errorf("SetRawHeader(%q, %q) should not set the field", test.field, test.value)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package gomail
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// A ReplyTracker generates a unique reply address for each email and matches
+// the inbound replies to the application entity the email was about, for
+// example to implement "reply to this email to comment" features.
+//
+// The reply addresses are plus-addressed variants of Address whose tag encodes
+// the entity and a signature, so no state needs to be kept and replies cannot
+// be forged for other entities.
+type ReplyTracker struct {
+	// Address is the address receiving the replies, for example
+	// "reply@example.com". Its mail server must deliver the plus-addressed
+	// variants, like "reply+tag@example.com", to it.
+	Address string
+	// Key is the secret key used to sign the tags.
+	Key []byte
+}
+
+// replyEncoding is case-insensitive since mail servers may change the case of
+// the local part.
+var replyEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+func (t *ReplyTracker) tag(entity string) string {
+	return replyEncoding.EncodeToString([]byte(entity)) + "-" + t.signature(entity)
+}
+
+func (t *ReplyTracker) signature(entity string) string {
+	mac := hmac.New(sha256.New, t.Key)
+	mac.Write([]byte(entity))
+	return replyEncoding.EncodeToString(mac.Sum(nil)[:5])
+}
+
+// entity returns the entity encoded in tag if its signature is valid.
+func (t *ReplyTracker) entity(tag string) (string, bool) {
+	tag = strings.ToLower(tag)
+	i := strings.LastIndexByte(tag, '-')
+	if i == -1 {
+		return "", false
+	}
+	b, err := replyEncoding.DecodeString(tag[:i])
+	if err != nil {
+		return "", false
+	}
+	entity := string(b)
+	if !hmac.Equal([]byte(tag[i+1:]), []byte(t.signature(entity))) {
+		return "", false
+	}
+	return entity, true
+}
+
+// ReplyAddress returns the reply address of the given entity. It returns an
+// error if the entity is too long to fit in the 64 octets of a local part.
+func (t *ReplyTracker) ReplyAddress(entity string) (string, error) {
+	addr := PlusAddress(t.Address, t.tag(entity))
+	if local, _ := splitAddress(addr); len(local) > 64 {
+		return "", fmt.Errorf("gomail: the entity %q is too long for a reply address", entity)
+	}
+	return addr, nil
+}
+
+// Track sets the Reply-To field of m to the reply address of the given entity.
+// It also sets a Message-ID identifying the entity, so that the replies sent
+// by clients ignoring the Reply-To field can still be matched with their
+// In-Reply-To or References field.
+func (t *ReplyTracker) Track(m *Message, entity string) error {
+	addr, err := t.ReplyAddress(entity)
+	if err != nil {
+		return err
+	}
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return err
+	}
+	m.SetHeader("Reply-To", addr)
+	m.SetHeader("Message-ID", "<"+t.tag(entity)+"."+hex.EncodeToString(b[:])+"@"+m.messageIDDomainOrDefault()+">")
+	return nil
+}
+
+// Match returns the entity of a reply address.
+func (t *ReplyTracker) Match(address string) (string, bool) {
+	base, tag := ParsePlusAddress(address)
+	if tag == "" || !strings.EqualFold(base, t.Address) {
+		return "", false
+	}
+	return t.entity(tag)
+}
+
+// MatchReply returns the entity an inbound reply is about, using its
+// recipients and then its In-Reply-To and References fields.
+func (t *ReplyTracker) MatchReply(h mail.Header) (string, bool) {
+	for _, field := range []string{"To", "Cc", "Delivered-To"} {
+		list, err := h.AddressList(field)
+		if err != nil {
+			continue
+		}
+		for _, addr := range list {
+			if entity, ok := t.Match(addr.Address); ok {
+				return entity, true
+			}
+		}
+	}
+
+	for _, field := range []string{"In-Reply-To", "References"} {
+		for _, id := range strings.Fields(h.Get(field)) {
+			id = strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">")
+			local, _ := splitAddress(id)
+			if i := strings.IndexByte(local, '.'); i >= 0 {
+				if entity, ok := t.entity(local[:i]); ok {
+					return entity, true
+				}
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,63 @@
+package gomail
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestReplyTracker(t *testing.T) {
+	rt := &ReplyTracker{Address: "reply@example.com", Key: []byte("secret")}
+
+	addr, err := rt.ReplyAddress("issue/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(addr, "reply+") || !strings.HasSuffix(addr, "@example.com") {
+		t.Errorf("Invalid reply address %q", addr)
+	}
+	if entity, ok := rt.Match(strings.ToUpper(addr)); !ok || entity != "issue/42" {
+		t.Errorf("Match(%q) = %q, %v", addr, entity, ok)
+	}
+
+	other := &ReplyTracker{Address: "reply@example.com", Key: []byte("other")}
+	forged, _ := other.ReplyAddress("issue/43")
+	for _, a := range []string{forged, "reply@example.com", "reply+abc@example.com", strings.Replace(addr, "reply+", "other+", 1)} {
+		if entity, ok := rt.Match(a); ok {
+			t.Errorf("Match(%q) should fail, got %q", a, entity)
+		}
+	}
+
+	if _, err := rt.ReplyAddress(strings.Repeat("a", 40)); err == nil {
+		t.Error("ReplyAddress should fail with a long entity")
+	}
+}
+
+func TestReplyTrackerMatchReply(t *testing.T) {
+	rt := &ReplyTracker{Address: "reply@example.com", Key: []byte("secret")}
+	m := NewMessage()
+	m.SetHeader("From", "notifications@example.com")
+	if err := rt.Track(m, "issue/42"); err != nil {
+		t.Fatal(err)
+	}
+	replyTo := m.GetHeader("Reply-To")[0]
+	msgID := m.GetHeader("Message-ID")[0]
+	if !strings.HasSuffix(msgID, "@example.com>") {
+		t.Errorf("Invalid Message-ID %q", msgID)
+	}
+
+	tests := []mail.Header{
+		{"To": {"Reply <" + replyTo + ">"}},
+		{"To": {"bob@example.org"}, "Cc": {replyTo}},
+		{"To": {"notifications@example.com"}, "In-Reply-To": {msgID}},
+		{"To": {"notifications@example.com"}, "References": {"<1@example.org> " + msgID}},
+	}
+	for _, h := range tests {
+		if entity, ok := rt.MatchReply(h); !ok || entity != "issue/42" {
+			t.Errorf("MatchReply(%v) = %q, %v", h, entity, ok)
+		}
+	}
+	if _, ok := rt.MatchReply(mail.Header{"To": {"notifications@example.com"}, "In-Reply-To": {"<a.b@example.com>"}}); ok {
+		t.Error("MatchReply should fail for an unknown reply")
+	}
+}
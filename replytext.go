@@ -0,0 +1,97 @@
+package gomail
+
+import (
+	"html"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+var (
+	// replyAttribution matches the line introducing the quoted email, like
+	// "On Mon, Jan 2, 2006 at 3:04 PM Bob <bob@example.com> wrote:".
+	replyAttribution = regexp.MustCompile(`(?i)^(on\b.+\bwrote|le\b.+\ba écrit|am\b.+\bschrieb|el\b.+\bescribió)\s*:\s*$`)
+	// replySeparator matches the separators inserted by Outlook and others
+	// before the quoted email.
+	replySeparator = regexp.MustCompile(`(?i)^(-{2,}\s*original message\s*-{2,}|_{10,}|-{2,}\s*forwarded message\s*-{2,})$`)
+	// replyHeaderFrom and replyHeader match the header block Outlook writes
+	// before the quoted email, like "From: Bob" followed by "Sent: Monday".
+	replyHeaderFrom = regexp.MustCompile(`(?i)^\*?(from|de|von)\s*:\*?\s`)
+	replyHeader     = regexp.MustCompile(`(?i)^\*?(sent|date|to|subject|envoyé|gesendet)\s*:`)
+	// replySignature matches the signature delimiter and the signatures
+	// added by mobile clients.
+	replySignature = regexp.MustCompile(`(?i)^(--\s*|sent from my\b.*|get outlook for\b.*)$`)
+)
+
+// ReplyText returns the new text of the plain text body of a reply: the
+// quoted lines, starting with ">", are removed and the text is cut before the
+// quoted original email, introduced by an "On ... wrote:" line or an Outlook
+// separator, and before the signature.
+func ReplyText(text string) string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	lines := strings.Split(text, "\n")
+	var kept []string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if isReplyCut(lines, i, trimmed) {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		kept = append(kept, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// isReplyCut reports whether the reply text ends before the line i.
+func isReplyCut(lines []string, i int, line string) bool {
+	if replySeparator.MatchString(line) || replySignature.MatchString(line) || replyAttribution.MatchString(line) {
+		return true
+	}
+	// The attribution line is often wrapped.
+	if i+1 < len(lines) && strings.HasPrefix(strings.ToLower(line), "on ") &&
+		replyAttribution.MatchString(line+" "+strings.TrimSpace(lines[i+1])) {
+		return true
+	}
+	if replyHeaderFrom.MatchString(line) {
+		for j := i + 1; j < len(lines) && j <= i+3; j++ {
+			if replyHeader.MatchString(strings.TrimSpace(lines[j])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	htmlQuote = regexp.MustCompile(`(?is)<blockquote\b.*</blockquote>`)
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6])>`)
+)
+
+// ParseReplyText parses an inbound reply and returns its new text, see
+// ReplyText. The text/plain body is used, or else the text of the text/html
+// body without its quoted blocks.
+func ParseReplyText(r io.Reader) (string, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	p := parsePreview(raw)
+	if p.Err != nil {
+		return "", p.Err
+	}
+	text := p.Text
+	if text == "" && p.HTML != "" {
+		text = htmlText(htmlQuote.ReplaceAllString(p.HTML, ""))
+	}
+	return ReplyText(text), nil
+}
+
+// htmlText returns the text of an HTML document, keeping the line breaks.
+func htmlText(s string) string {
+	s = htmlIgnored.ReplaceAllString(s, "")
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+}
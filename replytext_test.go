@@ -0,0 +1,99 @@
+package gomail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplyText(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{
+			"gmail",
+			"Sounds good.\r\n\r\nOn Mon, Jun 23, 2014 at 5:46 PM Bob <bob@example.com> wrote:\r\n> Can we meet tomorrow?\r\n",
+			"Sounds good.",
+		},
+		{
+			"wrapped attribution",
+			"Sounds good.\n\nOn Mon, Jun 23, 2014 at 5:46 PM, Bob Smith <\nbob@example.com> wrote:\n> Can we meet tomorrow?\n",
+			"Sounds good.",
+		},
+		{
+			"french",
+			"Parfait.\n\nLe lun. 23 juin 2014 à 17:46, Bob <bob@example.com> a écrit :\n> Demain ?\n",
+			"Parfait.",
+		},
+		{
+			"outlook separator",
+			"Sounds good.\n\n-----Original Message-----\nFrom: Bob\nSent: Monday\n\nCan we meet tomorrow?\n",
+			"Sounds good.",
+		},
+		{
+			"outlook header",
+			"Sounds good.\n\n________________________________\nFrom: Bob <bob@example.com>\nSent: Monday, June 23, 2014 5:46 PM\nTo: Alice\n\nCan we meet tomorrow?\n",
+			"Sounds good.",
+		},
+		{
+			"outlook header without separator",
+			"Sounds good.\n\nFrom: Bob <bob@example.com>\nSent: Monday, June 23, 2014 5:46 PM\n\nCan we meet tomorrow?\n",
+			"Sounds good.",
+		},
+		{
+			"signature",
+			"Sounds good.\n\n-- \nAlice\nACME Inc.\n",
+			"Sounds good.",
+		},
+		{
+			"mobile signature",
+			"Sounds good.\n\nSent from my iPhone\n",
+			"Sounds good.",
+		},
+		{
+			"inline reply",
+			"> Can we meet tomorrow?\nYes.\n> At 10?\nAt 11 rather.\n",
+			"Yes.\nAt 11 rather.",
+		},
+		{
+			"from in text",
+			"From: the team, thanks!\n\nAlice\n",
+			"From: the team, thanks!\n\nAlice",
+		},
+	}
+	for _, test := range tests {
+		if got := ReplyText(test.text); got != test.want {
+			t.Errorf("%s: invalid reply text, got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseReplyText(t *testing.T) {
+	plain := "From: bob@example.com\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Caf=C3=A9 at 10.\r\n" +
+		"\r\n" +
+		"On Mon, Jun 23, 2014 at 5:46 PM Alice <alice@example.com> wrote:\r\n" +
+		"> Coffee?\r\n"
+	text, err := ParseReplyText(strings.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Café at 10."; text != want {
+		t.Errorf("Invalid text, got %q, want %q", text, want)
+	}
+
+	html := "From: bob@example.com\r\n" +
+		"Content-Type: text/html; charset=UTF-8\r\n" +
+		"\r\n" +
+		"<html><body><div>Yes &amp; thanks.</div><div>Bob</div>" +
+		"<blockquote><div>Coffee?</div></blockquote></body></html>\r\n"
+	text, err = ParseReplyText(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Yes & thanks.\nBob"; text != want {
+		t.Errorf("Invalid text, got %q, want %q", text, want)
+	}
+}
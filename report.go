@@ -0,0 +1,192 @@
+package gomail
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// A DeliveryReport is a delivery status notification, as defined in RFC 3464,
+// generated by a mail server for the sender of an email it could not deliver,
+// or only with delay. It is the opposite of ParseBounce.
+type DeliveryReport struct {
+	// ReportingMTA is the host name of the server generating the report.
+	ReportingMTA string
+	// EnvelopeID is the envelope identifier of the original email, see
+	// DSN.EnvelopeID, if any.
+	EnvelopeID string
+	// ArrivalDate is the time the original email was received, if not zero.
+	ArrivalDate time.Time
+	// Recipients are the recipients the report is about. Their Action is
+	// "failed", "delayed", "delivered", "relayed" or "expanded".
+	Recipients []*BounceRecipient
+	// Original is the original email, or only its header. If HeadersOnly is
+	// true or Original has no body, only the header is returned in the
+	// report.
+	Original    []byte
+	HeadersOnly bool
+}
+
+// SetDeliveryReport makes the message a multipart/report delivery status
+// notification: its body, or a generic text if it has none, is followed by the
+// message/delivery-status part describing r and by the original email. The
+// From, To and Subject fields must still be set. The attachments set before
+// are removed since a report has no other parts.
+//
+// The notifications must be sent with an empty envelope sender, see
+// SetEnvelopeFrom, so they never trigger other notifications.
+func (m *Message) SetDeliveryReport(r *DeliveryReport) {
+	var status bytes.Buffer
+	writeReportField(&status, "Reporting-MTA", "dns; "+r.ReportingMTA)
+	if r.EnvelopeID != "" {
+		writeReportField(&status, "Original-Envelope-Id", r.EnvelopeID)
+	}
+	if !r.ArrivalDate.IsZero() {
+		writeReportField(&status, "Arrival-Date", r.ArrivalDate.Format(time.RFC1123Z))
+	}
+	var text strings.Builder
+	text.WriteString("This is an automatically generated delivery status notification.\n")
+	for _, rcpt := range r.Recipients {
+		status.WriteString("\r\n")
+		writeReportField(&status, "Final-Recipient", "rfc822; "+rcpt.Address)
+		writeReportField(&status, "Action", rcpt.Action)
+		writeReportField(&status, "Status", rcpt.Status)
+		if rcpt.Diagnostic != "" {
+			writeReportField(&status, "Diagnostic-Code", "smtp; "+rcpt.Diagnostic)
+		}
+
+		text.WriteString("\n" + rcpt.Address + ": " + rcpt.Action + " (" + rcpt.Status + ")")
+		if rcpt.Diagnostic != "" {
+			text.WriteString("\n    " + rcpt.Diagnostic)
+		}
+		text.WriteString("\n")
+	}
+
+	if len(m.parts) == 0 {
+		m.SetBody("text/plain", text.String())
+	}
+	m.setReport("delivery-status", "message/delivery-status", status.Bytes(), r.Original, r.HeadersOnly)
+}
+
+// A DispositionNotification is a message disposition notification, or read
+// receipt, as defined in RFC 8098, sent by a mail client to the address
+// requested with RequestMDN.
+type DispositionNotification struct {
+	// ReportingUA is the name of the mail client generating the
+	// notification, for example "mail.example.com; MyClient 1.0", if any.
+	ReportingUA string
+	// OriginalRecipient is the original recipient of the email, as given by
+	// the Original-Recipient field, if any.
+	OriginalRecipient string
+	// FinalRecipient is the address of the recipient sending the
+	// notification.
+	FinalRecipient string
+	// OriginalMessageID is the Message-ID of the original email, with its
+	// angle brackets.
+	OriginalMessageID string
+	// Disposition is the disposition of the email. If empty, it is
+	// "manual-action/MDN-sent-manually; displayed".
+	Disposition string
+	// Original is the original email or only its header. Only its header is
+	// returned in the notification.
+	Original []byte
+}
+
+// SetDispositionNotification makes the message a multipart/report message
+// disposition notification: its body, or a generic text if it has none, is
+// followed by the message/disposition-notification part describing n and by
+// the header of the original email. The From, To and Subject fields must still
+// be set. The attachments set before are removed since a report has no other
+// parts.
+func (m *Message) SetDispositionNotification(n *DispositionNotification) {
+	disposition := n.Disposition
+	if disposition == "" {
+		disposition = "manual-action/MDN-sent-manually; displayed"
+	}
+
+	var report bytes.Buffer
+	if n.ReportingUA != "" {
+		writeReportField(&report, "Reporting-UA", n.ReportingUA)
+	}
+	if n.OriginalRecipient != "" {
+		writeReportField(&report, "Original-Recipient", "rfc822; "+n.OriginalRecipient)
+	}
+	writeReportField(&report, "Final-Recipient", "rfc822; "+n.FinalRecipient)
+	if n.OriginalMessageID != "" {
+		writeReportField(&report, "Original-Message-ID", n.OriginalMessageID)
+	}
+	writeReportField(&report, "Disposition", disposition)
+
+	if len(m.parts) == 0 {
+		m.SetBody("text/plain", "The email sent to "+n.FinalRecipient+" "+dispositionText(disposition)+"\n")
+	}
+	m.setReport("disposition-notification", "message/disposition-notification", report.Bytes(), n.Original, true)
+}
+
+// RequestMDN requests a message disposition notification, or read receipt, to
+// be sent to the given address when the email is displayed, by setting the
+// Disposition-Notification-To field. The mail clients are free to ignore it.
+func (m *Message) RequestMDN(address string) {
+	m.SetHeader("Disposition-Notification-To", address)
+}
+
+// dispositionText describes the disposition type of a Disposition field, for
+// example "displayed" in "manual-action/MDN-sent-manually; displayed".
+func dispositionText(disposition string) string {
+	typ := disposition
+	if i := strings.LastIndexByte(typ, ';'); i >= 0 {
+		typ = typ[i+1:]
+	}
+	if i := strings.IndexByte(typ, '/'); i >= 0 {
+		typ = typ[:i]
+	}
+	switch typ = strings.ToLower(strings.TrimSpace(typ)); typ {
+	case "displayed":
+		return "was displayed. This is no guarantee that it was read or understood."
+	case "deleted":
+		return "was deleted. It may or may not have been seen."
+	case "dispatched":
+		return "was printed, faxed or forwarded without necessarily being displayed."
+	case "processed":
+		return "was processed without being displayed."
+	}
+	return "was given the disposition " + stripControlBreaks(disposition) + "."
+}
+
+// writeReportField writes a field of a report part.
+func writeReportField(w *bytes.Buffer, field, value string) {
+	w.WriteString(field + ": " + stripControlBreaks(value) + "\r\n")
+}
+
+// setReport sets the report type of the message and replaces its attachments
+// with the report and the original email, or its header, as parts without a
+// Content-Disposition field: RFC 6522 requires the report to be the second
+// part.
+func (m *Message) setReport(reportType, contentType string, report, original []byte, headersOnly bool) {
+	m.reportType = reportType
+	m.attachments = nil
+	m.attachReportPart("report", contentType, report)
+	if len(original) == 0 {
+		return
+	}
+
+	original = toCRLF(original)
+	if i := bytes.Index(original, []byte("\r\n\r\n")); i >= 0 && (headersOnly || i+4 == len(original)) {
+		m.attachReportPart("original", "text/rfc822-headers", original[:i+2])
+	} else if i == -1 {
+		m.attachReportPart("original", "text/rfc822-headers", original)
+	} else {
+		m.attachReportPart("original", "message/rfc822", original)
+	}
+}
+
+func (m *Message) attachReportPart(name, contentType string, content []byte) {
+	m.Attach(name,
+		SetHeader(map[string][]string{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {string(identityEncoding(content))},
+			"Content-Disposition":       nil,
+		}),
+		setContent(content),
+	)
+}
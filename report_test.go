@@ -0,0 +1,133 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testOriginal = "From: from@example.com\r\n" +
+	"To: to@example.com\r\n" +
+	"Message-ID: <1234@example.com>\r\n" +
+	"Subject: Hello\r\n" +
+	"\r\n" +
+	"Hello!\r\n"
+
+func TestDeliveryReport(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", "MAILER-DAEMON@example.com")
+	m.SetHeader("To", "from@example.com")
+	m.SetHeader("Subject", "Undelivered Mail Returned to Sender")
+	m.SetEnvelopeFrom("")
+	m.SetDeliveryReport(&DeliveryReport{
+		ReportingMTA: "mail.example.com",
+		EnvelopeID:   "QQ314159",
+		ArrivalDate:  time.Date(2014, 6, 25, 17, 46, 0, 0, time.UTC),
+		Recipients: []*BounceRecipient{
+			{Address: "to@example.com", Action: "failed", Status: "5.1.1", Diagnostic: "550 5.1.1 User unknown"},
+		},
+		Original: []byte(testOriginal),
+	})
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"Content-Type: multipart/report; report-type=delivery-status;\r\n boundary=",
+		"to@example.com: failed (5.1.1)\r\n    550 5.1.1 User unknown\r\n",
+		"Content-Transfer-Encoding: 7bit\r\nContent-Type: message/delivery-status\r\n\r\n" +
+			"Reporting-MTA: dns; mail.example.com\r\n" +
+			"Original-Envelope-Id: QQ314159\r\n" +
+			"Arrival-Date: Wed, 25 Jun 2014 17:46:00 +0000\r\n" +
+			"\r\n" +
+			"Final-Recipient: rfc822; to@example.com\r\n" +
+			"Action: failed\r\n" +
+			"Status: 5.1.1\r\n" +
+			"Diagnostic-Code: smtp; 550 5.1.1 User unknown\r\n",
+		"Content-Type: message/rfc822\r\n\r\n" + testOriginal,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Content-Disposition") {
+		t.Errorf("The report parts should have no Content-Disposition:\n%s", got)
+	}
+
+	b, err := ParseBounce(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Type != BounceHard || b.MessageID != "1234@example.com" || len(b.Recipients) != 1 ||
+		b.Recipients[0].Address != "to@example.com" || b.Recipients[0].Diagnostic != "550 5.1.1 User unknown" {
+		t.Errorf("Invalid bounce, got %+v", b)
+	}
+}
+
+func TestDispositionNotification(t *testing.T) {
+	original := NewMessage()
+	original.RequestMDN("from@example.com")
+	if got := original.GetHeader("Disposition-Notification-To"); len(got) != 1 || got[0] != "from@example.com" {
+		t.Errorf("Invalid Disposition-Notification-To, got %q", got)
+	}
+
+	m := NewMessage()
+	m.SetHeader("From", "to@example.com")
+	m.SetHeader("To", "from@example.com")
+	m.SetHeader("Subject", "Read: Hello")
+	m.Attach("notes.txt", SetCopyFunc(func(io.Writer) error { return nil }))
+	m.SetDispositionNotification(&DispositionNotification{
+		ReportingUA:       "mail.example.com; gomail",
+		FinalRecipient:    "to@example.com",
+		OriginalMessageID: "<1234@example.com>",
+		Original:          []byte(testOriginal),
+	})
+	if len(m.attachments) != 2 {
+		t.Errorf("The previous attachments should be replaced by the report, got %d parts", len(m.attachments))
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"Content-Type: multipart/report; report-type=disposition-notification;\r\n boundary=",
+		"The email sent to to@example.com was displayed.",
+		"Content-Type: message/disposition-notification\r\n\r\n" +
+			"Reporting-UA: mail.example.com; gomail\r\n" +
+			"Final-Recipient: rfc822; to@example.com\r\n" +
+			"Original-Message-ID: <1234@example.com>\r\n" +
+			"Disposition: manual-action/MDN-sent-manually; displayed\r\n",
+		"Content-Type: text/rfc822-headers\r\n\r\n" + testOriginal[:strings.Index(testOriginal, "\r\n\r\n")+2] + "\r\n--",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Hello!") {
+		t.Errorf("Only the header of the original email should be returned:\n%s", got)
+	}
+}
+
+func TestDispositionText(t *testing.T) {
+	tests := []struct {
+		disposition string
+		want        string
+	}{
+		{"manual-action/MDN-sent-manually; displayed", "was displayed. This is no guarantee that it was read or understood."},
+		{"automatic-action/MDN-sent-automatically; deleted", "was deleted. It may or may not have been seen."},
+		{"automatic-action/MDN-sent-automatically; Dispatched", "was printed, faxed or forwarded without necessarily being displayed."},
+		{"automatic-action/MDN-sent-automatically; processed/error", "was processed without being displayed."},
+		{"manual-action/MDN-sent-manually; unknown", "was given the disposition manual-action/MDN-sent-manually; unknown."},
+	}
+	for _, test := range tests {
+		if got := dispositionText(test.disposition); got != test.want {
+			t.Errorf("dispositionText(%q) = %q, want %q", test.disposition, got, test.want)
+		}
+	}
+}
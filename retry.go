@@ -0,0 +1,128 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/textproto"
+	"time"
+)
+
+// A RetryPolicy defines how Dialer.DialAndSend retries to send emails after a
+// temporary failure.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It defaults to one
+	// second.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between two attempts. It defaults to one
+	// minute.
+	MaxBackoff time.Duration
+	// Multiplier is the factor applied to the delay after each retry. It
+	// defaults to 2.
+	Multiplier float64
+	// Jitter is the fraction of the delay that is randomized to avoid
+	// synchronized retries. For example 0.2 makes the delay vary by up to 20%
+	// in both directions.
+	Jitter float64
+	// Retryable reports whether an error is worth a retry. It defaults to
+	// IsTemporary.
+	Retryable func(err error) bool
+	// OnRetry, if set, is called before each retry with the number of the
+	// failed attempt, its error and the delay before the next attempt. It can
+	// be used for logging.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// SetRetryPolicy is a message setting to retry the email with p instead of the
+// RetryPolicy of the Dialer or the Retry setting of the Queue. A nil policy
+// restores the default.
+func SetRetryPolicy(p *RetryPolicy) MessageSetting {
+	return func(m *Message) {
+		m.retry = p
+	}
+}
+
+// IsTemporary reports whether err is a temporary failure: a network error, an
+// SMTP reply with a 4xx code or a temporary APIError.
+func IsTemporary(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var serr *SendError
+	if errors.As(err, &serr) {
+		if serr.Sent {
+			return false
+		}
+		for _, r := range serr.Rejected {
+			if r.Code < 400 || r.Code >= 500 {
+				return false
+			}
+		}
+		return true
+	}
+
+	var aerr *APIError
+	if errors.As(err, &aerr) {
+		return aerr.Temporary()
+	}
+
+	var perr *textproto.Error
+	if errors.As(err, &perr) {
+		return perr.Code >= 400 && perr.Code < 500
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// next returns the delay before the next attempt after the given failed
+// attempt, or false if the emails should not be retried.
+func (p *RetryPolicy) next(attempt int, err error) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+		return 0, false
+	}
+
+	delay, max, mult := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if delay <= 0 {
+		delay = time.Second
+	}
+	if max <= 0 {
+		max = time.Minute
+	}
+	if mult < 1 {
+		mult = 2
+	}
+
+	for i := 1; i < attempt && delay < max; i++ {
+		delay = time.Duration(float64(delay) * mult)
+	}
+	if delay > max {
+		delay = max
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration((randFloat64()*2 - 1) * p.Jitter * float64(delay))
+	}
+
+	return delay, true
+}
+
+// retryable reports whether err is worth a retry according to the policy.
+func (p *RetryPolicy) retryable(err error) bool {
+	if p == nil || p.Retryable == nil {
+		return IsTemporary(err)
+	}
+	return p.Retryable(err)
+}
+
+// Stubbed out for tests.
+var (
+	sleep       = time.Sleep
+	randFloat64 = rand.Float64
+)
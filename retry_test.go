@@ -0,0 +1,122 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("gomail: invalid address"), false},
+		{io.EOF, true},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{&textproto.Error{Code: 421, Msg: "Try again later"}, true},
+		{&textproto.Error{Code: 535, Msg: "Authentication failed"}, false},
+		{fmt.Errorf("gomail: could not send email 1: %w", &textproto.Error{Code: 451}), true},
+		{&SendError{Rejected: []*RecipientError{{Code: 450}}}, true},
+		{&SendError{Rejected: []*RecipientError{{Code: 450}, {Code: 550}}}, false},
+		{&SendError{Rejected: []*RecipientError{{Code: 450}}, Sent: true}, false},
+	}
+
+	for _, test := range tests {
+		if got := IsTemporary(test.err); got != test.want {
+			t.Errorf("IsTemporary(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     3,
+	}
+	err := &textproto.Error{Code: 421}
+
+	var got []time.Duration
+	for attempt := 1; ; attempt++ {
+		delay, ok := p.next(attempt, err)
+		if !ok {
+			break
+		}
+		got = append(got, delay)
+	}
+	want := []time.Duration{
+		100 * time.Millisecond,
+		300 * time.Millisecond,
+		900 * time.Millisecond,
+		time.Second,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid delays, got %v, want %v", got, want)
+	}
+
+	if _, ok := p.next(1, &textproto.Error{Code: 550}); ok {
+		t.Error("Permanent errors should not be retried")
+	}
+
+	defer func(f func() float64) { randFloat64 = f }(randFloat64)
+	randFloat64 = func() float64 { return 1 }
+	p.Jitter = 0.5
+	if delay, _ := p.next(1, err); delay != 150*time.Millisecond {
+		t.Errorf("Invalid delay with jitter, got %v, want %v", delay, 150*time.Millisecond)
+	}
+}
+
+func TestDialerRetry(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	var retries []int
+	d := &Dialer{
+		Host: testHost,
+		Port: testPort,
+		RetryPolicy: &RetryPolicy{
+			MaxAttempts: 3,
+			OnRetry: func(attempt int, err error, delay time.Duration) {
+				retries = append(retries, attempt)
+			},
+		},
+	}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Quit",
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+		},
+		mailErr: &textproto.Error{Code: 421, Msg: "Too many connections"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(delays, []time.Duration{time.Second}) {
+		t.Errorf("Invalid delays, got %v", delays)
+	}
+	if !reflect.DeepEqual(retries, []int{1}) {
+		t.Errorf("Invalid retries, got %v", retries)
+	}
+}
@@ -0,0 +1,107 @@
+package gomail
+
+import (
+	"container/heap"
+	"time"
+)
+
+// SetSendTime sets the time at which a Queue sends the email. Emails with a
+// send time in the past are sent immediately.
+func (m *Message) SetSendTime(t time.Time) {
+	m.sendAt = t
+}
+
+// schedule holds the emails of a queue waiting for their send time, earliest
+// first.
+type schedule []*queueItem
+
+func (s schedule) Len() int           { return len(s) }
+func (s schedule) Less(i, j int) bool { return s[i].at.Before(s[j].at) }
+func (s schedule) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (s *schedule) Push(x interface{}) {
+	*s = append(*s, x.(*queueItem))
+}
+
+func (s *schedule) Pop() interface{} {
+	old := *s
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*s = old[:len(old)-1]
+	return item
+}
+
+// schedule adds an email to the emails waiting for their send time.
+func (q *Queue) schedule(item *queueItem) {
+	q.schedMu.Lock()
+	heap.Push(&q.sched, item)
+	q.schedMu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runSchedule enqueues the scheduled emails when they are due, until the queue
+// is shut down.
+func (q *Queue) runSchedule() {
+	defer close(q.schedDone)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		var due []*queueItem
+		q.schedMu.Lock()
+		for len(q.sched) > 0 && !q.sched[0].at.After(now()) {
+			due = append(due, heap.Pop(&q.sched).(*queueItem))
+		}
+		var next time.Duration = -1
+		if len(q.sched) > 0 {
+			next = q.sched[0].at.Sub(now())
+		}
+		q.schedMu.Unlock()
+
+		for _, item := range due {
+			select {
+			case q.ch <- item:
+			case <-q.ctx.Done():
+				return
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next >= 0 {
+			timer.Reset(next)
+		}
+
+		select {
+		case <-q.wake:
+		case <-timer.C:
+		case <-q.stop:
+			q.dropScheduled()
+			return
+		}
+	}
+}
+
+// dropScheduled reports the scheduled emails that will not be sent by this
+// queue. The stored emails are kept and will be sent by the next queue.
+func (q *Queue) dropScheduled() {
+	q.schedMu.Lock()
+	items := q.sched
+	q.sched = nil
+	q.schedMu.Unlock()
+
+	for _, item := range items {
+		q.release(item)
+		if item.id == "" {
+			q.reportError(item.m, ErrQueueClosed)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package gomail
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func useRealClock() func() {
+	f := now
+	now = time.Now
+	return func() { now = f }
+}
+
+func waitSent(t *testing.T, d *fakeDialer, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		d.mu.Lock()
+		sent := len(d.sent)
+		d.mu.Unlock()
+		if sent >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for %d emails, got %d", n, sent)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueueEnqueueAt(t *testing.T) {
+	defer useRealClock()()
+
+	d := &fakeDialer{}
+	q := NewQueue(d)
+
+	later := testQueueMessage(testTo1)
+	at := time.Now().Add(50 * time.Millisecond)
+	if err := q.EnqueueAt(at, later); err != nil {
+		t.Fatal(err)
+	}
+	m := testQueueMessage(testTo2)
+	m.SetSendTime(time.Now().Add(-time.Hour))
+	if err := q.Enqueue(m); err != nil {
+		t.Fatal(err)
+	}
+
+	waitSent(t, d, 2)
+	if time.Now().Before(at) {
+		t.Error("The scheduled email has been sent too early")
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.sent[0] != testTo2 || d.sent[1] != testTo1 {
+		t.Errorf("Invalid order, got %v", d.sent)
+	}
+	if got, want := later.GetHeader("Date"), []string{later.FormatDate(at)}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("Invalid Date header, got %v, want %v", got, want)
+	}
+	if got := m.GetHeader("Date"); len(got) != 0 {
+		t.Errorf("Date header set on an email sent immediately: %v", got)
+	}
+}
+
+func TestQueueScheduleOrder(t *testing.T) {
+	defer useRealClock()()
+
+	d := &fakeDialer{}
+	q := NewQueue(d)
+	start := time.Now()
+	for i, to := range []string{testTo1, testTo2, testFrom} {
+		m := testQueueMessage(to)
+		m.SetSendTime(start.Add(time.Duration(60-20*i) * time.Millisecond))
+		if err := q.Enqueue(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitSent(t, d, 3)
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d.sent[0] != testFrom || d.sent[1] != testTo2 || d.sent[2] != testTo1 {
+		t.Errorf("Invalid order, got %v", d.sent)
+	}
+}
+
+func TestQueueShutdownScheduled(t *testing.T) {
+	defer useRealClock()()
+
+	var errs []error
+	d := &fakeDialer{}
+	q := NewQueue(d, OnError(func(m *Message, err error) {
+		errs = append(errs, err)
+	}))
+	if err := q.EnqueueAt(time.Now().Add(time.Hour), testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.sent) != 0 {
+		t.Errorf("Invalid number of emails sent, got %d, want 0", len(d.sent))
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrQueueClosed) {
+		t.Errorf("Invalid errors, got %v, want [%v]", errs, ErrQueueClosed)
+	}
+}
+
+func TestQueueSpoolScheduled(t *testing.T) {
+	defer useRealClock()()
+
+	s, cleanup := testDirStore(t)
+	defer cleanup()
+
+	d := &fakeDialer{}
+	q := NewQueue(d, Spool(s))
+	at := time.Now().Add(100 * time.Millisecond)
+	if err := q.EnqueueAt(at, testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.sent) != 0 {
+		t.Fatalf("Invalid number of emails sent, got %d, want 0", len(d.sent))
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].SendAt.Equal(at) {
+		t.Fatalf("Invalid stored emails, got %+v", list)
+	}
+
+	// The next queue sends the email at its send time.
+	q = NewQueue(d, Spool(s))
+	waitSent(t, d, 1)
+	if time.Now().Before(at) {
+		t.Error("The scheduled email has been sent too early")
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := s.List(); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 0 {
+		t.Errorf("Invalid number of stored emails, got %d, want 0", len(list))
+	}
+}
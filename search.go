@@ -0,0 +1,247 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// An ArchiveIndex is a full-text index of the emails of an Archive, used by
+// Archive.Search. MemoryIndex is a simple implementation, other ones can
+// for example use a search engine.
+type ArchiveIndex interface {
+	// Add indexes an archived email. It can be called concurrently.
+	Add(doc *ArchiveDocument) error
+	// Search returns the IDs of the emails matching q.
+	Search(q *SearchQuery) ([]string, error)
+}
+
+// An ArchiveDocument is the searchable content of an archived email.
+type ArchiveDocument struct {
+	Email *ArchivedEmail
+	// Subject is the decoded Subject field.
+	Subject string
+	// Body is the text body of the email, or its HTML body without the tags
+	// if it has no text body.
+	Body string
+}
+
+// A SearchQuery selects archived emails. The emails must match all the
+// non-zero fields.
+type SearchQuery struct {
+	// Recipient is an envelope recipient of the email, compared
+	// case-insensitively.
+	Recipient string
+	// Subject and Body are words that must all appear in the subject and in
+	// the body of the email, in any order and case.
+	Subject string
+	Body    string
+	// Since and Until select the emails archived in [Since, Until).
+	Since time.Time
+	Until time.Time
+}
+
+// Indexed is an archive setting to add the archived emails to idx so they can
+// be searched with Archive.Search.
+func Indexed(idx ArchiveIndex) ArchiveSetting {
+	return func(a *Archive) {
+		a.index = idx
+	}
+}
+
+// Search returns the archived emails matching q, oldest first. The archive
+// must have been opened with the Indexed setting.
+func (a *Archive) Search(q *SearchQuery) ([]*ArchivedEmail, error) {
+	if a.index == nil {
+		return nil, errors.New("gomail: the archive has no search index")
+	}
+	ids, err := a.index.Search(q)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]*ArchivedEmail, 0, len(ids))
+	for _, id := range ids {
+		if e, ok := a.byID[id]; ok {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+// Reindex adds all the archived emails to the index of the archive. It is
+// needed when an index that is not persisted, like MemoryIndex, is used.
+func (a *Archive) Reindex() error {
+	if a.index == nil {
+		return errors.New("gomail: the archive has no search index")
+	}
+	a.mu.Lock()
+	all := append([]*ArchivedEmail(nil), a.all...)
+	a.mu.Unlock()
+
+	for _, e := range all {
+		if err := a.indexEmail(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archive) indexEmail(e *ArchivedEmail) error {
+	r, err := a.Open(e.ID)
+	if err != nil {
+		return err
+	}
+	raw, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("gomail: could not read archived email %q: %w", e.ID, err)
+	}
+
+	p := parsePreview(raw)
+	doc := &ArchiveDocument{Email: e, Subject: p.Subject, Body: p.Text}
+	if doc.Body == "" && p.HTML != "" {
+		doc.Body = stripTags(p.HTML)
+	}
+	if err := a.index.Add(doc); err != nil {
+		return fmt.Errorf("gomail: could not index archived email %q: %w", e.ID, err)
+	}
+	return nil
+}
+
+var (
+	htmlIgnored = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>`)
+	htmlTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// stripTags returns the text of an HTML document.
+func stripTags(s string) string {
+	s = htmlIgnored.ReplaceAllString(s, " ")
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, " "))
+}
+
+// A MemoryIndex is an ArchiveIndex kept in memory. It is lost when the
+// program exits, so Archive.Reindex must be called after opening an archive.
+type MemoryIndex struct {
+	mu    sync.RWMutex
+	docs  map[string]*memoryDoc
+	terms map[string][]string
+}
+
+type memoryDoc struct {
+	email   *ArchivedEmail
+	subject map[string]bool
+}
+
+// NewMemoryIndex returns an empty MemoryIndex.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:  make(map[string]*memoryDoc),
+		terms: make(map[string][]string),
+	}
+}
+
+// Add implements ArchiveIndex.
+func (idx *MemoryIndex) Add(doc *ArchiveDocument) error {
+	d := &memoryDoc{email: doc.Email, subject: make(map[string]bool)}
+	for _, t := range searchTerms(doc.Subject) {
+		d.subject[t] = true
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.docs[doc.Email.ID]; ok {
+		return nil
+	}
+	idx.docs[doc.Email.ID] = d
+	seen := make(map[string]bool)
+	for _, t := range searchTerms(doc.Body) {
+		if !seen[t] {
+			seen[t] = true
+			idx.terms[t] = append(idx.terms[t], doc.Email.ID)
+		}
+	}
+	return nil
+}
+
+// Search implements ArchiveIndex.
+func (idx *MemoryIndex) Search(q *SearchQuery) ([]string, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var candidates []string
+	if terms := searchTerms(q.Body); len(terms) > 0 {
+		candidates = idx.terms[terms[0]]
+		for _, t := range terms[1:] {
+			candidates = intersect(candidates, idx.terms[t])
+		}
+	} else {
+		for id := range idx.docs {
+			candidates = append(candidates, id)
+		}
+	}
+
+	subject := searchTerms(q.Subject)
+	var ids []string
+	for _, id := range candidates {
+		if d := idx.docs[id]; d.match(q, subject) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (d *memoryDoc) match(q *SearchQuery, subject []string) bool {
+	if !q.Since.IsZero() && d.email.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !d.email.Time.Before(q.Until) {
+		return false
+	}
+	for _, t := range subject {
+		if !d.subject[t] {
+			return false
+		}
+	}
+	if q.Recipient == "" {
+		return true
+	}
+	for _, addr := range d.email.To {
+		if strings.EqualFold(addr, q.Recipient) {
+			return true
+		}
+	}
+	return false
+}
+
+// intersect returns the IDs in both a and b.
+func intersect(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, id := range b {
+		in[id] = true
+	}
+	var ids []string
+	for _, id := range a {
+		if in[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// searchTerms splits s into lowercase words.
+func searchTerms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,103 @@
+package gomail
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestArchiveSearch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := NewArchive(dir, Indexed(NewMemoryIndex()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(to, subject, contentType, body string) {
+		m := NewMessage()
+		m.SetHeader("From", testFrom)
+		m.SetHeader("To", to)
+		m.SetHeader("Subject", subject)
+		m.SetBody(contentType, body)
+		if err := Send(a, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(testTo1, "Votre facture de juin", "text/plain", "Le montant est de 42 €. Merci !")
+	send(testTo2, "Your June invoice", "text/html", "<style>p { color: red }</style><p>The amount is <b>42</b>&nbsp;EUR.</p>")
+	send("Bob@Example.org", "Password reset", "text/plain", "Click the link to reset your password.")
+
+	if _, err := a.Search(&SearchQuery{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q    *SearchQuery
+		want []string
+	}{
+		{&SearchQuery{}, []string{testTo1, testTo2, "Bob@Example.org"}},
+		{&SearchQuery{Body: "42"}, []string{testTo1, testTo2}},
+		{&SearchQuery{Body: "AMOUNT 42"}, []string{testTo2}},
+		{&SearchQuery{Body: "color"}, nil},
+		{&SearchQuery{Subject: "june invoice"}, []string{testTo2}},
+		{&SearchQuery{Subject: "facture", Body: "merci"}, []string{testTo1}},
+		{&SearchQuery{Recipient: "bob@example.org"}, []string{"Bob@Example.org"}},
+		{&SearchQuery{Body: "42", Recipient: "bob@example.org"}, nil},
+		{&SearchQuery{Since: now().Add(time.Second)}, nil},
+		{&SearchQuery{Until: now().Add(time.Second)}, []string{testTo1, testTo2, "Bob@Example.org"}},
+	}
+	check := func(a *Archive) {
+		for _, test := range tests {
+			list, err := a.Search(test.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range list {
+				got = append(got, e.To[0])
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("Search(%+v) = %q, want %q", test.q, got, test.want)
+				continue
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("Search(%+v) = %q, want %q", test.q, got, test.want)
+					break
+				}
+			}
+		}
+	}
+	check(a)
+
+	// A memory index must be rebuilt when the archive is opened again.
+	a, err = NewArchive(dir, Indexed(NewMemoryIndex()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Reindex(); err != nil {
+		t.Fatal(err)
+	}
+	check(a)
+
+	a, err = NewArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Search(&SearchQuery{}); err == nil {
+		t.Error("Search should fail without an index")
+	}
+}
+
+func TestStripTags(t *testing.T) {
+	got := searchTerms(stripTags(`<html><head><script>var x = 1;</script></head><body><p class="a">Caf&eacute; <i>ouvert</i></p></body></html>`))
+	if len(got) != 2 || got[0] != "café" || got[1] != "ouvert" {
+		t.Errorf("Invalid text, got %q", got)
+	}
+}
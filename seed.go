@@ -0,0 +1,147 @@
+package gomail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A SeedMailbox is a mailbox used to check where the emails sent to a seed
+// address land. It is usually backed by an IMAP or POP3 client.
+type SeedMailbox interface {
+	// Find looks for the email with the given Message-ID and returns the
+	// folder where it has been delivered, for example "INBOX" or "Spam", or an
+	// empty string if it has not been delivered yet.
+	Find(messageID string) (folder string, err error)
+}
+
+// The SeedMailboxFunc type is an adapter to allow the use of ordinary
+// functions as seed mailboxes.
+type SeedMailboxFunc func(messageID string) (string, error)
+
+// Find calls f(messageID).
+func (f SeedMailboxFunc) Find(messageID string) (string, error) {
+	return f(messageID)
+}
+
+// A Seed is an address whose mailbox is monitored by a SeedProbe.
+type Seed struct {
+	Address string
+	Mailbox SeedMailbox
+}
+
+// A SeedResult reports where the email sent to a seed landed.
+type SeedResult struct {
+	// Address is the address of the seed.
+	Address string
+	// Folder is the folder where the email has been delivered. It is empty if
+	// the email was not found before the timeout.
+	Folder string
+	// Latency is the time between the send and the moment the email was
+	// found.
+	Latency time.Duration
+	// Err is the last error returned by the mailbox, if any.
+	Err error
+}
+
+// Delivered reports whether the email has been found in the mailbox.
+func (r *SeedResult) Delivered() bool {
+	return r.Folder != ""
+}
+
+// A SeedProbe monitors deliverability by sending a copy of an email to seed
+// mailboxes and checking where it lands.
+type SeedProbe struct {
+	// Seeds are the monitored addresses.
+	Seeds []*Seed
+	// Timeout is how long the mailboxes are polled. It defaults to five
+	// minutes.
+	Timeout time.Duration
+	// Interval is the delay between two polls. It defaults to 30 seconds.
+	Interval time.Duration
+}
+
+// Probe sends a copy of m to the seeds using s and polls their mailboxes until
+// the email is found in all of them or the timeout expires. The copy is sent
+// with the same envelope sender as m but only to the seeds.
+//
+// If m has no Message-ID header, one is generated and set on m so the email
+// can be found in the mailboxes.
+func (p *SeedProbe) Probe(s Sender, m *Message) ([]*SeedResult, error) {
+	from, err := m.getFrom()
+	if err != nil {
+		return nil, err
+	}
+	id, err := probeMessageID(m, from)
+	if err != nil {
+		return nil, err
+	}
+
+	to := make([]string, len(p.Seeds))
+	for i, seed := range p.Seeds {
+		to[i] = seed.Address
+	}
+	start := now()
+	if err := s.Send(from, to, m); err != nil {
+		return nil, fmt.Errorf("gomail: could not send to seeds: %w", err)
+	}
+
+	return p.poll(id, start), nil
+}
+
+func (p *SeedProbe) poll(id string, start time.Time) []*SeedResult {
+	timeout, interval := p.Timeout, p.Interval
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	results := make([]*SeedResult, len(p.Seeds))
+	for i, seed := range p.Seeds {
+		results[i] = &SeedResult{Address: seed.Address}
+	}
+	for {
+		pending := 0
+		for i, seed := range p.Seeds {
+			r := results[i]
+			if r.Delivered() {
+				continue
+			}
+			r.Folder, r.Err = seed.Mailbox.Find(id)
+			if r.Delivered() {
+				r.Latency = now().Sub(start)
+			} else {
+				pending++
+			}
+		}
+		if pending == 0 || now().Add(interval).Sub(start) > timeout {
+			return results
+		}
+		sleep(interval)
+	}
+}
+
+// probeMessageID returns the Message-ID of m, without angle brackets, setting
+// a random one if needed.
+func probeMessageID(m *Message, from string) (string, error) {
+	if v := m.header["Message-ID"]; len(v) > 0 && v[0] != "" {
+		id := v[0]
+		if len(id) > 2 && id[0] == '<' && id[len(id)-1] == '>' {
+			id = id[1 : len(id)-1]
+		}
+		return id, nil
+	}
+
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
+	}
+	_, domain := splitAddress(from)
+	id := hex.EncodeToString(b[:]) + "@" + domain
+	m.SetHeader("Message-ID", "<"+id+">")
+	return id, nil
+}
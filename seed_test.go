@@ -0,0 +1,89 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeedProbe(t *testing.T) {
+	defer func(n func() time.Time, s func(time.Duration)) { now, sleep = n, s }(now, sleep)
+	current := time.Date(2014, 6, 25, 17, 46, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	sleep = func(d time.Duration) { current = current.Add(d) }
+
+	var ids []string
+	polls := 0
+	inbox := SeedMailboxFunc(func(id string) (string, error) {
+		ids = append(ids, id)
+		polls++
+		if polls < 3 {
+			return "", nil
+		}
+		return "INBOX", nil
+	})
+	errMailbox := errors.New("login failed")
+	broken := SeedMailboxFunc(func(id string) (string, error) {
+		return "", errMailbox
+	})
+
+	var sent []string
+	s := SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		if from != "from@example.com" {
+			t.Errorf("Invalid from, got %q", from)
+		}
+		sent = append(sent, to...)
+		return nil
+	})
+
+	p := &SeedProbe{
+		Seeds: []*Seed{
+			{Address: "seed1@example.org", Mailbox: inbox},
+			{Address: "seed2@example.net", Mailbox: broken},
+		},
+		Timeout:  time.Minute,
+		Interval: 10 * time.Second,
+	}
+	m := NewMessage()
+	m.SetHeader("From", "from@example.com")
+	m.SetHeader("To", "to@example.com")
+	results, err := p.Probe(s, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 2 || sent[0] != "seed1@example.org" || sent[1] != "seed2@example.net" {
+		t.Errorf("Invalid recipients, got %q", sent)
+	}
+	msgID := m.GetHeader("Message-ID")
+	if len(msgID) != 1 || !strings.HasSuffix(msgID[0], "@example.com>") {
+		t.Fatalf("Invalid Message-ID, got %q", msgID)
+	}
+	if ids[0] != strings.Trim(msgID[0], "<>") {
+		t.Errorf("Invalid searched ID, got %q, want %q", ids[0], msgID[0])
+	}
+
+	if r := results[0]; !r.Delivered() || r.Folder != "INBOX" || r.Latency != 20*time.Second {
+		t.Errorf("Invalid result, got %+v", r)
+	}
+	if r := results[1]; r.Delivered() || r.Err != errMailbox {
+		t.Errorf("Invalid result, got %+v", r)
+	}
+	if elapsed := current.Sub(time.Date(2014, 6, 25, 17, 46, 0, 0, time.UTC)); elapsed != time.Minute {
+		t.Errorf("Invalid polling duration, got %v, want 1m0s", elapsed)
+	}
+}
+
+func TestSeedProbeMessageID(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("Message-ID", "<abc@example.com>")
+	id, err := probeMessageID(m, "from@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "abc@example.com" {
+		t.Errorf("Invalid ID, got %q", id)
+	}
+}
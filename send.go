@@ -36,29 +36,53 @@ func (f SendFunc) Send(from string, to []string, msg io.WriterTo) error {
 func Send(s Sender, msg ...*Message) error {
 	for i, m := range msg {
 		if err := send(s, m); err != nil {
-			return fmt.Errorf("gomail: could not send email %d: %v", i+1, err)
+			return fmt.Errorf("gomail: could not send email %d: %w", i+1, err)
 		}
 	}
 
 	return nil
 }
 
-func send(s Sender, m *Message) error {
-	from, err := m.getFrom()
-	if err != nil {
-		return err
+// SendRaw sends an already rendered email read from r using the given Sender,
+// without parsing or re-encoding it. It can be used to send an email saved as
+// an EML file. The envelope must be given since the email is not parsed.
+//
+// If r is an io.Seeker, the email is read again from the start when the
+// Sender writes it more than once.
+func SendRaw(s Sender, from string, to []string, r io.Reader) error {
+	return SendEnvelope(s, &Envelope{From: from, To: to}, &rawMessage{r: r})
+}
+
+// rawMessage is an email already rendered.
+type rawMessage struct {
+	r    io.Reader
+	read bool
+}
+
+func (m *rawMessage) WriteTo(w io.Writer) (int64, error) {
+	if m.read {
+		seeker, ok := m.r.(io.Seeker)
+		if !ok {
+			return 0, errors.New("gomail: raw email cannot be read twice")
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
 	}
+	m.read = true
+	return io.Copy(w, m.r)
+}
 
-	to, err := m.getRecipients()
+func send(s Sender, m *Message) error {
+	e, err := m.Envelope()
 	if err != nil {
 		return err
 	}
-
-	if err := s.Send(from, to, m); err != nil {
+	if m, err = m.fitSize(); err != nil {
 		return err
 	}
 
-	return nil
+	return sendEnvelope(s, e, m)
 }
 
 func (m *Message) getFrom() (string, error) {
@@ -110,7 +134,7 @@ func addAddress(list []string, addr string) []string {
 func parseAddress(field string) (string, error) {
 	addr, err := mail.ParseAddress(field)
 	if err != nil {
-		return "", fmt.Errorf("gomail: invalid address %q: %v", field, err)
+		return "", fmt.Errorf("gomail: invalid address %q: %w", field, err)
 	}
 	return addr.Address, nil
 }
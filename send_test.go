@@ -3,7 +3,9 @@ package gomail
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -16,6 +18,7 @@ const (
 		"From: " + testFrom + "\r\n" +
 		"Mime-Version: 1.0\r\n" +
 		"Date: Wed, 25 Jun 2014 17:46:00 +0000\r\n" +
+		"Message-ID: <1234@example.com>\r\n" +
 		"Content-Type: text/plain; charset=UTF-8\r\n" +
 		"Content-Transfer-Encoding: quoted-printable\r\n" +
 		"\r\n" +
@@ -78,3 +81,37 @@ func stubSend(t *testing.T, wantFrom string, wantTo []string, wantBody string) m
 		return nil
 	}
 }
+
+func TestSendRaw(t *testing.T) {
+	var writes int
+	s := SendFunc(func(from string, to []string, msg io.WriterTo) error {
+		for i := 0; i < 2; i++ {
+			var buf bytes.Buffer
+			if _, err := msg.WriteTo(&buf); err != nil {
+				return err
+			}
+			if buf.String() != testMsg {
+				t.Errorf("Invalid message, got %q, want %q", buf.String(), testMsg)
+			}
+			writes++
+		}
+		return nil
+	})
+
+	if err := SendRaw(s, testFrom, []string{testTo1}, strings.NewReader(testMsg)); err != nil {
+		t.Fatal(err)
+	}
+	if writes != 2 {
+		t.Errorf("Invalid number of writes, got %d, want 2", writes)
+	}
+
+	// A reader that is not a Seeker can only be written once.
+	r := ioutil.NopCloser(strings.NewReader(testMsg))
+	if err := SendRaw(s, testFrom, []string{testTo1}, r); err == nil {
+		t.Error("SendRaw should fail when the reader is read twice")
+	}
+
+	if err := SendRaw(s, testFrom, nil, strings.NewReader(testMsg)); err == nil {
+		t.Error("SendRaw should fail without recipients")
+	}
+}
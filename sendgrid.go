@@ -0,0 +1,280 @@
+package gomail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+// A SendGridSender sends emails with the mail/send endpoint of the SendGrid v3
+// API. Since this endpoint does not accept MIME messages, the headers, parts,
+// attachments and embedded files of the Message are mapped to its JSON
+// payload. It implements SendCloser and SendDialer so it can be used with a
+// Queue.
+type SendGridSender struct {
+	// APIKey is the API key.
+	APIKey string
+	// Endpoint is the URL of the mail/send endpoint. It defaults to
+	// https://api.sendgrid.com/v3/mail/send.
+	Endpoint string
+	// Client is the HTTP client used to call the API. It defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+// NewSendGridSender returns a new SendGrid sender.
+func NewSendGridSender(apiKey string) *SendGridSender {
+	return &SendGridSender{APIKey: apiKey}
+}
+
+// Dial implements SendDialer. No connection is opened since every email is
+// sent with its own HTTP request.
+func (s *SendGridSender) Dial() (SendCloser, error) {
+	return s, nil
+}
+
+// Send implements Sender. msg must be a *Message. The recipients that are
+// neither in the To nor in the Cc header are sent a blind copy.
+func (s *SendGridSender) Send(from string, to []string, msg io.WriterTo) error {
+	m, ok := msg.(*Message)
+	if !ok {
+		return fmt.Errorf("gomail: SendGrid can only send a *Message, got %T", msg)
+	}
+	p, err := newSendGridPayload(m, to)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.sendgrid.com/v3/mail/send"
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	return doAPIRequest(s.Client, "SendGrid", req)
+}
+
+// Close implements SendCloser.
+func (s *SendGridSender) Close() error {
+	return nil
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To  []*sendGridAddress `json:"to"`
+	Cc  []*sendGridAddress `json:"cc,omitempty"`
+	Bcc []*sendGridAddress `json:"bcc,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+type sendGridPayload struct {
+	Personalizations []*sendGridPersonalization `json:"personalizations"`
+	From             *sendGridAddress           `json:"from"`
+	ReplyTo          *sendGridAddress           `json:"reply_to,omitempty"`
+	Subject          string                     `json:"subject,omitempty"`
+	Content          []*sendGridContent         `json:"content,omitempty"`
+	Attachments      []*sendGridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string          `json:"headers,omitempty"`
+}
+
+// sendGridReserved are the headers SendGrid sets itself.
+var sendGridReserved = map[string]bool{
+	"From": true, "To": true, "Cc": true, "Bcc": true, "Reply-To": true,
+	"Subject": true, "Content-Type": true, "Content-Transfer-Encoding": true,
+	"Mime-Version": true, "Date": true, "Received": true, "Dkim-Signature": true,
+}
+
+func newSendGridPayload(m *Message, to []string) (*sendGridPayload, error) {
+	header := make(map[string][]string, len(m.header))
+	dec := new(mime.WordDecoder)
+	for k, v := range m.header {
+		if sendGridReserved[k] && k != "Subject" {
+			continue
+		}
+		values := make([]string, len(v))
+		for i := range v {
+			s, err := dec.DecodeHeader(v[i])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = s
+		}
+		header[k] = values
+	}
+
+	// The address fields are parsed raw since net/mail decodes the names.
+	p := &sendGridPayload{}
+	from, err := sendGridAddresses(m.header["From"])
+	if err != nil {
+		return nil, fmt.Errorf(`gomail: invalid "From" field: %w`, err)
+	}
+	if len(from) == 0 {
+		return nil, errors.New(`gomail: invalid message, "From" field is absent`)
+	}
+	p.From = from[0]
+	if replyTo, err := sendGridAddresses(m.header["Reply-To"]); err != nil {
+		return nil, err
+	} else if len(replyTo) > 0 {
+		p.ReplyTo = replyTo[0]
+	}
+	if len(header["Subject"]) > 0 {
+		p.Subject = header["Subject"][0]
+	}
+
+	pers, err := sendGridRecipients(m.header, to)
+	if err != nil {
+		return nil, err
+	}
+	p.Personalizations = []*sendGridPersonalization{pers}
+
+	for k, v := range header {
+		if k == "Subject" {
+			continue
+		}
+		if p.Headers == nil {
+			p.Headers = make(map[string]string)
+		}
+		p.Headers[k] = strings.Join(v, ", ")
+	}
+
+	for _, part := range m.signedParts() {
+		var buf bytes.Buffer
+		if err := part.copier(&buf); err != nil {
+			return nil, err
+		}
+		p.Content = append(p.Content, &sendGridContent{Type: part.contentType, Value: buf.String()})
+	}
+
+	for _, f := range m.attachments {
+		a, err := newSendGridAttachment(f, "attachment")
+		if err != nil {
+			return nil, err
+		}
+		p.Attachments = append(p.Attachments, a)
+	}
+	for _, f := range m.embedded {
+		a, err := newSendGridAttachment(f, "inline")
+		if err != nil {
+			return nil, err
+		}
+		p.Attachments = append(p.Attachments, a)
+	}
+
+	return p, nil
+}
+
+// sendGridRecipients sorts the envelope recipients into To, Cc and Bcc
+// according to the headers of the message.
+func sendGridRecipients(header map[string][]string, to []string) (*sendGridPersonalization, error) {
+	p := new(sendGridPersonalization)
+	visible := make(map[string]bool)
+	for _, field := range []string{"To", "Cc"} {
+		list, err := sendGridAddresses(header[field])
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range list {
+			if !inAddresses(to, a.Email) || visible[strings.ToLower(a.Email)] {
+				continue
+			}
+			visible[strings.ToLower(a.Email)] = true
+			if field == "To" {
+				p.To = append(p.To, a)
+			} else {
+				p.Cc = append(p.Cc, a)
+			}
+		}
+	}
+	for _, addr := range to {
+		if !visible[strings.ToLower(addr)] {
+			p.Bcc = append(p.Bcc, &sendGridAddress{Email: addr})
+		}
+	}
+
+	if len(p.To) == 0 {
+		return nil, errors.New("gomail: SendGrid requires at least one recipient in the To field")
+	}
+	return p, nil
+}
+
+func inAddresses(list []string, addr string) bool {
+	for _, a := range list {
+		if strings.EqualFold(a, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func sendGridAddresses(values []string) ([]*sendGridAddress, error) {
+	var list []*sendGridAddress
+	for _, v := range values {
+		addrs, err := mail.ParseAddressList(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			list = append(list, &sendGridAddress{Email: a.Address, Name: a.Name})
+		}
+	}
+	return list, nil
+}
+
+func newSendGridAttachment(f *file, disposition string) (*sendGridAttachment, error) {
+	var buf bytes.Buffer
+	if err := f.CopyFunc(&buf); err != nil {
+		return nil, err
+	}
+
+	a := &sendGridAttachment{
+		Content:     base64.StdEncoding.EncodeToString(buf.Bytes()),
+		Filename:    f.Name,
+		Disposition: disposition,
+	}
+	ct := f.mediaType()
+	if v, ok := f.Header["Content-Type"]; ok && len(v) > 0 {
+		ct = v[0]
+	}
+	if mediaType, _, err := mime.ParseMediaType(ct); err == nil {
+		a.Type = mediaType
+	}
+	if disposition == "inline" {
+		a.ContentID = f.Name
+		if id, ok := f.Header["Content-ID"]; ok && len(id) > 0 {
+			a.ContentID = strings.Trim(id[0], "<>")
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,103 @@
+package gomail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSendGridSender(t *testing.T) {
+	var got sendGridPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer key" {
+			t.Errorf("Invalid Authorization, got %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	m := NewMessage()
+	m.SetAddressHeader("From", testFrom, "Zoë")
+	m.SetHeader("To", testTo1)
+	m.SetHeader("Cc", testTo2)
+	m.SetHeader("Subject", "¡Hola, señor!")
+	m.SetHeader("X-Campaign", "spring")
+	m.SetBody("text/plain", "Hello")
+	m.AddAlternative("text/html", "<b>Hello</b>")
+	m.Attach("report.pdf", SetCopyFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, "%PDF")
+		return err
+	}))
+	m.Embed("logo.png", SetCopyFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, "PNG")
+		return err
+	}), SetHeader(map[string][]string{"Content-ID": {"<logo>"}}))
+
+	s := &SendGridSender{APIKey: "key", Endpoint: srv.URL}
+	if err := s.Send(testFrom, []string{testTo1, testTo2, "hidden@example.com"}, m); err != nil {
+		t.Fatal(err)
+	}
+
+	want := sendGridPayload{
+		Personalizations: []*sendGridPersonalization{{
+			To:  []*sendGridAddress{{Email: testTo1}},
+			Cc:  []*sendGridAddress{{Email: testTo2}},
+			Bcc: []*sendGridAddress{{Email: "hidden@example.com"}},
+		}},
+		From:    &sendGridAddress{Email: testFrom, Name: "Zoë"},
+		Subject: "¡Hola, señor!",
+		Content: []*sendGridContent{
+			{Type: "text/plain", Value: "Hello"},
+			{Type: "text/html", Value: "<b>Hello</b>"},
+		},
+		Attachments: []*sendGridAttachment{
+			{Content: base64.StdEncoding.EncodeToString([]byte("%PDF")), Type: "application/pdf", Filename: "report.pdf", Disposition: "attachment"},
+			{Content: base64.StdEncoding.EncodeToString([]byte("PNG")), Type: "image/png", Filename: "logo.png", Disposition: "inline", ContentID: "logo"},
+		},
+		Headers: map[string]string{"X-Campaign": "spring"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		wantJSON, _ := json.MarshalIndent(want, "", "  ")
+		t.Errorf("Invalid payload, got:\n%s\nwant:\n%s", gotJSON, wantJSON)
+	}
+}
+
+func TestSendGridSenderErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"errors":[{"message":"too many requests"}]}`)
+	}))
+	defer srv.Close()
+
+	s := &SendGridSender{Endpoint: srv.URL}
+	err := s.Send(testFrom, []string{testTo1}, getTestMessage())
+	var aerr *APIError
+	if !errors.As(err, &aerr) || aerr.StatusCode != 429 || aerr.Provider != "SendGrid" {
+		t.Fatalf("Invalid error, got %v", err)
+	}
+	if !IsTemporary(err) {
+		t.Error("A 429 response should be temporary")
+	}
+
+	if err := s.Send(testFrom, []string{testTo1}, bytes.NewBufferString("raw")); err == nil {
+		t.Error("Send should fail with a raw message")
+	}
+
+	m := getTestMessage()
+	m.SetHeader("To")
+	if err := s.Send(testFrom, []string{testTo1}, m); err == nil {
+		t.Error("Send should fail without a To recipient")
+	}
+}
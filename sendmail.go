@@ -0,0 +1,90 @@
+package gomail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// A SendmailSender sends emails by piping them to a local sendmail-compatible
+// program, such as the sendmail binaries of Postfix or Exim. It can be used on
+// servers where only the local MTA is allowed to connect to the outside. It
+// implements SendCloser and SendDialer so it can be used with a Queue.
+type SendmailSender struct {
+	// Path is the path of the program. It defaults to /usr/sbin/sendmail.
+	Path string
+	// Args are the arguments given to the program before the envelope
+	// arguments. They default to "-i" so a line with a single dot does not end
+	// the email.
+	//
+	// The envelope sender is always given with -f and the recipients are
+	// given after "--". -t must not be used since the Bcc field is never
+	// written in the email.
+	Args []string
+}
+
+// NewSendmailSender returns a new sendmail sender using /usr/sbin/sendmail.
+func NewSendmailSender() *SendmailSender {
+	return &SendmailSender{}
+}
+
+// Dial implements SendDialer. Nothing is opened since the program is started
+// for every email.
+func (s *SendmailSender) Dial() (SendCloser, error) {
+	return s, nil
+}
+
+// Send implements Sender.
+func (s *SendmailSender) Send(from string, to []string, msg io.WriterTo) error {
+	if strings.HasPrefix(from, "-") {
+		return fmt.Errorf("gomail: invalid envelope sender %q", from)
+	}
+	for _, addr := range to {
+		if strings.HasPrefix(addr, "-") {
+			return fmt.Errorf("gomail: invalid recipient %q", addr)
+		}
+	}
+
+	path := s.Path
+	if path == "" {
+		path = "/usr/sbin/sendmail"
+	}
+	args := s.Args
+	if args == nil {
+		args = []string{"-i"}
+	}
+	args = append(append(args[:len(args):len(args)], "-f", from, "--"), to...)
+
+	cmd := execCommand(path, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("gomail: could not start sendmail: %w", err)
+	}
+
+	_, werr := msg.WriteTo(stdin)
+	if err := stdin.Close(); werr == nil {
+		werr = err
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("gomail: sendmail failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("gomail: sendmail failed: %w", err)
+	}
+	return werr
+}
+
+// Close implements SendCloser.
+func (s *SendmailSender) Close() error {
+	return nil
+}
+
+// Stubbed out for tests.
+var execCommand = exec.Command
@@ -0,0 +1,122 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubSendmail makes execCommand run TestSendmailHelperProcess, which writes
+// its arguments and input in the returned directory.
+func stubSendmail(t *testing.T, fail bool) (dir string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execCommand = func(name string, arg ...string) *exec.Cmd {
+		args := append([]string{"-test.run=TestSendmailHelperProcess", "--", name}, arg...)
+		cmd := exec.Command(os.Args[0], args...)
+		cmd.Env = append(os.Environ(), "GOMAIL_SENDMAIL_DIR="+dir)
+		if fail {
+			cmd.Env = append(cmd.Env, "GOMAIL_SENDMAIL_FAIL=1")
+		}
+		return cmd
+	}
+	return dir, func() {
+		execCommand = exec.Command
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSendmailHelperProcess(t *testing.T) {
+	dir := os.Getenv("GOMAIL_SENDMAIL_DIR")
+	if dir == "" {
+		return
+	}
+
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	msg, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		os.Exit(1)
+	}
+	if os.Getenv("GOMAIL_SENDMAIL_FAIL") != "" {
+		fmt.Fprintln(os.Stderr, "sendmail: fatal: no recipient")
+		os.Exit(75)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "args"), []byte(strings.Join(args[1:], "\n")), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "msg"), msg, 0644)
+	os.Exit(0)
+}
+
+func TestSendmailSender(t *testing.T) {
+	dir, cleanup := stubSendmail(t, false)
+	defer cleanup()
+
+	if err := Send(NewSendmailSender(), getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/usr/sbin/sendmail", "-i", "-f", testFrom, "--", testTo1, testTo2}
+	if got := strings.Split(string(args), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid arguments, got %q, want %q", got, want)
+	}
+	msg, err := ioutil.ReadFile(filepath.Join(dir, "msg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareBodies(t, string(msg), testMsg)
+}
+
+func TestSendmailSenderArgs(t *testing.T) {
+	dir, cleanup := stubSendmail(t, false)
+	defer cleanup()
+
+	s := &SendmailSender{Path: "/usr/sbin/exim", Args: []string{"-oi", "-odq"}}
+	if err := s.Send(testFrom, []string{testTo1}, getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/usr/sbin/exim", "-oi", "-odq", "-f", testFrom, "--", testTo1}
+	if got := strings.Split(string(args), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid arguments, got %q, want %q", got, want)
+	}
+	if len(s.Args) != 2 {
+		t.Errorf("Args should not be modified, got %q", s.Args)
+	}
+}
+
+func TestSendmailSenderError(t *testing.T) {
+	_, cleanup := stubSendmail(t, true)
+	defer cleanup()
+
+	err := NewSendmailSender().Send(testFrom, []string{testTo1}, getTestMessage())
+	if err == nil || !strings.Contains(err.Error(), "no recipient") {
+		t.Errorf("Invalid error, got %v", err)
+	}
+	var eerr *exec.ExitError
+	if !errors.As(err, &eerr) || eerr.ExitCode() != 75 {
+		t.Errorf("The error should wrap the exit status, got %v", err)
+	}
+
+	if err := NewSendmailSender().Send("-oQ/tmp", []string{testTo1}, getTestMessage()); err == nil {
+		t.Error("Send should reject a sender starting with a dash")
+	}
+}
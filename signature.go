@@ -0,0 +1,114 @@
+package gomail
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// A Signature is the signature block appended to the bodies of an email.
+type Signature struct {
+	// HTML is the signature of the text/html body.
+	HTML string
+	// Text is the signature of the text/plain body, without the "-- "
+	// separator.
+	Text string
+}
+
+// SetSignature sets the signature appended to the text/plain and text/html
+// bodies of the message when it is sent, whenever the bodies are set. In the
+// text/plain body, it follows the "-- " separator line. If one of html and
+// text is empty, it is derived from the other so both alternatives get the
+// same signature. Empty signatures remove the signature.
+func (m *Message) SetSignature(html, text string) {
+	if html == "" && text == "" {
+		m.signature = nil
+		return
+	}
+	m.signature = &Signature{HTML: html, Text: text}
+}
+
+// signedParts returns the parts of the message with the signature appended.
+func (m *Message) signedParts() []*part {
+	if m.signature == nil {
+		return m.parts
+	}
+	sig := *m.signature
+	if sig.Text == "" {
+		sig.Text = strings.TrimSpace(htmlText(sig.HTML))
+	}
+	if sig.HTML == "" {
+		sig.HTML = strings.Replace(html.EscapeString(sig.Text), "\n", "<br>\r\n", -1)
+	}
+
+	parts := make([]*part, len(m.parts))
+	for i, p := range m.parts {
+		cp := *p
+		switch {
+		case strings.HasPrefix(p.contentType, "text/plain"):
+			cp.copier = textSignatureCopier(p.copier, sig.Text)
+		case strings.HasPrefix(p.contentType, "text/html"):
+			cp.copier = htmlSignatureCopier(p.copier, sig.HTML)
+		}
+		parts[i] = &cp
+	}
+	return parts
+}
+
+func textSignatureCopier(f func(io.Writer) error, sig string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		lw := &lastByteWriter{w: w}
+		if err := f(lw); err != nil {
+			return err
+		}
+		sep := "-- \r\n"
+		if lw.n > 0 && lw.last != '\n' {
+			sep = "\r\n" + sep
+		}
+		_, err := io.WriteString(w, sep+sig)
+		return err
+	}
+}
+
+var bodyEndTag = regexp.MustCompile(`(?i)</body\s*>`)
+
+// appendHTML inserts s at the end of the body of an HTML document, before the
+// last </body> tag so the tags found earlier in comments or scripts are
+// ignored.
+func appendHTML(doc, s string) string {
+	if locs := bodyEndTag.FindAllStringIndex(doc, -1); locs != nil {
+		i := locs[len(locs)-1][0]
+		return doc[:i] + s + doc[i:]
+	}
+	return doc + s
+}
+
+func htmlSignatureCopier(f func(io.Writer) error, sig string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		var buf bytes.Buffer
+		if err := f(&buf); err != nil {
+			return err
+		}
+		doc := appendHTML(buf.String(), `<div class="signature">`+sig+`</div>`)
+		_, err := io.WriteString(w, doc)
+		return err
+	}
+}
+
+// lastByteWriter records the number of bytes written and the last one.
+type lastByteWriter struct {
+	w    io.Writer
+	n    int64
+	last byte
+}
+
+func (w *lastByteWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.n += int64(n)
+		w.last = p[n-1]
+	}
+	return n, err
+}
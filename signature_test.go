@@ -0,0 +1,84 @@
+package gomail
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSetSignature(t *testing.T) {
+	m := NewMessage(SetEncoding(Unencoded))
+	m.SetSignature("<b>Alice</b>", "")
+	m.SetHeader("From", "from@example.com")
+	m.SetHeader("To", "to@example.com")
+	m.SetBody("text/plain", "Hello")
+	m.AddAlternative("text/html", "<html><body><p>Hello</p></body></html>")
+
+	want := &message{
+		from: "from@example.com",
+		to:   []string{"to@example.com"},
+		content: "From: from@example.com\r\n" +
+			"To: to@example.com\r\n" +
+			"Content-Type: multipart/alternative;\r\n" +
+			" boundary=_BOUNDARY_1_\r\n" +
+			"\r\n" +
+			"--_BOUNDARY_1_\r\n" +
+			"Content-Type: text/plain; charset=UTF-8\r\n" +
+			"Content-Transfer-Encoding: 8bit\r\n" +
+			"\r\n" +
+			"Hello\r\n" +
+			"-- \r\n" +
+			"Alice\r\n" +
+			"--_BOUNDARY_1_\r\n" +
+			"Content-Type: text/html; charset=UTF-8\r\n" +
+			"Content-Transfer-Encoding: 8bit\r\n" +
+			"\r\n" +
+			`<html><body><p>Hello</p><div class="signature"><b>Alice</b></div></body></html>` + "\r\n" +
+			"--_BOUNDARY_1_--\r\n",
+	}
+
+	testMessage(t, m, 1, want)
+}
+
+func TestAppendHTML(t *testing.T) {
+	tests := []struct {
+		doc, want string
+	}{
+		{"<p>Hi</p>", "<p>Hi</p>SIG"},
+		{"<html><body><p>Hi</p></BODY></html>", "<html><body><p>Hi</p>SIG</BODY></html>"},
+		{
+			"<html><body><!-- </body> --><script>s = '</body>'</script></body></html>",
+			"<html><body><!-- </body> --><script>s = '</body>'</script>SIG</body></html>",
+		},
+	}
+	for _, test := range tests {
+		if got := appendHTML(test.doc, "SIG"); got != test.want {
+			t.Errorf("appendHTML(%q) = %q, want %q", test.doc, got, test.want)
+		}
+	}
+}
+
+func TestSignatureFromText(t *testing.T) {
+	m := NewMessage()
+	m.SetBody("text/plain", "Hello\n")
+	m.AddAlternative("text/html", "<p>Hello</p>")
+	m.SetSignature("", "Alice & Bob\nACME")
+
+	want := []string{
+		"Hello\n-- \r\nAlice & Bob\nACME",
+		`<p>Hello</p><div class="signature">Alice &amp; Bob<br>` + "\r\n" + `ACME</div>`,
+	}
+	for i, p := range m.signedParts() {
+		var buf bytes.Buffer
+		if err := p.copier(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want[i] {
+			t.Errorf("Invalid %s body, got %q, want %q", p.contentType, buf.String(), want[i])
+		}
+	}
+
+	m.SetSignature("", "")
+	if parts := m.signedParts(); len(parts) != 2 || parts[0] != m.parts[0] {
+		t.Error("SetSignature with empty signatures should remove the signature")
+	}
+}
@@ -0,0 +1,199 @@
+package gomail
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+)
+
+// EncodedSize returns the size in bytes of the message as it is sent, after
+// the bodies and files are encoded. The message is rendered to compute it, so
+// the files are read.
+func (m *Message) EncodedSize() (int64, error) {
+	return messageSize(m)
+}
+
+// messageSize returns the size of the rendered message.
+func messageSize(m *Message) (int64, error) {
+	w := new(countingWriter)
+	_, err := m.WriteTo(w)
+	return w.n, err
+}
+
+// checkSize returns an error wrapping ErrMessageTooLarge if msg is a Message
+// larger than the maximum size advertised by the server with the SIZE
+// extension, so it is not streamed only to be rejected at the end. Measuring
+// the message reads its files, so it is not checked if a file can be read only
+// once: the server then rejects it after the transfer.
+func (c *smtpSender) checkSize(msg io.WriterTo) error {
+	m, ok := msg.(*Message)
+	if !ok || !m.filesRepeatable() {
+		return nil
+	}
+	max := (&Capabilities{Extensions: c.extensions()}).MaxSize()
+	if max <= 0 {
+		return nil
+	}
+	n, err := m.EncodedSize()
+	if err != nil {
+		return err
+	}
+	if n > max {
+		return &wrappedError{ErrMessageTooLarge, fmt.Errorf("gomail: the message is %d bytes, more than the maximum of %d bytes accepted by the server", n, max)}
+	}
+	return nil
+}
+
+// filesRepeatable reports whether the attached and embedded files can be read
+// several times.
+func (m *Message) filesRepeatable() bool {
+	for _, list := range [][]*file{m.attachments, m.embedded} {
+		for _, f := range list {
+			if !f.repeatable {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// An OversizeFunc is called with the file name and the encoded size in bytes
+// of an attachment when a message exceeds its MaxSize. It reports whether the
+// attachment is dropped and can return a link, for example to download the
+// file from a file sharing service, added at the end of the text bodies of the
+// message in place of the attachment.
+type OversizeFunc func(name string, size int64) (link string, drop bool)
+
+// OnOversize is a message setting to call f when the message exceeds its
+// MaxSize before it is sent. f is called for the attachments, from the
+// largest, until the message fits. The message itself is not modified: a copy
+// without the dropped attachments is sent.
+func OnOversize(f OversizeFunc) MessageSetting {
+	return func(m *Message) {
+		m.oversize = f
+	}
+}
+
+// fitSize returns the message to send given the MaxSize setting: m if it fits,
+// or a copy of m without the attachments dropped by the OnOversize setting. It
+// returns an error wrapping ErrMessageTooLarge if the message does not fit.
+func (m *Message) fitSize() (*Message, error) {
+	if m.maxSize <= 0 {
+		return m, nil
+	}
+	n, err := messageSize(m)
+	if err != nil || n <= m.maxSize {
+		return m, err
+	}
+
+	if m.oversize != nil && len(m.attachments) > 0 {
+		files, sizes, err := attachmentSizes(m.attachments)
+		if err != nil {
+			return nil, err
+		}
+		c := m.Clone()
+		var names, links []string
+		for i, f := range files {
+			link, drop := m.oversize(f.Name, sizes[i])
+			if !drop {
+				continue
+			}
+			c.attachments = removeFile(c.attachments, f.Name)
+			if link != "" {
+				names = append(names, f.Name)
+				links = append(links, link)
+			}
+			c.parts = m.parts
+			if err := c.addLinks(names, links); err != nil {
+				return nil, err
+			}
+			if n, err = messageSize(c); err != nil {
+				return nil, err
+			}
+			if n <= m.maxSize {
+				return c, nil
+			}
+		}
+	}
+	return nil, &wrappedError{ErrMessageTooLarge,
+		fmt.Errorf("gomail: the message is %d bytes, more than the maximum of %d bytes", n, m.maxSize)}
+}
+
+// attachmentSizes returns the attachments sorted from the largest, with their
+// base64-encoded sizes.
+func attachmentSizes(attachments []*file) ([]*file, []int64, error) {
+	files := append([]*file(nil), attachments...)
+	sizes := make(map[*file]int64, len(files))
+	for _, f := range files {
+		w := new(countingWriter)
+		wc := newBase64LineWriter(w)
+		err := f.CopyFunc(wc)
+		if cerr := wc.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("gomail: could not read the file %q: %w", f.Name, err)
+		}
+		sizes[f] = w.n
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return sizes[files[i]] > sizes[files[j]]
+	})
+	list := make([]int64, len(files))
+	for i, f := range files {
+		list[i] = sizes[f]
+	}
+	return files, list, nil
+}
+
+// removeFile returns files without the first file of the given name.
+func removeFile(files []*file, name string) []*file {
+	for i, f := range files {
+		if f.Name == name {
+			return append(files[:i:i], files[i+1:]...)
+		}
+	}
+	return files
+}
+
+// addLinks replaces the parts of m by copies whose text/plain and text/html
+// bodies end with the links of the dropped attachments.
+func (m *Message) addLinks(names, links []string) error {
+	if len(links) == 0 {
+		return nil
+	}
+	parts := make([]*part, len(m.parts))
+	for i, p := range m.parts {
+		cp := *p
+		parts[i] = &cp
+		isHTML := strings.HasPrefix(p.contentType, "text/html")
+		if !isHTML && !strings.HasPrefix(p.contentType, "text/plain") {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := p.copier(&buf); err != nil {
+			return err
+		}
+		var sb strings.Builder
+		for j, link := range links {
+			if isHTML {
+				sb.WriteString(`<p><a href="` + html.EscapeString(link) + `">` + html.EscapeString(names[j]) + "</a></p>")
+			} else {
+				sb.WriteString("\n" + names[j] + ": " + link)
+			}
+		}
+		body := buf.String()
+		if isHTML {
+			body = appendHTML(body, sb.String())
+		} else {
+			body += "\n" + sb.String() + "\n"
+		}
+		cp.copier = newCopier(body)
+	}
+	m.parts = parts
+	return nil
+}
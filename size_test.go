@@ -0,0 +1,161 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncodedSize(t *testing.T) {
+	m := getTestMessage()
+	m.Attach(mockCopyFile("/tmp/test.pdf"))
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	n, err := m.EncodedSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("Invalid size, got %d, want %d", n, buf.Len())
+	}
+}
+
+func TestDialerSizeLimit(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort, CapabilitiesTTL: -1}
+	err := sendMailWithClient(t, d, &mockClient{
+		t:    t,
+		want: []string{"Extension STARTTLS", "StartTLS", "Quit", "Close"},
+		ext:  map[string]string{"SIZE": "100"},
+	})
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMessageTooLarge)
+	}
+
+	err = sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+			"Close",
+		},
+		ext: map[string]string{"SIZE": strconv.Itoa(len(testMsg))},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func copyBytes(n int) FileSetting {
+	return SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write(bytes.Repeat([]byte("a"), n))
+		return err
+	})
+}
+
+func TestSendMaxSize(t *testing.T) {
+	m := NewMessage(MaxSize(2000))
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", "Hello!")
+	m.Attach("big.bin", copyBytes(2000))
+
+	err := Send(SendFunc(func(string, []string, io.WriterTo) error {
+		t.Error("The email should not be sent")
+		return nil
+	}), m)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMessageTooLarge)
+	}
+}
+
+func TestOnOversize(t *testing.T) {
+	var called []string
+	m := NewMessage(MaxSize(3000), OnOversize(func(name string, size int64) (string, bool) {
+		called = append(called, name+" "+strconv.FormatInt(size, 10))
+		return "https://example.com/" + name, name == "big.bin"
+	}))
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", "Hello!")
+	m.AddAlternative("text/html", "<html><body><p>Hello!</p></body></html>")
+	m.Attach("small.bin", copyBytes(600))
+	m.Attach("big.bin", copyBytes(1500))
+
+	var buf bytes.Buffer
+	err := Send(SendFunc(func(_ string, _ []string, msg io.WriterTo) error {
+		_, err := msg.WriteTo(&buf)
+		return err
+	}), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"big.bin 2052"}; strings.Join(called, ",") != strings.Join(want, ",") {
+		t.Errorf("Invalid calls, got %q, want %q", called, want)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"Hello!\r\n\r\nbig.bin: https://example.com/big.bin\r\n",
+		`<a href=3D"https://example.com/big.bin">`,
+		"</a></p></body></html>",
+		`filename="small.bin"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "filename=\"big.bin\"") {
+		t.Errorf("The big attachment should be dropped:\n%s", got)
+	}
+	if len(m.attachments) != 2 {
+		t.Error("The message should not be modified")
+	}
+
+	// The message does not fit even without the attachments dropped.
+	m.SetBody("text/plain", strings.Repeat("a", 3000))
+	if err := Send(SendFunc(func(string, []string, io.WriterTo) error { return nil }), m); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMessageTooLarge)
+	}
+}
+
+func TestDialerSizeLimitOneShotFile(t *testing.T) {
+	c := &smtpSender{
+		smtpClient: &mockClient{t: t, ext: map[string]string{"SIZE": "100"}},
+		d:          &Dialer{},
+	}
+	m := getTestMessage()
+	r := strings.NewReader("content")
+	m.Attach("file.txt", SetCopyFunc(func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	}))
+	if err := c.checkSize(m); err != nil {
+		t.Errorf("A message with a file read once should not be measured, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Y29udGVudA=="; !strings.Contains(buf.String(), want) {
+		t.Errorf("The file should be sent, missing %q in:\n%s", want, buf.String())
+	}
+
+	m = getTestMessage()
+	m.Attach("file.txt", setContent(bytes.Repeat([]byte("a"), 200)))
+	if err := c.checkSize(m); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMessageTooLarge)
+	}
+}
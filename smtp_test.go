@@ -3,10 +3,13 @@ package gomail
 import (
 	"bytes"
 	"crypto/tls"
+	"errors"
 	"io"
 	"net"
 	"net/smtp"
+	"net/textproto"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -97,6 +100,25 @@ func TestDialerSSLConfig(t *testing.T) {
 	})
 }
 
+func TestDialerLocalNameAddress(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort, LocalName: "192.0.2.1", TLSPolicy: NoTLS}
+	testSendMail(t, d, []string{
+		"Hello [192.0.2.1]",
+		"Mail " + testFrom,
+		"Rcpt " + testTo1,
+		"Rcpt " + testTo2,
+		"Data",
+		"Write message",
+		"Close writer",
+		"Quit",
+		"Close",
+	})
+
+	if got, want := heloName("2001:db8::1"), "[IPv6:2001:db8::1]"; got != want {
+		t.Errorf("Invalid name, got %q, want %q", got, want)
+	}
+}
+
 func TestDialerNoAuth(t *testing.T) {
 	d := &Dialer{
 		Host: testHost,
@@ -127,6 +149,7 @@ func TestDialerTimeout(t *testing.T) {
 		"Mail " + testFrom,
 		"Extension STARTTLS",
 		"StartTLS",
+		"Close",
 		"Mail " + testFrom,
 		"Rcpt " + testTo1,
 		"Rcpt " + testTo2,
@@ -138,13 +161,222 @@ func TestDialerTimeout(t *testing.T) {
 	})
 }
 
+func TestDialerIdleTimeout(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Extension STARTTLS",
+			"StartTLS",
+			"Close",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+		},
+		mailErr: &textproto.Error{Code: 421, Msg: "4.4.2 mx.example.com Error: timeout exceeded"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialerRedialOnce(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Extension STARTTLS",
+			"StartTLS",
+			"Close",
+			"Mail " + testFrom,
+			"Quit",
+		},
+		timeout: true,
+		mailErr: io.EOF,
+	})
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Invalid error, got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestDialerDSN(t *testing.T) {
+	d := &Dialer{
+		Host: testHost,
+		Port: testPort,
+		DSN: &DSN{
+			Notify:     NotifySuccess | NotifyFailure,
+			Return:     ReturnHeaders,
+			EnvelopeID: "id+1",
+		},
+	}
+	testSendMail(t, d, []string{
+		"Extension STARTTLS",
+		"StartTLS",
+		"Extension DSN",
+		"Mail " + testFrom + " RET=HDRS ENVID=id+2B1",
+		"Rcpt " + testTo1 + " NOTIFY=SUCCESS,FAILURE ORCPT=rfc822;" + testTo1,
+		"Rcpt " + testTo2 + " NOTIFY=SUCCESS,FAILURE ORCPT=rfc822;" + testTo2,
+		"Data",
+		"Write message",
+		"Close writer",
+		"Quit",
+		"Close",
+	})
+}
+
+func TestSMTPConnParams(t *testing.T) {
+	c, cmds := newFakeConn(t, []string{"8BITMIME", "DSN"})
+	if err := c.Mail(testFrom, "RET=FULL"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Rcpt(testTo1, "NOTIFY=NEVER"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Rcpt(testTo2); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Quit(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"EHLO localhost",
+		"MAIL FROM:<" + testFrom + "> BODY=8BITMIME RET=FULL",
+		"RCPT TO:<" + testTo1 + "> NOTIFY=NEVER",
+		"RCPT TO:<" + testTo2 + ">",
+		"QUIT",
+	}
+	if !reflect.DeepEqual(*cmds, want) {
+		t.Errorf("Invalid commands, got %q, want %q", *cmds, want)
+	}
+}
+
+// newFakeConn returns an smtpConn connected to a fake SMTP server advertising
+// the given extensions. The commands received by the server are appended to
+// the returned slice.
+func newFakeConn(t *testing.T, ext []string) (*smtpConn, *[]string) {
+	client, server := net.Pipe()
+	cmds := new([]string)
+	go func() {
+		defer server.Close()
+		tc := textproto.NewConn(server)
+		tc.PrintfLine("220 %s ESMTP", testHost)
+		for {
+			line, err := tc.ReadLine()
+			if err != nil {
+				return
+			}
+			*cmds = append(*cmds, line)
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				lines := append([]string{testHost}, ext...)
+				for i, l := range lines {
+					sep := "-"
+					if i == len(lines)-1 {
+						sep = " "
+					}
+					tc.PrintfLine("250%s%s", sep, l)
+				}
+			case line == "QUIT":
+				tc.PrintfLine("221 Bye")
+				return
+			default:
+				tc.PrintfLine("250 OK")
+			}
+		}
+	}()
+
+	c, err := smtp.NewClient(client, testHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &smtpConn{c}, cmds
+}
+
+func TestDialerRejectedRecipient(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Reset",
+			"Quit",
+			"Close",
+		},
+		rejected: map[string]bool{testTo2: true},
+	})
+
+	var serr *SendError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Invalid error, got %v, want a *SendError", err)
+	}
+	want := &SendError{
+		Accepted: []string{testTo1},
+		Rejected: []*RecipientError{{Address: testTo2, Code: 550, Message: "No such user"}},
+	}
+	if !reflect.DeepEqual(serr, want) {
+		t.Errorf("Invalid error, got %#v, want %#v", serr, want)
+	}
+}
+
+func TestDialerPartialSend(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort, AllowPartialSend: true}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+			"Close",
+		},
+		rejected: map[string]bool{testTo1: true},
+	})
+
+	var serr *SendError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Invalid error, got %v, want a *SendError", err)
+	}
+	if !serr.Sent || !reflect.DeepEqual(serr.Accepted, []string{testTo2}) {
+		t.Errorf("Invalid error, got %#v", serr)
+	}
+}
+
 type mockClient struct {
-	t       *testing.T
-	i       int
-	want    []string
-	addr    string
-	config  *tls.Config
-	timeout bool
+	t        *testing.T
+	i        int
+	want     []string
+	addr     string
+	config   *tls.Config
+	timeout  bool
+	mailErr  error
+	rejected map[string]bool
+	ext      map[string]string
+	// unsupported are the extensions not supported by the server, all the
+	// others are.
+	unsupported map[string]bool
+	// bodies are the emails expected by the successive DATA commands,
+	// testMsg is expected if empty.
+	bodies []string
 }
 
 func (c *mockClient) Hello(localName string) error {
@@ -152,9 +384,13 @@ func (c *mockClient) Hello(localName string) error {
 	return nil
 }
 
+func (c *mockClient) extensions() map[string]string {
+	return c.ext
+}
+
 func (c *mockClient) Extension(ext string) (bool, string) {
 	c.do("Extension " + ext)
-	return true, ""
+	return !c.unsupported[ext], ""
 }
 
 func (c *mockClient) StartTLS(config *tls.Config) error {
@@ -171,23 +407,52 @@ func (c *mockClient) Auth(a smtp.Auth) error {
 	return nil
 }
 
-func (c *mockClient) Mail(from string) error {
-	c.do("Mail " + from)
+func (c *mockClient) Mail(from string, params ...string) error {
+	c.do(strings.Join(append([]string{"Mail", from}, params...), " "))
 	if c.timeout {
 		c.timeout = false
 		return io.EOF
 	}
+	if err := c.mailErr; err != nil {
+		c.mailErr = nil
+		return err
+	}
 	return nil
 }
 
-func (c *mockClient) Rcpt(to string) error {
-	c.do("Rcpt " + to)
+func (c *mockClient) Rcpt(to string, params ...string) error {
+	c.do(strings.Join(append([]string{"Rcpt", to}, params...), " "))
+	if c.rejected[to] {
+		return &textproto.Error{Code: 550, Msg: "No such user"}
+	}
 	return nil
 }
 
 func (c *mockClient) Data() (io.WriteCloser, error) {
 	c.do("Data")
-	return &mockWriter{c: c, want: testMsg}, nil
+	want := testMsg
+	if len(c.bodies) > 0 {
+		want, c.bodies = c.bodies[0], c.bodies[1:]
+	}
+	return &mockWriter{c: c, want: want}, nil
+}
+
+func (c *mockClient) Bdat(binary bool) (io.WriteCloser, error) {
+	if binary {
+		c.do("Bdat binary")
+	} else {
+		c.do("Bdat")
+	}
+	want := testMsg
+	if len(c.bodies) > 0 {
+		want, c.bodies = c.bodies[0], c.bodies[1:]
+	}
+	return &mockWriter{c: c, want: want}, nil
+}
+
+func (c *mockClient) Reset() error {
+	c.do("Reset")
+	return nil
 }
 
 func (c *mockClient) Quit() error {
@@ -243,11 +508,18 @@ func doTestSendMail(t *testing.T, d *Dialer, want []string, timeout bool) {
 	testClient := &mockClient{
 		t:       t,
 		want:    want,
-		addr:    addr(d.Host, d.Port),
-		config:  d.TLSConfig,
 		timeout: timeout,
 	}
 
+	if err := sendMailWithClient(t, d, testClient); err != nil {
+		t.Error(err)
+	}
+}
+
+func sendMailWithClient(t *testing.T, d *Dialer, testClient *mockClient) error {
+	testClient.addr = addr(d.Host, d.Port)
+	testClient.config = d.TLSConfig
+
 	netDialTimeout = func(network, address string, d time.Duration) (net.Conn, error) {
 		if network != "tcp" {
 			t.Errorf("Invalid network, got %q, want tcp", network)
@@ -274,9 +546,7 @@ func doTestSendMail(t *testing.T, d *Dialer, want []string, timeout bool) {
 		return testClient, nil
 	}
 
-	if err := d.DialAndSend(getTestMessage()); err != nil {
-		t.Error(err)
-	}
+	return d.DialAndSend(getTestMessage())
 }
 
 func assertConfig(t *testing.T, got, want *tls.Config) {
@@ -290,3 +560,53 @@ func assertConfig(t *testing.T, got, want *tls.Config) {
 		t.Errorf("Invalid field InsecureSkipVerify in config, got %v, want %v", got.InsecureSkipVerify, want.InsecureSkipVerify)
 	}
 }
+
+func TestDialerDryRun(t *testing.T) {
+	d := NewDialer(testHost, testPort, "user", "pwd")
+	d.DryRun = true
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Extension AUTH",
+			"Auth",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Rcpt " + testTo2,
+			"Reset",
+			"Quit",
+			"Close",
+		},
+		rejected: map[string]bool{testTo2: true},
+	})
+	var serr *SendError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Invalid error, got %v, want a *SendError", err)
+	}
+	if serr.Sent || len(serr.Accepted) != 1 || serr.Accepted[0] != testTo1 || len(serr.Rejected) != 1 {
+		t.Errorf("Invalid error, got %+v", serr)
+	}
+}
+
+func TestDialerDryRunOutput(t *testing.T) {
+	var buf bytes.Buffer
+	d := NewDialer(testHost, testPort, "user", "pwd")
+	d.DryRun = true
+	d.DryRunOutput = &buf
+	netDialTimeout = func(network, address string, d time.Duration) (net.Conn, error) {
+		t.Error("The Dialer should not connect")
+		return nil, errors.New("dial")
+	}
+	if err := d.DialAndSend(getTestMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	envelope := "X-Sender: <" + testFrom + ">\r\n" +
+		"X-Receiver: <" + testTo1 + ">\r\n" +
+		"X-Receiver: <" + testTo2 + ">\r\n"
+	if !strings.HasPrefix(buf.String(), envelope) {
+		t.Fatalf("Invalid envelope, got:\n%s", buf.String())
+	}
+	compareBodies(t, strings.TrimSuffix(strings.TrimPrefix(buf.String(), envelope), "\r\n"), testMsg)
+}
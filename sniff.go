@@ -0,0 +1,132 @@
+package gomail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// SetContentType is a file setting to set the media type of the file instead
+// of guessing it from its name and content.
+func SetContentType(mediaType string) FileSetting {
+	return func(f *file) {
+		f.MediaType = mediaType
+	}
+}
+
+// sniffLen is the number of bytes read to detect the media type of a file, as
+// http.DetectContentType.
+const sniffLen = 512
+
+// mediaType returns the media type of the file: the one set with
+// SetContentType, or else guessed from its extension, or else detected from
+// its first bytes if its copy function can run several times.
+func (f *file) mediaType() string {
+	if f.MediaType != "" {
+		return f.MediaType
+	}
+	if t := mime.TypeByExtension(filepath.Ext(f.Name)); t != "" {
+		return t
+	}
+	if !f.repeatable {
+		return "application/octet-stream"
+	}
+	return sniffContentType(f.CopyFunc)
+}
+
+// errSniffed stops the copy of a file once its first bytes are read.
+var errSniffed = errors.New("gomail: enough bytes read")
+
+// headWriter keeps the first sniffLen bytes written.
+type headWriter struct {
+	buf []byte
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	n := sniffLen - len(w.buf)
+	if len(p) < n {
+		n = len(p)
+	}
+	w.buf = append(w.buf, p[:n]...)
+	if len(w.buf) == sniffLen {
+		return n, errSniffed
+	}
+	return n, nil
+}
+
+// sniffContentType returns the media type of the content written by copy,
+// detected from its first bytes, or application/octet-stream if it cannot be
+// read. The read error is then reported when the content is written.
+func sniffContentType(copy func(io.Writer) error) string {
+	w := new(headWriter)
+	if err := copy(w); err != nil && !errors.Is(err, errSniffed) {
+		return "application/octet-stream"
+	}
+	return detectContentType(w.buf)
+}
+
+// A signature identifies a media type by the bytes found at an offset of the
+// content.
+type signature struct {
+	offset    int
+	magic     string
+	mediaType string
+}
+
+// signatures are the media types not detected by http.DetectContentType.
+var signatures = []signature{
+	{0, "7z\xbc\xaf\x27\x1c", "application/x-7z-compressed"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\xfd7zXZ\x00", "application/x-xz"},
+	{257, "ustar", "application/x-tar"},
+	{0, "{\\rtf", "application/rtf"},
+	{0, "II*\x00", "image/tiff"},
+	{0, "MM\x00*", "image/tiff"},
+	{0, "8BPS", "image/vnd.adobe.photoshop"},
+	{4, "ftypheic", "image/heic"},
+	{4, "ftypavif", "image/avif"},
+	{0, "BEGIN:VCALENDAR", "text/calendar"},
+	{0, "BEGIN:VCARD", "text/vcard"},
+	{30, "mimetypeapplication/vnd.oasis.opendocument.text", "application/vnd.oasis.opendocument.text"},
+	{30, "mimetypeapplication/vnd.oasis.opendocument.spreadsheet", "application/vnd.oasis.opendocument.spreadsheet"},
+	{30, "mimetypeapplication/vnd.oasis.opendocument.presentation", "application/vnd.oasis.opendocument.presentation"},
+}
+
+// officeDirs are the directories identifying the Office Open XML documents,
+// which are ZIP archives.
+var officeDirs = []struct {
+	dir       string
+	mediaType string
+}{
+	{"word/", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	{"xl/", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	{"ppt/", "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+}
+
+// detectContentType returns the media type of the content starting with b.
+func detectContentType(b []byte) string {
+	for _, s := range signatures {
+		if len(b) >= s.offset+len(s.magic) && string(b[s.offset:s.offset+len(s.magic)]) == s.magic {
+			return s.mediaType
+		}
+	}
+
+	t := http.DetectContentType(b)
+	switch {
+	case strings.HasPrefix(t, "application/zip"):
+		for _, d := range officeDirs {
+			if bytes.Contains(b, []byte(d.dir)) {
+				return d.mediaType
+			}
+		}
+	case strings.HasPrefix(t, "text/xml"):
+		if bytes.Contains(b, []byte("<svg")) {
+			return "image/svg+xml"
+		}
+	}
+	return t
+}
@@ -0,0 +1,87 @@
+package gomail
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+	docx := append([]byte("PK\x03\x04\x14\x00\x06\x00"), "[Content_Types].xml word/document.xml"...)
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"%PDF-1.7\n", "application/pdf"},
+		{"\x89PNG\r\n\x1a\n", "image/png"},
+		{"7z\xbc\xaf\x27\x1c\x00\x04", "application/x-7z-compressed"},
+		{string(tar), "application/x-tar"},
+		{"{\\rtf1\\ansi", "application/rtf"},
+		{"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n", "text/calendar"},
+		{string(docx), "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"PK\x03\x04\x14\x00\x06\x00data.csv", "application/zip"},
+		{`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"/>`, "image/svg+xml"},
+		{"Hello!", "text/plain; charset=utf-8"},
+		{"\x00\x01\x02", "application/octet-stream"},
+	}
+	for _, test := range tests {
+		if got := detectContentType([]byte(test.content)); got != test.want {
+			t.Errorf("detectContentType(%q) = %q, want %q", test.content, got, test.want)
+		}
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 1000)
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "image")
+	if err := ioutil.WriteFile(image, []byte(png), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	oneShot := SetCopyFunc(func(w io.Writer) error {
+		calls++
+		_, err := io.WriteString(w, png)
+		return err
+	})
+
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", "Test")
+	m.Attach(image)
+	m.Attach("custom", oneShot)
+	m.Attach("report.pdf", oneShot)
+	m.Attach("data", oneShot, SetContentType("application/x-custom"))
+	m.EmbedReaderWithCID("logo", strings.NewReader(png))
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`Content-Type: image/png; name="image"`,
+		`Content-Type: application/octet-stream; name="custom"`,
+		`Content-Type: application/pdf; name="report.pdf"`,
+		`Content-Type: application/x-custom; name="data"`,
+		`Content-Type: image/png; name="logo"`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Missing %q in:\n%s", want, buf.String())
+		}
+	}
+	if calls != 3 {
+		t.Errorf("The custom copy functions should run once each, got %d calls", calls)
+	}
+}
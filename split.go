@@ -0,0 +1,70 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// SplitFor returns a copy of the message for one of its recipients, to be sent
+// to that recipient only: the To field only contains the recipient, with its
+// name if it is listed in the To or Cc field, and the Cc and Bcc fields are
+// removed.
+func (m *Message) SplitFor(address string) *Message {
+	c := m.Clone()
+	to := address
+	for _, field := range []string{"To", "Cc"} {
+		for _, v := range m.header[field] {
+			if addr, err := mail.ParseAddress(v); err == nil && strings.EqualFold(addr.Address, address) {
+				to = v
+				break
+			}
+		}
+		if to != address {
+			break
+		}
+	}
+	c.header["To"] = []string{to}
+	delete(c.header, "Cc")
+	delete(c.header, "Bcc")
+	delete(c.addrErrors, "Cc")
+	delete(c.addrErrors, "Bcc")
+	return c
+}
+
+// sendSplit sends a copy of m to each recipient of e in its own transaction.
+// The rejected recipients are reported in a *SendError whose Sent field is
+// true if the email was sent to the other recipients. Any other error stops
+// the sending, the recipients before it have already received the email.
+func (c *smtpSender) sendSplit(e *Envelope, m *Message) error {
+	var serr *SendError
+	accepted := make([]string, 0, len(e.To))
+	for _, addr := range e.To {
+		se := &Envelope{From: e.From, To: []string{addr}, Options: e.Options}
+		if c.d.VERP != "" {
+			se.From = VERPAddress(c.d.VERP, addr)
+		}
+		err := c.SendEnvelope(se, m.SplitFor(addr))
+		if err == nil {
+			accepted = append(accepted, addr)
+			continue
+		}
+
+		var rerr *SendError
+		if !errors.As(err, &rerr) {
+			return fmt.Errorf("gomail: could not send the email to %s: %w", addr, err)
+		}
+		if serr == nil {
+			serr = new(SendError)
+		}
+		serr.Rejected = append(serr.Rejected, rerr.Rejected...)
+	}
+
+	if serr != nil {
+		serr.Accepted = accepted
+		serr.Sent = len(accepted) > 0
+		return serr
+	}
+	return nil
+}
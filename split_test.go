@@ -0,0 +1,132 @@
+package gomail
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitFor(t *testing.T) {
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", "Alice <alice@example.com>", testTo1)
+	m.SetHeader("Cc", `"Bob" <BOB@example.com>`)
+	m.SetHeader("Bcc", testTo2)
+
+	tests := []struct {
+		addr, want string
+	}{
+		{"alice@example.com", "Alice <alice@example.com>"},
+		{"bob@example.com", `"Bob" <BOB@example.com>`},
+		{testTo2, testTo2},
+	}
+	for _, test := range tests {
+		c := m.SplitFor(test.addr)
+		if got := c.GetHeader("To"); len(got) != 1 || got[0] != test.want {
+			t.Errorf("SplitFor(%q): invalid To field, got %q, want %q", test.addr, got, test.want)
+		}
+		if len(c.GetHeader("Cc")) != 0 || len(c.GetHeader("Bcc")) != 0 {
+			t.Errorf("SplitFor(%q): the Cc and Bcc fields should be removed", test.addr)
+		}
+	}
+	if len(m.GetHeader("To")) != 2 || len(m.GetHeader("Cc")) != 1 {
+		t.Error("SplitFor should not modify the message")
+	}
+}
+
+func TestDialerSplitRecipients(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort, SplitRecipients: true}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Mail " + testFrom,
+			"Rcpt " + testTo2,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+			"Close",
+		},
+		bodies: []string{
+			strings.Replace(testMsg, testTo1+", "+testTo2, testTo1, 1),
+			strings.Replace(testMsg, testTo1+", "+testTo2, testTo2, 1),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialerSplitRecipientsVERP(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort, SplitRecipients: true, VERP: "bounces@example.com"}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail bounces+to1=example.com@example.com",
+			"Rcpt " + testTo1,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Mail bounces+to2=example.com@example.com",
+			"Rcpt " + testTo2,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+			"Close",
+		},
+		bodies: []string{
+			strings.Replace(testMsg, testTo1+", "+testTo2, testTo1, 1),
+			strings.Replace(testMsg, testTo1+", "+testTo2, testTo2, 1),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialerSplitRecipientsRejected(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort, SplitRecipients: true}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"StartTLS",
+			"Mail " + testFrom,
+			"Rcpt " + testTo1,
+			"Reset",
+			"Mail " + testFrom,
+			"Rcpt " + testTo2,
+			"Data",
+			"Write message",
+			"Close writer",
+			"Quit",
+			"Close",
+		},
+		rejected: map[string]bool{testTo1: true},
+		bodies:   []string{strings.Replace(testMsg, testTo1+", "+testTo2, testTo2, 1)},
+	})
+
+	var serr *SendError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Invalid error, got %v, want a *SendError", err)
+	}
+	want := &SendError{
+		Accepted: []string{testTo2},
+		Rejected: []*RecipientError{{Address: testTo1, Code: 550, Message: "No such user"}},
+		Sent:     true,
+	}
+	if !reflect.DeepEqual(serr, want) {
+		t.Errorf("Invalid error, got %#v, want %#v", serr, want)
+	}
+}
@@ -0,0 +1,218 @@
+package gomail
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// A Store persists the emails of a Queue until they are sent so they survive a
+// crash or a restart.
+type Store interface {
+	// Put persists msg with the envelope e and sets e.ID.
+	Put(e *StoredEnvelope, msg io.WriterTo) error
+	// Open returns a reader of the message stored with the given ID.
+	Open(id string) (io.ReadCloser, error)
+	// Delete removes the email stored with the given ID.
+	Delete(id string) error
+	// List returns the envelopes of the stored emails, oldest first.
+	List() ([]*StoredEnvelope, error)
+}
+
+// A StoredEnvelope is the envelope of an email persisted in a Store.
+type StoredEnvelope struct {
+	ID   string   `json:"-"`
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	// DSN are the delivery status notifications requested for the email.
+	DSN *DSN `json:"dsn,omitempty"`
+	// RequireTLS defines whether the email is only sent over an encrypted
+	// connection.
+	RequireTLS bool `json:"require_tls,omitempty"`
+	// SendAt is the time at which the email is scheduled to be sent, if any.
+	SendAt time.Time `json:"send_at"`
+}
+
+// A DirStore is a Store keeping each email in its own file in a directory.
+//
+// Like in a maildir, emails are written in the tmp subdirectory and atomically
+// moved to the new subdirectory once complete, so an email being written
+// during a crash is never sent.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns a DirStore using dir, creating it if needed.
+func NewDirStore(dir string) (*DirStore, error) {
+	for _, sub := range []string{"tmp", "new"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+// Put implements Store.
+func (s *DirStore) Put(e *StoredEnvelope, msg io.WriterTo) error {
+	id, err := newStoreID()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(s.dir, "tmp", id)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	err = writeStoredEmail(f, e, msg)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path(id))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	e.ID = id
+	return nil
+}
+
+// writeStoredEmail writes the envelope as a JSON line followed by the message.
+func writeStoredEmail(f *os.File, e *StoredEnvelope, msg io.WriterTo) error {
+	w := bufio.NewWriter(f)
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Open implements Store.
+func (s *DirStore) Open(id string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	if _, err := r.ReadSlice('\n'); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("gomail: invalid stored email %q: %w", id, err)
+	}
+	return &storedReader{r, f}, nil
+}
+
+type storedReader struct {
+	*bufio.Reader
+	f *os.File
+}
+
+func (r *storedReader) Close() error {
+	return r.f.Close()
+}
+
+// Delete implements Store.
+func (s *DirStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// List implements Store.
+func (s *DirStore) List() ([]*StoredEnvelope, error) {
+	names, err := readDirNames(filepath.Join(s.dir, "new"))
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*StoredEnvelope, 0, len(names))
+	for _, id := range names {
+		e, err := s.envelope(id)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+func (s *DirStore) envelope(id string) (*StoredEnvelope, error) {
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadSlice('\n')
+	if err != nil {
+		return nil, fmt.Errorf("gomail: invalid stored email %q: %w", id, err)
+	}
+	e := &StoredEnvelope{ID: id}
+	if err := json.Unmarshal(line, e); err != nil {
+		return nil, fmt.Errorf("gomail: invalid stored email %q: %w", id, err)
+	}
+	return e, nil
+}
+
+func (s *DirStore) path(id string) string {
+	return filepath.Join(s.dir, "new", id)
+}
+
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+var storeSeq uint32
+
+// newStoreID returns a unique ID whose lexical order is the creation order.
+func newStoreID() (string, error) {
+	var b [6]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
+	}
+	seq := atomic.AddUint32(&storeSeq, 1)
+	return fmt.Sprintf("%020d.%010d.%s", time.Now().UnixNano(), seq, hex.EncodeToString(b[:])), nil
+}
+
+// storedMessage is a message read from a Store when it is sent.
+type storedMessage struct {
+	store Store
+	id    string
+}
+
+func (m *storedMessage) WriteTo(w io.Writer) (int64, error) {
+	r, err := m.store.Open(m.id)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(w, r)
+}
@@ -0,0 +1,157 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testDirStore(t *testing.T) (*DirStore, func()) {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewDirStore(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestDirStore(t *testing.T) {
+	s, cleanup := testDirStore(t)
+	defer cleanup()
+
+	var ids []string
+	for _, to := range []string{testTo1, testTo2} {
+		e := &StoredEnvelope{From: testFrom, To: []string{to}}
+		if err := s.Put(e, testQueueMessage(to)); err != nil {
+			t.Fatal(err)
+		}
+		if e.ID == "" {
+			t.Fatal("Put did not set the ID")
+		}
+		ids = append(ids, e.ID)
+	}
+	// A partially written email must be ignored.
+	if err := ioutil.WriteFile(filepath.Join(s.dir, "tmp", "partial"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("Invalid number of stored emails, got %d, want 2", len(list))
+	}
+	for i, e := range list {
+		if e.ID != ids[i] || e.From != testFrom || len(e.To) != 1 {
+			t.Errorf("Invalid envelope #%d, got %+v", i, e)
+		}
+	}
+	if list[1].To[0] != testTo2 {
+		t.Errorf("Invalid order, got %q, want %q", list[1].To[0], testTo2)
+	}
+
+	var want bytes.Buffer
+	if _, err := testQueueMessage(testTo1).WriteTo(&want); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if _, err := (&storedMessage{store: s, id: ids[0]}).WriteTo(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(got.Bytes(), []byte("\r\n\r\n"+testBody)) || got.Len() != want.Len() {
+		t.Errorf("Invalid stored message, got:\n%s\nwant:\n%s", got.String(), want.String())
+	}
+
+	if err := s.Delete(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ids[0]); err != nil {
+		t.Errorf("Delete of a missing email returned %v", err)
+	}
+	if list, err = s.List(); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 || list[0].ID != ids[1] {
+		t.Errorf("Invalid stored emails after Delete, got %+v", list)
+	}
+}
+
+func TestQueueSpool(t *testing.T) {
+	s, cleanup := testDirStore(t)
+	defer cleanup()
+
+	// The server is down: the emails must stay in the store.
+	down := &fakeDialer{fail: func(int) error {
+		return &textproto.Error{Code: 421, Msg: "Service not available"}
+	}}
+	var errs int
+	q := NewQueue(down, Spool(s), OnError(func(m *Message, err error) {
+		if m == nil {
+			t.Error("OnError received a nil message")
+		}
+		errs++
+	}))
+	for _, to := range []string{testTo1, testTo2} {
+		if err := q.Enqueue(testQueueMessage(to)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if errs != 2 {
+		t.Errorf("Invalid number of errors, got %d, want 2", errs)
+	}
+	if list, err := s.List(); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 2 {
+		t.Fatalf("Invalid number of stored emails, got %d, want 2", len(list))
+	}
+
+	// A new queue sends the stored emails and removes them.
+	up := &fakeDialer{}
+	q = NewQueue(up, Spool(s))
+	if err := q.Enqueue(testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(up.sent) != 3 {
+		t.Errorf("Invalid number of emails sent, got %d, want 3", len(up.sent))
+	}
+	if list, err := s.List(); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 0 {
+		t.Errorf("Invalid number of stored emails, got %d, want 0", len(list))
+	}
+}
+
+func TestQueueSpoolPermanentFailure(t *testing.T) {
+	s, cleanup := testDirStore(t)
+	defer cleanup()
+
+	d := &fakeDialer{fail: func(int) error {
+		return &textproto.Error{Code: 550, Msg: "Mailbox unavailable"}
+	}}
+	q := NewQueue(d, Spool(s))
+	if err := q.Enqueue(testQueueMessage(testTo1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := s.List(); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 0 {
+		t.Errorf("Invalid number of stored emails, got %d, want 0", len(list))
+	}
+}
@@ -0,0 +1,309 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// A TemplateStore holds named email templates loaded from a file system, for
+// example an embed.FS in production or os.DirFS in development, where Watch
+// reloads the templates when they are edited. It is safe for concurrent use.
+// NewTemplateStore, which loads the templates from an fs.FS, requires Go 1.16
+// or newer; NewTemplateStoreDir loads them from a directory with any Go
+// version.
+//
+// Each directory at the root of the file system is a template named after the
+// directory and made of the files:
+//
+//	subject.txt  the text/template of the Subject field, required
+//	text.txt     the text/template of the text/plain body
+//	html.html    the html/template of the text/html body
+//	assets/      the files embedded in the email, referenced as "cid:<name>"
+//
+// At least one of the bodies is required.
+type TemplateStore struct {
+	files templateFiles
+
+	mu        sync.RWMutex
+	templates map[string]*emailTemplate
+	stamp     string
+}
+
+type emailTemplate struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+	assets  []templateAsset
+}
+
+type templateAsset struct {
+	name string
+	data []byte
+}
+
+// templateFiles are the files of a TemplateStore. The names are slash-separated
+// paths relative to the root of the templates.
+type templateFiles interface {
+	readFile(name string) ([]byte, error)
+	// readDir returns the entries of a directory sorted by name.
+	readDir(name string) ([]os.FileInfo, error)
+	// walk calls fn for each regular file, in lexical order.
+	walk(fn func(name string, info os.FileInfo) error) error
+}
+
+// NewTemplateStoreDir loads the templates of the directory dir.
+func NewTemplateStoreDir(dir string) (*TemplateStore, error) {
+	return newTemplateStore(dirFiles(dir))
+}
+
+func newTemplateStore(files templateFiles) (*TemplateStore, error) {
+	s := &TemplateStore{files: files}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// dirFiles are the files of a directory.
+type dirFiles string
+
+func (d dirFiles) path(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(name))
+}
+
+func (d dirFiles) readFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(d.path(name))
+}
+
+func (d dirFiles) readDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(d.path(name))
+}
+
+func (d dirFiles) walk(fn func(name string, info os.FileInfo) error) error {
+	return filepath.Walk(string(d), func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(string(d), p)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), info)
+	})
+}
+
+// Names returns the names of the templates, sorted.
+func (s *TemplateStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.templates))
+	for name := range s.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the template name with data and sets the Subject field, the
+// bodies and the embedded assets of m.
+func (s *TemplateStore) Render(m *Message, name string, data interface{}) error {
+	s.mu.RLock()
+	t, ok := s.templates[name]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("gomail: unknown template %q", name)
+	}
+
+	var buf bytes.Buffer
+	if err := t.subject.Execute(&buf, data); err != nil {
+		return fmt.Errorf("gomail: could not execute the subject of template %q: %w", name, err)
+	}
+	subject := strings.TrimSpace(buf.String())
+
+	var bodies []struct{ contentType, body string }
+	if t.text != nil {
+		buf.Reset()
+		if err := t.text.Execute(&buf, data); err != nil {
+			return fmt.Errorf("gomail: could not execute the text body of template %q: %w", name, err)
+		}
+		bodies = append(bodies, struct{ contentType, body string }{"text/plain", buf.String()})
+	}
+	if t.html != nil {
+		buf.Reset()
+		if err := t.html.Execute(&buf, data); err != nil {
+			return fmt.Errorf("gomail: could not execute the HTML body of template %q: %w", name, err)
+		}
+		bodies = append(bodies, struct{ contentType, body string }{"text/html", buf.String()})
+	}
+
+	m.SetHeader("Subject", subject)
+	for i, b := range bodies {
+		if i == 0 {
+			m.SetBody(b.contentType, b.body)
+		} else {
+			m.AddAlternative(b.contentType, b.body)
+		}
+	}
+	for _, a := range t.assets {
+		m.Embed(a.name, setContent(a.data))
+	}
+	return nil
+}
+
+// Reload loads the templates again. If a template is invalid, an error is
+// returned and the previous templates are kept.
+func (s *TemplateStore) Reload() error {
+	stamp, err := s.fingerprint()
+	if err != nil {
+		return err
+	}
+	entries, err := s.files.readDir(".")
+	if err != nil {
+		return fmt.Errorf("gomail: could not list the templates: %w", err)
+	}
+
+	templates := make(map[string]*emailTemplate)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		t, err := loadEmailTemplate(s.files, e.Name())
+		if err != nil {
+			return err
+		}
+		templates[e.Name()] = t
+	}
+
+	s.mu.Lock()
+	s.templates, s.stamp = templates, stamp
+	s.mu.Unlock()
+	return nil
+}
+
+func loadEmailTemplate(files templateFiles, name string) (*emailTemplate, error) {
+	read := func(file string) (string, bool, error) {
+		b, err := files.readFile(path.Join(name, file))
+		if errors.Is(err, os.ErrNotExist) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("gomail: could not read the template %q: %w", name, err)
+		}
+		return string(b), true, nil
+	}
+	invalid := func(file string, err error) error {
+		return fmt.Errorf("gomail: invalid template %s/%s: %w", name, file, err)
+	}
+
+	t := new(emailTemplate)
+	src, ok, err := read("subject.txt")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("gomail: the template %q has no subject.txt file", name)
+	}
+	if t.subject, err = template.New("subject").Parse(src); err != nil {
+		return nil, invalid("subject.txt", err)
+	}
+
+	if src, ok, err = read("text.txt"); err != nil {
+		return nil, err
+	} else if ok {
+		if t.text, err = template.New("text").Parse(src); err != nil {
+			return nil, invalid("text.txt", err)
+		}
+	}
+	if src, ok, err = read("html.html"); err != nil {
+		return nil, err
+	} else if ok {
+		if t.html, err = htmltemplate.New("html").Parse(src); err != nil {
+			return nil, invalid("html.html", err)
+		}
+	}
+	if t.text == nil && t.html == nil {
+		return nil, fmt.Errorf("gomail: the template %q has no text.txt or html.html file", name)
+	}
+
+	assets, err := files.readDir(path.Join(name, "assets"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("gomail: could not list the assets of template %q: %w", name, err)
+	}
+	for _, a := range assets {
+		if a.IsDir() {
+			continue
+		}
+		b, err := files.readFile(path.Join(name, "assets", a.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("gomail: could not read the assets of template %q: %w", name, err)
+		}
+		t.assets = append(t.assets, templateAsset{name: a.Name(), data: b})
+	}
+	return t, nil
+}
+
+// Watch reloads the templates when their files change, checking every
+// interval until ctx is done. The errors, including invalid templates, are
+// passed to errorFunc if not nil and the previous templates are kept. The
+// files of an embed.FS never change, so Watch is only useful with a file
+// system like os.DirFS or with NewTemplateStoreDir.
+func (s *TemplateStore) Watch(ctx context.Context, interval time.Duration, errorFunc func(err error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := s.reloadIfChanged()
+				if err != nil && errorFunc != nil {
+					errorFunc(err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *TemplateStore) reloadIfChanged() error {
+	stamp, err := s.fingerprint()
+	if err != nil {
+		return err
+	}
+	// The stamp is updated even if the templates are invalid, so the error is
+	// only reported once per change.
+	s.mu.Lock()
+	changed := stamp != s.stamp
+	s.stamp = stamp
+	s.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	return s.Reload()
+}
+
+// fingerprint returns a summary of the names, sizes and modification times of
+// the files, which changes when a file is edited, added or removed.
+func (s *TemplateStore) fingerprint() (string, error) {
+	var sb strings.Builder
+	err := s.files.walk(func(name string, info os.FileInfo) error {
+		fmt.Fprintf(&sb, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("gomail: could not list the templates: %w", err)
+	}
+	return sb.String(), nil
+}
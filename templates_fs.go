@@ -0,0 +1,51 @@
+//go:build go1.16
+// +build go1.16
+
+package gomail
+
+import (
+	"io/fs"
+	"os"
+)
+
+// NewTemplateStore loads the templates of fsys. It requires Go 1.16 or newer,
+// see NewTemplateStoreDir for older versions.
+func NewTemplateStore(fsys fs.FS) (*TemplateStore, error) {
+	return newTemplateStore(fsFiles{fsys})
+}
+
+// fsFiles are the files of an fs.FS.
+type fsFiles struct {
+	fsys fs.FS
+}
+
+func (f fsFiles) readFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, name)
+}
+
+func (f fsFiles) readDir(name string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(f.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, len(entries))
+	for i, e := range entries {
+		if infos[i], err = e.Info(); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+func (f fsFiles) walk(fn func(name string, info os.FileInfo) error) error {
+	return fs.WalkDir(f.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(p, info)
+	})
+}
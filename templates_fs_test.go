@@ -0,0 +1,102 @@
+//go:build go1.16
+// +build go1.16
+
+package gomail
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testTemplateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"welcome/subject.txt":     {Data: []byte("Welcome {{.Name}}\n")},
+		"welcome/text.txt":        {Data: []byte("Hello {{.Name}}")},
+		"welcome/html.html":       {Data: []byte(`<p>Hello {{.Name}}</p><img src="cid:logo.png">`)},
+		"welcome/assets/logo.png": {Data: []byte("PNG")},
+		"reset/subject.txt":       {Data: []byte("Reset your password")},
+		"reset/text.txt":          {Data: []byte("Code: {{.Code}}")},
+		"README":                  {Data: []byte("not a template")},
+	}
+}
+
+func TestTemplateStore(t *testing.T) {
+	s, err := NewTemplateStore(testTemplateFS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Names(), []string{"reset", "welcome"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid names, got %v, want %v", got, want)
+	}
+
+	m := NewMessage()
+	if err := s.Render(m, "welcome", map[string]string{"Name": "<Ana>"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetHeader("Subject"); len(got) != 1 || got[0] != "Welcome <Ana>" {
+		t.Errorf("Invalid subject, got %q", got)
+	}
+	var bodies []string
+	for _, p := range m.Parts() {
+		var buf bytes.Buffer
+		p.WriteTo(&buf)
+		bodies = append(bodies, p.ContentType+": "+buf.String())
+	}
+	want := []string{
+		"text/plain: Hello <Ana>",
+		`text/html: <p>Hello &lt;Ana&gt;</p><img src="cid:logo.png">`,
+	}
+	if !reflect.DeepEqual(bodies, want) {
+		t.Errorf("Invalid bodies, got %q, want %q", bodies, want)
+	}
+	if files := m.EmbeddedFiles(); len(files) != 1 || files[0].Name != "logo.png" {
+		t.Errorf("Invalid embedded files, got %+v", files)
+	}
+
+	if err := s.Render(NewMessage(), "missing", nil); err == nil {
+		t.Error("Render should fail with an unknown template")
+	}
+}
+
+func TestTemplateStoreInvalid(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"no subject": {"a/text.txt": {Data: []byte("Hello")}},
+		"no body":    {"a/subject.txt": {Data: []byte("Hello")}},
+		"syntax": {
+			"a/subject.txt": {Data: []byte("Hello")},
+			"a/html.html":   {Data: []byte("{{.Name")},
+		},
+	} {
+		if _, err := NewTemplateStore(fsys); err == nil {
+			t.Errorf("%s: NewTemplateStore should fail", name)
+		}
+	}
+}
+
+func TestTemplateStoreReload(t *testing.T) {
+	fsys := testTemplateFS()
+	s, err := NewTemplateStore(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["reset/text.txt"] = &fstest.MapFile{Data: []byte("{{.Code"), ModTime: time.Unix(1, 0)}
+	if err := s.reloadIfChanged(); err == nil {
+		t.Error("The invalid template should be reported")
+	}
+	if got := s.Names(); len(got) != 2 {
+		t.Errorf("The previous templates should be kept, got %v", got)
+	}
+
+	delete(fsys, "reset/text.txt")
+	delete(fsys, "reset/subject.txt")
+	if err := s.reloadIfChanged(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Names(), []string{"welcome"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid names, got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package gomail
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTemplateDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "gomail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTemplateStoreDir(t *testing.T) {
+	dir := writeTemplateDir(t, map[string]string{
+		"welcome/subject.txt":     "Welcome {{.Name}}\n",
+		"welcome/html.html":       `<p>Hello {{.Name}}</p><img src="cid:logo.png">`,
+		"welcome/assets/logo.png": "PNG",
+		"reset/subject.txt":       "Reset your password",
+		"reset/text.txt":          "Code: {{.Code}}",
+		"README":                  "not a template",
+	})
+	defer os.RemoveAll(dir)
+
+	s, err := NewTemplateStoreDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Names(), []string{"reset", "welcome"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid names, got %v, want %v", got, want)
+	}
+
+	m := NewMessage()
+	if err := s.Render(m, "welcome", map[string]string{"Name": "<Ana>"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetHeader("Subject"); len(got) != 1 || got[0] != "Welcome <Ana>" {
+		t.Errorf("Invalid subject, got %q", got)
+	}
+	if files := m.EmbeddedFiles(); len(files) != 1 || files[0].Name != "logo.png" {
+		t.Errorf("Invalid embedded files, got %v", files)
+	}
+
+	if _, err := NewTemplateStoreDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("A missing directory should be reported")
+	}
+}
+
+func TestTemplateStoreWatch(t *testing.T) {
+	dir := writeTemplateDir(t, map[string]string{
+		"a/subject.txt": "Hello",
+		"a/text.txt":    "v1",
+	})
+	defer os.RemoveAll(dir)
+	s, err := NewTemplateStoreDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Watch(ctx, 5*time.Millisecond, func(err error) {
+		// The files are removed once ctx is canceled.
+		if ctx.Err() == nil {
+			t.Error(err)
+		}
+	})
+	if err := ioutil.WriteFile(filepath.Join(dir, "a", "text.txt"), []byte("version 2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 200; i++ {
+		m := NewMessage()
+		if err := s.Render(m, "a", nil); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		m.Parts()[0].WriteTo(&buf)
+		if strings.Contains(buf.String(), "version 2") {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("The edited template should be reloaded")
+}
@@ -0,0 +1,96 @@
+package gomail
+
+import (
+	"crypto/tls"
+	"errors"
+)
+
+// A TLSPolicy defines whether a Dialer encrypts the connection with the
+// STARTTLS extension. It is not used with Dialer.SSL, where the connection is
+// always encrypted.
+type TLSPolicy int
+
+const (
+	// OpportunisticTLS uses STARTTLS when the server supports it and sends
+	// the emails in plaintext otherwise. It is the default.
+	OpportunisticTLS TLSPolicy = iota
+	// MandatoryTLS requires STARTTLS: Dial fails with ErrTLSRequired if the
+	// server does not support it, so the emails are never sent in plaintext.
+	MandatoryTLS
+	// NoTLS never uses STARTTLS, for example for a local relay.
+	NoTLS
+)
+
+func (p TLSPolicy) String() string {
+	switch p {
+	case OpportunisticTLS:
+		return "opportunistic"
+	case MandatoryTLS:
+		return "mandatory"
+	case NoTLS:
+		return "none"
+	}
+	return "unknown"
+}
+
+// parseTLSPolicy returns the TLSPolicy named s, see TLSPolicy.String. The empty
+// string is OpportunisticTLS.
+func parseTLSPolicy(s string) (TLSPolicy, bool) {
+	if s == "" {
+		return OpportunisticTLS, true
+	}
+	for _, p := range []TLSPolicy{OpportunisticTLS, MandatoryTLS, NoTLS} {
+		if s == p.String() {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// errNoSTARTTLS is returned, wrapped with ErrTLSRequired, when the MandatoryTLS
+// policy is used with a server not supporting STARTTLS.
+var errNoSTARTTLS = errors.New("gomail: the SMTP server does not support STARTTLS")
+
+// RequireTLS is a message setting to only send the email over an encrypted
+// connection, whatever the TLSPolicy of the Dialer, so a Dialer using
+// OpportunisticTLS can also send sensitive emails. If the connection is not
+// encrypted, the email is not sent and the error wraps ErrTLSRequired. It only
+// applies to the connection to the SMTP server, not to the next hops.
+func RequireTLS() MessageSetting {
+	return func(m *Message) {
+		m.requireTLS = true
+	}
+}
+
+// errNotEncrypted is returned, wrapped with ErrTLSRequired, when an email
+// requiring TLS is sent over an unencrypted connection.
+var errNotEncrypted = errors.New("gomail: the email requires TLS but the connection is not encrypted")
+
+// tlsConfig returns the TLS configuration used with the server: the one
+// returned by TLSConfigFor, TLSConfig or a default one verifying the host
+// name, with the MinTLSVersion and ClientCertificates settings applied.
+func (d *Dialer) tlsConfig() *tls.Config {
+	var config *tls.Config
+	if d.TLSConfigFor != nil {
+		config = d.TLSConfigFor(d.Host)
+	}
+	if config == nil {
+		config = d.TLSConfig
+	}
+	if config == nil {
+		config = &tls.Config{ServerName: d.Host}
+	}
+	if d.MinTLSVersion == 0 && len(d.ClientCertificates) == 0 {
+		return config
+	}
+
+	// The configuration of the user is not modified.
+	config = config.Clone()
+	if d.MinTLSVersion > config.MinVersion {
+		config.MinVersion = d.MinTLSVersion
+	}
+	if len(config.Certificates) == 0 {
+		config.Certificates = d.ClientCertificates
+	}
+	return config
+}
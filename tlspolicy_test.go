@@ -0,0 +1,121 @@
+package gomail
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+)
+
+func TestDialerMandatoryTLS(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort, TLSPolicy: MandatoryTLS}
+	err := sendMailWithClient(t, d, &mockClient{
+		t: t,
+		want: []string{
+			"Extension STARTTLS",
+			"Close",
+		},
+		unsupported: map[string]bool{"STARTTLS": true},
+	})
+	if !errors.Is(err, ErrTLSRequired) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrTLSRequired)
+	}
+}
+
+func TestDialerNoTLS(t *testing.T) {
+	d := &Dialer{Host: testHost, Port: testPort, TLSPolicy: NoTLS}
+	testSendMail(t, d, []string{
+		"Mail " + testFrom,
+		"Rcpt " + testTo1,
+		"Rcpt " + testTo2,
+		"Data",
+		"Write message",
+		"Close writer",
+		"Quit",
+		"Close",
+	})
+}
+
+func TestDialerTLSConfig(t *testing.T) {
+	cert := tls.Certificate{Certificate: [][]byte{[]byte("cert")}}
+	user := &tls.Config{ServerName: "user.example.com", MinVersion: tls.VersionTLS10}
+	d := &Dialer{
+		Host:               testHost,
+		TLSConfig:          user,
+		MinTLSVersion:      tls.VersionTLS12,
+		ClientCertificates: []tls.Certificate{cert},
+	}
+
+	got := d.tlsConfig()
+	if got.ServerName != "user.example.com" || got.MinVersion != tls.VersionTLS12 || len(got.Certificates) != 1 {
+		t.Errorf("Invalid config, got %+v", got)
+	}
+	if user.MinVersion != tls.VersionTLS10 || len(user.Certificates) != 0 {
+		t.Error("The TLS configuration of the user should not be modified")
+	}
+
+	var host string
+	d.TLSConfigFor = func(h string) *tls.Config {
+		host = h
+		return &tls.Config{ServerName: "for.example.com", MinVersion: tls.VersionTLS13}
+	}
+	got = d.tlsConfig()
+	if host != testHost {
+		t.Errorf("Invalid host, got %q, want %q", host, testHost)
+	}
+	if got.ServerName != "for.example.com" || got.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Invalid config, got %+v", got)
+	}
+
+	d.TLSConfigFor = func(string) *tls.Config { return nil }
+	if got := d.tlsConfig(); got.ServerName != "user.example.com" {
+		t.Errorf("TLSConfig should be used when TLSConfigFor returns nil, got %+v", got)
+	}
+}
+
+func TestParseTLSPolicy(t *testing.T) {
+	tests := []struct {
+		s    string
+		want TLSPolicy
+		ok   bool
+	}{
+		{"", OpportunisticTLS, true},
+		{"opportunistic", OpportunisticTLS, true},
+		{"mandatory", MandatoryTLS, true},
+		{"none", NoTLS, true},
+		{"always", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := parseTLSPolicy(test.s)
+		if got != test.want || ok != test.ok {
+			t.Errorf("parseTLSPolicy(%q) = %v, %v, want %v, %v", test.s, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestRequireTLS(t *testing.T) {
+	m := getTestMessage()
+	RequireTLS()(m)
+
+	c := &mockClient{t: t}
+	s := &smtpSender{smtpClient: c, d: &Dialer{}}
+	if err := Send(s, m); !errors.Is(err, ErrTLSRequired) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrTLSRequired)
+	}
+
+	c = &mockClient{t: t, want: []string{
+		"Mail " + testFrom,
+		"Rcpt " + testTo1,
+		"Rcpt " + testTo2,
+		"Data",
+		"Write message",
+		"Close writer",
+	}}
+	s = &smtpSender{smtpClient: c, d: &Dialer{}, encrypted: true}
+	if err := Send(s, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if e, err := m.Clone().Envelope(); err != nil || !e.Options.RequireTLS {
+		t.Errorf("The envelope should require TLS, got %+v, %v", e, err)
+	}
+}
@@ -0,0 +1,41 @@
+package gomail
+
+import "strings"
+
+// SetListUnsubscribe sets the List-Unsubscribe header defined in RFC 2369 to
+// the given mailto and HTTPS unsubscribe addresses. Either of them can be
+// empty. The "mailto:" scheme is added to mailto if missing. As with SetHeader,
+// the CR, LF and NUL characters are removed and, with the StrictAddresses
+// setting, reported by Validate.
+//
+// If oneClick is true and url is not empty, the List-Unsubscribe-Post header
+// is also set to enable one-click unsubscription as defined in RFC 8058: mail
+// clients then unsubscribe the recipient with a POST request to url, which
+// must therefore use HTTPS and not require any other interaction. RFC 8058
+// also requires the email to have a valid DKIM signature covering both
+// headers, which DKIMSigner does by default.
+func (m *Message) SetListUnsubscribe(mailto, url string, oneClick bool) {
+	m.checkInjection("List-Unsubscribe", "List-Unsubscribe", mailto, url)
+	mailto, url = stripControlBreaks(mailto), stripControlBreaks(url)
+
+	var uris []string
+	if mailto != "" {
+		if !strings.HasPrefix(strings.ToLower(mailto), "mailto:") {
+			mailto = "mailto:" + mailto
+		}
+		uris = append(uris, "<"+mailto+">")
+	}
+	if url != "" {
+		uris = append(uris, "<"+url+">")
+	}
+
+	delete(m.header, "List-Unsubscribe-Post")
+	if len(uris) == 0 {
+		delete(m.header, "List-Unsubscribe")
+		return
+	}
+	m.header["List-Unsubscribe"] = []string{strings.Join(uris, ", ")}
+	if oneClick && url != "" {
+		m.header["List-Unsubscribe-Post"] = []string{"List-Unsubscribe=One-Click"}
+	}
+}
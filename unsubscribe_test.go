@@ -0,0 +1,67 @@
+package gomail
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetListUnsubscribe(t *testing.T) {
+	tests := []struct {
+		mailto, url string
+		oneClick    bool
+		want, post  string
+	}{
+		{
+			"unsubscribe@example.com", "https://example.com/unsubscribe?id=42", true,
+			"<mailto:unsubscribe@example.com>, <https://example.com/unsubscribe?id=42>",
+			"List-Unsubscribe=One-Click",
+		},
+		{
+			"mailto:unsubscribe@example.com?subject=unsubscribe", "", true,
+			"<mailto:unsubscribe@example.com?subject=unsubscribe>", "",
+		},
+		{
+			"", "https://example.com/unsubscribe", false,
+			"<https://example.com/unsubscribe>", "",
+		},
+		{"", "", true, "", ""},
+	}
+
+	for _, test := range tests {
+		m := NewMessage()
+		m.SetHeader("From", testFrom)
+		m.SetListUnsubscribe("old@example.com", "https://example.com/old", true)
+		m.SetListUnsubscribe(test.mailto, test.url, test.oneClick)
+
+		h := renderHeader(t, m)
+		if got := h.Get("List-Unsubscribe"); got != test.want {
+			t.Errorf("Invalid List-Unsubscribe, got %q, want %q", got, test.want)
+		}
+		if got := h.Get("List-Unsubscribe-Post"); got != test.post {
+			t.Errorf("Invalid List-Unsubscribe-Post, got %q, want %q", got, test.post)
+		}
+	}
+}
+
+func TestSetListUnsubscribeInjection(t *testing.T) {
+	m := NewMessage(StrictAddresses())
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetListUnsubscribe("unsubscribe@example.com", "https://example.com/u\r\nBcc: evil@example.com", true)
+
+	h := renderHeader(t, m)
+	if got, want := h.Get("List-Unsubscribe"), "<mailto:unsubscribe@example.com>, <https://example.com/uBcc: evil@example.com>"; got != want {
+		t.Errorf("Invalid List-Unsubscribe, got %q, want %q", got, want)
+	}
+	if bcc := h.Get("Bcc"); bcc != "" {
+		t.Errorf("Injected Bcc field %q", bcc)
+	}
+	if err := m.Validate(); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrInvalidHeader)
+	}
+
+	m.SetListUnsubscribe("unsubscribe@example.com", "https://example.com/u", true)
+	if err := m.Validate(); err != nil {
+		t.Errorf("Setting a valid URI should clear the error, got %v", err)
+	}
+}
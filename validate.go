@@ -0,0 +1,254 @@
+package gomail
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StrictAddresses is a message setting to validate the addresses when they are
+// set with SetHeader or SetAddressHeader, instead of when the email is sent.
+//
+// Besides being parsed as defined in RFC 5322, the addresses must have a local
+// part of at most 64 octets and a valid domain name. The invalid addresses are
+// still set but Validate returns them and the email is not sent.
+//
+// The header fields, addresses and file names containing CR, LF or NUL
+// characters are also reported, with ErrInvalidHeader, instead of being
+// silently sanitized.
+func StrictAddresses() MessageSetting {
+	return func(m *Message) {
+		m.strict = true
+	}
+}
+
+// An AddressError describes an invalid address of a message.
+type AddressError struct {
+	// Field is the header field containing the address, for example "To".
+	Field string
+	// Address is the invalid value.
+	Address string
+	// Err is the reason why the address is invalid.
+	Err error
+}
+
+func (e *AddressError) Error() string {
+	return "gomail: invalid address " + strconv.Quote(e.Address) + " in the " +
+		strconv.Quote(e.Field) + " field: " + e.Err.Error()
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// A ValidationError lists the problems found by Message.Validate.
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = strings.TrimPrefix(err.Error(), "gomail: ")
+	}
+	return "gomail: invalid message: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether one of the errors matches target.
+func (e *ValidationError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxSize is a message setting to set the maximum size in bytes of the
+// rendered message, checked by Validate and before the message is sent or
+// enqueued. A message exceeding it is not sent and Send, Queue.Enqueue or
+// Mailer.Send returns an error wrapping ErrMessageTooLarge, unless the
+// OnOversize setting makes it fit.
+func MaxSize(n int64) MessageSetting {
+	return func(m *Message) {
+		m.maxSize = n
+	}
+}
+
+// addressFieldNames are the address fields in the order they are validated.
+var addressFieldNames = []string{"From", "Sender", "Reply-To", "To", "Cc", "Bcc"}
+
+// Validate checks that the message can be sent before connecting to a server:
+//   - it has a sender and at least one recipient,
+//   - its addresses are valid,
+//   - its embedded files have distinct Content-IDs,
+//   - its alternative bodies are not empty,
+//   - its attached and embedded files can be read,
+//   - it does not exceed the MaxSize setting, if any.
+//
+// It returns a *ValidationError listing all the problems found. Since the
+// bodies and files are read, Validate can be costly for large emails.
+func (m *Message) Validate() error {
+	errs := append(m.headerErrors(), m.contentErrors()...)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (m *Message) headerErrors() []error {
+	var errs []error
+	for _, field := range addressFieldNames {
+		if m.strict {
+			errs = append(errs, m.addrErrors[field]...)
+			continue
+		}
+		for _, v := range m.header[field] {
+			if _, err := mail.ParseAddress(v); err != nil {
+				errs = append(errs, &AddressError{Field: field, Address: v, Err: err})
+			}
+		}
+	}
+
+	if m.strict {
+		errs = append(errs, m.injectionErrs()...)
+	}
+
+	if len(m.header["From"]) == 0 && len(m.header["Sender"]) == 0 {
+		errs = append(errs, errors.New(`gomail: the "From" field is absent`))
+	}
+	if len(m.header["To"])+len(m.header["Cc"])+len(m.header["Bcc"]) == 0 {
+		errs = append(errs, errors.New("gomail: no recipient"))
+	}
+	return errs
+}
+
+func (m *Message) contentErrors() []error {
+	var errs []error
+
+	ids := make(map[string]string)
+	for _, f := range m.embedded {
+		id := "<" + f.Name + ">"
+		if v := f.Header["Content-ID"]; len(v) > 0 {
+			id = v[0]
+		}
+		id = strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">")
+		if name, ok := ids[id]; ok {
+			errs = append(errs, fmt.Errorf("gomail: the embedded files %q and %q have the same Content-ID <%s>", name, f.Name, id))
+		}
+		ids[id] = f.Name
+	}
+
+	if len(m.parts) > 1 {
+		for _, p := range m.parts {
+			w := new(countingWriter)
+			if err := p.copier(w); err != nil {
+				errs = append(errs, fmt.Errorf("gomail: could not render the %s body: %w", p.contentType, err))
+			} else if w.n == 0 {
+				errs = append(errs, fmt.Errorf("gomail: the %s alternative body is empty", p.contentType))
+			}
+		}
+	}
+
+	for _, list := range [][]*file{m.attachments, m.embedded} {
+		for _, f := range list {
+			if err := f.CopyFunc(ioutil.Discard); err != nil {
+				errs = append(errs, fmt.Errorf("gomail: could not read the file %q: %w", f.Name, err))
+			}
+		}
+	}
+
+	// The size is only checked when the message can be rendered.
+	if m.maxSize > 0 && len(errs) == 0 {
+		n, err := messageSize(m)
+		if err != nil {
+			errs = append(errs, err)
+		} else if n > m.maxSize {
+			errs = append(errs, &wrappedError{ErrMessageTooLarge,
+				fmt.Errorf("the message is %d bytes, more than the maximum of %d bytes", n, m.maxSize)})
+		}
+	}
+	return errs
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// checkAddresses records the invalid addresses of field in strict mode.
+func (m *Message) checkAddresses(field string, values []string) {
+	if !m.strict || !addressFields[field] {
+		return
+	}
+	if m.addrErrors == nil {
+		m.addrErrors = make(map[string][]error)
+	}
+	delete(m.addrErrors, field)
+	for _, v := range values {
+		if err := checkAddress(v); err != nil {
+			m.addrErrors[field] = append(m.addrErrors[field], &AddressError{Field: field, Address: v, Err: err})
+		}
+	}
+}
+
+func checkAddress(value string) error {
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		if list, lerr := mail.ParseAddressList(value); lerr == nil && len(list) > 1 {
+			return errors.New("it contains several addresses, give each address as a separate value")
+		}
+		return err
+	}
+
+	local, domain := splitAddress(addr.Address)
+	if len(local) > 64 {
+		return errors.New("the local part is longer than 64 octets")
+	}
+	return checkDomain(domain)
+}
+
+// checkDomain checks that domain is a valid host name as defined in RFC 1123
+// or an address literal.
+func checkDomain(domain string) error {
+	if strings.HasPrefix(domain, "[") && strings.HasSuffix(domain, "]") {
+		return nil
+	}
+	if domain == "" {
+		return errors.New("the domain is empty")
+	}
+	if len(domain) > 253 {
+		return errors.New("the domain is longer than 253 octets")
+	}
+	for _, label := range strings.Split(domain, ".") {
+		switch {
+		case label == "":
+			return errors.New("the domain has an empty label")
+		case len(label) > 63:
+			return errors.New("a domain label is longer than 63 octets")
+		case label[0] == '-' || label[len(label)-1] == '-':
+			return errors.New("a domain label starts or ends with a hyphen")
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			// Non-ASCII octets are part of internationalized domain names.
+			if c < utf8.RuneSelf && !isLetterDigitHyphen(c) {
+				return errors.New("the domain contains the invalid character " + strconv.QuoteRune(rune(c)))
+			}
+		}
+	}
+	return nil
+}
+
+func isLetterDigitHyphen(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-'
+}
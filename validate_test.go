@@ -0,0 +1,150 @@
+package gomail
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStrictAddresses(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"bob@example.com", ""},
+		{"Bob <bob@example.com>", ""},
+		{"bob@[192.0.2.1]", ""},
+		{"josé@bücher.example", ""},
+		{"bob", "missing '@'"},
+		{"bob@example.com, alice@example.com", "several addresses"},
+		{strings.Repeat("a", 65) + "@example.com", "local part is longer than 64 octets"},
+		{"bob@example..com", "invalid"},
+		{"bob@-example.com", "starts or ends with a hyphen"},
+		{"bob@example_1.com", "invalid character '_'"},
+		{"bob@" + strings.Repeat("a", 64) + ".com", "label is longer than 63 octets"},
+	}
+
+	for _, test := range tests {
+		m := NewMessage(StrictAddresses())
+		m.SetHeader("From", testFrom)
+		m.SetHeader("To", test.addr)
+
+		err := m.Validate()
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", test.addr, err)
+			}
+			continue
+		}
+
+		verr, ok := err.(*ValidationError)
+		if !ok {
+			t.Fatalf("%q: Validate should return a *ValidationError, got %v", test.addr, err)
+		}
+		var aerr *AddressError
+		if len(verr.Errors) != 1 || !errors.As(verr.Errors[0], &aerr) {
+			t.Fatalf("%q: invalid errors, got %v", test.addr, verr.Errors)
+		}
+		if aerr.Field != "To" || aerr.Address != test.addr || !strings.Contains(aerr.Error(), test.want) {
+			t.Errorf("%q: invalid error, got %v, want it to contain %q", test.addr, aerr, test.want)
+		}
+
+		// The email is not sent.
+		if err := Send(SendFunc(func(string, []string, io.WriterTo) error {
+			t.Errorf("%q: the email should not be sent", test.addr)
+			return nil
+		}), m); err == nil {
+			t.Errorf("%q: Send should fail", test.addr)
+		}
+
+		// Setting the field again replaces the errors.
+		m.SetAddressHeader("To", testTo1, "To")
+		if err := m.Validate(); err != nil {
+			t.Errorf("%q: unexpected error after fixing the address, got %v", test.addr, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	m := NewMessage()
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("Validate should fail on an empty message")
+	}
+	if got, want := err.Error(), `gomail: invalid message: the "From" field is absent; no recipient`; got != want {
+		t.Errorf("Invalid error, got %q, want %q", got, want)
+	}
+
+	// Without StrictAddresses, the addresses are only parsed.
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1, "bob@-example.com")
+	m.SetHeader("Cc", "not an address")
+	err = m.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 1 {
+		t.Fatalf("Invalid error, got %v", err)
+	}
+	if !strings.HasPrefix(verr.Errors[0].Error(), `gomail: invalid address "not an address" in the "Cc" field: `) {
+		t.Errorf("Invalid error, got %v", verr.Errors[0])
+	}
+
+	m.Reset()
+	m.SetHeader("From", testFrom)
+	m.SetHeader("Bcc", testTo1)
+	if err := m.Validate(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestValidateContent(t *testing.T) {
+	copyString := func(s string) FileSetting {
+		return SetCopyFunc(func(w io.Writer) error {
+			_, err := io.WriteString(w, s)
+			return err
+		})
+	}
+
+	m := NewMessage(MaxSize(1 << 20))
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", "Hello!")
+	m.AddAlternative("text/html", "")
+	m.Embed("logo.png", copyString("PNG"))
+	m.Embed("images/logo.png", copyString("PNG"))
+	m.Attach("/does/not/exist.pdf")
+
+	err := m.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Validate should return a *ValidationError, got %v", err)
+	}
+	want := []string{
+		`gomail: the embedded files "logo.png" and "logo.png" have the same Content-ID <logo.png>`,
+		`gomail: the text/html alternative body is empty`,
+		`gomail: could not read the file "exist.pdf": open /does/not/exist.pdf: `,
+	}
+	if len(verr.Errors) != len(want) {
+		t.Fatalf("Invalid number of errors, got %v", verr.Errors)
+	}
+	for i, w := range want {
+		if got := verr.Errors[i].Error(); !strings.HasPrefix(got, w) {
+			t.Errorf("Invalid error, got %q, want %q", got, w)
+		}
+	}
+	if errors.Is(err, ErrMessageTooLarge) {
+		t.Error("The size should only be checked when the message can be rendered")
+	}
+
+	m = NewMessage(MaxSize(1000))
+	m.SetHeader("From", testFrom)
+	m.SetHeader("To", testTo1)
+	m.SetBody("text/plain", "Hello!")
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	m.Attach("big.bin", copyString(strings.Repeat("a", 1000)))
+	if err := m.Validate(); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Invalid error, got %v, want %v", err, ErrMessageTooLarge)
+	}
+}
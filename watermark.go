@@ -0,0 +1,138 @@
+package gomail
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// A Watermarker embeds in each copy of an email an invisible token identifying
+// its recipient, so a leaked copy can be traced back to the recipient it was
+// sent to.
+//
+// The token is written in the text bodies as zero-width characters, which
+// survive copy and paste, and in the HTML bodies also as a comment. It is
+// derived from the recipient address with Key, so no list of tokens needs to
+// be kept. The zero-width characters require the UTF-8 charset, the default.
+type Watermarker struct {
+	// Key is the secret key used to derive the tokens.
+	Key []byte
+}
+
+const (
+	watermarkMark = '\u2060' // WORD JOINER
+	watermarkZero = '\u200b' // ZERO WIDTH SPACE
+	watermarkOne  = '\u200c' // ZERO WIDTH NON-JOINER
+)
+
+// Token returns the token identifying the given recipient address.
+func (w *Watermarker) Token(address string) string {
+	mac := hmac.New(sha256.New, w.Key)
+	mac.Write([]byte(strings.ToLower(address)))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// Watermark returns a copy of m whose text/plain and text/html bodies contain
+// the token of the given recipient address.
+func (w *Watermarker) Watermark(m *Message, address string) (*Message, error) {
+	token := w.Token(address)
+	hidden := hiddenToken(token)
+
+	c := m.Clone()
+	for _, p := range c.parts {
+		var html bool
+		switch {
+		case strings.HasPrefix(p.contentType, "text/html"):
+			html = true
+		case strings.HasPrefix(p.contentType, "text/plain"):
+		default:
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := p.copier(&buf); err != nil {
+			return nil, err
+		}
+		body := buf.String()
+		if html {
+			body = insertHTML(body, "<!-- "+token+" -->"+hidden)
+		} else if i := strings.IndexByte(body, ' '); i >= 0 {
+			body = body[:i] + hidden + body[i:]
+		} else {
+			body += hidden
+		}
+		p.copier = newCopier(body)
+	}
+	return c, nil
+}
+
+// hiddenToken encodes a hexadecimal token in zero-width characters.
+func hiddenToken(token string) string {
+	b, _ := hex.DecodeString(token)
+	var sb strings.Builder
+	sb.WriteRune(watermarkMark)
+	for _, c := range b {
+		for i := 7; i >= 0; i-- {
+			if c&(1<<uint(i)) != 0 {
+				sb.WriteRune(watermarkOne)
+			} else {
+				sb.WriteRune(watermarkZero)
+			}
+		}
+	}
+	sb.WriteRune(watermarkMark)
+	return sb.String()
+}
+
+var bodyTag = regexp.MustCompile(`(?i)<body\b[^>]*>`)
+
+// insertHTML inserts s at the start of the body of an HTML document.
+func insertHTML(doc, s string) string {
+	if loc := bodyTag.FindStringIndex(doc); loc != nil {
+		return doc[:loc[1]] + s + doc[loc[1]:]
+	}
+	return s + doc
+}
+
+var hiddenTokenRegexp = regexp.MustCompile("\u2060([\u200b\u200c]{64})\u2060")
+
+// Trace returns the recipient among candidates to which the email containing
+// text was sent, if text contains a watermark token.
+func (w *Watermarker) Trace(text string, candidates []string) (string, bool) {
+	tokens := watermarkTokens(text)
+	if len(tokens) == 0 {
+		return "", false
+	}
+	for _, addr := range candidates {
+		t := w.Token(addr)
+		for _, token := range tokens {
+			if hmac.Equal([]byte(t), []byte(token)) {
+				return addr, true
+			}
+		}
+	}
+	return "", false
+}
+
+var commentToken = regexp.MustCompile(`<!-- ([0-9a-f]{16}) -->`)
+
+// watermarkTokens returns the tokens found in text.
+func watermarkTokens(text string) []string {
+	var tokens []string
+	for _, m := range hiddenTokenRegexp.FindAllStringSubmatch(text, -1) {
+		b := make([]byte, 8)
+		for i, r := range []rune(m[1]) {
+			if r == watermarkOne {
+				b[i/8] |= 1 << uint(7-i%8)
+			}
+		}
+		tokens = append(tokens, hex.EncodeToString(b))
+	}
+	for _, m := range commentToken.FindAllStringSubmatch(text, -1) {
+		tokens = append(tokens, m[1])
+	}
+	return tokens
+}
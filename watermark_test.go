@@ -0,0 +1,71 @@
+package gomail
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWatermarker(t *testing.T) {
+	w := &Watermarker{Key: []byte("secret")}
+	if w.Token(testTo1) == w.Token(testTo2) || w.Token(testTo1) != w.Token(strings.ToUpper(testTo1)) {
+		t.Fatal("Each recipient should have its own token")
+	}
+
+	m := NewMessage()
+	m.SetBody("text/plain", "Quarterly results attached.")
+	m.AddAlternative("text/html", `<html><body class="x"><p>Quarterly results</p></body></html>`)
+	c, err := w.Watermark(m, testTo1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := c.Parts()
+	var text, html strings.Builder
+	parts[0].WriteTo(&text)
+	parts[1].WriteTo(&html)
+	if got := strings.Replace(text.String(), hiddenToken(w.Token(testTo1)), "", 1); got != "Quarterly results attached." {
+		t.Errorf("Invalid text body, got %q", text.String())
+	}
+	if !strings.HasPrefix(html.String(), `<html><body class="x"><!-- `+w.Token(testTo1)+` -->`) {
+		t.Errorf("Invalid HTML body, got %q", html.String())
+	}
+
+	var orig strings.Builder
+	m.Parts()[0].WriteTo(&orig)
+	if orig.String() != "Quarterly results attached." {
+		t.Error("Watermark should not modify the message")
+	}
+
+	// A pasted excerpt is enough to trace the recipient.
+	excerpt := text.String()[:len(text.String())-10]
+	candidates := []string{testTo2, testTo1}
+	if got, ok := w.Trace(excerpt, candidates); !ok || got != testTo1 {
+		t.Errorf("Trace = %q, %v, want %q", got, ok, testTo1)
+	}
+	if got, ok := w.Trace(html.String(), candidates); !ok || got != testTo1 {
+		t.Errorf("Trace = %q, %v, want %q", got, ok, testTo1)
+	}
+	if _, ok := w.Trace("Quarterly results attached.", candidates); ok {
+		t.Error("Trace should fail without a watermark")
+	}
+}
+
+func TestBulkSenderWatermark(t *testing.T) {
+	r := &bulkRecorder{sent: make(map[string]string)}
+	w := &Watermarker{Key: []byte("secret")}
+	s := &BulkSender{Dialer: r, Watermarker: w}
+
+	m := NewMessage()
+	m.SetHeader("From", testFrom)
+	m.SetBody("text/plain", "Hello {{.Name}}", SetPartEncoding(Unencoded))
+	results, err := s.Send(context.Background(), m, []BulkRecipient{{Address: testTo1, Name: "Ann"}, {Address: testTo2, Name: "Bo"}})
+	if err != nil || results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("Invalid results, got %v, %v", results, err)
+	}
+	for _, addr := range []string{testTo1, testTo2} {
+		if got, ok := w.Trace(r.sent[addr], []string{testTo1, testTo2}); !ok || got != addr {
+			t.Errorf("Trace = %q, %v, want %q", got, ok, addr)
+		}
+	}
+}
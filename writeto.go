@@ -3,42 +3,42 @@ package gomail
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
-	"mime"
 	"mime/multipart"
-	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // WriteTo implements io.WriterTo. It dumps the whole message into w.
 func (m *Message) WriteTo(w io.Writer) (int64, error) {
-	mw := &messageWriter{w: w}
+	mw := m.newWriter(w)
 	mw.writeMessage(m)
 	return mw.n, mw.err
 }
 
 func (w *messageWriter) writeMessage(m *Message) {
-	if _, ok := m.header["Mime-Version"]; !ok {
-		w.writeString("Mime-Version: 1.0\r\n")
-	}
-	if _, ok := m.header["Date"]; !ok {
-		w.writeHeader("Date", m.FormatDate(now()))
+	w.writeMessageHeader(m)
+	if m.root != nil {
+		w.writeTree(m.root, m)
+		return
 	}
-	w.writeHeaders(m.header)
 
-	if m.hasMixedPart() {
-		w.openMultipart("mixed")
+	if m.reportType != "" {
+		w.openMultipart("report; report-type="+m.reportType, nil)
+	} else if m.hasMixedPart() {
+		w.openMultipart("mixed", nil)
 	}
 
 	if m.hasRelatedPart() {
-		w.openMultipart("related")
+		w.openMultipart("related", nil)
 	}
 
 	if m.hasAlternativePart() {
-		w.openMultipart("alternative")
+		w.openMultipart("alternative", nil)
 	}
-	for _, part := range m.parts {
+	for _, part := range m.signedParts() {
 		w.writePart(part, m.charset)
 	}
 	if m.hasAlternativePart() {
@@ -51,11 +51,37 @@ func (w *messageWriter) writeMessage(m *Message) {
 	}
 
 	w.addFiles(m.attachments, true)
-	if m.hasMixedPart() {
+	if m.reportType != "" || m.hasMixedPart() {
 		w.closeMultipart()
 	}
 }
 
+func (w *messageWriter) writeMessageHeader(m *Message) {
+	if _, ok := m.header["Mime-Version"]; !ok && !m.noMimeVersion {
+		w.writeString("Mime-Version: 1.0\r\n")
+	}
+	if _, ok := m.header["Date"]; !ok && !m.noDate {
+		w.writeHeader("Date", m.FormatDate(now()))
+	}
+	if !m.noMessageID && !m.hasMessageID() {
+		var id string
+		var err error
+		if w.idGenerator != nil {
+			if id, err = w.idGenerator.NewID(); err == nil {
+				id = "<" + id + "@" + m.messageIDDomainOrDefault() + ">"
+			}
+		} else {
+			id, err = newMessageID(m.messageIDDomainOrDefault())
+		}
+		if err != nil {
+			w.err = err
+			return
+		}
+		w.writeHeader("Message-ID", id)
+	}
+	w.writeHeaders(m.header)
+}
+
 func (m *Message) hasMixedPart() bool {
 	return (len(m.parts) > 0 && len(m.attachments) > 0) || len(m.attachments) > 1
 }
@@ -71,24 +97,63 @@ func (m *Message) hasAlternativePart() bool {
 type messageWriter struct {
 	w          io.Writer
 	n          int64
-	writers    [3]*multipart.Writer
+	writers    []*multipart.Writer
 	partWriter io.Writer
 	depth      uint8
 	err        error
+	// transport is the encoding allowed by the transport for the bodies, see
+	// Message.writeUnencoded.
+	transport Encoding
+	// idGenerator generates the boundaries, see SetIDGenerator.
+	idGenerator IDGenerator
+	// sortHeaders defines whether the header fields are written in the
+	// order of headerOrder, see SetHeaderOrder.
+	sortHeaders bool
+	headerOrder []string
+	// fieldNames are the names of the header fields of the message, see
+	// Message.setFieldName.
+	fieldNames map[string]string
+	// rawFields are the fields of the message written as is, see
+	// Message.SetRawHeader.
+	rawFields map[string]bool
 }
 
-func (w *messageWriter) openMultipart(mimeType string) {
+// openMultipart opens a multipart of the given subtype, which can be followed
+// by parameters. header are the other header fields of the multipart, if any.
+func (w *messageWriter) openMultipart(mimeType string, header map[string][]string) {
 	mw := multipart.NewWriter(w)
+	id, err := newID(w.idGenerator)
+	if err == nil {
+		err = mw.SetBoundary(id)
+	}
+	if err != nil && w.err == nil {
+		w.err = fmt.Errorf("gomail: could not generate a boundary: %w", err)
+	}
 	contentType := "multipart/" + mimeType + ";\r\n boundary=" + mw.Boundary()
-	w.writers[w.depth] = mw
+	w.writers = append(w.writers[:w.depth], mw)
 
 	if w.depth == 0 {
+		if len(header) > 0 {
+			h := make(map[string][]string, len(header))
+			for k, v := range header {
+				if fieldKey(k) != "Content-Type" {
+					h[k] = v
+				}
+			}
+			w.writeHeaders(h)
+		}
 		w.writeHeader("Content-Type", contentType)
 		w.writeString("\r\n")
 	} else {
-		w.createPart(map[string][]string{
+		h := map[string][]string{
 			"Content-Type": {contentType},
-		})
+		}
+		for k, v := range sanitizePartHeader(header) {
+			if _, ok := h[fieldKey(k)]; !ok {
+				h[k] = v
+			}
+		}
+		w.createPart(h)
 	}
 	w.depth++
 }
@@ -97,6 +162,30 @@ func (w *messageWriter) createPart(h map[string][]string) {
 	w.partWriter, w.err = w.writers[w.depth-1].CreatePart(h)
 }
 
+// sanitizePartHeader returns h without the CR, LF and NUL characters in its
+// field names and values, which writeHeader and mime/multipart write as is.
+func sanitizePartHeader(h map[string][]string) map[string][]string {
+	clean := true
+	for k, v := range h {
+		clean = clean && sanitizeFieldName(k) == k
+		for _, s := range v {
+			clean = clean && !hasControlBreak(s)
+		}
+	}
+	if clean {
+		return h
+	}
+	sanitized := make(map[string][]string, len(h))
+	for k, v := range h {
+		values := make([]string, len(v))
+		for i, s := range v {
+			values[i] = stripControlBreaks(s)
+		}
+		sanitized[sanitizeFieldName(k)] = values
+	}
+	return sanitized
+}
+
 func (w *messageWriter) closeMultipart() {
 	if w.depth > 0 {
 		w.writers[w.depth-1].Close()
@@ -105,21 +194,24 @@ func (w *messageWriter) closeMultipart() {
 }
 
 func (w *messageWriter) writePart(p *part, charset string) {
-	w.writeHeaders(map[string][]string{
+	enc, copier := w.partEncoding(p)
+	h := map[string][]string{
 		"Content-Type":              {p.contentType + "; charset=" + charset},
-		"Content-Transfer-Encoding": {string(p.encoding)},
-	})
-	w.writeBody(p.copier, p.encoding)
+		"Content-Transfer-Encoding": {string(enc)},
+	}
+	for k, v := range p.header {
+		if _, ok := h[fieldKey(k)]; !ok {
+			h[k] = v
+		}
+	}
+	w.writeHeaders(h)
+	w.writeBody(copier, enc)
 }
 
 func (w *messageWriter) addFiles(files []*file, isAttachment bool) {
 	for _, f := range files {
 		if _, ok := f.Header["Content-Type"]; !ok {
-			mediaType := mime.TypeByExtension(filepath.Ext(f.Name))
-			if mediaType == "" {
-				mediaType = "application/octet-stream"
-			}
-			f.setHeader("Content-Type", mediaType+`; name="`+f.Name+`"`)
+			f.setHeader("Content-Type", f.mediaType()+`; name="`+quoteParam.Replace(f.Name)+`"`)
 		}
 
 		if _, ok := f.Header["Content-Transfer-Encoding"]; !ok {
@@ -133,21 +225,36 @@ func (w *messageWriter) addFiles(files []*file, isAttachment bool) {
 			} else {
 				disp = "inline"
 			}
-			f.setHeader("Content-Disposition", disp+`; filename="`+f.Name+`"`)
+			f.setHeader("Content-Disposition", disp+`; filename="`+quoteParam.Replace(f.Name)+`"`)
 		}
 
 		if !isAttachment {
 			if _, ok := f.Header["Content-ID"]; !ok {
-				f.setHeader("Content-ID", "<"+f.Name+">")
+				f.setHeader("Content-ID", "<"+stripControlBreaks(f.Name)+">")
 			} else {
-				for i := 0; i < len(f.Header["Content-ID"]); i++ {
-					if f.Header["Content-ID"][i][:1] == "<" && f.Header["Content-ID"][i][len(v)-1:] == ">" {
+				for i, v := range f.Header["Content-ID"] {
+					if strings.HasPrefix(v, "<") && strings.HasSuffix(v, ">") {
 						continue
 					}
-					f.Header["Content-ID"][i] = "<" + f.Header["Content-ID"][i] + ">"
+					f.Header["Content-ID"][i] = "<" + v + ">"
 				}
 			}
 		}
+		if isIdentityEncoding(f.Header["Content-Transfer-Encoding"][0]) {
+			w.writeHeaders(f.Header)
+			w.writeBody(f.CopyFunc, Unencoded)
+			continue
+		}
+		if w.transport == binaryEncoding && f.Header["Content-Transfer-Encoding"][0] == string(Base64) {
+			h := make(map[string][]string, len(f.Header))
+			for k, v := range f.Header {
+				h[k] = v
+			}
+			h["Content-Transfer-Encoding"] = []string{string(binaryEncoding)}
+			w.writeHeaders(h)
+			w.writeBody(f.CopyFunc, binaryEncoding)
+			continue
+		}
 		w.writeHeaders(f.Header)
 		w.writeBody(f.CopyFunc, Base64)
 	}
@@ -227,8 +334,10 @@ func (w *messageWriter) writeLine(s string, charsLeft int) string {
 	}
 
 	// We could not insert a newline cleanly so look for a space or a newline
-	// even if it is after the limit.
-	for i := 75; i < len(s); i++ {
+	// even if it is after the limit, as long as the line stays under the hard
+	// limit of RFC 5322.
+	hardLimit := charsLeft + maxHeaderLineLen - 76
+	for i := charsLeft; i < len(s) && i < hardLimit; i++ {
 		if s[i] == ' ' {
 			w.writeString(s[:i])
 			w.writeString("\r\n ")
@@ -240,20 +349,89 @@ func (w *messageWriter) writeLine(s string, charsLeft int) string {
 		}
 	}
 
-	// Too bad, no space or newline in the whole string. Just write everything.
-	w.writeString(s)
-	return ""
+	if len(s) <= hardLimit {
+		w.writeString(s)
+		return ""
+	}
+
+	// Too bad, no space or newline before the hard limit. Fold the line
+	// anyway since some servers reject longer lines.
+	i := hardBreak(s, hardLimit)
+	w.writeString(s[:i])
+	w.writeString("\r\n ")
+	return s[i:]
+}
+
+// maxHeaderLineLen is the maximum length of a header line, excluding CRLF, as
+// defined in RFC 5322, section 2.1.1.
+const maxHeaderLineLen = 998
+
+// hardBreak returns the index, at most n, where s is broken when it has no
+// whitespace to fold at. It is the last boundary of an encoded-word so the
+// encoded-words are kept intact or, if there is none, the last character
+// boundary.
+func hardBreak(s string, n int) int {
+	best := 0
+	for i := 0; i < n; {
+		j := strings.Index(s[i:n], "=?")
+		if j == -1 {
+			break
+		}
+		start := i + j
+		end := encodedWordEnd(s, start)
+		if end == -1 || end > n {
+			if start > 0 {
+				best = start
+			}
+			break
+		}
+		best, i = end, end
+	}
+	if best > 0 {
+		return best
+	}
+
+	for n > 1 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// encodedWordEnd returns the index following the encoded-word starting at
+// start in s, or -1 if there is no valid encoded-word.
+func encodedWordEnd(s string, start int) int {
+	// Skip "=?charset?encoding?" before looking for the final "?=" since the
+	// encoded text can contain "=".
+	i := start + 2
+	for n := 0; n < 2; n++ {
+		j := strings.IndexByte(s[i:], '?')
+		if j == -1 {
+			return -1
+		}
+		i += j + 1
+	}
+	j := strings.Index(s[i:], "?=")
+	if j == -1 {
+		return -1
+	}
+	return i + j + 2
 }
 
 func (w *messageWriter) writeHeaders(h map[string][]string) {
 	if w.depth == 0 {
-		for k, v := range h {
-			if k != "Bcc" {
-				w.writeHeader(k, v...)
+		// The raw fields are written as set, with their folding.
+		clean := sanitizePartHeader(h)
+		for _, k := range w.headerKeys(clean) {
+			switch {
+			case k == "Bcc" || k == "":
+			case w.rawFields[k]:
+				w.writeString(typedFieldName(w.fieldNames, k) + ": " + h[k][0] + "\r\n")
+			default:
+				w.writeHeader(typedFieldName(w.fieldNames, k), clean[k]...)
 			}
 		}
 	} else {
-		w.createPart(h)
+		w.createPart(sanitizePartHeader(h))
 	}
 }
 
@@ -267,15 +445,19 @@ func (w *messageWriter) writeBody(f func(io.Writer) error, enc Encoding) {
 	}
 
 	if enc == Base64 {
-		wc := base64.NewEncoder(base64.StdEncoding, newBase64LineWriter(subWriter))
+		wc := newBase64LineWriter(subWriter)
 		w.err = f(wc)
-		wc.Close()
-	} else if enc == Unencoded {
+		if err := wc.Close(); w.err == nil {
+			w.err = err
+		}
+	} else if enc == Unencoded || enc == binaryEncoding {
 		w.err = f(subWriter)
 	} else {
 		wc := newQPWriter(subWriter)
 		w.err = f(wc)
-		wc.Close()
+		if err := wc.Close(); w.err == nil {
+			w.err = err
+		}
 	}
 }
 
@@ -283,30 +465,97 @@ func (w *messageWriter) writeBody(f func(io.Writer) error, enc Encoding) {
 // RFC 2045, 6.8. (page 25) for base64.
 const maxLineLen = 76
 
-// base64LineWriter limits text encoded in base64 to 76 characters per line
+const (
+	// base64LineIn is the number of raw bytes that fit in a 76 characters
+	// base64 line.
+	base64LineIn = maxLineLen / 4 * 3
+	// base64BufLines is the number of encoded lines buffered before they are
+	// written to the underlying writer.
+	base64BufLines = 64
+)
+
+// base64LineWriter encodes the data written to it in base64 and limits the
+// encoded text to 76 characters per line. Encoded lines are written to the
+// underlying writer in chunks so memory usage does not depend on the size of
+// the data.
 type base64LineWriter struct {
-	w       io.Writer
-	lineLen int
+	w     io.Writer
+	in    [base64LineIn]byte
+	nin   int
+	out   []byte
+	lines int
+	err   error
 }
 
 func newBase64LineWriter(w io.Writer) *base64LineWriter {
-	return &base64LineWriter{w: w}
+	return &base64LineWriter{
+		w:   w,
+		out: make([]byte, 0, base64BufLines*(maxLineLen+2)),
+	}
 }
 
 func (w *base64LineWriter) Write(p []byte) (int, error) {
-	n := 0
-	for len(p)+w.lineLen > maxLineLen {
-		w.w.Write(p[:maxLineLen-w.lineLen])
-		w.w.Write([]byte("\r\n"))
-		p = p[maxLineLen-w.lineLen:]
-		n += maxLineLen - w.lineLen
-		w.lineLen = 0
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := len(p)
+	if w.nin > 0 {
+		c := copy(w.in[w.nin:], p)
+		w.nin += c
+		p = p[c:]
+		if w.nin < base64LineIn {
+			return n, nil
+		}
+		w.encodeLine(w.in[:])
+		w.nin = 0
+	}
+
+	for len(p) >= base64LineIn {
+		w.encodeLine(p[:base64LineIn])
+		p = p[base64LineIn:]
+	}
+	w.nin = copy(w.in[:], p)
+
+	if w.err != nil {
+		return 0, w.err
+	}
+	return n, nil
+}
+
+// Close encodes the remaining data and flushes the buffered lines. It does not
+// close the underlying writer.
+func (w *base64LineWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.nin > 0 {
+		w.encodeLine(w.in[:w.nin])
+		w.nin = 0
+	}
+	w.flush()
+	return w.err
+}
+
+func (w *base64LineWriter) encodeLine(p []byte) {
+	if w.lines > 0 {
+		w.out = append(w.out, '\r', '\n')
 	}
+	start := len(w.out)
+	w.out = w.out[:start+base64.StdEncoding.EncodedLen(len(p))]
+	base64.StdEncoding.Encode(w.out[start:], p)
+	w.lines++
 
-	w.w.Write(p)
-	w.lineLen += len(p)
+	if len(w.out)+maxLineLen+2 > cap(w.out) {
+		w.flush()
+	}
+}
 
-	return n + len(p), nil
+func (w *base64LineWriter) flush() {
+	if w.err == nil && len(w.out) > 0 {
+		_, w.err = w.w.Write(w.out)
+	}
+	w.out = w.out[:0]
 }
 
 // Stubbed out for testing.